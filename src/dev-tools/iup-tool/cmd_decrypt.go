@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
+	"github.com/spf13/cobra"
+)
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt a payload posted to update platform",
+	Long: "Decrypt the base64 request body of post_process_event or post_result, " +
+		"using --encrypt-scheme and --key-version taken from the " + envelope.HeaderScheme + " and " +
+		envelope.HeaderKeyVersion + " request headers",
+	Run: runDecrypt,
+}
+
+var decryptInput string
+
+func init() {
+	decryptCmd.Flags().StringVarP(&decryptInput, "input", "i", "-", "File containing the base64 request body, - for stdin")
+	rootCmd.AddCommand(decryptCmd)
+}
+
+func runDecrypt(cmd *cobra.Command, args []string) {
+	var content []byte
+	var err error
+	if decryptInput == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(decryptInput)
+	}
+	if err != nil {
+		logger.Warningf("failed to read input: %v", err)
+		os.Exit(1)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		logger.Warningf("failed to decode base64 body: %v", err)
+		os.Exit(1)
+	}
+	c, err := newPayloadCipher(globalEncryptScheme, globalKeyVersion)
+	if err != nil {
+		logger.Warningf("failed to create cipher: %v", err)
+		os.Exit(1)
+	}
+	plain, err := c.Open(data)
+	if err != nil {
+		logger.Warningf("failed to decrypt payload: %v", err)
+		os.Exit(1)
+	}
+	_, _ = os.Stdout.Write(append(plain, '\n'))
+}
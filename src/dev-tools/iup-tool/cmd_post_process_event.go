@@ -116,15 +116,8 @@ func genPostProcessEventResponse(requestUrl, token string, event *ProcessEvent)
 
 	logger.Debugf("upgrade post process event msg is %v", string(eventData))
 
-	// Encrypt message (consistent with message_report.go)
-	encryptMsg, err := EncryptMsg(eventData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt message: %v", err)
-	}
-
-	// Create request with base64-encoded encrypted data
-	body := strings.NewReader(base64.StdEncoding.EncodeToString(encryptMsg))
-	request, err := http.NewRequest(Urls[PostProcessEvent].method, policyUrl, body)
+	// Create request with base64-encoded encrypted data (consistent with message_report.go)
+	request, err := newPayloadRequest(Urls[PostProcessEvent].method, policyUrl, eventData)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", PostProcessEvent.string(), err.Error())
 	}
@@ -1,12 +1,10 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -106,16 +104,8 @@ func genPostResultResponse(requestURL, token string, result *UpgradePostMsg) (*h
 
 	logger.Debugf("upgrade post content is %v", string(resultData))
 
-	// Encrypt message using AES-CBC encryption
-	encryptMsg, err := EncryptMsg(resultData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt message: %v", err)
-	}
-
-	// Encode encrypted message using base64 standard encoding
-	base64EncodeString := base64.StdEncoding.EncodeToString(encryptMsg)
-
-	request, err := http.NewRequest(Urls[PostResult].method, policyURL, strings.NewReader(base64EncodeString))
+	// Encrypt message and encode it using base64 standard encoding
+	request, err := newPayloadRequest(Urls[PostResult].method, policyURL, resultData)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", PostResult.string(), err.Error())
 	}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformfixture"
+	"github.com/spf13/cobra"
+)
+
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record a client session with update platform into a fixture directory",
+	Long: "Listen as a proxy of the update platform, forward every request to the platform and save the request headers, " +
+		"decrypted body and response into the fixture directory. Point lastore-daemon or iup-tool --platform-url at the listen address",
+	Run: runRecord,
+}
+
+var (
+	recordListen   string
+	recordUpstream string
+	recordDir      string
+)
+
+func init() {
+	recordCmd.Flags().StringVarP(&recordListen, "listen", "l", defaultFixtureListen, "Listen address")
+	recordCmd.Flags().StringVarP(&recordUpstream, "upstream", "u", "", "Update platform URL, platform-url in dSettings by default")
+	recordCmd.Flags().StringVarP(&recordDir, "dir", "d", "", "Fixture directory (required)")
+	_ = recordCmd.MarkFlagRequired("dir")
+	rootCmd.AddCommand(recordCmd)
+}
+
+func runRecord(cmd *cobra.Command, args []string) {
+	upstream := recordUpstream
+	if upstream == "" {
+		upstream = updatePlatform.requestURL
+	}
+	if upstream == "" {
+		logger.Warning("update platform URL is empty, use --upstream")
+		os.Exit(1)
+	}
+	recorder, err := platformfixture.NewRecorder(platformfixture.RecordOptions{
+		Upstream: upstream,
+		Dir:      recordDir,
+		Client:   newHTTPClient(),
+		Decrypt:  decryptPayload,
+		OnExchange: func(e *platformfixture.Exchange) {
+			if e.Error != "" {
+				logger.Warningf("%s %s %s: %s", e.FileName(), e.Request.Method, e.Request.Path, e.Error)
+				return
+			}
+			logger.Infof("%s %s %s: %d", e.FileName(), e.Request.Method, e.Request.Path, e.Response.StatusCode)
+		},
+	})
+	if err != nil {
+		logger.Warningf("failed to open fixture directory: %v", err)
+		os.Exit(1)
+	}
+	logger.Infof("recording %s to %s, listen on %s", upstream, recordDir, recordListen)
+	if err := http.ListenAndServe(recordListen, recorder); err != nil {
+		logger.Warning(err)
+		os.Exit(1)
+	}
+}
+
+// decryptPayload 按请求头解密上报的数据,请求体不是加密数据时返回 nil
+func decryptPayload(header http.Header, body []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, nil
+	}
+	scheme := header.Get(envelope.HeaderScheme)
+	c, err := newPayloadCipher(scheme, header.Get(envelope.HeaderKeyVersion))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := c.Open(data)
+	if err != nil && scheme == "" {
+		// 兼容模式没有请求头,无法区分是否加密
+		return nil, nil
+	}
+	return plain, err
+}
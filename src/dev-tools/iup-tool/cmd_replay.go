@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformfixture"
+	"github.com/spf13/cobra"
+)
+
+const defaultFixtureListen = "127.0.0.1:18080"
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Serve a recorded fixture directory as a local update platform",
+	Long: "Serve the responses recorded by iup-tool record, requests are matched by method and path, " +
+		"repeated requests get the recorded responses in order",
+	Run: runReplay,
+}
+
+var (
+	replayListen string
+	replayDir    string
+)
+
+func init() {
+	replayCmd.Flags().StringVarP(&replayListen, "listen", "l", defaultFixtureListen, "Listen address")
+	replayCmd.Flags().StringVarP(&replayDir, "dir", "d", "", "Fixture directory (required)")
+	_ = replayCmd.MarkFlagRequired("dir")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	exchanges, err := platformfixture.Load(replayDir)
+	if err != nil {
+		logger.Warningf("failed to load fixtures: %v", err)
+		os.Exit(1)
+	}
+	if len(exchanges) == 0 {
+		logger.Warningf("no fixture found in %s", replayDir)
+		os.Exit(1)
+	}
+	replayer := platformfixture.NewReplayer(exchanges)
+	replayer.OnRequest = func(req *http.Request, body []byte, e *platformfixture.Exchange) {
+		if e == nil {
+			logger.Warningf("%s %s: no recorded response", req.Method, req.URL.Path)
+			return
+		}
+		logger.Infof("%s %s: %s", req.Method, req.URL.Path, e.FileName())
+		if len(body) != 0 {
+			if plain, err := decryptPayload(req.Header, body); err != nil {
+				logger.Warningf("failed to decrypt payload: %v", err)
+			} else if plain != nil {
+				logger.Debugf("payload: %s", plain)
+			}
+		}
+	}
+	logger.Infof("replaying %d fixtures from %s, listen on %s", len(exchanges), replayDir, replayListen)
+	if err := http.ListenAndServe(replayListen, replayer); err != nil {
+		logger.Warning(err)
+		os.Exit(1)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/linuxdeepin/go-lib/log"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
 	"github.com/spf13/cobra"
 )
 
@@ -15,9 +16,12 @@ var updatePlatform UpdatePlatformManager
 // initUpdatePlatform initialize update platform manager
 func initUpdatePlatform() {
 	updatePlatform = UpdatePlatformManager{
-		requestURL: getPlatformURLFromDSettings(),
+		requestURL: globalPlatformURL,
 		Token:      getTokenFromAptConfig(),
 	}
+	if updatePlatform.requestURL == "" {
+		updatePlatform.requestURL = getPlatformURLFromDSettings()
+	}
 	// 从 token 中 提取 machine ID
 	updatePlatform.machineID = extractMachineIDFromToken(updatePlatform.Token)
 }
@@ -41,14 +45,22 @@ var rootCmd = &cobra.Command{
 }
 
 var (
-	globalTimeout int
-	globalDebug   bool
+	globalTimeout       int
+	globalDebug         bool
+	globalEncryptScheme string
+	globalKeyFile       string
+	globalKeyVersion    string
+	globalPlatformURL   string
 )
 
 func init() {
 	// 全局 flags
 	rootCmd.PersistentFlags().IntVar(&globalTimeout, "timeout", 40, "HTTP request timeout in seconds")
 	rootCmd.PersistentFlags().BoolVar(&globalDebug, "debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&globalEncryptScheme, "encrypt-scheme", envelope.SchemeLegacy, "Payload encryption scheme (aes-cbc, aes-256-gcm)")
+	rootCmd.PersistentFlags().StringVar(&globalKeyFile, "key-file", envelope.DefaultKeyFile, "Payload key file for aes-256-gcm")
+	rootCmd.PersistentFlags().StringVar(&globalPlatformURL, "platform-url", "", "Update platform URL, platform-url in dSettings by default")
+	rootCmd.PersistentFlags().StringVar(&globalKeyVersion, "key-version", "", "Payload key version, the first key in key file by default")
 }
 
 func main() {
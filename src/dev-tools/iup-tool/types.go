@@ -164,8 +164,10 @@ type UpdateLogMeta struct {
 
 // ShellCheck 检查脚本
 type ShellCheck struct {
-	Name  string `json:"name"`  // 检查脚本的名字
-	Shell string `json:"shell"` // 检查脚本的内容
+	Name      string `json:"name"`      // 检查脚本的名字
+	Shell     string `json:"shell"`     // 检查脚本的内容
+	Signature string `json:"signature"` // 阶段、脚本名和脚本内容的 ed25519 签名
+	KeyID     string `json:"keyId"`     // 签名使用的公钥
 }
 
 // PackageInfo 软件包信息
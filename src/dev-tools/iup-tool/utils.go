@@ -3,13 +3,10 @@ package main
 import (
 	"archive/tar"
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
+	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
 	"os/exec"
@@ -19,6 +16,7 @@ import (
 
 	"github.com/godbus/dbus/v5"
 	ConfigManager "github.com/linuxdeepin/go-dbus-factory/org.desktopspec.ConfigManager"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
 )
 
 // UpdatePlatformManager 更新平台管理器
@@ -227,86 +225,39 @@ func tarFiles(files []string, outFile string) error {
 	return nil
 }
 
-// Encryption constants and functions for message encryption
-const (
-	BlockSize = 32
-	randomLen = 16 // random value length
-)
-
-const encodingAesKey = secret
-
-// EncryptMsg encrypts message using AES-CBC
-func EncryptMsg(data []byte) ([]byte, error) {
-	// Get 16-byte random string and prepend to plaintext
-	replyMsgBytes, err := GetRandomBytes(randomLen)
-	if err != nil {
-		return nil, err
+// newPayloadCipher creates the cipher selected by --encrypt-scheme, keys are read from --key-file
+func newPayloadCipher(scheme, version string) (envelope.Cipher, error) {
+	var keys envelope.KeySet
+	if scheme == envelope.SchemeGCM {
+		content, err := os.ReadFile(globalKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys, err = envelope.ParseKeySet(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", globalKeyFile, err)
+		}
 	}
-	replyMsgBytes = append(replyMsgBytes, data...)
-	// Use first 16 bytes of key as AES-CBC IV
-	iv := []byte(Substr(encodingAesKey, 0, 16))
+	return envelope.New(scheme, keys, version)
+}
 
-	// Generate ciphertext based on key
-	block, err := aes.NewCipher([]byte(encodingAesKey))
+// newPayloadRequest encrypts data and creates the request (consistent with updateplatform.NewPayloadRequest)
+func newPayloadRequest(method, url string, data []byte) (*http.Request, error) {
+	c, err := newPayloadCipher(globalEncryptScheme, globalKeyVersion)
 	if err != nil {
-		logger.Warningf("[encrypt] aes-cbc encrypt data failed, error:%v", err)
 		return nil, err
 	}
-	encodeBytes := replyMsgBytes
-	encodeBytes = PKCS7Encode(encodeBytes, BlockSize)
-	blockMode := cipher.NewCBCEncrypter(block, iv)
-	crypted := make([]byte, len(encodeBytes))
-	if len(crypted)%block.BlockSize() != 0 {
-		return nil, errors.New("encrypt failed, input not full blocks")
-	}
-	blockMode.CryptBlocks(crypted, encodeBytes)
-	return crypted, nil
-}
-
-// PKCS7Encode pads plaintext for encryption
-func PKCS7Encode(text []byte, blockSize int) []byte {
-	textLen := len(text)
-	padding := blockSize - (textLen % blockSize)
-	if padding == 0 {
-		padding = blockSize
+	encryptMsg, err := c.Seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt message: %v", err)
 	}
-
-	paddingByte := bytes.Repeat([]byte(string(rune(padding))), padding)
-
-	return append(text, paddingByte...)
-}
-
-// GetRandomBytes generates random bytes of specified length
-func GetRandomBytes(length uint32) ([]byte, error) {
-	res := make([]byte, length)
-	_, err := rand.Read(res)
+	request, err := http.NewRequest(method, url, strings.NewReader(base64.StdEncoding.EncodeToString(encryptMsg)))
 	if err != nil {
-		logger.Warning(err)
 		return nil, err
 	}
-	return res, nil
-}
-
-// Substr extracts substring from string
-func Substr(str string, start int, length int) string {
-	if start < 0 {
-		start = 0
-	}
-	if length < 0 {
-		length = 0
+	request.Header.Set(envelope.HeaderScheme, c.Scheme())
+	if c.KeyVersion() != "" {
+		request.Header.Set(envelope.HeaderKeyVersion, c.KeyVersion())
 	}
-
-	strSlice := strings.Split(str, "")
-	strSliceLen := len(strSlice)
-
-	end := start + length
-
-	if end > strSliceLen {
-		end = strSliceLen
-	}
-	if start > strSliceLen {
-		start = strSliceLen
-	}
-
-	return strings.Join(strSlice[start:end], "")
+	return request, nil
 }
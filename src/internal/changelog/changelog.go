@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package changelog 从已下载的 deb 包中提取 changelog.Debian.gz,
+// 解析已安装版本和候选版本之间的条目,并提取其中的 bug 和 CVE 编号
+package changelog
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	debVersion "pault.ag/go/debian/version"
+)
+
+// Entry 表示 changelog 中一个版本的条目
+type Entry struct {
+	Version      string
+	Distribution string
+	Urgency      string
+	Changes      string
+	Maintainer   string
+	Date         string
+	Bugs         []string
+	CVEs         []string
+}
+
+// PackageChangelog 表示一个包从已安装版本到候选版本之间的变更
+type PackageChangelog struct {
+	Package          string
+	InstalledVersion string
+	CandidateVersion string
+	Entries          []Entry
+	Bugs             []string
+	CVEs             []string
+}
+
+var (
+	// hello (2.10-1) unstable; urgency=low
+	headerRegexp = regexp.MustCompile(`^(\S+)\s+\(([^()\s]+)\)\s*([^;]*)(?:;(.*))?$`)
+	// Closes: #123, #456 / Closes: 123
+	closesRegexp = regexp.MustCompile(`(?i)closes:\s*(?:bug)?#?\s?\d+(?:,\s*(?:bug)?#?\s?\d+)*`)
+	// LP: #123, #456
+	lpRegexp     = regexp.MustCompile(`(?i)lp:\s*#\d+(?:,\s*#\d+)*`)
+	numberRegexp = regexp.MustCompile(`\d+`)
+	cveRegexp    = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)
+)
+
+// ExtractRefs 提取文本中引用的 bug 和 CVE 编号,Debian bug 表示为 "#123",
+// Launchpad bug 表示为 "LP#123"
+func ExtractRefs(text string) (bugs []string, cves []string) {
+	seen := make(map[string]bool)
+	add := func(list *[]string, ref string) {
+		if !seen[ref] {
+			seen[ref] = true
+			*list = append(*list, ref)
+		}
+	}
+	for _, match := range closesRegexp.FindAllString(text, -1) {
+		for _, num := range numberRegexp.FindAllString(match, -1) {
+			add(&bugs, "#"+num)
+		}
+	}
+	for _, match := range lpRegexp.FindAllString(text, -1) {
+		for _, num := range numberRegexp.FindAllString(match, -1) {
+			add(&bugs, "LP#"+num)
+		}
+	}
+	for _, match := range cveRegexp.FindAllString(text, -1) {
+		add(&cves, strings.ToUpper(match))
+	}
+	return bugs, cves
+}
+
+// ParseEntries 解析 changelog,返回版本号大于 from 且不大于 to 的条目。
+// changelog 按版本从新到旧排列,遇到不大于 from 的版本后停止解析;from 为空时返回 to 之前的所有条目,
+// to 为空时不限制上限。无法识别的行会被跳过,以兼容格式不规范的第三方包
+func ParseEntries(r io.Reader, from, to string) ([]Entry, error) {
+	var fromVer, toVer *debVersion.Version
+	if from != "" {
+		v, err := debVersion.Parse(from)
+		if err != nil {
+			return nil, err
+		}
+		fromVer = &v
+	}
+	if to != "" {
+		v, err := debVersion.Parse(to)
+		if err != nil {
+			return nil, err
+		}
+		toVer = &v
+	}
+
+	var entries []Entry
+	var cur *Entry
+	var changes []string
+	skip := false
+	finish := func() {
+		if cur != nil && !skip {
+			cur.Changes = strings.Trim(strings.Join(changes, "\n"), "\n")
+			cur.Bugs, cur.CVEs = ExtractRefs(cur.Changes)
+			entries = append(entries, *cur)
+		}
+		cur = nil
+		changes = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" {
+			if cur != nil {
+				changes = append(changes, "")
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			match := headerRegexp.FindStringSubmatch(line)
+			if match == nil {
+				// 旧格式的条目或 emacs 的 Local variables 等尾部内容
+				if cur == nil {
+					continue
+				}
+				break
+			}
+			finish()
+			ver, err := debVersion.Parse(match[2])
+			if err != nil {
+				skip = true
+				cur = &Entry{}
+				continue
+			}
+			if fromVer != nil && debVersion.Compare(ver, *fromVer) <= 0 {
+				break
+			}
+			skip = toVer != nil && debVersion.Compare(ver, *toVer) > 0
+			cur = &Entry{
+				Version:      match[2],
+				Distribution: strings.TrimSpace(match[3]),
+			}
+			for _, opt := range strings.Split(match[4], ",") {
+				key, value, ok := strings.Cut(strings.TrimSpace(opt), "=")
+				if ok && strings.EqualFold(key, "urgency") {
+					cur.Urgency = value
+				}
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if strings.HasPrefix(line, " -- ") {
+			// -- Maintainer <mail>  Mon, 02 Jan 2006 15:04:05 -0700
+			signoff := strings.TrimPrefix(line, " -- ")
+			who, when, _ := strings.Cut(signoff, "  ")
+			cur.Maintainer = strings.TrimSpace(who)
+			cur.Date = strings.TrimSpace(when)
+			finish()
+			continue
+		}
+		changes = append(changes, line)
+	}
+	finish()
+	return entries, scanner.Err()
+}
+
+// NewPackageChangelog 汇总所有条目中的 bug 和 CVE 编号
+func NewPackageChangelog(pkg, installed, candidate string, entries []Entry) *PackageChangelog {
+	c := &PackageChangelog{
+		Package:          pkg,
+		InstalledVersion: installed,
+		CandidateVersion: candidate,
+		Entries:          entries,
+		Bugs:             []string{},
+		CVEs:             []string{},
+	}
+	bugs := make(map[string]bool)
+	cves := make(map[string]bool)
+	for _, e := range entries {
+		for _, b := range e.Bugs {
+			if !bugs[b] {
+				bugs[b] = true
+				c.Bugs = append(c.Bugs, b)
+			}
+		}
+		for _, cve := range e.CVEs {
+			cves[cve] = true
+		}
+	}
+	for cve := range cves {
+		c.CVEs = append(c.CVEs, cve)
+	}
+	sort.Strings(c.CVEs)
+	return c
+}
@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package changelog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChangelog = `openssl (3.0.11-1deepin3) unstable; urgency=high
+
+  * Fix CVE-2024-0727 and cve-2023-6237.
+  * Backport upstream fix (Closes: #1060347, #1060348).
+
+ -- Deepin Packages Builder <packages@deepin.org>  Tue, 6 Feb 2024 10:00:00 +0800
+
+openssl (3.0.11-1deepin2) unstable; urgency=medium
+
+  * Fix crash on startup (LP: #2045250).
+  * Also fixes CVE-2024-0727.
+
+ -- Deepin Packages Builder <packages@deepin.org>  Mon, 08 Jan 2024 10:00:00 +0800
+
+openssl (3.0.11-1deepin1) unstable; urgency=medium
+
+  * Rebuild.
+
+ -- Deepin Packages Builder <packages@deepin.org>  Mon, 01 Jan 2024 10:00:00 +0800
+
+Local variables:
+mode: debian-changelog
+End:
+`
+
+func TestParseEntries(t *testing.T) {
+	entries, err := ParseEntries(strings.NewReader(testChangelog), "3.0.11-1deepin1", "3.0.11-1deepin3")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "3.0.11-1deepin3", entries[0].Version)
+	assert.Equal(t, "unstable", entries[0].Distribution)
+	assert.Equal(t, "high", entries[0].Urgency)
+	assert.Equal(t, "Deepin Packages Builder <packages@deepin.org>", entries[0].Maintainer)
+	assert.Equal(t, "Tue, 6 Feb 2024 10:00:00 +0800", entries[0].Date)
+	assert.Equal(t, []string{"#1060347", "#1060348"}, entries[0].Bugs)
+	assert.Equal(t, []string{"CVE-2024-0727", "CVE-2023-6237"}, entries[0].CVEs)
+	assert.True(t, strings.HasPrefix(entries[0].Changes, "  * Fix CVE-2024-0727"))
+
+	assert.Equal(t, []string{"LP#2045250"}, entries[1].Bugs)
+
+	// 候选版本之后的条目不返回
+	entries, err = ParseEntries(strings.NewReader(testChangelog), "3.0.11-1deepin1", "3.0.11-1deepin2")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "3.0.11-1deepin2", entries[0].Version)
+
+	// 未安装时返回所有条目,尾部的 emacs 配置被忽略
+	entries, err = ParseEntries(strings.NewReader(testChangelog), "", "")
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	_, err = ParseEntries(strings.NewReader(testChangelog), "not a version!", "")
+	assert.Error(t, err)
+}
+
+func TestNewPackageChangelog(t *testing.T) {
+	entries, err := ParseEntries(strings.NewReader(testChangelog), "3.0.11-1deepin1", "")
+	require.NoError(t, err)
+	c := NewPackageChangelog("openssl", "3.0.11-1deepin1", "3.0.11-1deepin3", entries)
+	assert.Equal(t, []string{"#1060347", "#1060348", "LP#2045250"}, c.Bugs)
+	assert.Equal(t, []string{"CVE-2023-6237", "CVE-2024-0727"}, c.CVEs)
+}
+
+func TestExtractRefs(t *testing.T) {
+	bugs, cves := ExtractRefs("Closes: 123, #456\nCloses: Bug#789\nsee CVE-2021-44228")
+	assert.Equal(t, []string{"#123", "#456", "#789"}, bugs)
+	assert.Equal(t, []string{"CVE-2021-44228"}, cves)
+
+	bugs, cves = ExtractRefs("nothing here, not even CVE-21-1")
+	assert.Empty(t, bugs)
+	assert.Empty(t, cves)
+}
+
+func TestParseDebFileName(t *testing.T) {
+	deb, ok := ParseDebFileName("/var/cache/lastore/archives/libssl3_1%3a3.0.11-1deepin3_amd64.deb")
+	require.True(t, ok)
+	assert.Equal(t, "libssl3", deb.Package)
+	assert.Equal(t, "1:3.0.11-1deepin3", deb.Version)
+	assert.Equal(t, "amd64", deb.Arch)
+
+	_, ok = ParseDebFileName("lock")
+	assert.False(t, ok)
+	_, ok = ParseDebFileName("partial_1.0.deb")
+	assert.False(t, ok)
+}
+
+func TestFindChangelog(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte(testChangelog))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./usr/share/doc/openssl/", Typeflag: tar.TypeDir, Mode: 0755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./usr/share/doc/openssl/changelog.Debian.gz", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(gz.Len())}))
+	_, err = tw.Write(gz.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	data, err := findChangelog(bytes.NewReader(buf.Bytes()), "openssl")
+	require.NoError(t, err)
+	assert.Equal(t, testChangelog, string(data))
+
+	_, err = findChangelog(bytes.NewReader(buf.Bytes()), "libssl3")
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package changelog
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	debVersion "pault.ag/go/debian/version"
+)
+
+// ErrNotFound deb 包中不包含 changelog
+var ErrNotFound = errors.New("changelog not found in deb")
+
+// maxChangelogSize 解压后 changelog 的最大长度,避免异常的包占用过多内存
+const maxChangelogSize = 16 * 1024 * 1024
+
+// DebFile 归档目录中的 deb 包
+type DebFile struct {
+	Path    string
+	Package string
+	Version string
+	Arch    string
+}
+
+// ParseDebFileName 解析 apt 下载的包文件名 <package>_<version>_<arch>.deb,
+// 版本号中的 ':' 被转义为 "%3a"
+func ParseDebFileName(name string) (DebFile, bool) {
+	base := filepath.Base(name)
+	if !strings.HasSuffix(base, ".deb") {
+		return DebFile{}, false
+	}
+	parts := strings.Split(strings.TrimSuffix(base, ".deb"), "_")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return DebFile{}, false
+	}
+	ver, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return DebFile{}, false
+	}
+	return DebFile{
+		Path:    name,
+		Package: parts[0],
+		Version: ver,
+		Arch:    parts[2],
+	}, true
+}
+
+// ListDebFiles 列出归档目录中的 deb 包,同一个包存在多个版本时只保留最高版本
+func ListDebFiles(dirs ...string) map[string]DebFile {
+	result := make(map[string]DebFile)
+	for _, dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.deb"))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			deb, ok := ParseDebFileName(match)
+			if !ok {
+				continue
+			}
+			if old, ok := result[deb.Package]; ok && compareVersion(old.Version, deb.Version) >= 0 {
+				continue
+			}
+			result[deb.Package] = deb
+		}
+	}
+	return result
+}
+
+func compareVersion(a, b string) int {
+	va, errA := debVersion.Parse(a)
+	vb, errB := debVersion.Parse(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return debVersion.Compare(va, vb)
+}
+
+// changelogPaths 返回 changelog 在数据包中可能的路径,原生包使用 changelog.gz
+func changelogPaths(pkg string) []string {
+	dir := "./usr/share/doc/" + pkg + "/"
+	return []string{dir + "changelog.Debian.gz", dir + "changelog.gz"}
+}
+
+// ReadFromDeb 读取 deb 包中 pkg 的 changelog 并解压
+func ReadFromDeb(debPath, pkg string) ([]byte, error) {
+	// 使用 dpkg-deb 解出数据包,不需要关心数据包的压缩格式
+	cmd := exec.Command("dpkg-deb", "--fsys-tarfile", debPath) // #nosec G204
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	data, findErr := findChangelog(stdout, pkg)
+	// 找到后不需要读取剩余的内容
+	_ = cmd.Process.Kill()
+	waitErr := cmd.Wait()
+	if findErr == ErrNotFound && waitErr != nil && stderr.Len() > 0 {
+		return nil, fmt.Errorf("dpkg-deb --fsys-tarfile %s: %s", debPath, strings.TrimSpace(stderr.String()))
+	}
+	return data, findErr
+}
+
+func findChangelog(r io.Reader, pkg string) ([]byte, error) {
+	candidates := changelogPaths(pkg)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := hdr.Name
+		if !strings.HasPrefix(name, "./") {
+			name = "./" + strings.TrimPrefix(name, "/")
+		}
+		for _, candidate := range candidates {
+			if name == candidate {
+				return gunzip(tr)
+			}
+		}
+	}
+}
+
+func gunzip(r io.Reader) ([]byte, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	data, err := io.ReadAll(io.LimitReader(gr, maxChangelogSize))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// LoadPackageChangelog 读取 deb 包中已安装版本到 deb 包版本之间的变更
+func LoadPackageChangelog(deb DebFile, installed string) (*PackageChangelog, error) {
+	if _, err := os.Stat(deb.Path); err != nil {
+		return nil, err
+	}
+	data, err := ReadFromDeb(deb.Path, deb.Package)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ParseEntries(strings.NewReader(string(data)), installed, deb.Version)
+	if err != nil {
+		return nil, err
+	}
+	return NewPackageChangelog(deb.Package, installed, deb.Version, entries), nil
+}
+
+type cacheKey struct {
+	path      string
+	installed string
+}
+
+// Cache 缓存已解析的 changelog,deb 包文件名中带有版本号,因此以路径和已安装版本作为键即可
+type Cache struct {
+	mu   sync.Mutex
+	data map[cacheKey]*PackageChangelog
+}
+
+func NewCache() *Cache {
+	return &Cache{data: make(map[cacheKey]*PackageChangelog)}
+}
+
+// Load 优先从缓存中读取,deb 包已被清理时重新读取(会返回错误)
+func (c *Cache) Load(deb DebFile, installed string) (*PackageChangelog, error) {
+	key := cacheKey{path: deb.Path, installed: installed}
+	c.mu.Lock()
+	cached, ok := c.data[key]
+	c.mu.Unlock()
+	if ok {
+		if _, err := os.Stat(deb.Path); err == nil {
+			return cached, nil
+		}
+	}
+	result, err := LoadPackageChangelog(deb, installed)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		delete(c.data, key)
+		return nil, err
+	}
+	c.data[key] = result
+	return result, nil
+}
+
+// Prune 删除不在 debs 中的缓存
+func (c *Cache) Prune(debs map[string]DebFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.data {
+		found := false
+		for _, deb := range debs {
+			if deb.Path == key.path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(c.data, key)
+		}
+	}
+}
@@ -119,8 +119,22 @@ type Config struct {
 	PlatformDisabled       DisabledStatus
 	EnableVersionCheck     bool
 	UpdateProcessUpload    bool
+	UploadBaselineDrift    bool   // 上报更新过程状态时是否附带基线偏离报告
+	KeepKernels            int    // 保留的内核数量,包括当前内核,最少为 2
+	CheckScriptTestKeys    bool   // 开发调试用,同时信任测试公钥签名的检查脚本
+	PayloadEncryption      string // 上报数据的加密方式,aes-cbc 为兼容模式,aes-256-gcm 使用部署密钥
+	PlatformCAFile         string // 访问更新平台时额外信任的 CA 证书
+	PlatformClientCert     string // 访问更新平台使用的客户端证书
+	PlatformClientKey      string // 客户端证书的私钥
+	PlatformEndpoints      string // 多个更新平台地址的 json 列表,配置后在地址之间故障切换
+	PlatformPushUrl        string // 接收平台策略变化通知的长连接地址,为空时只通过定时轮询获取
 	PlatformRepoComponents string // 更新平台仓库组件
 	UpgradeDeliveryEnabled bool   // 传递优化服务是否开机可用
+	TelemetryLevel         string // 遥测级别 none、minimal、standard、full,决定上报给更新平台的设备信息
+	TelemetryRedact        string // 上报字段的脱敏配置,字段名到 drop 或 hash 的 json 对象
+
+	// 无法访问更新平台时,可以使用的最后一次有效响应的最长时间,为 0 时不使用
+	PlatformCacheMaxStale time.Duration
 
 	ClassifiedUpdatablePackages map[string][]string
 	OnlineCache                 string
@@ -212,6 +226,18 @@ const (
 	dSettingsKeyPlatformDisabled                     = "platform-disabled"
 	dSettingsKeyEnableVersionCheck                   = "enable-version-check"
 	dSettingsKeyUpdateProcessUpload                  = "update-process-upload"
+	dSettingsKeyUploadBaselineDrift                  = "upload-baseline-drift"
+	dSettingsKeyKeepKernels                          = "keep-kernels"
+	dSettingsKeyCheckScriptTestKeys                  = "check-script-test-keys"
+	dSettingsKeyPayloadEncryption                    = "payload-encryption"
+	dSettingsKeyPlatformCAFile                       = "platform-ca-file"
+	dSettingsKeyPlatformClientCert                   = "platform-client-cert"
+	dSettingsKeyPlatformClientKey                    = "platform-client-key"
+	dSettingsKeyPlatformCacheMaxStale                = "platform-cache-max-stale"
+	dSettingsKeyPlatformEndpoints                    = "platform-endpoints"
+	dSettingsKeyPlatformPushUrl                      = "platform-push-url"
+	DSettingsKeyTelemetryLevel                       = "telemetry-level"
+	DSettingsKeyTelemetryRedact                      = "telemetry-redact"
 	dSettingsKeyEnableCoreList                       = "enable-core-list"
 	dSettingsKeyClientPackageName                    = "client-package-name"
 	DSettingsKeyUpgradeDeliveryEnabled               = "upgrade-delivery-enabled"
@@ -690,6 +716,90 @@ func getConfigFromDSettings() *Config {
 		c.UpdateProcessUpload = v.Value().(bool)
 	}
 
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyUploadBaselineDrift)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.UploadBaselineDrift = v.Value().(bool)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyKeepKernels)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.KeepKernels = int(v.Value().(int64))
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyCheckScriptTestKeys)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.CheckScriptTestKeys = v.Value().(bool)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPayloadEncryption)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PayloadEncryption = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPlatformCAFile)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PlatformCAFile = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPlatformClientCert)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PlatformClientCert = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPlatformClientKey)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PlatformClientKey = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPlatformCacheMaxStale)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PlatformCacheMaxStale = time.Duration(v.Value().(int64)) * time.Second
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPlatformEndpoints)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PlatformEndpoints = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, dSettingsKeyPlatformPushUrl)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.PlatformPushUrl = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, DSettingsKeyTelemetryLevel)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.TelemetryLevel = v.Value().(string)
+	}
+
+	v, err = c.dsLastoreManager.Value(0, DSettingsKeyTelemetryRedact)
+	if err != nil {
+		logger.Warning(err)
+	} else {
+		c.TelemetryRedact = v.Value().(string)
+	}
+
 	v, err = c.dsLastoreManager.Value(0, dSettingsKeyEnableCoreList)
 	if err != nil {
 		logger.Warning(err)
@@ -911,6 +1021,36 @@ func getConfigFromDSettings() *Config {
 				}
 				c.dsettingsChangedCbMapMu.Unlock()
 			}
+		case DSettingsKeyTelemetryLevel:
+			v, err = c.dsLastoreManager.Value(0, DSettingsKeyTelemetryLevel)
+			if err != nil {
+				logger.Warning(err)
+			} else {
+				oldValue := c.TelemetryLevel
+				newValue := v.Value().(string)
+				c.TelemetryLevel = newValue
+				c.dsettingsChangedCbMapMu.Lock()
+				cb := c.dsettingsChangedCbMap[key]
+				if cb != nil {
+					go cb(oldValue, newValue)
+				}
+				c.dsettingsChangedCbMapMu.Unlock()
+			}
+		case DSettingsKeyTelemetryRedact:
+			v, err = c.dsLastoreManager.Value(0, DSettingsKeyTelemetryRedact)
+			if err != nil {
+				logger.Warning(err)
+			} else {
+				oldValue := c.TelemetryRedact
+				newValue := v.Value().(string)
+				c.TelemetryRedact = newValue
+				c.dsettingsChangedCbMapMu.Lock()
+				cb := c.dsettingsChangedCbMap[key]
+				if cb != nil {
+					go cb(oldValue, newValue)
+				}
+				c.dsettingsChangedCbMapMu.Unlock()
+			}
 		case DSettingsKeyLastoreDaemonStatus:
 			oldStatus := c.lastoreDaemonStatus
 			updateLastoreDaemonStatus()
@@ -1299,3 +1439,24 @@ func (c *Config) ResetDSettings(key string) error {
 	}
 	return nil
 }
+
+// DSettingsValues 返回 lastore 所有 dconfig 配置的当前值,用于收集诊断信息
+func (c *Config) DSettingsValues() (map[string]interface{}, error) {
+	if c.dsLastoreManager == nil {
+		return nil, errors.New("not exist lastore dsettings")
+	}
+	keys, err := c.dsLastoreManager.KeyList().Get(0)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		v, err := c.dsLastoreManager.Value(0, key)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		values[key] = v.Value()
+	}
+	return values, nil
+}
@@ -0,0 +1,250 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package envelope 加密上报更新平台的数据。
+// 支持两种方式:兼容旧平台的 AES-CBC(固定密钥),以及使用部署密钥的 AES-256-GCM。
+// 部署密钥保存在仅 root 可读的密钥文件中,每行一个密钥版本,请求头中携带加密方式和密钥版本,平台据此选择解密密钥
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	SchemeLegacy = "aes-cbc"     // 兼容模式
+	SchemeGCM    = "aes-256-gcm" // 认证加密
+
+	HeaderScheme      = "X-Encrypt-Scheme"
+	HeaderKeyVersion  = "X-Encrypt-Key-Version"
+	HeaderKeyVersions = "X-Encrypt-Key-Versions" // 客户端持有的密钥版本,平台在响应中通过 HeaderKeyVersion 选择其中之一
+
+	DefaultKeyFile = "/etc/lastore/platform-payload.keys"
+)
+
+const (
+	legacyKey       = "DflXyFwTmaoGmbDkVj8uD62XGb01pkJn"
+	legacyBlockSize = 32
+	legacyRandomLen = 16 // 明文前填充的随机值长度
+)
+
+// keyFileOwner 密钥文件必须属于该用户
+var keyFileOwner uint32 = 0
+
+// Cipher 上报数据的加密方式
+type Cipher interface {
+	Scheme() string
+	KeyVersion() string // 兼容模式为空
+	Seal(plain []byte) ([]byte, error)
+	Open(data []byte) ([]byte, error)
+}
+
+type legacyCipher struct{}
+
+// Legacy 返回兼容旧平台的 AES-CBC 加密:密钥固定,IV 为密钥的前 16 字节,明文前填充 16 字节随机值
+func Legacy() Cipher {
+	return legacyCipher{}
+}
+
+func (legacyCipher) Scheme() string {
+	return SchemeLegacy
+}
+
+func (legacyCipher) KeyVersion() string {
+	return ""
+}
+
+func (legacyCipher) Seal(plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher([]byte(legacyKey))
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, legacyRandomLen, legacyRandomLen+len(plain)+legacyBlockSize)
+	if _, err := io.ReadFull(rand.Reader, text); err != nil {
+		return nil, err
+	}
+	text = append(text, plain...)
+	padding := legacyBlockSize - len(text)%legacyBlockSize
+	text = append(text, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, []byte(legacyKey[:aes.BlockSize])).CryptBlocks(text, text)
+	return text, nil
+}
+
+func (legacyCipher) Open(data []byte) ([]byte, error) {
+	block, err := aes.NewCipher([]byte(legacyKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+	text := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, []byte(legacyKey[:aes.BlockSize])).CryptBlocks(text, data)
+	padding := int(text[len(text)-1])
+	if padding == 0 || padding > legacyBlockSize || padding > len(text)-legacyRandomLen {
+		return nil, errors.New("invalid padding")
+	}
+	return text[legacyRandomLen : len(text)-padding], nil
+}
+
+// Key 部署密钥
+type Key struct {
+	Version string
+	Secret  []byte // 32 字节 AES-256 密钥
+}
+
+type gcmCipher struct {
+	version string
+	aead    cipher.AEAD
+}
+
+// NewGCM 使用部署密钥的 AES-256-GCM 加密,密文为 nonce 加上带认证标签的密文,密钥版本作为附加认证数据
+func NewGCM(key Key) (Cipher, error) {
+	if len(key.Secret) != 32 {
+		return nil, fmt.Errorf("key %v: invalid AES-256 key size %d", key.Version, len(key.Secret))
+	}
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmCipher{version: key.Version, aead: aead}, nil
+}
+
+func (c *gcmCipher) Scheme() string {
+	return SchemeGCM
+}
+
+func (c *gcmCipher) KeyVersion() string {
+	return c.version
+}
+
+func (c *gcmCipher) Seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plain, []byte(c.version)), nil
+}
+
+func (c *gcmCipher) Open(data []byte) ([]byte, error) {
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, sealed, []byte(c.version))
+}
+
+// KeySet 部署密钥集合,第一个为当前使用的密钥,其余为轮换前的旧密钥
+type KeySet []Key
+
+// LoadKeySet 加载密钥文件,文件必须属于 root 且不能被其他用户读写
+func LoadKeySet(path string) (KeySet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%v: key file must not be accessible by group or others (mode %v)", path, info.Mode().Perm())
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid != keyFileOwner {
+		return nil, fmt.Errorf("%v: key file must be owned by uid %d", path, keyFileOwner)
+	}
+	return ParseKeySet(f)
+}
+
+// ParseKeySet 解析密钥文件,每行为"<密钥版本> <base64 编码的 32 字节密钥>",忽略空行和 # 开头的注释
+func ParseKeySet(r io.Reader) (KeySet, error) {
+	var keys KeySet
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid key line %q", line)
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("duplicate key version %v", fields[0])
+		}
+		secret, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", fields[0], err)
+		}
+		if len(secret) != 32 {
+			return nil, fmt.Errorf("key %v: invalid AES-256 key size %d", fields[0], len(secret))
+		}
+		seen[fields[0]] = true
+		keys = append(keys, Key{Version: fields[0], Secret: secret})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no key found")
+	}
+	return keys, nil
+}
+
+// Versions 返回所有密钥版本
+func (s KeySet) Versions() []string {
+	result := make([]string, 0, len(s))
+	for _, key := range s {
+		result = append(result, key.Version)
+	}
+	return result
+}
+
+// Lookup 查找密钥版本,version 为空时返回当前使用的密钥
+func (s KeySet) Lookup(version string) (Key, bool) {
+	if len(s) == 0 {
+		return Key{}, false
+	}
+	if version == "" {
+		return s[0], true
+	}
+	for _, key := range s {
+		if key.Version == version {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// New 按加密方式和密钥版本创建 Cipher,用于加密,也用于服务端测试时按请求头解密
+func New(scheme string, keys KeySet, version string) (Cipher, error) {
+	switch scheme {
+	case "", SchemeLegacy:
+		return Legacy(), nil
+	case SchemeGCM:
+		key, ok := keys.Lookup(version)
+		if !ok {
+			return nil, fmt.Errorf("key version %q not found", version)
+		}
+		return NewGCM(key)
+	default:
+		return nil, fmt.Errorf("unknown encrypt scheme %q", scheme)
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacy(t *testing.T) {
+	plain := []byte(`{"taskId":1,"status":0}`)
+	data, err := Legacy().Seal(plain)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(data)%legacyBlockSize)
+
+	// 与旧实现一致:IV 为密钥前 16 字节,去掉 PKCS7 填充和 16 字节随机前缀后为明文
+	block, err := aes.NewCipher([]byte(legacyKey))
+	require.NoError(t, err)
+	text := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, []byte(legacyKey[:16])).CryptBlocks(text, data)
+	padding := int(text[len(text)-1])
+	assert.Equal(t, plain, text[legacyRandomLen:len(text)-padding])
+
+	opened, err := Legacy().Open(data)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+	assert.Equal(t, "", Legacy().KeyVersion())
+
+	_, err = Legacy().Open([]byte("short"))
+	assert.Error(t, err)
+}
+
+func testKeys(t *testing.T) KeySet {
+	content := "# current key first\n" +
+		"2026-10 " + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)) + "\n\n" +
+		"2026-01 " + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)) + "\n"
+	keys, err := ParseKeySet(strings.NewReader(content))
+	require.NoError(t, err)
+	return keys
+}
+
+func TestGCM(t *testing.T) {
+	keys := testKeys(t)
+	assert.Equal(t, []string{"2026-10", "2026-01"}, keys.Versions())
+
+	c, err := New(SchemeGCM, keys, "")
+	require.NoError(t, err)
+	assert.Equal(t, SchemeGCM, c.Scheme())
+	assert.Equal(t, "2026-10", c.KeyVersion())
+
+	plain := []byte(`{"taskId":1}`)
+	data, err := c.Seal(plain)
+	require.NoError(t, err)
+	opened, err := c.Open(data)
+	require.NoError(t, err)
+	assert.Equal(t, plain, opened)
+
+	// 篡改密文或使用其他密钥版本都无法解密
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = c.Open(tampered)
+	assert.Error(t, err)
+	old, err := New(SchemeGCM, keys, "2026-01")
+	require.NoError(t, err)
+	_, err = old.Open(data)
+	assert.Error(t, err)
+
+	_, err = New(SchemeGCM, keys, "2025-01")
+	assert.Error(t, err)
+	_, err = New("rot13", keys, "")
+	assert.Error(t, err)
+	legacy, err := New("", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, SchemeLegacy, legacy.Scheme())
+}
+
+func TestParseKeySetInvalid(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"2026-10",
+		"2026-10 c2hvcnQ=",
+		"2026-10 not-base64!",
+		"v1 " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\nv1 " + base64.StdEncoding.EncodeToString(make([]byte, 32)),
+	} {
+		_, err := ParseKeySet(strings.NewReader(content))
+		assert.Error(t, err, content)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	keyFileOwner = uint32(os.Getuid())
+	defer func() {
+		keyFileOwner = 0
+	}()
+	path := filepath.Join(t.TempDir(), "platform-payload.keys")
+	content := "v1 " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	_, err := LoadKeySet(path)
+	assert.Error(t, err)
+
+	require.NoError(t, os.Chmod(path, 0600))
+	keys, err := LoadKeySet(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, keys.Versions())
+
+	keyFileOwner = uint32(os.Getuid()) + 1
+	_, err = LoadKeySet(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package kernel 管理更新过程中的内核:保留当前内核和最新的若干个内核,在 /boot 空间不足时清理旧内核,
+// 并在提示重启前确认新内核的 initramfs 和 GRUB 启动项已经生成
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	debVersion "pault.ag/go/debian/version"
+)
+
+const (
+	DefaultBootDir = "/boot"
+	DefaultGrubCfg = "/boot/grub/grub.cfg"
+	// MinKeep 至少保留当前内核和新安装的内核
+	MinKeep = 2
+
+	vmlinuzPrefix = "vmlinuz-"
+	initrdPrefix  = "initrd.img-"
+	imagePrefix   = "linux-image-"
+)
+
+// Kernel 已安装的内核
+type Kernel struct {
+	Version        string // 内核版本,与 uname -r 一致,如 6.6.40-amd64-desktop-rolling
+	Package        string `json:",omitempty"` // 提供该内核的软件包
+	PackageVersion string `json:",omitempty"`
+}
+
+// Change 一次更新前后的内核变化
+type Change struct {
+	Running   string
+	Default   string   // 最新的内核,重启后默认启动
+	Installed []string `json:",omitempty"` // 本次更新新安装的内核
+	Removed   []string `json:",omitempty"` // 本次更新清理的旧内核
+	Repaired  []string `json:",omitempty"` // 补充生成 initramfs 或 GRUB 启动项的内核
+}
+
+// IsKernelPackage 判断是否为内核镜像包,linux-image-amd64 等元包也包括在内
+func IsKernelPackage(pkg string) bool {
+	if i := strings.IndexAny(pkg, ":="); i >= 0 {
+		pkg = pkg[:i]
+	}
+	return strings.HasPrefix(pkg, imagePrefix)
+}
+
+// RunningVersion 当前运行的内核版本
+func RunningVersion() (string, error) {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return "", err
+	}
+	var buf []byte
+	for _, c := range uts.Release {
+		if c == 0 {
+			break
+		}
+		buf = append(buf, byte(c))
+	}
+	return string(buf), nil
+}
+
+// ListKernels 列出 bootDir 中安装的内核,按从新到旧排序
+func ListKernels(bootDir string) ([]Kernel, error) {
+	files, err := filepath.Glob(filepath.Join(bootDir, vmlinuzPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	var kernels []Kernel
+	for _, file := range files {
+		version := strings.TrimPrefix(filepath.Base(file), vmlinuzPrefix)
+		// 忽略 update-initramfs 等工具留下的备份文件
+		if strings.HasSuffix(version, ".bak") || strings.HasSuffix(version, ".old") {
+			continue
+		}
+		k := Kernel{Version: version}
+		k.Package, k.PackageVersion = queryOwner(file)
+		kernels = append(kernels, k)
+	}
+	SortKernels(kernels)
+	return kernels, nil
+}
+
+// queryOwner 查询安装 file 的软件包及其版本,没有软件包时返回空
+var queryOwner = func(file string) (string, string) {
+	out, err := exec.Command("dpkg-query", "-S", file).Output() // #nosec G204
+	if err != nil {
+		return "", ""
+	}
+	// linux-image-6.6.40-amd64-desktop-rolling: /boot/vmlinuz-6.6.40-amd64-desktop-rolling
+	pkg := strings.TrimSpace(strings.SplitN(string(out), ":", 2)[0])
+	if pkg == "" || strings.Contains(pkg, ",") {
+		return "", ""
+	}
+	ver, err := exec.Command("dpkg-query", "-W", "-f", "${Version}", "--", pkg).Output() // #nosec G204
+	if err != nil {
+		return pkg, ""
+	}
+	return pkg, string(bytes.TrimSpace(ver))
+}
+
+func compareVersion(a, b string) int {
+	va, errA := debVersion.Parse(a)
+	vb, errB := debVersion.Parse(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return debVersion.Compare(va, vb)
+}
+
+// SortKernels 按从新到旧排序,有软件包版本时比较软件包版本,否则比较内核版本
+func SortKernels(kernels []Kernel) {
+	sort.SliceStable(kernels, func(i, j int) bool {
+		a, b := kernels[i], kernels[j]
+		if a.PackageVersion != "" && b.PackageVersion != "" {
+			if c := compareVersion(a.PackageVersion, b.PackageVersion); c != 0 {
+				return c > 0
+			}
+		}
+		return compareVersion(a.Version, b.Version) > 0
+	})
+}
+
+// Removable 按照保留当前内核和最新的 keep-1 个其他内核的策略,返回可以清理的内核。
+// kernels 需要按从新到旧排序,没有软件包的内核不会被清理
+func Removable(kernels []Kernel, running string, keep int) []Kernel {
+	if keep < MinKeep {
+		keep = MinKeep
+	}
+	kept := 0
+	for _, k := range kernels {
+		if k.Version == running {
+			kept++
+			break
+		}
+	}
+	var result []Kernel
+	for _, k := range kernels {
+		if k.Version == running {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if k.Package != "" {
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// FreeSpace 返回 dir 所在分区的可用空间
+func FreeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil // #nosec G115
+}
+
+// Footprint 内核在 bootDir 中占用的空间,包括 vmlinuz、initrd、config 和 System.map 等文件
+func Footprint(bootDir, version string) uint64 {
+	files, err := filepath.Glob(filepath.Join(bootDir, "*-"+version))
+	if err != nil {
+		return 0
+	}
+	var size uint64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		size += uint64(info.Size()) // #nosec G115
+	}
+	return size
+}
+
+// Verify 检查内核的 initramfs 和 GRUB 启动项是否存在,grubCfg 不存在时不检查启动项
+func Verify(bootDir, grubCfg, version string) (hasInitrd, hasGrubEntry bool) {
+	info, err := os.Stat(filepath.Join(bootDir, initrdPrefix+version))
+	hasInitrd = err == nil && info.Size() > 0
+	content, err := os.ReadFile(grubCfg)
+	if err != nil {
+		return hasInitrd, os.IsNotExist(err)
+	}
+	return hasInitrd, bytes.Contains(content, []byte(vmlinuzPrefix+version+" ")) ||
+		bytes.Contains(content, []byte(vmlinuzPrefix+version+"\n"))
+}
+
+// EnsureBootable 确认内核的 initramfs 和 GRUB 启动项存在,缺少时重新生成,repaired 表示是否进行了修复
+func EnsureBootable(bootDir, grubCfg, version string) (repaired bool, err error) {
+	hasInitrd, hasGrub := Verify(bootDir, grubCfg, version)
+	if !hasInitrd {
+		repaired = true
+		out, err := exec.Command("update-initramfs", "-c", "-k", version).CombinedOutput() // #nosec G204
+		if err != nil {
+			return repaired, fmt.Errorf("update-initramfs for %v failed: %v: %s", version, err, out)
+		}
+	}
+	if !hasInitrd || !hasGrub {
+		repaired = true
+		out, err := exec.Command("update-grub").CombinedOutput()
+		if err != nil {
+			return repaired, fmt.Errorf("update-grub failed: %v: %s", err, out)
+		}
+	}
+	hasInitrd, hasGrub = Verify(bootDir, grubCfg, version)
+	if !hasInitrd {
+		return repaired, fmt.Errorf("initramfs of kernel %v is missing", version)
+	}
+	if !hasGrub {
+		return repaired, fmt.Errorf("no grub entry for kernel %v", version)
+	}
+	return repaired, nil
+}
+
+// Versions 内核版本列表
+func Versions(kernels []Kernel) []string {
+	var result []string
+	for _, k := range kernels {
+		result = append(result, k.Version)
+	}
+	return result
+}
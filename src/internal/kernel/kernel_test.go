@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package kernel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsKernelPackage(t *testing.T) {
+	assert.True(t, IsKernelPackage("linux-image-6.6.40-amd64-desktop-rolling"))
+	assert.True(t, IsKernelPackage("linux-image-amd64:amd64"))
+	assert.True(t, IsKernelPackage("linux-image-6.1.0-18-amd64=6.1.76-1"))
+	assert.False(t, IsKernelPackage("linux-headers-6.1.0-18-amd64"))
+	assert.False(t, IsKernelPackage("linux-firmware"))
+}
+
+func TestSortKernels(t *testing.T) {
+	kernels := []Kernel{
+		{Version: "6.1.0-9-amd64"},
+		{Version: "6.1.0-18-amd64"},
+		{Version: "5.10.0-1-amd64"},
+		{Version: "6.6.40-amd64-desktop-rolling", Package: "a", PackageVersion: "23.01.01.02"},
+		{Version: "6.6.9-amd64-desktop-rolling", Package: "b", PackageVersion: "23.01.01.10"},
+	}
+	SortKernels(kernels)
+	assert.Equal(t, []string{
+		"6.6.9-amd64-desktop-rolling",
+		"6.6.40-amd64-desktop-rolling",
+		"6.1.0-18-amd64",
+		"6.1.0-9-amd64",
+		"5.10.0-1-amd64",
+	}, Versions(kernels))
+}
+
+func TestRemovable(t *testing.T) {
+	kernels := []Kernel{
+		{Version: "6.6.3", Package: "linux-image-6.6.3"},
+		{Version: "6.6.2", Package: "linux-image-6.6.2"},
+		{Version: "6.6.1", Package: "linux-image-6.6.1"},
+		{Version: "6.6.0"},
+		{Version: "6.5.0", Package: "linux-image-6.5.0"},
+	}
+	// 保留当前内核和最新的一个其他内核
+	assert.Equal(t, []string{"6.6.2", "6.5.0"}, Versions(Removable(kernels, "6.6.1", 2)))
+	assert.Equal(t, []string{"6.6.2", "6.5.0"}, Versions(Removable(kernels, "6.6.1", 0)))
+	assert.Equal(t, []string{"6.5.0"}, Versions(Removable(kernels, "6.6.1", 3)))
+	assert.Equal(t, []string{"6.6.1", "6.5.0"}, Versions(Removable(kernels, "6.6.3", 2)))
+	// 当前内核不在列表中时只保留最新的内核
+	assert.Equal(t, []string{"6.6.1", "6.5.0"}, Versions(Removable(kernels, "6.7.0", 2)))
+	assert.Empty(t, Removable(kernels, "6.6.1", 10))
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	grubCfg := filepath.Join(dir, "grub.cfg")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "vmlinuz-6.6.3"), []byte("kernel"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "initrd.img-6.6.3"), []byte("initrd"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "initrd.img-6.6.2"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config-6.6.3"), []byte("config"), 0644))
+
+	hasInitrd, hasGrub := Verify(dir, grubCfg, "6.6.3")
+	assert.True(t, hasInitrd)
+	assert.True(t, hasGrub, "no grub.cfg, skip checking grub entry")
+
+	require.NoError(t, os.WriteFile(grubCfg, []byte(
+		"\tlinux\t/boot/vmlinuz-6.6.3 root=UUID=1234 ro quiet\n"+
+			"\tlinux\t/boot/vmlinuz-6.6.30 root=UUID=1234 ro quiet\n"), 0644))
+	hasInitrd, hasGrub = Verify(dir, grubCfg, "6.6.3")
+	assert.True(t, hasInitrd)
+	assert.True(t, hasGrub)
+
+	hasInitrd, hasGrub = Verify(dir, grubCfg, "6.6.2")
+	assert.False(t, hasInitrd, "empty initrd")
+	assert.False(t, hasGrub)
+
+	assert.Equal(t, uint64(len("kernel")+len("initrd")+len("config")), Footprint(dir, "6.6.3"))
+}
+
+func TestListKernels(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"vmlinuz-6.6.2", "vmlinuz-6.6.10", "vmlinuz-6.6.10.bak", "initrd.img-6.6.2"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	origin := queryOwner
+	defer func() { queryOwner = origin }()
+	queryOwner = func(file string) (string, string) {
+		return "linux-image-" + filepath.Base(file)[len(vmlinuzPrefix):], ""
+	}
+	kernels, err := ListKernels(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []Kernel{
+		{Version: "6.6.10", Package: "linux-image-6.6.10"},
+		{Version: "6.6.2", Package: "linux-image-6.6.2"},
+	}, kernels)
+}
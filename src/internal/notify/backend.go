@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/syslog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	journalSocket          = "/run/systemd/journal/socket"
+	defaultJournalIdent    = "lastore-daemon"
+	defaultWebhookTimeout  = 10 * time.Second
+	defaultSendmailPath    = "/usr/sbin/sendmail"
+	defaultSendmailTimeout = 30 * time.Second
+)
+
+// journalBackend 通过 journald 原生协议写入带结构化字段的日志,journald 不可用时回退到 syslog
+type journalBackend struct {
+	identifier string
+}
+
+func newJournalBackend(cfg BackendConfig) *journalBackend {
+	ident := cfg.Identifier
+	if ident == "" {
+		ident = defaultJournalIdent
+	}
+	return &journalBackend{identifier: ident}
+}
+
+func (b *journalBackend) priority(msg *Message) syslog.Priority {
+	if msg.Type == EventFailed {
+		return syslog.LOG_ERR
+	}
+	if msg.Type == EventRebootRequired {
+		return syslog.LOG_WARNING
+	}
+	return syslog.LOG_NOTICE
+}
+
+func (b *journalBackend) text(msg *Message) string {
+	if msg.Text == "" {
+		return msg.Subject()
+	}
+	return msg.Subject() + ": " + msg.Text
+}
+
+func (b *journalBackend) fields(msg *Message) [][2]string {
+	fields := [][2]string{
+		{"MESSAGE", b.text(msg)},
+		{"PRIORITY", fmt.Sprint(int(b.priority(msg)))},
+		{"SYSLOG_IDENTIFIER", b.identifier},
+		{"LASTORE_EVENT", string(msg.Type)},
+	}
+	if msg.UpdateType != "" {
+		fields = append(fields, [2]string{"LASTORE_UPDATE_TYPE", msg.UpdateType})
+	}
+	if msg.ErrType != "" {
+		fields = append(fields, [2]string{"LASTORE_ERROR_TYPE", msg.ErrType})
+	}
+	return fields
+}
+
+// encodeJournalFields 按 journald 原生协议编码,值中含换行时使用长度前缀的二进制格式
+func encodeJournalFields(fields [][2]string) []byte {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		key, value := f[0], f[1]
+		if strings.ContainsRune(value, '\n') {
+			buf.WriteString(key)
+			buf.WriteByte('\n')
+			_ = binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+			buf.WriteString(value)
+			buf.WriteByte('\n')
+		} else {
+			buf.WriteString(key)
+			buf.WriteByte('=')
+			buf.WriteString(value)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func (b *journalBackend) Send(msg *Message) error {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err == nil {
+		defer conn.Close()
+		_, err = conn.Write(encodeJournalFields(b.fields(msg)))
+		if err == nil {
+			return nil
+		}
+	}
+	logger.Debugf("write to journal failed: %v, fallback to syslog", err)
+	w, err := syslog.New(b.priority(msg)|syslog.LOG_DAEMON, b.identifier)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	_, err = fmt.Fprintf(w, "event=%s update_type=%s error_type=%s %s", msg.Type, msg.UpdateType, msg.ErrType, b.text(msg))
+	return err
+}
+
+type webhookBackend struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+type webhookPayload struct {
+	Event      EventType `json:"event"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	UpdateType string    `json:"update_type,omitempty"`
+	ErrorType  string    `json:"error_type,omitempty"`
+	Hostname   string    `json:"hostname"`
+	Time       int64     `json:"time"`
+}
+
+func newWebhookBackend(cfg BackendConfig) (*webhookBackend, error) {
+	u, err := url.Parse(cfg.Url)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webhook url: %q", cfg.Url)
+	}
+	timeout := defaultWebhookTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	return &webhookBackend{
+		url:     cfg.Url,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (b *webhookBackend) Send(msg *Message) error {
+	content, err := json.Marshal(webhookPayload{
+		Event:      msg.Type,
+		Title:      msg.Subject(),
+		Text:       msg.Text,
+		UpdateType: msg.UpdateType,
+		ErrorType:  msg.ErrType,
+		Hostname:   msg.Hostname,
+		Time:       msg.Time.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, b.url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for k, v := range b.headers {
+		request.Header.Set(k, v)
+	}
+	response, err := b.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %v response status %v", b.url, response.Status)
+	}
+	return nil
+}
+
+// mailBackend 通过本地 MTA 的 sendmail 接口投递邮件
+type mailBackend struct {
+	sendmail string
+	from     string
+	to       []string
+}
+
+func newMailBackend(cfg BackendConfig) (*mailBackend, error) {
+	if len(cfg.To) == 0 {
+		return nil, errors.New("mail backend requires at least one recipient")
+	}
+	for _, addr := range append([]string{cfg.From}, cfg.To...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid mail address: %q", addr)
+		}
+	}
+	sendmail := cfg.Sendmail
+	if sendmail == "" {
+		sendmail = defaultSendmailPath
+	}
+	return &mailBackend{
+		sendmail: sendmail,
+		from:     cfg.From,
+		to:       cfg.To,
+	}, nil
+}
+
+func (b *mailBackend) compose(msg *Message) []byte {
+	var buf bytes.Buffer
+	if b.from != "" {
+		fmt.Fprintf(&buf, "From: %s\r\n", b.from)
+	}
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(b.to, ", "))
+	subject := strings.ReplaceAll(msg.Subject(), "\n", " ")
+	if msg.Hostname != "" {
+		subject = fmt.Sprintf("[%s] %s", msg.Hostname, subject)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", msg.Time.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	fmt.Fprintf(&buf, "X-Lastore-Event: %s\r\n", msg.Type)
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Text)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func (b *mailBackend) Send(msg *Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSendmailTimeout)
+	defer cancel()
+	args := []string{"-i"}
+	if b.from != "" {
+		args = append(args, "-f", b.from)
+	}
+	args = append(args, "--")
+	args = append(args, b.to...)
+	cmd := exec.CommandContext(ctx, b.sendmail, args...)
+	cmd.Stdin = bytes.NewReader(b.compose(msg))
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%v: %v", err, errBuf.String())
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package notify 将更新过程中的事件分发到多个通知后端(桌面气泡、journal、webhook、邮件)。
+// 后端配置来自 /etc/deepin/lastore-daemon/notify.conf.d/*.json，每个文件描述一个后端。
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/gettext"
+	"github.com/linuxdeepin/go-lib/log"
+)
+
+var logger = log.NewLogger("lastore/notify")
+
+const ConfigDirPath = "/etc/deepin/lastore-daemon/notify.conf.d/"
+
+type EventType string
+
+const (
+	EventAvailable      EventType = "available"       // 检查到可用更新
+	EventDownloading    EventType = "downloading"     // 开始下载更新包
+	EventDownloaded     EventType = "downloaded"      // 更新包下载完成
+	EventInstalling     EventType = "installing"      // 开始安装更新
+	EventInstalled      EventType = "installed"       // 更新安装完成
+	EventFailed         EventType = "failed"          // 检查、下载、备份、安装或卸载失败
+	EventRebootRequired EventType = "reboot_required" // 需要重启生效
+	EventScheduled      EventType = "scheduled"       // 定时更新的时间变化
+	EventRemoved        EventType = "removed"         // 应用卸载完成
+	EventCleaned        EventType = "cleaned"         // 软件包缓存清理完成
+)
+
+func (e EventType) IsValid() bool {
+	switch e {
+	case EventAvailable, EventDownloading, EventDownloaded, EventInstalling, EventInstalled, EventFailed,
+		EventRebootRequired, EventScheduled, EventRemoved, EventCleaned:
+		return true
+	}
+	return false
+}
+
+// defaultSummary 非桌面后端在事件没有标题时使用的标题
+func (e EventType) defaultSummary() string {
+	switch e {
+	case EventAvailable:
+		return gettext.Tr("New version available!")
+	case EventDownloading:
+		return gettext.Tr("New version available! Downloading...")
+	case EventDownloaded:
+		return gettext.Tr("Downloading completed. You can install updates when shutdown or reboot.")
+	case EventInstalling:
+		return gettext.Tr("Start to update. Please do not shutdown")
+	case EventInstalled:
+		return gettext.Tr("Updates successful")
+	case EventFailed:
+		return gettext.Tr("Updates failed.")
+	case EventRebootRequired:
+		return gettext.Tr("Restart the computer to use the system and applications properly.")
+	case EventScheduled:
+		return gettext.Tr("The update schedule has changed")
+	case EventRemoved:
+		return gettext.Tr("Removed successfully")
+	case EventCleaned:
+		return gettext.Tr("Package cache wiped")
+	}
+	return string(e)
+}
+
+type BackendType string
+
+const (
+	DesktopBackend BackendType = "desktop"
+	JournalBackend BackendType = "journal"
+	WebhookBackend BackendType = "webhook"
+	MailBackend    BackendType = "mail"
+)
+
+// DesktopOptions 桌面气泡专用的参数,其他后端忽略。事件不带该参数时桌面后端不发送气泡。
+type DesktopOptions struct {
+	AppName       string
+	AppIcon       string
+	Actions       []string
+	Hints         map[string]dbus.Variant
+	ExpireTimeout int32
+}
+
+type Event struct {
+	Type       EventType
+	Summary    string
+	Body       string
+	UpdateType string // 更新类型,如 system_upgrade
+	ErrType    string // 失败事件的 JobErrorType
+	Time       time.Time
+	Desktop    *DesktopOptions
+}
+
+// Message 按后端模板渲染后的事件
+type Message struct {
+	*Event
+	Hostname string
+	Title    string
+	Text     string
+}
+
+// Subject 渲染后的标题,为空时使用事件类型的默认标题
+func (msg *Message) Subject() string {
+	if msg.Title != "" {
+		return msg.Title
+	}
+	return msg.Type.defaultSummary()
+}
+
+type Backend interface {
+	Send(msg *Message) error
+}
+
+type TemplateText struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type BackendConfig struct {
+	Name     string      `json:"name"`
+	Type     BackendType `json:"type"`
+	Disabled bool        `json:"disabled"`
+	// 为空时接收所有事件
+	Events []EventType `json:"events"`
+	// 模板使用的语言,如 zh_CN,为空时使用默认模板
+	Locale string `json:"locale"`
+	// locale -> event -> 模板,locale 为空字符串的项作为兜底
+	Templates map[string]map[EventType]TemplateText `json:"templates"`
+
+	// journal
+	Identifier string `json:"identifier"`
+	// webhook
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Timeout int               `json:"timeout"` // 单位秒
+	// mail
+	Sendmail string   `json:"sendmail"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+func (cfg *BackendConfig) accept(typ EventType) bool {
+	if cfg.Disabled {
+		return false
+	}
+	if len(cfg.Events) == 0 {
+		return true
+	}
+	for _, e := range cfg.Events {
+		if e == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupTemplate 依次匹配 zh_CN.UTF-8 -> zh_CN -> zh -> ""
+func (cfg *BackendConfig) lookupTemplate(typ EventType) (TemplateText, bool) {
+	if len(cfg.Templates) == 0 {
+		return TemplateText{}, false
+	}
+	var candidates []string
+	locale := cfg.Locale
+	if locale != "" {
+		candidates = append(candidates, locale)
+		if i := strings.IndexAny(locale, ".@"); i > 0 {
+			locale = locale[:i]
+			candidates = append(candidates, locale)
+		}
+		if i := strings.Index(locale, "_"); i > 0 {
+			candidates = append(candidates, locale[:i])
+		}
+	}
+	candidates = append(candidates, "")
+	for _, l := range candidates {
+		if t, ok := cfg.Templates[l][typ]; ok {
+			return t, true
+		}
+	}
+	return TemplateText{}, false
+}
+
+func (cfg *BackendConfig) render(ev *Event) (*Message, error) {
+	msg := &Message{
+		Event: ev,
+		Title: ev.Summary,
+		Text:  ev.Body,
+	}
+	msg.Hostname, _ = os.Hostname()
+	tpl, ok := cfg.lookupTemplate(ev.Type)
+	if !ok {
+		return msg, nil
+	}
+	// 模板中可以使用 {{.Title}} {{.Text}} 引用原始内容
+	var err error
+	if tpl.Title != "" {
+		msg.Title, err = execTemplate(tpl.Title, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tpl.Text != "" {
+		msg.Text, err = execTemplate(tpl.Text, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func execTemplate(text string, msg *Message) (string, error) {
+	t, err := template.New("notify").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, msg)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// LoadBackendConfigs 读取目录中的 json 配置,按文件名排序,单个文件错误不影响其他文件
+func LoadBackendConfigs(dir string) []BackendConfig {
+	infos, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warningf("failed to read dir %v, error is %v", dir, err)
+		}
+		return nil
+	}
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	var configs []BackendConfig
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		var cfg BackendConfig
+		err = json.Unmarshal(content, &cfg)
+		if err != nil {
+			logger.Warningf("invalid notify config %v: %v", name, err)
+			continue
+		}
+		if cfg.Name == "" {
+			cfg.Name = strings.TrimSuffix(name, ".json")
+		}
+		for _, e := range cfg.Events {
+			if !e.IsValid() {
+				logger.Warningf("notify config %v: unknown event %q", name, e)
+			}
+		}
+		configs = append(configs, cfg)
+	}
+	return configs
+}
+
+// NewBackend 创建 journal、webhook、mail 后端;桌面后端依赖会话代理,由 lastore-daemon 自行实现
+func NewBackend(cfg BackendConfig) (Backend, error) {
+	switch cfg.Type {
+	case JournalBackend:
+		return newJournalBackend(cfg), nil
+	case WebhookBackend:
+		return newWebhookBackend(cfg)
+	case MailBackend:
+		return newMailBackend(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported notify backend type: %q", cfg.Type)
+	}
+}
+
+type backendEntry struct {
+	cfg     BackendConfig
+	backend Backend
+}
+
+type Dispatcher struct {
+	mu      sync.RWMutex
+	entries []*backendEntry
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+func (d *Dispatcher) Register(cfg BackendConfig, b Backend) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, &backendEntry{cfg: cfg, backend: b})
+}
+
+// Reset 清空已注册的后端,重新加载配置前调用
+func (d *Dispatcher) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = nil
+}
+
+// Dispatch 将事件并发发送到所有匹配的后端,等待全部完成后返回
+func (d *Dispatcher) Dispatch(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	d.mu.RLock()
+	entries := make([]*backendEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		if e.cfg.accept(ev.Type) {
+			entries = append(entries, e)
+		}
+	}
+	d.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		msg, err := e.cfg.render(&ev)
+		if err != nil {
+			logger.Warningf("render notify template for %v failed: %v", e.cfg.Name, err)
+			continue
+		}
+		wg.Add(1)
+		go func(e *backendEntry, msg *Message) {
+			defer wg.Done()
+			err := e.backend.Send(msg)
+			if err != nil {
+				logger.Warningf("send %v notify via %v failed: %v", msg.Type, e.cfg.Name, err)
+			}
+		}(e, msg)
+	}
+	wg.Wait()
+}
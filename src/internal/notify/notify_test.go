@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordBackend struct {
+	mu   sync.Mutex
+	msgs []*Message
+}
+
+func (b *recordBackend) Send(msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, msg)
+	return nil
+}
+
+func TestDispatcherFiltersEvents(t *testing.T) {
+	d := NewDispatcher()
+	all := &recordBackend{}
+	failedOnly := &recordBackend{}
+	disabled := &recordBackend{}
+	d.Register(BackendConfig{Name: "all"}, all)
+	d.Register(BackendConfig{Name: "failed", Events: []EventType{EventFailed}}, failedOnly)
+	d.Register(BackendConfig{Name: "disabled", Disabled: true}, disabled)
+
+	d.Dispatch(Event{Type: EventAvailable, Body: "a"})
+	d.Dispatch(Event{Type: EventFailed, Body: "b"})
+
+	assert.Len(t, all.msgs, 2)
+	require.Len(t, failedOnly.msgs, 1)
+	assert.Equal(t, "b", failedOnly.msgs[0].Text)
+	assert.False(t, failedOnly.msgs[0].Time.IsZero())
+	assert.Empty(t, disabled.msgs)
+}
+
+func TestLookupTemplateLocaleFallback(t *testing.T) {
+	cfg := BackendConfig{
+		Locale: "zh_CN.UTF-8",
+		Templates: map[string]map[EventType]TemplateText{
+			"zh":    {EventFailed: {Title: "zh"}},
+			"zh_CN": {EventAvailable: {Title: "zh_CN"}},
+			"":      {EventInstalled: {Title: "default"}},
+		},
+	}
+	tpl, ok := cfg.lookupTemplate(EventAvailable)
+	assert.True(t, ok)
+	assert.Equal(t, "zh_CN", tpl.Title)
+	tpl, ok = cfg.lookupTemplate(EventFailed)
+	assert.True(t, ok)
+	assert.Equal(t, "zh", tpl.Title)
+	tpl, ok = cfg.lookupTemplate(EventInstalled)
+	assert.True(t, ok)
+	assert.Equal(t, "default", tpl.Title)
+	_, ok = cfg.lookupTemplate(EventDownloaded)
+	assert.False(t, ok)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	cfg := BackendConfig{
+		Templates: map[string]map[EventType]TemplateText{
+			"": {EventFailed: {Title: "更新失败 {{.ErrType}}", Text: "{{.UpdateType}}: {{.Text}}"}},
+		},
+	}
+	msg, err := cfg.render(&Event{Type: EventFailed, Body: "detail", ErrType: "fetchFailed", UpdateType: "system_upgrade"})
+	require.NoError(t, err)
+	assert.Equal(t, "更新失败 fetchFailed", msg.Title)
+	assert.Equal(t, "system_upgrade: detail", msg.Text)
+
+	cfg.Templates[""][EventFailed] = TemplateText{Title: "{{.Bad"}
+	_, err = cfg.render(&Event{Type: EventFailed})
+	assert.Error(t, err)
+}
+
+func TestLoadBackendConfigs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20-mail.json"), []byte(`{"type":"mail","to":["root@localhost"],"events":["failed"]}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "10-hook.json"), []byte(`{"name":"helpdesk","type":"webhook","url":"http://127.0.0.1:9000/"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "30-bad.json"), []byte(`{`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte(`ignored`), 0644))
+
+	configs := LoadBackendConfigs(dir)
+	require.Len(t, configs, 2)
+	assert.Equal(t, "helpdesk", configs[0].Name)
+	assert.Equal(t, WebhookBackend, configs[0].Type)
+	assert.Equal(t, "20-mail", configs[1].Name)
+	assert.Equal(t, []EventType{EventFailed}, configs[1].Events)
+
+	assert.Nil(t, LoadBackendConfigs(filepath.Join(dir, "not-exist")))
+}
+
+func TestWebhookBackend(t *testing.T) {
+	var got webhookPayload
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Token")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	b, err := NewBackend(BackendConfig{Type: WebhookBackend, Url: server.URL, Headers: map[string]string{"X-Token": "abc"}})
+	require.NoError(t, err)
+	err = b.Send(&Message{
+		Event: &Event{Type: EventRebootRequired, UpdateType: "system_upgrade", Time: time.Unix(100, 0)},
+		Title: "reboot",
+		Text:  "please reboot",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	assert.Equal(t, EventRebootRequired, got.Event)
+	assert.Equal(t, "reboot", got.Title)
+	assert.Equal(t, "please reboot", got.Text)
+	assert.Equal(t, int64(100), got.Time)
+
+	_, err = NewBackend(BackendConfig{Type: WebhookBackend, Url: "file:///etc/passwd"})
+	assert.Error(t, err)
+}
+
+func TestEncodeJournalFields(t *testing.T) {
+	data := encodeJournalFields([][2]string{{"MESSAGE", "a"}, {"LASTORE_EVENT", "x\ny"}})
+	assert.Equal(t, "MESSAGE=a\nLASTORE_EVENT\n\x03\x00\x00\x00\x00\x00\x00\x00x\ny\n", string(data))
+}
+
+func TestMailCompose(t *testing.T) {
+	_, err := NewBackend(BackendConfig{Type: MailBackend})
+	assert.Error(t, err)
+	_, err = NewBackend(BackendConfig{Type: MailBackend, To: []string{"a@b\r\nBcc: c@d"}})
+	assert.Error(t, err)
+
+	b, err := newMailBackend(BackendConfig{Type: MailBackend, From: "lastore@localhost", To: []string{"root@localhost", "ops@localhost"}})
+	require.NoError(t, err)
+	content := string(b.compose(&Message{
+		Event:    &Event{Type: EventFailed, Time: time.Unix(0, 0)},
+		Hostname: "kiosk-01",
+		Title:    "failed",
+		Text:     "detail",
+	}))
+	assert.Contains(t, content, "To: root@localhost, ops@localhost\r\n")
+	assert.Contains(t, content, "Subject: [kiosk-01] failed\r\n")
+	assert.Contains(t, content, "X-Lastore-Event: failed\r\n")
+	assert.True(t, strings.HasSuffix(content, "\r\n\r\ndetail\r\n"))
+}
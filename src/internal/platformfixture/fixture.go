@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package platformfixture 录制客户端与更新平台之间的请求和响应,保存为目录中的 json 文件,
+// 并可以将录制的数据作为本地更新平台回放,用于离线复现现场问题和单元测试
+package platformfixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Exchange 一次请求和平台的响应,每个保存为一个文件
+type Exchange struct {
+	Seq      int
+	Time     int64
+	Request  Request
+	Response Response
+	Error    string `json:",omitempty"` // 访问平台失败的原因,回放时断开连接
+}
+
+// Request 客户端发送的请求
+type Request struct {
+	Method       string
+	Path         string
+	Query        string      `json:",omitempty"`
+	Header       http.Header `json:",omitempty"`
+	Body         Payload
+	Decrypted    json.RawMessage `json:",omitempty"` // 上报数据解密后的内容
+	DecryptError string          `json:",omitempty"`
+}
+
+// Response 平台返回的响应
+type Response struct {
+	StatusCode int
+	Header     http.Header `json:",omitempty"`
+	Body       Payload
+}
+
+// Payload 请求或响应的数据,json 数据原样保存便于阅读和修改,其他文本保存为字符串,二进制数据保存为 base64
+type Payload struct {
+	JSON   json.RawMessage `json:",omitempty"`
+	Text   string          `json:",omitempty"`
+	Binary []byte          `json:",omitempty"`
+}
+
+// NewPayload 按数据的内容选择保存方式
+func NewPayload(data []byte) Payload {
+	switch {
+	case len(data) == 0:
+		return Payload{}
+	case json.Valid(data):
+		return Payload{JSON: append(json.RawMessage(nil), data...)}
+	case utf8.Valid(data):
+		return Payload{Text: string(data)}
+	default:
+		return Payload{Binary: append([]byte(nil), data...)}
+	}
+}
+
+// Bytes 返回发送的数据
+func (p Payload) Bytes() []byte {
+	switch {
+	case len(p.JSON) != 0:
+		return p.JSON
+	case p.Text != "":
+		return []byte(p.Text)
+	default:
+		return p.Binary
+	}
+}
+
+// FileName 按序号、方法和路径生成文件名,按文件名排序即为录制顺序
+func (e *Exchange) FileName() string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(e.Request.Path, "/"))
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return fmt.Sprintf("%04d-%s-%s.json", e.Seq, strings.ToLower(e.Request.Method), name)
+}
+
+// Save 保存到目录中,数据包含设备信息,只有所有者可以读取
+func Save(dir string, e *Exchange) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, e.FileName()), append(content, '\n'), 0600)
+}
+
+// Load 按录制顺序读取目录中的所有请求
+func Load(dir string) ([]*Exchange, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var exchanges []*Exchange
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		e := &Exchange{}
+		if err := json.Unmarshal(content, e); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		exchanges = append(exchanges, e)
+	}
+	return exchanges, nil
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package platformfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayload(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte(`{"result":true}`), []byte("aGVsbG8="), {0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}} {
+		p := NewPayload(data)
+		content, err := json.Marshal(p)
+		require.NoError(t, err)
+		var decoded Payload
+		require.NoError(t, json.Unmarshal(content, &decoded))
+		assert.Equal(t, string(data), string(decoded.Bytes()))
+	}
+	assert.NotNil(t, NewPayload([]byte(`{"a":1}`)).JSON)
+	assert.Equal(t, "aGVsbG8=", NewPayload([]byte("aGVsbG8=")).Text)
+	assert.NotNil(t, NewPayload([]byte{0xff, 0xfe}).Binary)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	response, err := http.Get(url)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return response.StatusCode, string(body)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	version := 0
+	platform := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			assert.Equal(t, "token", r.Header.Get("X-Repo-Token"))
+			version++
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprintf(w, `{"result":true,"data":{"version":%d}}`, version)
+		case "/api/v1/process/events":
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, "ZW5jcnlwdGVk", string(body))
+			_, _ = io.WriteString(w, `{"result":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer platform.Close()
+
+	dir := t.TempDir()
+	var recorded []*Exchange
+	recorder, err := NewRecorder(RecordOptions{
+		Upstream: platform.URL,
+		Dir:      dir,
+		Decrypt: func(header http.Header, body []byte) ([]byte, error) {
+			if string(body) != "ZW5jcnlwdGVk" {
+				return nil, errors.New("bad body")
+			}
+			return []byte(`{"taskID":7}`), nil
+		},
+		OnExchange: func(e *Exchange) {
+			recorded = append(recorded, e)
+		},
+	})
+	require.NoError(t, err)
+	proxy := httptest.NewServer(recorder)
+
+	for i := 0; i < 2; i++ {
+		request, err := http.NewRequest(http.MethodGet, proxy.URL+"/api/v1/version", nil)
+		require.NoError(t, err)
+		request.Header.Set("X-Repo-Token", "token")
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		assert.Equal(t, `"v1"`, response.Header.Get("ETag"))
+		_ = response.Body.Close()
+	}
+	response, err := http.Post(proxy.URL+"/api/v1/process/events", "", strings.NewReader("ZW5jcnlwdGVk"))
+	require.NoError(t, err)
+	_ = response.Body.Close()
+	status, _ := get(t, proxy.URL+"/api/v1/cve/sync?synctime=2026-10-01")
+	assert.Equal(t, http.StatusNotFound, status)
+	proxy.Close()
+
+	// 平台无法访问
+	platform.Close()
+	proxy = httptest.NewServer(recorder)
+	status, _ = get(t, proxy.URL+"/api/v1/systemupdatelogs")
+	assert.Equal(t, http.StatusBadGateway, status)
+	proxy.Close()
+
+	require.Len(t, recorded, 5)
+	assert.Equal(t, "0003-post-api_v1_process_events.json", recorded[2].FileName())
+	assert.JSONEq(t, `{"taskID":7}`, string(recorded[2].Request.Decrypted))
+	assert.Equal(t, "synctime=2026-10-01", recorded[3].Request.Query)
+	assert.NotEmpty(t, recorded[4].Error)
+
+	exchanges, err := Load(dir)
+	require.NoError(t, err)
+	require.Len(t, exchanges, 5)
+	assert.Equal(t, "token", exchanges[0].Request.Header.Get("X-Repo-Token"))
+
+	var unmatched []string
+	replayer := NewReplayer(exchanges)
+	replayer.OnRequest = func(req *http.Request, body []byte, e *Exchange) {
+		if e == nil {
+			unmatched = append(unmatched, req.URL.Path)
+		}
+	}
+	server := httptest.NewServer(replayer)
+	defer server.Close()
+
+	// 按录制顺序返回,用完后重复最后一次
+	for _, want := range []int{1, 2, 2} {
+		status, body := get(t, server.URL+"/api/v1/version")
+		assert.Equal(t, http.StatusOK, status)
+		var msg struct {
+			Data struct{ Version int }
+		}
+		require.NoError(t, json.Unmarshal([]byte(body), &msg))
+		assert.Equal(t, want, msg.Data.Version)
+	}
+	response, err = http.Post(server.URL+"/api/v1/process/events", "", bytes.NewReader(nil))
+	require.NoError(t, err)
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	// 查询参数不同时使用同一路径的录制数据
+	status, _ = get(t, server.URL+"/api/v1/cve/sync")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// 录制时无法访问平台的请求断开连接
+	_, err = http.Get(server.URL + "/api/v1/systemupdatelogs")
+	assert.Error(t, err)
+
+	status, _ = get(t, server.URL+"/api/v1/package")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, []string{"/api/v1/package"}, unmatched)
+
+	// 继续录制时序号接着已有的数据
+	recorder, err = NewRecorder(RecordOptions{Upstream: platform.URL, Dir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, 6, recorder.nextSeq())
+}
@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package platformfixture
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 不转发的请求头和响应头
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Content-Length",
+}
+
+// RecordOptions 录制的配置
+type RecordOptions struct {
+	Upstream string       // 更新平台地址
+	Dir      string       // 保存录制数据的目录
+	Client   *http.Client // 访问平台使用的客户端,为空时使用 http.DefaultClient
+	// Decrypt 解密上报的数据,数据没有加密时返回 nil
+	Decrypt func(header http.Header, body []byte) ([]byte, error)
+	// OnExchange 每次请求保存后调用
+	OnExchange func(e *Exchange)
+}
+
+// Recorder 将请求转发到更新平台并保存请求和响应,客户端将平台地址配置为 Recorder 的地址即可录制
+type Recorder struct {
+	opts RecordOptions
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder 创建 Recorder,目录中已有录制数据时继续录制
+func NewRecorder(opts RecordOptions) (*Recorder, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	exchanges, err := Load(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{opts: opts}
+	for _, e := range exchanges {
+		if e.Seq > r.seq {
+			r.seq = e.Seq
+		}
+	}
+	return r, nil
+}
+
+func (r *Recorder) nextSeq() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	return r.seq
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e := &Exchange{
+		Seq:  r.nextSeq(),
+		Time: time.Now().Unix(),
+		Request: Request{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  req.URL.RawQuery,
+			Header: cloneHeader(req.Header),
+			Body:   NewPayload(body),
+		},
+	}
+	if r.opts.Decrypt != nil && len(body) != 0 {
+		plain, err := r.opts.Decrypt(req.Header, body)
+		if err != nil {
+			e.Request.DecryptError = err.Error()
+		} else if plain != nil {
+			e.Request.Decrypted = NewPayload(plain).JSON
+			if e.Request.Decrypted == nil {
+				e.Request.DecryptError = "decrypted data is not json"
+			}
+		}
+	}
+
+	respBody, err := r.forward(req, body, e)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if saveErr := Save(r.opts.Dir, e); saveErr != nil {
+		http.Error(w, saveErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.opts.OnExchange != nil {
+		r.opts.OnExchange(e)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for key, values := range e.Response.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(e.Response.StatusCode)
+	_, _ = w.Write(respBody)
+}
+
+// forward 将请求发送到平台,返回响应的原始数据
+func (r *Recorder) forward(req *http.Request, body []byte, e *Exchange) ([]byte, error) {
+	url := strings.TrimSuffix(r.opts.Upstream, "/") + req.URL.Path
+	if req.URL.RawQuery != "" {
+		url += "?" + req.URL.RawQuery
+	}
+	upstreamReq, err := http.NewRequestWithContext(req.Context(), req.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	upstreamReq.Header = cloneHeader(req.Header)
+	// 由 Client 处理压缩,保存解压后的数据
+	upstreamReq.Header.Del("Accept-Encoding")
+	response, err := r.opts.Client.Do(upstreamReq)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	respBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	e.Response = Response{
+		StatusCode: response.StatusCode,
+		Header:     cloneHeader(response.Header),
+		Body:       NewPayload(respBody),
+	}
+	return respBody, nil
+}
+
+func cloneHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, key := range hopHeaders {
+		header.Del(key)
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return header
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package platformfixture
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Replayer 作为本地更新平台返回录制的响应。
+// 按方法和路径匹配请求,查询参数相同的优先;同一请求录制了多次时按录制顺序返回,用完后重复最后一次
+type Replayer struct {
+	// OnRequest 每次请求时调用,没有匹配的录制数据时 e 为 nil
+	OnRequest func(req *http.Request, body []byte, e *Exchange)
+
+	exchanges map[string][]*Exchange
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// NewReplayer 使用录制的数据创建 Replayer
+func NewReplayer(exchanges []*Exchange) *Replayer {
+	r := &Replayer{
+		exchanges: make(map[string][]*Exchange),
+		served:    make(map[string]int),
+	}
+	for _, e := range exchanges {
+		path := e.Request.Method + " " + e.Request.Path
+		query := path + "?" + e.Request.Query
+		r.exchanges[path] = append(r.exchanges[path], e)
+		r.exchanges[query] = append(r.exchanges[query], e)
+	}
+	return r
+}
+
+// LoadReplayer 读取目录中的录制数据创建 Replayer
+func LoadReplayer(dir string) (*Replayer, error) {
+	exchanges, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(exchanges), nil
+}
+
+// match 返回请求对应的录制数据
+func (r *Replayer) match(req *http.Request) *Exchange {
+	path := req.Method + " " + req.URL.Path
+	key := path + "?" + req.URL.RawQuery
+	if _, ok := r.exchanges[key]; !ok {
+		key = path
+	}
+	exchanges := r.exchanges[key]
+	if len(exchanges) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.served[key]
+	if i < len(exchanges)-1 {
+		r.served[key] = i + 1
+	}
+	return exchanges[i]
+}
+
+func (r *Replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	e := r.match(req)
+	if r.OnRequest != nil {
+		r.OnRequest(req, body, e)
+	}
+	if e == nil {
+		http.Error(w, "no recorded response for "+req.Method+" "+req.URL.Path, http.StatusNotFound)
+		return
+	}
+	if e.Error != "" {
+		// 录制时无法访问平台,断开连接使客户端得到网络错误
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+		http.Error(w, e.Error, http.StatusBadGateway)
+		return
+	}
+	for key, values := range e.Response.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(e.Response.StatusCode)
+	_, _ = w.Write(e.Response.Body.Bytes())
+}
@@ -0,0 +1,559 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package platformhttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultEndpointStateFile 端点健康状态和任务绑定的持久化文件,lastore-daemon 和 lastore-tools 共用
+	DefaultEndpointStateFile = "/var/lib/lastore/platform-endpoints.json"
+
+	minEndpointCooldown = 30 * time.Second
+	maxEndpointCooldown = 10 * time.Minute
+	probeTimeout        = 3 * time.Second
+	maxStickyTasks      = 100
+)
+
+// Endpoint 更新平台的一个地址
+type Endpoint struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority"` // 数值小的优先,没有配置时为在列表中的位置
+	Weight   int    `json:"weight"`   // 同一优先级的多个地址按权重分配终端,没有配置时为 1
+}
+
+/*
+ParseEndpoints 解析 dconfig 中配置的地址列表,按列表顺序为主用、备用:
+
+	[{"url":"https://platform-a.example.com"},{"url":"https://platform-b.example.com"}]
+
+同一优先级的地址按权重分配终端,每台终端固定选择其中一个:
+
+	[{"url":"https://a","priority":0,"weight":3},{"url":"https://b","priority":0,"weight":1},{"url":"https://backup","priority":1}]
+*/
+func ParseEndpoints(value string) ([]Endpoint, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	var raw []struct {
+		URL      string `json:"url"`
+		Priority *int   `json:"priority"`
+		Weight   *int   `json:"weight"`
+	}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("invalid platform endpoints: %w", err)
+	}
+	endpoints := make([]Endpoint, 0, len(raw))
+	seen := make(map[string]bool)
+	for i, r := range raw {
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid platform endpoint %q", r.URL)
+		}
+		ep := Endpoint{URL: strings.TrimSuffix(r.URL, "/"), Priority: i, Weight: 1}
+		if seen[ep.URL] {
+			return nil, fmt.Errorf("duplicate platform endpoint %q", r.URL)
+		}
+		seen[ep.URL] = true
+		if r.Priority != nil {
+			ep.Priority = *r.Priority
+		}
+		if r.Weight != nil {
+			if *r.Weight <= 0 {
+				return nil, fmt.Errorf("invalid weight %d of platform endpoint %q", *r.Weight, r.URL)
+			}
+			ep.Weight = *r.Weight
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+type endpointHealth struct {
+	Failures    int    `json:",omitempty"` // 连续失败次数
+	DownUntil   int64  `json:",omitempty"` // 在该时间之前不再选择该地址
+	LastError   string `json:",omitempty"`
+	LastSuccess int64  `json:",omitempty"`
+}
+
+type stickyTask struct {
+	URL  string
+	Time int64
+}
+
+type endpointState struct {
+	Health map[string]*endpointHealth
+	Tasks  map[string]stickyTask // 任务 ID 到地址的绑定,同一次更新的上报发送到同一个地址
+}
+
+// EndpointStatus 一个地址的状态
+type EndpointStatus struct {
+	Endpoint
+	Healthy     bool
+	Failures    int    `json:",omitempty"`
+	DownUntil   int64  `json:",omitempty"`
+	LastError   string `json:",omitempty"`
+	LastSuccess int64  `json:",omitempty"`
+	Tasks       []int  `json:",omitempty"` // 绑定到该地址的任务
+}
+
+// EndpointsStatus 所有地址的状态,Active 为当前使用的地址,Reason 为选择该地址的原因
+type EndpointsStatus struct {
+	Active    string
+	Reason    string
+	Endpoints []EndpointStatus
+}
+
+// Endpoints 在多个更新平台地址之间选择和故障切换:
+// 按优先级和权重排序,跳过不可用的地址;不可用的地址冷却期结束时在后台探测,探测失败时继续冷却;
+// 同一任务的请求固定发送到同一个地址,该地址不可用时才切换
+type Endpoints struct {
+	home      string // 构造请求使用的地址,请求发送前替换为选择的地址
+	endpoints []Endpoint
+	stateFile string
+	probe     func(ctx context.Context, endpoint string) error
+	now       func() time.Time
+
+	mu         sync.Mutex
+	state      endpointState
+	probeTimer *time.Timer // 在最早的冷却期结束时探测
+}
+
+// NewEndpoints 创建地址列表,home 为构造请求使用的地址,clientKey 用于在同一优先级的地址中固定选择一个,
+// stateFile 为空时不持久化状态
+func NewEndpoints(endpoints []Endpoint, home, clientKey, stateFile string) *Endpoints {
+	e := &Endpoints{
+		home:      strings.TrimSuffix(home, "/"),
+		endpoints: rankEndpoints(endpoints, clientKey),
+		stateFile: stateFile,
+		now:       time.Now,
+		state: endpointState{
+			Health: make(map[string]*endpointHealth),
+			Tasks:  make(map[string]stickyTask),
+		},
+	}
+	e.probe = e.defaultProbe(http.DefaultTransport)
+	if e.home == "" && len(e.endpoints) != 0 {
+		e.home = e.endpoints[0].URL
+	}
+	e.load()
+	e.mu.Lock()
+	e.scheduleProbe()
+	e.mu.Unlock()
+	return e
+}
+
+// rankEndpoints 按优先级排序,同一优先级按加权随机排序,随机数由 clientKey 和地址决定,同一终端的顺序固定
+func rankEndpoints(endpoints []Endpoint, clientKey string) []Endpoint {
+	ranked := append([]Endpoint(nil), endpoints...)
+	score := func(ep Endpoint) float64 {
+		sum := sha256.Sum256([]byte(clientKey + "\x00" + ep.URL))
+		// 取 (0,1) 之间的值,加权 rendezvous hash
+		x := (float64(binary.BigEndian.Uint64(sum[:8])>>11) + 0.5) / float64(uint64(1)<<53)
+		return -float64(ep.Weight) / math.Log(x)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Priority != ranked[j].Priority {
+			return ranked[i].Priority < ranked[j].Priority
+		}
+		return score(ranked[i]) > score(ranked[j])
+	})
+	return ranked
+}
+
+// Home 返回构造请求使用的地址
+func (e *Endpoints) Home() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.home
+}
+
+// SetHome 修改构造请求使用的地址
+func (e *Endpoints) SetHome(home string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.home = strings.TrimSuffix(home, "/")
+}
+
+func (e *Endpoints) load() {
+	if e.stateFile == "" {
+		return
+	}
+	content, err := os.ReadFile(e.stateFile)
+	if err != nil {
+		return
+	}
+	var state endpointState
+	if json.Unmarshal(content, &state) != nil {
+		return
+	}
+	for _, ep := range e.endpoints {
+		if h, ok := state.Health[ep.URL]; ok && h != nil {
+			e.state.Health[ep.URL] = h
+		}
+	}
+	for id, task := range state.Tasks {
+		if e.find(task.URL) != nil {
+			e.state.Tasks[id] = task
+		}
+	}
+}
+
+// save 调用时需要持有锁
+func (e *Endpoints) save() {
+	if e.stateFile == "" {
+		return
+	}
+	content, err := json.Marshal(e.state)
+	if err != nil {
+		return
+	}
+	tmp := e.stateFile + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(e.stateFile), 0755); err != nil {
+		return
+	}
+	// 非 root 运行时没有写权限,只在内存中记录
+	if os.WriteFile(tmp, content, 0644) != nil {
+		return
+	}
+	if os.Rename(tmp, e.stateFile) != nil {
+		_ = os.Remove(tmp)
+	}
+}
+
+func (e *Endpoints) find(rawURL string) *Endpoint {
+	for i := range e.endpoints {
+		if e.endpoints[i].URL == rawURL {
+			return &e.endpoints[i]
+		}
+	}
+	return nil
+}
+
+// healthy 调用时需要持有锁
+func (e *Endpoints) healthy(ep string, now time.Time) bool {
+	h := e.state.Health[ep]
+	return h == nil || now.Unix() >= h.DownUntil
+}
+
+// candidates 返回本次请求依次尝试的地址和选择第一个地址的原因
+func (e *Endpoints) candidates(taskID int) ([]string, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	var up, down []string
+	reason := ""
+	if taskID > 0 {
+		if task, ok := e.state.Tasks[strconv.Itoa(taskID)]; ok {
+			if e.healthy(task.URL, now) {
+				up = append(up, task.URL)
+				reason = fmt.Sprintf("task %d is bound to this endpoint", taskID)
+			} else {
+				reason = fmt.Sprintf("task %d failover: %s is unavailable", taskID, task.URL)
+			}
+		}
+	}
+	for i, ep := range e.endpoints {
+		if len(up) != 0 && up[0] == ep.URL {
+			continue
+		}
+		if !e.healthy(ep.URL, now) {
+			down = append(down, ep.URL)
+			continue
+		}
+		if reason == "" {
+			if i == 0 {
+				reason = "preferred endpoint"
+			} else {
+				reason = fmt.Sprintf("failover: %s is unavailable: %s", e.endpoints[0].URL, e.state.Health[e.endpoints[0].URL].LastError)
+			}
+		}
+		up = append(up, ep.URL)
+	}
+	if len(up) == 0 {
+		reason = "all endpoints are unavailable"
+	}
+	// 所有地址都不可用时仍然按顺序尝试
+	return append(up, down...), reason
+}
+
+// scheduleProbe 在优先级高于当前可用地址的地址中,最早的冷却期结束时在后台探测,调用时需要持有锁
+func (e *Endpoints) scheduleProbe() {
+	if e.probeTimer != nil {
+		e.probeTimer.Stop()
+		e.probeTimer = nil
+	}
+	var next int64
+	for _, ep := range e.endpoints {
+		h := e.state.Health[ep.URL]
+		if h == nil || h.Failures == 0 {
+			break
+		}
+		if next == 0 || h.DownUntil < next {
+			next = h.DownUntil
+		}
+	}
+	if next == 0 {
+		return
+	}
+	d := time.Unix(next, 0).Sub(e.now())
+	if d < 0 {
+		d = 0
+	}
+	e.probeTimer = time.AfterFunc(d, func() {
+		e.probeRecovered(context.Background())
+	})
+}
+
+// probeRecovered 探测冷却期已结束、优先级高于当前可用地址的地址,恢复后重新使用
+func (e *Endpoints) probeRecovered(ctx context.Context) {
+	e.mu.Lock()
+	now := e.now()
+	var targets []string
+	for _, ep := range e.endpoints {
+		h := e.state.Health[ep.URL]
+		if h == nil || h.Failures == 0 {
+			break
+		}
+		if now.Unix() >= h.DownUntil {
+			targets = append(targets, ep.URL)
+		}
+	}
+	e.mu.Unlock()
+	for _, ep := range targets {
+		if err := e.probe(ctx, ep); err != nil {
+			e.markFailure(ep, err)
+			continue
+		}
+		e.markSuccess(ep, 0)
+		return
+	}
+}
+
+func (e *Endpoints) defaultProbe(base http.RoundTripper) func(ctx context.Context, endpoint string) error {
+	return func(ctx context.Context, endpoint string) error {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("probe %s: response code=%d", endpoint, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+func (e *Endpoints) markFailure(ep string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	h := e.state.Health[ep]
+	if h == nil {
+		h = &endpointHealth{}
+		e.state.Health[ep] = h
+	}
+	h.Failures++
+	cooldown := minEndpointCooldown << (h.Failures - 1)
+	if h.Failures > 6 || cooldown > maxEndpointCooldown {
+		cooldown = maxEndpointCooldown
+	}
+	h.DownUntil = e.now().Add(cooldown).Unix()
+	h.LastError = err.Error()
+	e.save()
+	e.scheduleProbe()
+}
+
+func (e *Endpoints) markSuccess(ep string, taskID int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	changed := false
+	if h := e.state.Health[ep]; h == nil || h.Failures != 0 {
+		e.state.Health[ep] = &endpointHealth{LastSuccess: now.Unix()}
+		changed = true
+	} else if now.Unix()-h.LastSuccess >= 60 {
+		h.LastSuccess = now.Unix()
+		changed = true
+	}
+	if taskID > 0 {
+		id := strconv.Itoa(taskID)
+		if e.state.Tasks[id].URL != ep {
+			e.state.Tasks[id] = stickyTask{URL: ep, Time: now.Unix()}
+			e.trimTasks()
+			changed = true
+		}
+	}
+	if changed {
+		e.save()
+		e.scheduleProbe()
+	}
+}
+
+// trimTasks 只保留最近的 maxStickyTasks 个任务绑定,调用时需要持有锁
+func (e *Endpoints) trimTasks() {
+	if len(e.state.Tasks) <= maxStickyTasks {
+		return
+	}
+	ids := make([]string, 0, len(e.state.Tasks))
+	for id := range e.state.Tasks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return e.state.Tasks[ids[i]].Time < e.state.Tasks[ids[j]].Time
+	})
+	for _, id := range ids[:len(ids)-maxStickyTasks] {
+		delete(e.state.Tasks, id)
+	}
+}
+
+// Status 返回所有地址的状态
+func (e *Endpoints) Status() EndpointsStatus {
+	candidates, reason := e.candidates(0)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	status := EndpointsStatus{Reason: reason}
+	if len(candidates) != 0 {
+		status.Active = candidates[0]
+	}
+	tasks := make(map[string][]int)
+	for id, task := range e.state.Tasks {
+		if n, err := strconv.Atoi(id); err == nil {
+			tasks[task.URL] = append(tasks[task.URL], n)
+		}
+	}
+	for _, ep := range e.endpoints {
+		s := EndpointStatus{Endpoint: ep, Healthy: e.healthy(ep.URL, now), Tasks: tasks[ep.URL]}
+		sort.Ints(s.Tasks)
+		if h := e.state.Health[ep.URL]; h != nil {
+			s.Failures = h.Failures
+			s.DownUntil = h.DownUntil
+			s.LastError = h.LastError
+			s.LastSuccess = h.LastSuccess
+		}
+		status.Endpoints = append(status.Endpoints, s)
+	}
+	return status
+}
+
+// rewrite 把 home 或其他地址构造的请求地址替换为 endpoint,没有匹配的地址时返回 false
+func (e *Endpoints) rewrite(u *url.URL, endpoint string) (*url.URL, bool) {
+	bases := []string{e.Home()}
+	for _, ep := range e.endpoints {
+		bases = append(bases, ep.URL)
+	}
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, false
+	}
+	for _, base := range bases {
+		b, err := url.Parse(base)
+		if err != nil || b.Scheme != u.Scheme || b.Host != u.Host {
+			continue
+		}
+		rewritten := *u
+		rewritten.Scheme = target.Scheme
+		rewritten.Host = target.Host
+		rewritten.User = target.User
+		// 不在 base 路径下的请求(如 p2p-boot)只替换主机
+		basePath := strings.TrimSuffix(b.Path, "/")
+		if basePath == "" || u.Path == basePath || strings.HasPrefix(u.Path, basePath+"/") {
+			rewritten.Path = strings.TrimSuffix(target.Path, "/") + strings.TrimPrefix(u.Path, basePath)
+		}
+		rewritten.RawPath = ""
+		return &rewritten, true
+	}
+	return nil, false
+}
+
+func failoverNeeded(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// roundTrip 依次向候选地址发送请求,地址不可用时标记失败并切换到下一个地址
+func (e *Endpoints) roundTrip(req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if _, ok := e.rewrite(req.URL, e.Home()); !ok {
+		return do(req)
+	}
+	taskID := TaskIDFrom(req.Context())
+	candidates, _ := e.candidates(taskID)
+	if req.GetBody == nil && req.Body != nil && req.Body != http.NoBody {
+		// 请求体无法重新读取,不能切换地址
+		candidates = candidates[:1]
+	}
+	var resp *http.Response
+	var err error
+	for i, ep := range candidates {
+		r := req.Clone(req.Context())
+		r.URL, _ = e.rewrite(req.URL, ep)
+		r.Host = ""
+		if i > 0 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err = do(r)
+		if !failoverNeeded(resp, err) {
+			if err == nil {
+				e.markSuccess(ep, taskID)
+			}
+			return resp, err
+		}
+		cause := err
+		if cause == nil {
+			cause = fmt.Errorf("response code=%d", resp.StatusCode)
+		}
+		e.markFailure(ep, cause)
+		if i < len(candidates)-1 && resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+type taskIDKey struct{}
+
+// WithTaskID 在请求的 context 中记录任务 ID,同一任务的请求发送到同一个地址,小于等于 0 时不绑定
+func WithTaskID(ctx context.Context, taskID int) context.Context {
+	return context.WithValue(ctx, taskIDKey{}, taskID)
+}
+
+// TaskIDFrom 返回 WithTaskID 记录的任务 ID,没有记录时返回 0
+func TaskIDFrom(ctx context.Context) int {
+	taskID, _ := ctx.Value(taskIDKey{}).(int)
+	return taskID
+}
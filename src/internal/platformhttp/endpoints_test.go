@@ -0,0 +1,273 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package platformhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	endpoints, err := ParseEndpoints(`[{"url":"https://a.example.com/api/"},{"url":"https://b.example.com","weight":3},{"url":"http://c","priority":0}]`)
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{URL: "https://a.example.com/api", Priority: 0, Weight: 1},
+		{URL: "https://b.example.com", Priority: 1, Weight: 3},
+		{URL: "http://c", Priority: 0, Weight: 1},
+	}, endpoints)
+
+	endpoints, err = ParseEndpoints(" ")
+	assert.NoError(t, err)
+	assert.Empty(t, endpoints)
+
+	for _, value := range []string{
+		`{"url":"https://a"}`,
+		`[{"url":"ftp://a"}]`,
+		`[{"url":"https://a"},{"url":"https://a/"}]`,
+		`[{"url":"https://a","weight":0}]`,
+	} {
+		_, err := ParseEndpoints(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRankEndpoints(t *testing.T) {
+	endpoints := []Endpoint{
+		{URL: "https://backup", Priority: 1, Weight: 1},
+		{URL: "https://a", Priority: 0, Weight: 3},
+		{URL: "https://b", Priority: 0, Weight: 1},
+	}
+	count := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		ranked := rankEndpoints(endpoints, fmt.Sprintf("machine-%d", i))
+		assert.Equal(t, "https://backup", ranked[2].URL)
+		count[ranked[0].URL]++
+	}
+	// 权重 3:1
+	assert.InDelta(t, 0.75, float64(count["https://a"])/2000, 0.05)
+
+	// 同一终端的顺序固定
+	assert.Equal(t, rankEndpoints(endpoints, "machine"), rankEndpoints(endpoints, "machine"))
+}
+
+type endpointServer struct {
+	server *httptest.Server
+	hits   int32
+	status int32
+}
+
+func newEndpointServer(t *testing.T) *endpointServer {
+	s := &endpointServer{status: http.StatusOK}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+			return
+		}
+		atomic.AddInt32(&s.hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func newEndpointTransport(t *testing.T, endpoints *Endpoints) *Transport {
+	tr, err := NewTransport(Options{
+		Retries:   -1,
+		Endpoints: endpoints,
+		Proxy: func(*http.Request) (*url.URL, error) {
+			return nil, nil
+		},
+	})
+	require.NoError(t, err)
+	return tr
+}
+
+func TestEndpointsFailover(t *testing.T) {
+	primary := newEndpointServer(t)
+	backup := newEndpointServer(t)
+	stateFile := filepath.Join(t.TempDir(), "platform-endpoints.json")
+	endpoints := NewEndpoints([]Endpoint{
+		{URL: primary.server.URL + "/api", Priority: 0, Weight: 1},
+		{URL: backup.server.URL + "/api", Priority: 1, Weight: 1},
+	}, "https://platform.example.com/api", "machine", stateFile)
+	now := time.Unix(1700000000, 0)
+	endpoints.now = func() time.Time { return now }
+	client := newEndpointTransport(t, endpoints).Client(time.Second)
+
+	get := func(path string) string {
+		resp, err := client.Get("https://platform.example.com" + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		buf := make([]byte, 128)
+		n, _ := resp.Body.Read(buf)
+		return string(buf[:n])
+	}
+
+	// 请求地址替换为主用地址
+	assert.Equal(t, "/api/api/v1/version", get("/api/api/v1/version"))
+	assert.Equal(t, int32(1), primary.hits)
+	assert.Equal(t, "preferred endpoint", endpoints.Status().Reason)
+
+	// 主用地址不可用时切换到备用地址
+	atomic.StoreInt32(&primary.status, http.StatusServiceUnavailable)
+	get("/api/api/v1/version")
+	assert.Equal(t, int32(2), primary.hits)
+	assert.Equal(t, int32(1), backup.hits)
+	status := endpoints.Status()
+	assert.Equal(t, backup.server.URL+"/api", status.Active)
+	assert.Contains(t, status.Reason, "failover")
+	assert.False(t, status.Endpoints[0].Healthy)
+
+	// 冷却期内直接使用备用地址
+	get("/api/api/v1/version")
+	assert.Equal(t, int32(2), primary.hits)
+	assert.Equal(t, int32(2), backup.hits)
+
+	// 冷却期结束后探测,恢复后切回主用地址
+	atomic.StoreInt32(&primary.status, http.StatusOK)
+	now = now.Add(minEndpointCooldown)
+	get("/api/api/v1/version")
+	assert.Equal(t, int32(3), primary.hits)
+	assert.Equal(t, "preferred endpoint", endpoints.Status().Reason)
+
+	// 状态持久化
+	reloaded := NewEndpoints(endpoints.endpoints, "", "machine", stateFile)
+	assert.NotZero(t, reloaded.Status().Endpoints[0].LastSuccess)
+}
+
+func TestEndpointsStickyTask(t *testing.T) {
+	a := newEndpointServer(t)
+	b := newEndpointServer(t)
+	endpoints := NewEndpoints([]Endpoint{
+		{URL: a.server.URL, Priority: 0, Weight: 1},
+		{URL: b.server.URL, Priority: 1, Weight: 1},
+	}, a.server.URL, "machine", "")
+	now := time.Unix(1700000000, 0)
+	endpoints.now = func() time.Time { return now }
+	tr := newEndpointTransport(t, endpoints)
+
+	post := func(taskID int) {
+		resp, err := tr.TaskClient(time.Second, taskID).Post(a.server.URL+"/api/v1/process/events", "text/plain", strings.NewReader("event"))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	// 任务 1 开始时主用地址不可用,绑定到备用地址
+	atomic.StoreInt32(&a.status, http.StatusBadGateway)
+	post(1)
+	assert.Equal(t, int32(1), b.hits)
+
+	// 主用地址恢复后,任务 1 的上报仍然发送到备用地址,新任务使用主用地址
+	atomic.StoreInt32(&a.status, http.StatusOK)
+	now = now.Add(maxEndpointCooldown)
+	post(1)
+	assert.Equal(t, int32(2), b.hits)
+	post(2)
+	assert.Equal(t, int32(2), a.hits)
+
+	status := endpoints.Status()
+	assert.Equal(t, []int{2}, status.Endpoints[0].Tasks)
+	assert.Equal(t, []int{1}, status.Endpoints[1].Tasks)
+
+	// 绑定的地址不可用时切换
+	atomic.StoreInt32(&b.status, http.StatusBadGateway)
+	post(1)
+	assert.Equal(t, int32(3), a.hits)
+	assert.Equal(t, []int{1, 2}, endpoints.Status().Endpoints[0].Tasks)
+}
+
+func TestEndpointsRewrite(t *testing.T) {
+	endpoints := NewEndpoints([]Endpoint{
+		{URL: "https://a.example.com/api", Weight: 1},
+		{URL: "http://b.example.com/platform", Priority: 1, Weight: 1},
+	}, "https://a.example.com/api", "", "")
+	for _, c := range []struct{ in, endpoint, want string }{
+		{"https://a.example.com/api/api/v1/version?x=1", "http://b.example.com/platform", "http://b.example.com/platform/api/v1/version?x=1"},
+		// p2p-boot 等不在 base 路径下的请求只替换主机
+		{"https://a.example.com/p2p-boot/api/v1/ipfs/config", "http://b.example.com/platform", "http://b.example.com/p2p-boot/api/v1/ipfs/config"},
+		{"http://b.example.com/platform/api/v1/version", "https://a.example.com/api", "https://a.example.com/api/api/v1/version"},
+	} {
+		u, err := url.Parse(c.in)
+		require.NoError(t, err)
+		got, ok := endpoints.rewrite(u, c.endpoint)
+		require.True(t, ok, c.in)
+		assert.Equal(t, c.want, got.String())
+	}
+	u, _ := url.Parse("https://mirrors.example.com/dists/InRelease")
+	_, ok := endpoints.rewrite(u, "https://a.example.com/api")
+	assert.False(t, ok)
+}
+
+func TestEndpointsBackgroundProbe(t *testing.T) {
+	endpoints := NewEndpoints([]Endpoint{
+		{URL: "https://a", Weight: 1},
+		{URL: "https://b", Priority: 1, Weight: 1},
+	}, "", "", "")
+	probed := make(chan string, 4)
+	probeErr := errors.New("still down")
+	endpoints.probe = func(_ context.Context, ep string) error {
+		probed <- ep
+		return probeErr
+	}
+	now := time.Now()
+	endpoints.now = func() time.Time { return now }
+	endpoints.markFailure("https://a", errors.New("timeout"))
+
+	// 请求不等待探测
+	candidates, _ := endpoints.candidates(0)
+	assert.Equal(t, []string{"https://b", "https://a"}, candidates)
+	select {
+	case ep := <-probed:
+		t.Fatalf("unexpected probe %v during cooldown", ep)
+	default:
+	}
+
+	// 冷却期结束后在后台探测,探测失败时继续冷却
+	now = now.Add(minEndpointCooldown)
+	endpoints.mu.Lock()
+	endpoints.scheduleProbe()
+	endpoints.mu.Unlock()
+	select {
+	case ep := <-probed:
+		assert.Equal(t, "https://a", ep)
+	case <-time.After(5 * time.Second):
+		t.Fatal("probe is not scheduled")
+	}
+	require.Eventually(t, func() bool {
+		return endpoints.Status().Endpoints[0].Failures == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	endpoints.mu.Lock()
+	endpoints.probeTimer.Stop()
+	endpoints.mu.Unlock()
+}
+
+func TestEndpointsAllDown(t *testing.T) {
+	endpoints := NewEndpoints([]Endpoint{
+		{URL: "https://a", Weight: 1},
+		{URL: "https://b", Priority: 1, Weight: 1},
+	}, "", "", "")
+	endpoints.probe = func(context.Context, string) error {
+		return errors.New("probe failed")
+	}
+	endpoints.markFailure("https://a", errors.New("timeout"))
+	endpoints.markFailure("https://b", errors.New("timeout"))
+	candidates, reason := endpoints.candidates(0)
+	assert.Equal(t, []string{"https://a", "https://b"}, candidates)
+	assert.Equal(t, "all endpoints are unavailable", reason)
+}
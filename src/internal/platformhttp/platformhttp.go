@@ -0,0 +1,411 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package platformhttp 访问更新平台的公共 HTTP 传输层:
+// 遵循 apt 的 Acquire::http(s)::Proxy 和环境变量代理,支持自定义 CA 和客户端证书,
+// 幂等请求失败时重试,并记录每个请求的耗时和状态供调试查看
+package platformhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTraceFile 请求记录文件,lastore-daemon 和 lastore-tools 共用
+	DefaultTraceFile = "/var/cache/lastore/platform-requests.jsonl"
+
+	maxTraceSize    = 1 << 20 // 记录文件超过该大小时只保留最近的 keepTraceLines 条
+	keepTraceLines  = 1000
+	maxMemRecords   = 200
+	defaultRetries  = 2
+	defaultRetryGap = time.Second
+)
+
+// Options 传输层配置
+type Options struct {
+	CAFile    string // 追加到系统 CA 的 PEM 证书
+	CertFile  string // 客户端证书
+	KeyFile   string // 客户端证书私钥
+	Retries   int    // 幂等请求的重试次数,小于 0 时不重试,为 0 时使用默认值
+	RetryWait time.Duration
+	TraceFile string // 为空时只在内存中记录
+	// Proxy 为空时使用 apt 的代理配置,apt 没有配置时使用环境变量
+	Proxy func(*http.Request) (*url.URL, error)
+	// Endpoints 不为空时,发往更新平台的请求在多个地址之间选择和故障切换
+	Endpoints *Endpoints
+}
+
+// Record 一次请求的记录
+type Record struct {
+	Time     time.Time
+	Method   string
+	URL      string // 不包含查询参数
+	Status   int    `json:",omitempty"`
+	Duration int64  // 毫秒,包含重试
+	Attempts int
+	Error    string `json:",omitempty"`
+}
+
+// Transport 实现 http.RoundTripper,所有客户端共用底层连接池
+type Transport struct {
+	base      http.RoundTripper
+	retries   int
+	retryWait time.Duration
+	traceFile string
+	endpoints *Endpoints
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewTransport 按 opts 创建传输层,CA 或客户端证书无法加载时返回错误
+func NewTransport(opts Options) (*Transport, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = opts.Proxy
+	if base.Proxy == nil {
+		base.Proxy = AptProxy(LoadAptProxy())
+	}
+	if opts.CAFile != "" || opts.CertFile != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if opts.CAFile != "" {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			content, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(content) {
+				return nil, fmt.Errorf("%v: no certificate found", opts.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if opts.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		base.TLSClientConfig = tlsConfig
+	}
+	t := &Transport{
+		base:      base,
+		retries:   opts.Retries,
+		retryWait: opts.RetryWait,
+		traceFile: opts.TraceFile,
+		endpoints: opts.Endpoints,
+	}
+	if t.endpoints != nil {
+		// 探测使用相同的代理和证书配置
+		t.endpoints.probe = t.endpoints.defaultProbe(base)
+	}
+	if t.retries == 0 {
+		t.retries = defaultRetries
+	} else if t.retries < 0 {
+		t.retries = 0
+	}
+	if t.retryWait <= 0 {
+		t.retryWait = defaultRetryGap
+	}
+	return t, nil
+}
+
+// Client 返回使用该传输层的客户端,timeout 为单次请求的超时时间
+func (t *Transport) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: t,
+		Timeout:   timeout,
+	}
+}
+
+// TaskClient 返回发送 taskID 相关请求的客户端,同一任务的请求发送到同一个更新平台地址
+func (t *Transport) TaskClient(timeout time.Duration, taskID int) *http.Client {
+	return &http.Client{
+		Transport: taskTransport{t, taskID},
+		Timeout:   timeout,
+	}
+}
+
+type taskTransport struct {
+	t      *Transport
+	taskID int
+}
+
+func (tt taskTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return tt.t.RoundTrip(req.WithContext(WithTaskID(req.Context(), tt.taskID)))
+}
+
+// Endpoints 返回更新平台地址列表,没有配置多个地址时返回 nil
+func (t *Transport) Endpoints() *Endpoints {
+	return t.endpoints
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RoundTrip 发送请求,配置了多个更新平台地址时在地址之间故障切换
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.endpoints == nil {
+		return t.roundTrip(req)
+	}
+	return t.endpoints.roundTrip(req, t.roundTrip)
+}
+
+// roundTrip 发送请求,幂等请求遇到网络错误或 429、502、503、504 时按指数退避重试
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	retries := 0
+	if idempotent(req) {
+		retries = t.retries
+	}
+	var resp *http.Response
+	var err error
+	attempts := 0
+	r := req
+	for {
+		attempts++
+		resp, err = t.base.RoundTrip(r)
+		if attempts > retries || !shouldRetry(resp, err) {
+			break
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				err = bodyErr
+				resp = nil
+				break
+			}
+			// RoundTripper 不能修改调用者的请求,重试时使用请求的副本
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+		if waitErr := wait(req.Context(), t.retryWait<<(attempts-1)); waitErr != nil {
+			resp, err = nil, waitErr
+			break
+		}
+	}
+	record := Record{
+		Time:     start,
+		Method:   req.Method,
+		URL:      redactURL(req.URL),
+		Duration: time.Since(start).Milliseconds(),
+		Attempts: attempts,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Status = resp.StatusCode
+	}
+	t.record(record)
+	return resp, err
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	v := *u
+	v.RawQuery = ""
+	v.User = nil
+	v.Fragment = ""
+	return v.String()
+}
+
+func (t *Transport) record(r Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append(t.records, r)
+	if len(t.records) > maxMemRecords {
+		t.records = t.records[len(t.records)-maxMemRecords:]
+	}
+	if t.traceFile != "" {
+		// 非 root 运行时没有写权限,只在内存中记录
+		_ = appendTrace(t.traceFile, r)
+	}
+}
+
+// Records 返回本进程最近的请求记录
+func (t *Transport) Records() []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Record(nil), t.records...)
+}
+
+func appendTrace(path string, r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxTraceSize {
+		records, err := ReadTrace(path, keepTraceLines)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		for _, r := range records {
+			line, _ := json.Marshal(r)
+			buf.Write(append(line, '\n'))
+		}
+		return os.WriteFile(path, buf.Bytes(), 0600)
+	}
+	return nil
+}
+
+// ReadTrace 读取记录文件中最近的 n 条记录,n 小于等于 0 时返回全部
+func ReadTrace(path string, n int) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if n > 0 && len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return records, nil
+}
+
+// AptProxyConfig apt 的代理配置
+type AptProxyConfig struct {
+	HTTP  string
+	HTTPS string
+	Hosts map[string]string // <scheme>::<host> 到代理的映射,DIRECT 表示不使用代理
+}
+
+// LoadAptProxy 通过 apt-config 读取代理配置,失败时返回空配置
+func LoadAptProxy() *AptProxyConfig {
+	// #nosec G204
+	output, err := exec.Command("apt-config", "--format", "%f=%v%n", "dump", "Acquire").Output()
+	if err != nil {
+		return &AptProxyConfig{}
+	}
+	return parseAptProxy(string(output))
+}
+
+/*
+$ apt-config --format '%f=%v%n' dump Acquire
+Acquire::http::Proxy=http://proxy.example.com:3128/
+Acquire::http::Proxy::mirrors.example.com=DIRECT
+Acquire::https::Proxy=http://proxy.example.com:3128/
+*/
+func parseAptProxy(output string) *AptProxyConfig {
+	config := &AptProxyConfig{Hosts: make(map[string]string)}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || value == "" {
+			continue
+		}
+		for _, scheme := range []string{"http", "https"} {
+			prefix := "Acquire::" + scheme + "::Proxy"
+			switch {
+			case key == prefix:
+				if scheme == "http" {
+					config.HTTP = value
+				} else {
+					config.HTTPS = value
+				}
+			case strings.HasPrefix(key, prefix+"::"):
+				config.Hosts[scheme+"::"+strings.TrimPrefix(key, prefix+"::")] = value
+			}
+		}
+	}
+	return config
+}
+
+// AptProxy 按 apt 的规则选择代理:主机级配置优先,https 没有配置时使用 http 的配置,apt 没有配置时使用环境变量
+func AptProxy(config *AptProxyConfig) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		scheme := req.URL.Scheme
+		proxy, ok := config.Hosts[scheme+"::"+req.URL.Hostname()]
+		if !ok && scheme == "https" {
+			proxy, ok = config.Hosts["http::"+req.URL.Hostname()]
+		}
+		if !ok {
+			if scheme == "https" && config.HTTPS != "" {
+				proxy = config.HTTPS
+			} else {
+				proxy = config.HTTP
+			}
+		}
+		switch {
+		case strings.EqualFold(proxy, "DIRECT"):
+			return nil, nil
+		case proxy != "":
+			return url.Parse(proxy)
+		default:
+			return http.ProxyFromEnvironment(req)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package platformhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTransport(t *testing.T, traceFile string) *Transport {
+	tr, err := NewTransport(Options{
+		RetryWait: time.Millisecond,
+		TraceFile: traceFile,
+		Proxy: func(*http.Request) (*url.URL, error) {
+			return nil, nil
+		},
+	})
+	require.NoError(t, err)
+	return tr
+}
+
+func TestRetry(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	traceFile := filepath.Join(t.TempDir(), "requests.jsonl")
+	tr := newTestTransport(t, traceFile)
+	resp, err := tr.Client(time.Second).Get(server.URL + "/api/v1/version?token=secret")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), count)
+
+	records := tr.Records()
+	require.Len(t, records, 1)
+	assert.Equal(t, 3, records[0].Attempts)
+	assert.Equal(t, http.StatusOK, records[0].Status)
+	assert.Equal(t, server.URL+"/api/v1/version", records[0].URL)
+
+	// POST 不重试
+	atomic.StoreInt32(&count, 0)
+	resp, err = tr.Client(time.Second).Post(server.URL+"/api/v1/update/status", "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), count)
+
+	saved, err := ReadTrace(traceFile, 0)
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.Equal(t, http.MethodPost, saved[1].Method)
+	assert.Equal(t, 1, saved[1].Attempts)
+	saved, err = ReadTrace(traceFile, 1)
+	require.NoError(t, err)
+	assert.Len(t, saved, 1)
+}
+
+func TestRetryWithBody(t *testing.T) {
+	var count int32
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if atomic.AddInt32(&count, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tr := newTestTransport(t, "")
+	req, err := http.NewRequest(http.MethodGet, server.URL, strings.NewReader("body"))
+	require.NoError(t, err)
+	body := req.Body
+	resp, err := tr.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"body", "body"}, bodies)
+	// 不修改调用者的请求
+	assert.Equal(t, body, req.Body)
+
+	// 无法重新获取请求体时不重试
+	atomic.StoreInt32(&count, 0)
+	bodies = nil
+	req, err = http.NewRequest(http.MethodGet, server.URL, io.NopCloser(strings.NewReader("body")))
+	require.NoError(t, err)
+	resp, err = tr.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, []string{"body"}, bodies)
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := server.URL
+	server.Close()
+
+	tr := newTestTransport(t, "")
+	_, err := tr.Client(time.Second).Get(addr)
+	assert.Error(t, err)
+	records := tr.Records()
+	require.Len(t, records, 1)
+	assert.Equal(t, defaultRetries+1, records[0].Attempts)
+	assert.NotEmpty(t, records[0].Error)
+}
+
+func TestAptProxy(t *testing.T) {
+	config := parseAptProxy(`Acquire::Retries=3
+Acquire::http::Proxy=http://proxy.example.com:3128/
+Acquire::http::Proxy::mirrors.example.com=DIRECT
+Acquire::https::Proxy::secure.example.com=http://secure-proxy:8080
+Acquire::http::Timeout=30
+`)
+	assert.Equal(t, "http://proxy.example.com:3128/", config.HTTP)
+	assert.Equal(t, "", config.HTTPS)
+	assert.Equal(t, map[string]string{
+		"http::mirrors.example.com": "DIRECT",
+		"https::secure.example.com": "http://secure-proxy:8080",
+	}, config.Hosts)
+
+	proxy := AptProxy(config)
+	check := func(rawURL, want string) {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		require.NoError(t, err)
+		u, err := proxy(req)
+		require.NoError(t, err)
+		if want == "" {
+			assert.Nil(t, u, rawURL)
+		} else if assert.NotNil(t, u, rawURL) {
+			assert.Equal(t, want, u.String(), rawURL)
+		}
+	}
+	check("http://platform.example.com/api/v1/version", "http://proxy.example.com:3128/")
+	check("https://platform.example.com/api/v1/version", "http://proxy.example.com:3128/")
+	check("http://mirrors.example.com/", "")
+	check("https://mirrors.example.com/", "")
+	check("https://secure.example.com/", "http://secure-proxy:8080")
+
+	// apt 没有配置时使用环境变量
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+	proxy = AptProxy(&AptProxyConfig{})
+	check("https://platform.example.com/", "http://env-proxy:3128")
+}
+
+func TestTLSOptions(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewTransport(Options{CAFile: filepath.Join(dir, "missing.pem")})
+	assert.Error(t, err)
+
+	bad := filepath.Join(dir, "bad.pem")
+	require.NoError(t, os.WriteFile(bad, []byte("not a certificate"), 0644))
+	_, err = NewTransport(Options{CAFile: bad})
+	assert.Error(t, err)
+
+	_, err = NewTransport(Options{CertFile: bad, KeyFile: bad})
+	assert.Error(t, err)
+}
@@ -0,0 +1,473 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package policypush 与更新平台之间的长连接:平台通过 server-sent events 或长轮询通知策略、限速和 ipfs 配置的变化,
+// 连接断开时按抖动的退避时间重连,连接不可用期间由定时轮询获取变化
+package policypush
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 平台通知的事件类型
+const (
+	EventPolicy     = "policy"      // 更新策略变化
+	EventThrottling = "throttling"  // 限速配置变化
+	EventIPFSConfig = "ipfs-config" // ipfs 配置变化
+)
+
+// 连接方式
+const (
+	ModeSSE      = "sse"
+	ModeLongPoll = "long-poll"
+)
+
+const (
+	// DefaultStatusFile 连接状态文件,lastore-tools checkpolicy 据此判断是否需要轮询
+	DefaultStatusFile = "/run/lastore/policy-push.json"
+
+	// MaxHeartbeatAge 超过该时间没有收到数据时认为连接不可用
+	MaxHeartbeatAge = 3 * time.Minute
+
+	defaultIdleTimeout  = 2 * time.Minute // 超过该时间没有收到数据(包括心跳)时重连
+	defaultPollTimeout  = 5 * time.Minute // 等待响应头的最长时间,平台可以在此之前挂起长轮询请求
+	minBackoff          = 5 * time.Second
+	maxBackoff          = 10 * time.Minute
+	unsupportedBackoff  = time.Hour       // 平台不支持长连接时的重试间隔
+	minPollInterval     = 5 * time.Second // 长轮询两次请求的最小间隔
+	heartbeatSaveWindow = time.Minute     // 连接正常时写入心跳时间的最小间隔
+)
+
+var errUnsupported = errors.New("platform does not support push channel")
+
+// Event 平台通知的事件
+type Event struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Options 长连接配置
+type Options struct {
+	// URL 返回连接地址,每次连接时获取,为空时等待下次重试
+	URL func() string
+	// Client 发送请求的客户端,不能设置 Timeout,连接空闲超时由 IdleTimeout 控制
+	Client *http.Client
+	// Header 设置 token 等请求头
+	Header func(request *http.Request)
+	// Handlers 事件类型到处理函数,同一类型的处理串行执行,处理期间收到的多个事件合并为一次
+	Handlers   map[string]func()
+	StatusFile string // 为空时不保存连接状态
+	// IdleTimeout 收到响应后超过该时间没有收到数据时重连,为 0 时使用默认值
+	IdleTimeout time.Duration
+	// PollTimeout 超过该时间没有收到响应头时重连,平台挂起长轮询请求的时间需要小于该值,为 0 时使用默认值
+	PollTimeout time.Duration
+}
+
+// Status 长连接的状态
+type Status struct {
+	URL            string `json:",omitempty"`
+	Connected      bool
+	Mode           string `json:",omitempty"`
+	ConnectedSince int64  `json:",omitempty"`
+	Heartbeat      int64  `json:",omitempty"` // 连接正常时最近一次收到数据的时间
+	LastEventID    string `json:",omitempty"`
+	LastEventType  string `json:",omitempty"`
+	LastEvent      int64  `json:",omitempty"`
+	Reconnects     int
+	NextRetry      int64  `json:",omitempty"` // 连接断开时下次重连的时间
+	Error          string `json:",omitempty"` // 最近一次连接断开的原因
+}
+
+// Active 连接是否可用,不可用时需要轮询
+func (s Status) Active(now time.Time) bool {
+	return s.Connected && now.Sub(time.Unix(s.Heartbeat, 0)) <= MaxHeartbeatAge
+}
+
+// ReadStatus 读取状态文件
+func ReadStatus(path string) (Status, error) {
+	var status Status
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(content, &status)
+	return status, err
+}
+
+type handler struct {
+	fn      func()
+	pending chan struct{}
+}
+
+// Channel 与更新平台之间的长连接
+type Channel struct {
+	opts     Options
+	handlers map[string]*handler
+	now      func() time.Time
+	jitter   func(d time.Duration) time.Duration
+	backoff  time.Duration // 最小退避时间,测试时修改
+
+	mu        sync.Mutex
+	status    Status
+	savedAt   time.Time     // 最近一次写入状态文件的时间
+	attempts  int           // 连续失败的次数
+	retryHint time.Duration // 平台通过 retry 字段指定的重连时间
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// New 创建长连接,调用 Start 后开始连接
+func New(opts Options) *Channel {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = defaultPollTimeout
+	}
+	c := &Channel{
+		opts:     opts,
+		handlers: make(map[string]*handler),
+		now:      time.Now,
+		jitter:   equalJitter,
+		backoff:  minBackoff,
+	}
+	for name, fn := range opts.Handlers {
+		c.handlers[name] = &handler{fn: fn, pending: make(chan struct{}, 1)}
+	}
+	return c
+}
+
+// equalJitter 在 [d/2, d) 之间随机选择,避免大量终端同时重连
+func equalJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// Start 在后台连接平台,连接断开时自动重连
+func (c *Channel) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	for _, h := range c.handlers {
+		go h.run(ctx)
+	}
+	go c.run(ctx, c.done)
+}
+
+// Stop 断开连接并等待后台任务结束,状态文件标记为未连接
+func (c *Channel) Stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel = nil
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	c.update(func(s *Status) {
+		s.Connected = false
+		s.NextRetry = 0
+	}, true)
+}
+
+// Status 返回连接状态
+func (c *Channel) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (h *handler) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.pending:
+			h.fn()
+		}
+	}
+}
+
+func (c *Channel) dispatch(event Event) {
+	c.update(func(s *Status) {
+		if event.ID != "" {
+			s.LastEventID = event.ID
+		}
+		s.LastEventType = event.Type
+		s.LastEvent = c.now().Unix()
+	}, true)
+	h, ok := c.handlers[event.Type]
+	if !ok {
+		return
+	}
+	select {
+	case h.pending <- struct{}{}:
+	default:
+		// 已有未处理的同类事件,合并为一次处理
+	}
+}
+
+func (c *Channel) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		start := c.now()
+		err := c.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		delay := c.nextDelay(err, c.now().Sub(start))
+		// 长轮询正常结束时保持连接状态,立即发送下一次请求
+		if err != nil {
+			c.update(func(s *Status) {
+				s.Connected = false
+				s.Reconnects++
+				s.NextRetry = c.now().Add(delay).Unix()
+				s.Error = err.Error()
+			}, true)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// nextDelay 计算下次连接前的等待时间,err 为空表示长轮询正常结束
+func (c *Channel) nextDelay(err error, elapsed time.Duration) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.attempts = 0
+		if elapsed >= minPollInterval {
+			return 0
+		}
+		return minPollInterval - elapsed
+	}
+	if errors.Is(err, errUnsupported) {
+		return c.jitter(unsupportedBackoff)
+	}
+	delay := c.backoff << c.attempts
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	} else {
+		c.attempts++
+	}
+	if c.retryHint > delay {
+		delay = c.retryHint
+	}
+	return c.jitter(delay)
+}
+
+// connect 连接平台并处理事件直到连接断开
+func (c *Channel) connect(ctx context.Context) error {
+	url := ""
+	if c.opts.URL != nil {
+		url = c.opts.URL()
+	}
+	if url == "" {
+		return errors.New("push url is empty")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 平台可能挂起长轮询请求,等待响应头期间不使用 IdleTimeout,超过 PollTimeout 没有响应时断开连接
+	timer := time.AfterFunc(c.opts.PollTimeout, cancel)
+	defer timer.Stop()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "text/event-stream, application/json")
+	request.Header.Set("Cache-Control", "no-cache")
+	if c.opts.Header != nil {
+		c.opts.Header(request)
+	}
+	if id := c.Status().LastEventID; id != "" {
+		request.Header.Set("Last-Event-ID", id)
+	}
+	client := c.opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return c.idleError(ctx, c.opts.PollTimeout, err)
+	}
+	defer response.Body.Close()
+	// 收到响应后超过 IdleTimeout 没有收到数据时断开连接
+	touch := func() {
+		timer.Reset(c.opts.IdleTimeout)
+	}
+	touch()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		// 长轮询超时,没有变化
+		c.connected(url, ModeLongPoll)
+		return nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("%w: response code=%d", errUnsupported, response.StatusCode)
+	default:
+		return fmt.Errorf("response code=%d", response.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		c.connected(url, ModeSSE)
+		return c.idleError(ctx, c.opts.IdleTimeout, c.readSSE(response.Body, touch))
+	}
+
+	// 长轮询:平台有变化时返回事件列表
+	var result struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return c.idleError(ctx, c.opts.IdleTimeout, fmt.Errorf("invalid long-poll response: %w", err))
+	}
+	c.connected(url, ModeLongPoll)
+	for _, event := range result.Events {
+		c.dispatch(event)
+	}
+	return nil
+}
+
+// idleError 连接因空闲超时取消时返回更明确的错误
+func (c *Channel) idleError(ctx context.Context, timeout time.Duration, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return fmt.Errorf("no data received in %v: %w", timeout, err)
+	}
+	return err
+}
+
+func (c *Channel) connected(url, mode string) {
+	c.mu.Lock()
+	c.attempts = 0
+	c.mu.Unlock()
+	c.update(func(s *Status) {
+		now := c.now().Unix()
+		if !s.Connected || s.URL != url || s.Mode != mode {
+			s.ConnectedSince = now
+		}
+		s.URL = url
+		s.Connected = true
+		s.Mode = mode
+		s.Heartbeat = now
+		s.NextRetry = 0
+	}, true)
+}
+
+// heartbeat 收到数据时更新心跳时间,按 heartbeatSaveWindow 写入状态文件
+func (c *Channel) heartbeat() {
+	c.mu.Lock()
+	save := c.now().Sub(c.savedAt) >= heartbeatSaveWindow
+	c.mu.Unlock()
+	c.update(func(s *Status) {
+		s.Heartbeat = c.now().Unix()
+	}, save)
+}
+
+// readSSE 按 server-sent events 格式读取事件,注释行作为心跳
+func (c *Channel) readSSE(body io.Reader, touch func()) error {
+	reader := bufio.NewReader(body)
+	var event Event
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("stream closed by platform")
+			}
+			return err
+		}
+		touch()
+		c.heartbeat()
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(data) > 0 {
+				event.Data = json.RawMessage(strings.Join(data, "\n"))
+			}
+			if event.Type == "" && len(event.Data) > 0 {
+				// 没有 event 字段时,事件类型在 data 的 type 中
+				var inner Event
+				if json.Unmarshal(event.Data, &inner) == nil {
+					event.Type = inner.Type
+					if event.ID == "" {
+						event.ID = inner.ID
+					}
+				}
+			}
+			if event.Type != "" {
+				c.dispatch(event)
+			}
+			event, data = Event{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				c.mu.Lock()
+				c.retryHint = time.Duration(ms) * time.Millisecond
+				c.mu.Unlock()
+			}
+		}
+	}
+}
+
+// update 修改状态,save 为 true 时写入状态文件
+func (c *Channel) update(fn func(s *Status), save bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.status)
+	if !save || c.opts.StatusFile == "" {
+		return
+	}
+	c.savedAt = c.now()
+	content, err := json.Marshal(c.status)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(c.opts.StatusFile), 0755)
+	tmp := c.opts.StatusFile + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err == nil {
+		_ = os.Rename(tmp, c.opts.StatusFile)
+	}
+}
@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package policypush
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct {
+	n  int32
+	ch chan struct{}
+}
+
+func newCounter() *counter {
+	return &counter{ch: make(chan struct{}, 16)}
+}
+
+func (c *counter) handle() {
+	atomic.AddInt32(&c.n, 1)
+	c.ch <- struct{}{}
+}
+
+func (c *counter) wait(t *testing.T) {
+	select {
+	case <-c.ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not called")
+	}
+}
+
+func newTestChannel(url string, handlers map[string]func(), statusFile string) *Channel {
+	c := New(Options{
+		URL:         func() string { return url },
+		Handlers:    handlers,
+		StatusFile:  statusFile,
+		IdleTimeout: 500 * time.Millisecond,
+		Header: func(request *http.Request) {
+			request.Header.Set("X-Repo-Token", "token")
+		},
+	})
+	c.backoff = 10 * time.Millisecond
+	c.jitter = func(d time.Duration) time.Duration { return d }
+	return c
+}
+
+func TestChannelSSE(t *testing.T) {
+	var mu sync.Mutex
+	var lastEventIDs []string
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("X-Repo-Token"))
+		mu.Lock()
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&connections, 1) == 1 {
+			fmt.Fprint(w, ": ping\n\nid: 1\nevent: policy\ndata: {}\n\n")
+			fmt.Fprint(w, "id: 2\ndata: {\"type\":\"throttling\"}\n\n")
+			fmt.Fprint(w, "event: unknown\n\n")
+			// 第一次连接发送事件后断开
+			return
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	policy, throttling := newCounter(), newCounter()
+	statusFile := filepath.Join(t.TempDir(), "policy-push.json")
+	c := newTestChannel(server.URL, map[string]func(){
+		EventPolicy:     policy.handle,
+		EventThrottling: throttling.handle,
+	}, statusFile)
+	c.Start()
+	policy.wait(t)
+	throttling.wait(t)
+
+	// 断开后重连,携带最后一个事件的 id
+	require.Eventually(t, func() bool {
+		return c.Status().Connected && atomic.LoadInt32(&connections) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{"", "2"}, lastEventIDs)
+	mu.Unlock()
+
+	status, err := ReadStatus(statusFile)
+	require.NoError(t, err)
+	assert.True(t, status.Active(time.Now()))
+	assert.Equal(t, ModeSSE, status.Mode)
+	assert.Equal(t, 1, status.Reconnects)
+	assert.Equal(t, "unknown", status.LastEventType)
+
+	c.Stop()
+	status, err = ReadStatus(statusFile)
+	require.NoError(t, err)
+	assert.False(t, status.Active(time.Now()))
+}
+
+func TestChannelLongPoll(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&polls, 1) {
+		case 1:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"events":[{"type":"ipfs-config","id":"7"},{"type":"ipfs-config","id":"8"}]}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	ipfs := newCounter()
+	block := make(chan struct{})
+	c := newTestChannel(server.URL, map[string]func(){
+		EventIPFSConfig: func() {
+			<-block
+			ipfs.handle()
+		},
+	}, "")
+	c.Start()
+	defer c.Stop()
+	close(block)
+	ipfs.wait(t)
+
+	status := c.Status()
+	assert.True(t, status.Connected)
+	assert.Equal(t, ModeLongPoll, status.Mode)
+	assert.Equal(t, "8", status.LastEventID)
+	// 处理期间收到的同类事件合并,最多再处理一次
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, atomic.LoadInt32(&ipfs.n), int32(2))
+}
+
+func TestChannelIdleTimeout(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		// 不发送心跳
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := newTestChannel(server.URL, nil, "")
+	c.Start()
+	defer c.Stop()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&connections) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, c.Status().Error, "no data received")
+}
+
+func TestChannelLongPollHold(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) == 1 {
+			// 挂起请求的时间超过 IdleTimeout
+			time.Sleep(time.Second)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"events":[{"type":"policy","id":"3"}]}`)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	policy := newCounter()
+	c := newTestChannel(server.URL, map[string]func(){EventPolicy: policy.handle}, "")
+	c.Start()
+	defer c.Stop()
+	policy.wait(t)
+	assert.Equal(t, "3", c.Status().LastEventID)
+	assert.Empty(t, c.Status().Error)
+}
+
+func TestChannelPollTimeout(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&polls, 1)
+		// 不返回响应头
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := newTestChannel(server.URL, nil, "")
+	c.opts.PollTimeout = 200 * time.Millisecond
+	c.Start()
+	defer c.Stop()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&polls) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, c.Status().Error, "no data received in 200ms")
+}
+
+func TestNextDelay(t *testing.T) {
+	c := newTestChannel("", nil, "")
+	c.backoff = minBackoff
+	failure := errors.New("connection refused")
+
+	assert.Equal(t, 5*time.Second, c.nextDelay(failure, 0))
+	assert.Equal(t, 10*time.Second, c.nextDelay(failure, 0))
+	assert.Equal(t, 20*time.Second, c.nextDelay(failure, 0))
+	for i := 0; i < 20; i++ {
+		c.nextDelay(failure, 0)
+	}
+	assert.Equal(t, maxBackoff, c.nextDelay(failure, 0))
+
+	// 长轮询正常结束时重置退避,两次请求间隔至少 minPollInterval
+	assert.Equal(t, 3*time.Second, c.nextDelay(nil, 2*time.Second))
+	assert.Equal(t, time.Duration(0), c.nextDelay(nil, time.Minute))
+	assert.Equal(t, 5*time.Second, c.nextDelay(failure, 0))
+
+	// 平台不支持长连接
+	assert.Equal(t, unsupportedBackoff, c.nextDelay(fmt.Errorf("%w: response code=404", errUnsupported), 0))
+
+	// 平台指定的重连时间
+	c.retryHint = time.Minute
+	assert.Equal(t, time.Minute, c.nextDelay(failure, 0))
+
+	// 抖动在 [d/2, d) 之间
+	for i := 0; i < 100; i++ {
+		d := equalJitter(time.Minute)
+		assert.True(t, d >= 30*time.Second && d < time.Minute, d)
+	}
+}
+
+func TestChannelUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	c := newTestChannel(server.URL, nil, "")
+	c.Start()
+	defer c.Stop()
+	require.Eventually(t, func() bool {
+		return c.Status().Error != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	status := c.Status()
+	assert.False(t, status.Connected)
+	assert.Contains(t, status.Error, "does not support")
+	assert.InDelta(t, time.Now().Add(unsupportedBackoff).Unix(), status.NextRetry, 5)
+}
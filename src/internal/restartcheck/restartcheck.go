@@ -0,0 +1,305 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package restartcheck 在安装更新后分析哪些进程仍在使用已删除或被替换的文件,
+// 区分必须重启系统的情况(内核、init、核心包)、需要重新登录的情况(用户会话)和只需要重启部分服务的情况
+package restartcheck
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultProcDir            = "/proc"
+	DefaultRebootRequiredFile = "/run/reboot-required" // 软件包在安装脚本中创建该文件表示需要重启
+
+	deletedSuffix = " (deleted)"
+)
+
+// Result 重启分析结果
+type Result struct {
+	RebootRequired bool
+	RebootReasons  []string `json:",omitempty"` // 需要重启系统的原因
+	// 用户会话中的进程使用了已删除或被替换的文件,重新登录后生效
+	ReloginRequired bool
+	ReloginReasons  []string  `json:",omitempty"` // 需要重新登录的原因
+	Services        []string  `json:",omitempty"` // 重启后即可使用新文件的 systemd 系统服务
+	Processes       []Process `json:",omitempty"` // 使用了已删除或被替换文件的进程
+}
+
+// Process 使用了已删除或被替换文件的进程
+type Process struct {
+	Pid    int
+	Comm   string
+	Unit   string   `json:",omitempty"` // 所属的 systemd 系统服务,不属于系统服务的进程为空
+	Cgroup string   `json:",omitempty"` // systemd 层级中的 cgroup 路径
+	Files  []string // 已删除或被替换的可执行文件和库
+}
+
+// Options 分析参数,为空的路径使用默认值
+type Options struct {
+	ProcDir            string
+	RebootRequiredFile string
+	ChangedPackages    []string // 本次更新修改的软件包
+	CoreList           []string // 核心软件包清单,其中的软件包修改后需要重启系统
+	SelfUnit           string   // 调用者自身的服务,不会出现在 Services 中
+	SafeUnits          []string // 可以在会话中安全重启的服务,为空时使用 DefaultSafeUnits,以 @ 结尾的为模板服务前缀匹配
+}
+
+// rebootPackages 修改后必须重启系统的软件包,以 - 结尾的为前缀匹配
+var rebootPackages = []string{
+	"linux-image-",
+	"linux-modules-",
+	"linux-firmware",
+	"systemd",
+	"systemd-sysv",
+	"libsystemd0",
+	"dbus",
+	"dbus-daemon",
+	"libc6",
+}
+
+// DefaultSafeUnits 可以在会话中安全重启的服务,以 @ 结尾的为模板服务前缀匹配。
+// 不在列表中的服务(如 dbus、显示管理器、NetworkManager、polkit)重启可能中断会话或正在进行的更新,需要重启系统
+var DefaultSafeUnits = []string{
+	"cups.service",
+	"cups-browsed.service",
+	"ssh.service",
+	"sshd.service",
+	"cron.service",
+	"atd.service",
+	"rsyslog.service",
+	"systemd-timesyncd.service",
+	"chrony.service",
+	"avahi-daemon.service",
+	"colord.service",
+	"fwupd.service",
+}
+
+func matchList(list []string, name string, prefixSuffix string) bool {
+	for _, item := range list {
+		if strings.HasSuffix(item, prefixSuffix) {
+			if strings.HasPrefix(name, item) {
+				return true
+			}
+		} else if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze 分析已安装的更新对正在运行的进程的影响
+func Analyze(opts Options) (*Result, error) {
+	if opts.ProcDir == "" {
+		opts.ProcDir = DefaultProcDir
+	}
+	if opts.RebootRequiredFile == "" {
+		opts.RebootRequiredFile = DefaultRebootRequiredFile
+	}
+	if len(opts.SafeUnits) == 0 {
+		opts.SafeUnits = DefaultSafeUnits
+	}
+	result := &Result{}
+	core := make(map[string]bool)
+	for _, pkg := range opts.CoreList {
+		core[pkgName(pkg)] = true
+	}
+	changed := make([]string, 0, len(opts.ChangedPackages))
+	for _, pkg := range opts.ChangedPackages {
+		changed = append(changed, pkgName(pkg))
+	}
+	sort.Strings(changed)
+	for i, pkg := range changed {
+		if i > 0 && changed[i-1] == pkg {
+			continue
+		}
+		if matchList(rebootPackages, pkg, "-") {
+			result.RebootReasons = append(result.RebootReasons, fmt.Sprintf("package %v changed", pkg))
+		} else if core[pkg] {
+			result.RebootReasons = append(result.RebootReasons, fmt.Sprintf("core package %v changed", pkg))
+		}
+	}
+	if _, err := os.Stat(opts.RebootRequiredFile); err == nil {
+		result.RebootReasons = append(result.RebootReasons, fmt.Sprintf("%v exists", opts.RebootRequiredFile))
+	}
+
+	processes, err := scanProcesses(opts.ProcDir)
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[string]bool)
+	unsafe := make(map[string]bool)
+	session := make(map[string]bool)
+	orphan := make(map[string]bool)
+	for _, p := range processes {
+		if p.Pid == 1 {
+			result.RebootReasons = append(result.RebootReasons, "init process uses deleted files")
+			continue
+		}
+		if p.Unit == "" {
+			// 不属于系统服务的进程不能通过重启服务生效,用户会话中的进程需要重新登录,其他进程需要重启系统
+			if strings.HasPrefix(p.Cgroup, "/user.slice/") {
+				session[p.Comm] = true
+			} else {
+				orphan[p.Comm] = true
+			}
+			continue
+		}
+		if p.Unit == opts.SelfUnit {
+			continue
+		}
+		if !matchList(opts.SafeUnits, p.Unit, "@") {
+			unsafe[p.Unit] = true
+			continue
+		}
+		services[p.Unit] = true
+	}
+	for _, unit := range sortedKeys(unsafe) {
+		result.RebootReasons = append(result.RebootReasons, fmt.Sprintf("service %v can not be restarted safely", unit))
+	}
+	for _, comm := range sortedKeys(orphan) {
+		result.RebootReasons = append(result.RebootReasons, fmt.Sprintf("process %v outside any service uses deleted files", comm))
+	}
+	for _, comm := range sortedKeys(session) {
+		result.ReloginReasons = append(result.ReloginReasons, fmt.Sprintf("session process %v uses deleted files", comm))
+	}
+	result.ReloginRequired = len(result.ReloginReasons) > 0
+	result.Services = sortedKeys(services)
+	result.Processes = processes
+	result.RebootRequired = len(result.RebootReasons) > 0
+	return result, nil
+}
+
+func pkgName(pkg string) string {
+	if i := strings.IndexAny(pkg, ":="); i >= 0 {
+		return pkg[:i]
+	}
+	return pkg
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scanProcesses 遍历 procDir 中的进程,返回使用了已删除文件的进程,按 pid 排序
+func scanProcesses(procDir string) ([]Process, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	var result []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(procDir, entry.Name())
+		// 进程可能在遍历过程中退出,读取失败时忽略该进程
+		files := deletedFiles(dir)
+		if len(files) == 0 {
+			continue
+		}
+		comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+		cgroup := readCgroup(filepath.Join(dir, "cgroup"))
+		result = append(result, Process{
+			Pid:    pid,
+			Comm:   strings.TrimSpace(string(comm)),
+			Unit:   systemUnit(cgroup),
+			Cgroup: cgroup,
+			Files:  files,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Pid < result[j].Pid
+	})
+	return result, nil
+}
+
+// isSystemFile 只关心软件包安装的文件,忽略共享内存、memfd 等匿名映射
+func isSystemFile(path string) bool {
+	for _, prefix := range []string{"/usr/", "/lib", "/bin/", "/sbin/", "/opt/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// deletedFiles 进程的可执行文件和 maps 中已被删除(包括被 dpkg 替换)的文件
+func deletedFiles(dir string) []string {
+	found := make(map[string]bool)
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil && strings.HasSuffix(exe, deletedSuffix) {
+		exe = strings.TrimSuffix(exe, deletedSuffix)
+		if isSystemFile(exe) {
+			found[exe] = true
+		}
+	}
+	f, err := os.Open(filepath.Join(dir, "maps"))
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// 7f2c5c000000-7f2c5c022000 r--p 00000000 08:01 1835 /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)
+			line := scanner.Text()
+			if !strings.HasSuffix(line, deletedSuffix) {
+				continue
+			}
+			fields := strings.SplitN(line, " ", 6)
+			if len(fields) < 6 {
+				continue
+			}
+			path := strings.TrimSuffix(strings.TrimSpace(fields[5]), deletedSuffix)
+			if isSystemFile(path) {
+				found[path] = true
+			}
+		}
+	}
+	return sortedKeys(found)
+}
+
+// readCgroup 读取进程在 systemd 层级中的 cgroup 路径,例如 0::/system.slice/cups.service 中的 /system.slice/cups.service
+func readCgroup(cgroupFile string) string {
+	content, err := os.ReadFile(cgroupFile)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		// cgroup v2 的统一层级或 cgroup v1 的 systemd 层级
+		if !(fields[0] == "0" && fields[1] == "") && fields[1] != "name=systemd" {
+			continue
+		}
+		return fields[2]
+	}
+	return ""
+}
+
+// systemUnit 从 cgroup 路径中解析进程所属的系统服务
+func systemUnit(cgroup string) string {
+	if !strings.HasPrefix(cgroup, "/system.slice/") {
+		return ""
+	}
+	parts := strings.Split(cgroup, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if strings.HasSuffix(parts[i], ".service") {
+			return parts[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package restartcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProc struct {
+	pid    string
+	comm   string
+	exe    string
+	maps   string
+	cgroup string
+}
+
+func writeFakeProc(t *testing.T, dir string, procs []fakeProc) {
+	for _, p := range procs {
+		pdir := filepath.Join(dir, p.pid)
+		require.NoError(t, os.MkdirAll(pdir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(pdir, "comm"), []byte(p.comm+"\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(pdir, "maps"), []byte(p.maps), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(pdir, "cgroup"), []byte(p.cgroup), 0644))
+		if p.exe != "" {
+			require.NoError(t, os.Symlink(p.exe, filepath.Join(pdir, "exe")))
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	procDir := t.TempDir()
+	writeFakeProc(t, procDir, []fakeProc{
+		{pid: "1", comm: "systemd", exe: "/usr/lib/systemd/systemd", cgroup: "0::/init.scope\n"},
+		{pid: "120", comm: "cupsd", exe: "/usr/sbin/cupsd",
+			maps: "7f2c5c000000-7f2c5c022000 r--p 00000000 08:01 1835 /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)\n" +
+				"7f2c5c100000-7f2c5c122000 r--p 00000000 08:01 1836 /usr/lib/x86_64-linux-gnu/libc.so.6\n",
+			cgroup: "0::/system.slice/cups.service\n"},
+		{pid: "130", comm: "sshd", exe: "/usr/sbin/sshd (deleted)",
+			cgroup: "12:pids:/system.slice/ssh.service\n1:name=systemd:/system.slice/ssh.service\n"},
+		{pid: "140", comm: "agetty", exe: "/usr/sbin/agetty (deleted)",
+			cgroup: "0::/system.slice/system-getty.slice/getty@tty1.service\n"},
+		{pid: "150", comm: "dde-dock", exe: "/usr/bin/dde-dock",
+			maps:   "7f2c5c000000-7f2c5c022000 r--p 00000000 08:01 1835 /usr/lib/x86_64-linux-gnu/libssl.so.3 (deleted)\n",
+			cgroup: "0::/user.slice/user-1000.slice/session-2.scope\n"},
+		{pid: "160", comm: "lastore-daemon", exe: "/usr/libexec/lastore-daemon/lastore-daemon (deleted)",
+			cgroup: "0::/system.slice/lastore-daemon.service\n"},
+		{pid: "170", comm: "app", exe: "/usr/bin/app",
+			maps:   "7f2c5c000000-7f2c5c022000 rw-s 00000000 00:01 99 /dev/shm/app (deleted)\n",
+			cgroup: "0::/system.slice/app.service\n"},
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(procDir, "self"), 0755))
+
+	result, err := Analyze(Options{
+		ProcDir:            procDir,
+		RebootRequiredFile: filepath.Join(procDir, "reboot-required"),
+		ChangedPackages:    []string{"libssl3:amd64", "openssh-server", "libssl3"},
+		CoreList:           []string{"dde-session-shell"},
+		SelfUnit:           "lastore-daemon.service",
+	})
+	require.NoError(t, err)
+	assert.True(t, result.RebootRequired)
+	assert.Equal(t, []string{"service getty@tty1.service can not be restarted safely"}, result.RebootReasons)
+	assert.Equal(t, []string{"cups.service", "ssh.service"}, result.Services)
+	require.Len(t, result.Processes, 5)
+	assert.Equal(t, Process{Pid: 120, Comm: "cupsd", Unit: "cups.service", Cgroup: "/system.slice/cups.service",
+		Files: []string{"/usr/lib/x86_64-linux-gnu/libssl.so.3"}}, result.Processes[0])
+	assert.Equal(t, Process{Pid: 150, Comm: "dde-dock", Cgroup: "/user.slice/user-1000.slice/session-2.scope",
+		Files: []string{"/usr/lib/x86_64-linux-gnu/libssl.so.3"}}, result.Processes[3])
+	assert.True(t, result.ReloginRequired)
+	assert.Equal(t, []string{"session process dde-dock uses deleted files"}, result.ReloginReasons)
+
+	require.NoError(t, os.WriteFile(filepath.Join(procDir, "reboot-required"), nil, 0644))
+	result, err = Analyze(Options{
+		ProcDir:            procDir,
+		RebootRequiredFile: filepath.Join(procDir, "reboot-required"),
+		ChangedPackages:    []string{"linux-image-6.6-amd64", "dde-session-shell", "vim"},
+		CoreList:           []string{"dde-session-shell"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"core package dde-session-shell changed",
+		"package linux-image-6.6-amd64 changed",
+		filepath.Join(procDir, "reboot-required") + " exists",
+		"service getty@tty1.service can not be restarted safely",
+		"service lastore-daemon.service can not be restarted safely",
+	}, result.RebootReasons)
+	assert.Equal(t, []string{"cups.service", "ssh.service"}, result.Services)
+}
+
+func TestAnalyzeSafeUnits(t *testing.T) {
+	procDir := t.TempDir()
+	writeFakeProc(t, procDir, []fakeProc{
+		{pid: "120", comm: "NetworkManager", exe: "/usr/sbin/NetworkManager (deleted)",
+			cgroup: "0::/system.slice/NetworkManager.service\n"},
+		{pid: "130", comm: "polkitd", exe: "/usr/lib/polkit-1/polkitd (deleted)", cgroup: "0::/system.slice/polkit.service\n"},
+		{pid: "140", comm: "app", exe: "/usr/bin/app (deleted)", cgroup: "0::/system.slice/app@1.service\n"},
+	})
+	// 不在允许列表中的服务不会被重启
+	result, err := Analyze(Options{ProcDir: procDir, RebootRequiredFile: filepath.Join(procDir, "none")})
+	require.NoError(t, err)
+	assert.True(t, result.RebootRequired)
+	assert.Equal(t, []string{
+		"service NetworkManager.service can not be restarted safely",
+		"service app@1.service can not be restarted safely",
+		"service polkit.service can not be restarted safely",
+	}, result.RebootReasons)
+	assert.Empty(t, result.Services)
+
+	result, err = Analyze(Options{ProcDir: procDir, RebootRequiredFile: filepath.Join(procDir, "none"),
+		SafeUnits: []string{"app@", "polkit.service"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service NetworkManager.service can not be restarted safely"}, result.RebootReasons)
+	assert.Equal(t, []string{"app@1.service", "polkit.service"}, result.Services)
+}
+
+func TestAnalyzeNoReboot(t *testing.T) {
+	procDir := t.TempDir()
+	writeFakeProc(t, procDir, []fakeProc{
+		{pid: "1", comm: "systemd", exe: "/usr/lib/systemd/systemd", cgroup: "0::/init.scope\n"},
+		{pid: "120", comm: "cupsd", exe: "/usr/sbin/cupsd (deleted)", cgroup: "0::/system.slice/cups.service\n"},
+	})
+	result, err := Analyze(Options{ProcDir: procDir, RebootRequiredFile: filepath.Join(procDir, "none"),
+		ChangedPackages: []string{"cups-daemon"}})
+	require.NoError(t, err)
+	assert.False(t, result.RebootRequired)
+	assert.Empty(t, result.RebootReasons)
+	assert.Equal(t, []string{"cups.service"}, result.Services)
+
+	_, err = Analyze(Options{ProcDir: filepath.Join(procDir, "not-exist")})
+	assert.Error(t, err)
+}
+
+func TestAnalyzeWithoutUnit(t *testing.T) {
+	procDir := t.TempDir()
+	writeFakeProc(t, procDir, []fakeProc{
+		{pid: "120", comm: "dde-session", exe: "/usr/bin/dde-session (deleted)",
+			cgroup: "0::/user.slice/user-1000.slice/session-2.scope\n"},
+		{pid: "121", comm: "dde-dock", exe: "/usr/bin/dde-dock (deleted)",
+			cgroup: "0::/user.slice/user-1000.slice/user@1000.service/app.slice/dde-dock.service\n"},
+		{pid: "122", comm: "dde-dock", exe: "/usr/bin/dde-dock (deleted)",
+			cgroup: "0::/user.slice/user-1001.slice/user@1001.service/app.slice/dde-dock.service\n"},
+	})
+	// 用户会话中的进程重新登录后生效,不需要重启系统
+	result, err := Analyze(Options{ProcDir: procDir, RebootRequiredFile: filepath.Join(procDir, "none")})
+	require.NoError(t, err)
+	assert.False(t, result.RebootRequired)
+	assert.True(t, result.ReloginRequired)
+	assert.Equal(t, []string{
+		"session process dde-dock uses deleted files",
+		"session process dde-session uses deleted files",
+	}, result.ReloginReasons)
+	assert.Empty(t, result.Services)
+
+	// 既不属于系统服务也不属于用户会话的进程需要重启系统
+	writeFakeProc(t, procDir, []fakeProc{
+		{pid: "130", comm: "containerd-shim", exe: "/usr/bin/containerd-shim (deleted)",
+			cgroup: "0::/system.slice/docker-1a2b.scope\n"},
+		{pid: "140", comm: "plymouthd", exe: "/usr/sbin/plymouthd (deleted)", cgroup: "0::/init.scope\n"},
+	})
+	result, err = Analyze(Options{ProcDir: procDir, RebootRequiredFile: filepath.Join(procDir, "none")})
+	require.NoError(t, err)
+	assert.True(t, result.RebootRequired)
+	assert.Equal(t, []string{
+		"process containerd-shim outside any service uses deleted files",
+		"process plymouthd outside any service uses deleted files",
+	}, result.RebootReasons)
+	assert.True(t, result.ReloginRequired)
+}
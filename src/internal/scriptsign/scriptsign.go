@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package scriptsign 校验更新平台下发的检查脚本的分离签名。
+// 签名为 "<阶段>\n<脚本名>\n<脚本内容>" 的 ed25519 签名,脚本不能被换到其他阶段或改名执行,
+// 公钥预置在本机,每个公钥文件对应一个 key id,
+// 轮换时新旧公钥同时安装,平台切换到新 key id 签名后再移除旧公钥
+package scriptsign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultKeyDir 随软件包安装的公钥目录
+	DefaultKeyDir = "/usr/share/lastore/check-script-keys"
+	// TestKeyDir 开发调试用的测试公钥目录,仅在开启 check-script-test-keys 时加载
+	TestKeyDir = "/etc/lastore/check-script-test-keys"
+
+	keySuffix = ".pub"
+)
+
+var (
+	ErrUnsigned     = errors.New("script is not signed")
+	ErrUnknownKey   = errors.New("signing key is not trusted")
+	ErrBadSignature = errors.New("signature is invalid")
+	ErrBadName      = errors.New("script name is invalid")
+)
+
+// KeyRing key id 到公钥的映射
+type KeyRing map[string]ed25519.PublicKey
+
+// LoadKeyRing 加载 dirs 中的 <key id>.pub 公钥文件,目录不存在时忽略
+func LoadKeyRing(dirs ...string) (KeyRing, error) {
+	ring := make(KeyRing)
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+keySuffix))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			key, err := ParsePublicKey(content)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", file, err)
+			}
+			ring[strings.TrimSuffix(filepath.Base(file), keySuffix)] = key
+		}
+	}
+	return ring, nil
+}
+
+// ParsePublicKey 解析 base64 编码的 ed25519 公钥,忽略空行和 # 开头的注释
+func ParsePublicKey(content []byte) (ed25519.PublicKey, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := decodeBase64(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key size %d", len(key))
+		}
+		return key, nil
+	}
+	return nil, errors.New("no public key found")
+}
+
+// IDs 返回排序后的 key id
+func (r KeyRing) IDs() []string {
+	ids := make([]string, 0, len(r))
+	for id := range r {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Verify 校验 content 的签名,keyID 为空时依次尝试所有公钥
+func (r KeyRing) Verify(keyID string, content []byte, signature string) error {
+	if signature == "" {
+		return ErrUnsigned
+	}
+	sig, err := decodeBase64(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrBadSignature
+	}
+	if keyID != "" {
+		key, ok := r[keyID]
+		if !ok {
+			return fmt.Errorf("%w: %v", ErrUnknownKey, keyID)
+		}
+		if !ed25519.Verify(key, content, sig) {
+			return ErrBadSignature
+		}
+		return nil
+	}
+	if len(r) == 0 {
+		return ErrUnknownKey
+	}
+	for _, key := range r {
+		if ed25519.Verify(key, content, sig) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+// Sign 生成 content 的签名,供平台调试工具和测试使用
+func Sign(key ed25519.PrivateKey, content []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, content))
+}
+
+// Message 返回检查脚本签名的内容,stage 为平台响应中脚本列表的字段名,如 preCheck
+func Message(stage, name string, content []byte) []byte {
+	msg := make([]byte, 0, len(stage)+len(name)+2+len(content))
+	msg = append(msg, stage...)
+	msg = append(msg, '\n')
+	msg = append(msg, name...)
+	msg = append(msg, '\n')
+	return append(msg, content...)
+}
+
+// ValidName 检查脚本名只能是检查目录中的一个文件名,不能包含路径
+func ValidName(name string) error {
+	if name == "" || name == "." || strings.Contains(name, "/") || strings.Contains(name, "..") ||
+		filepath.Base(name) != name {
+		return fmt.Errorf("%w: %q", ErrBadName, name)
+	}
+	return nil
+}
+
+// VerifyScript 校验脚本名并校验 stage、name 和 content 的签名
+func (r KeyRing) VerifyScript(keyID, stage, name string, content []byte, signature string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	return r.Verify(keyID, Message(stage, name, content), signature)
+}
+
+// SignScript 生成检查脚本的签名,供平台调试工具和测试使用
+func SignScript(key ed25519.PrivateKey, stage, name string, content []byte) string {
+	return Sign(key, Message(stage, name, content))
+}
+
+// decodeBase64 兼容带或不带 padding 的标准 base64
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package scriptsign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir, id string, key ed25519.PublicKey) {
+	content := "# " + id + "\n" + base64.StdEncoding.EncodeToString(key) + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".pub"), []byte(content), 0644))
+}
+
+func TestVerify(t *testing.T) {
+	oldPub, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newPub, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, testKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeKey(t, dir, "2025", oldPub)
+	writeKey(t, dir, "2026", newPub)
+	ring, err := LoadKeyRing(dir, filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2025", "2026"}, ring.IDs())
+
+	script := []byte("#!/bin/bash\nexit 0\n")
+	assert.NoError(t, ring.Verify("2025", script, Sign(oldKey, script)))
+	assert.NoError(t, ring.Verify("2026", script, Sign(newKey, script)))
+	assert.NoError(t, ring.Verify("", script, Sign(newKey, script)))
+
+	assert.ErrorIs(t, ring.Verify("2026", script, ""), ErrUnsigned)
+	assert.ErrorIs(t, ring.Verify("2026", script, "not base64!"), ErrBadSignature)
+	assert.ErrorIs(t, ring.Verify("2026", script, Sign(oldKey, script)), ErrBadSignature)
+	assert.ErrorIs(t, ring.Verify("2026", []byte("#!/bin/bash\nrm -rf /\n"), Sign(newKey, script)), ErrBadSignature)
+	assert.ErrorIs(t, ring.Verify("test", script, Sign(testKey, script)), ErrUnknownKey)
+	assert.ErrorIs(t, ring.Verify("", script, Sign(testKey, script)), ErrBadSignature)
+	assert.ErrorIs(t, KeyRing{}.Verify("", script, Sign(testKey, script)), ErrUnknownKey)
+
+	// 去掉 padding 的签名同样有效
+	sig := base64.RawStdEncoding.EncodeToString(ed25519.Sign(newKey, script))
+	assert.NoError(t, ring.Verify("2026", script, sig))
+}
+
+func TestVerifyScript(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ring := KeyRing{"2026": pub}
+
+	script := []byte("#!/bin/bash\nexit 0\n")
+	sig := SignScript(key, "preCheck", "check.sh", script)
+	assert.NoError(t, ring.VerifyScript("2026", "preCheck", "check.sh", script, sig))
+	assert.Equal(t, "preCheck\ncheck.sh\n#!/bin/bash\nexit 0\n", string(Message("preCheck", "check.sh", script)))
+
+	// 签名绑定阶段和脚本名
+	assert.ErrorIs(t, ring.VerifyScript("2026", "postCheck", "check.sh", script, sig), ErrBadSignature)
+	assert.ErrorIs(t, ring.VerifyScript("2026", "preCheck", "other.sh", script, sig), ErrBadSignature)
+	assert.ErrorIs(t, ring.Verify("2026", script, sig), ErrBadSignature)
+
+	for _, name := range []string{"", ".", "..", "../check.sh", "a/b.sh", "/etc/cron.d/x", "a..sh"} {
+		sig := SignScript(key, "preCheck", name, script)
+		assert.ErrorIs(t, ring.VerifyScript("2026", "preCheck", name, script, sig), ErrBadName, name)
+	}
+}
+
+func TestLoadKeyRingInvalid(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.pub"), []byte("c2hvcnQ="), 0644))
+	_, err := LoadKeyRing(dir)
+	assert.Error(t, err)
+
+	_, err = ParsePublicKey([]byte("# only comment\n"))
+	assert.Error(t, err)
+}
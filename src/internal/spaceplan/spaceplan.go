@@ -0,0 +1,262 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package spaceplan 按挂载点规划下载和安装更新需要的磁盘空间,
+// 将事务中每个软件包的文件、下载缓存和备份需要的空间对应到所在的挂载点,分别计算各挂载点的缺口
+package spaceplan
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	DefaultMountInfo = "/proc/self/mountinfo"
+	DefaultDpkgInfo  = "/var/lib/dpkg/info"
+)
+
+// Need 一项空间需求
+type Need struct {
+	Path   string // 需要写入的路径,按所在的挂载点统计
+	Size   uint64
+	Reason string // 需求的来源,如 install、archives、backup
+}
+
+// MountPlan 一个挂载点的空间规划
+type MountPlan struct {
+	Mount     string
+	Free      uint64
+	Need      uint64
+	Shortfall uint64            `json:",omitempty"` // 还需要释放的空间
+	Reasons   map[string]uint64 // 按来源统计的需求
+}
+
+// Report 空间规划结果,按挂载点排序
+type Report struct {
+	Mounts []MountPlan
+	Fixes  []string `json:",omitempty"` // 已执行的自动修复
+}
+
+// Shortfalls 空间不足的挂载点
+func (r *Report) Shortfalls() []MountPlan {
+	var result []MountPlan
+	for _, mp := range r.Mounts {
+		if mp.Shortfall > 0 {
+			result = append(result, mp)
+		}
+	}
+	return result
+}
+
+// Lookup 返回挂载点的规划,挂载点没有需求时返回 nil
+func (r *Report) Lookup(mount string) *MountPlan {
+	for i := range r.Mounts {
+		if r.Mounts[i].Mount == mount {
+			return &r.Mounts[i]
+		}
+	}
+	return nil
+}
+
+// String 空间不足的挂载点摘要,用于错误详情
+func (r *Report) String() string {
+	var parts []string
+	for _, mp := range r.Shortfalls() {
+		parts = append(parts, fmt.Sprintf("%v needs %v more (free %v, need %v)",
+			mp.Mount, FormatSize(mp.Shortfall), FormatSize(mp.Free), FormatSize(mp.Need)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// FormatSize 格式化字节数
+func FormatSize(size uint64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%v", size, units[i])
+	}
+	return fmt.Sprintf("%.1f%v", value, units[i])
+}
+
+// ReadMountPoints 读取当前的挂载点列表
+func ReadMountPoints(mountInfo string) ([]string, error) {
+	f, err := os.Open(mountInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+// parseMountInfo 解析 /proc/self/mountinfo,第 5 列为挂载点,其中的空格等字符使用八进制转义:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(r io.Reader) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mount := unescapeMount(fields[4])
+		if !seen[mount] {
+			seen[mount] = true
+			result = append(result, mount)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// MountPoint 返回 path 所在的挂载点,即 mounts 中最长的前缀
+func MountPoint(path string, mounts []string) string {
+	path = filepath.Clean(path)
+	result := "/"
+	for _, mount := range mounts {
+		if mount == "/" || len(mount) <= len(result) {
+			continue
+		}
+		if path == mount || strings.HasPrefix(path, mount+"/") {
+			result = mount
+		}
+	}
+	return result
+}
+
+// FreeSpace 返回 dir 所在分区的可用空间
+func FreeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil // #nosec G115
+}
+
+// Plan 将需求汇总到挂载点并计算缺口,free 返回挂载点的可用空间
+func Plan(needs []Need, mounts []string, free func(mount string) (uint64, error)) (*Report, error) {
+	plans := make(map[string]*MountPlan)
+	for _, need := range needs {
+		if need.Size == 0 {
+			continue
+		}
+		mount := MountPoint(need.Path, mounts)
+		mp, ok := plans[mount]
+		if !ok {
+			mp = &MountPlan{Mount: mount, Reasons: make(map[string]uint64)}
+			plans[mount] = mp
+		}
+		mp.Need += need.Size
+		mp.Reasons[need.Reason] += need.Size
+	}
+	report := &Report{}
+	for _, mp := range plans {
+		size, err := free(mp.Mount)
+		if err != nil {
+			return nil, err
+		}
+		mp.Free = size
+		if mp.Need > mp.Free {
+			mp.Shortfall = mp.Need - mp.Free
+		}
+		report.Mounts = append(report.Mounts, *mp)
+	}
+	sort.Slice(report.Mounts, func(i, j int) bool {
+		return report.Mounts[i].Mount < report.Mounts[j].Mount
+	})
+	return report, nil
+}
+
+// PackageShares 按挂载点统计软件包已安装文件的大小,用于估算新版本的文件分布。
+// 文件列表来自 dpkgInfo 中的 <pkg>.list 或 <pkg>:<arch>.list,包未安装时返回 nil
+func PackageShares(dpkgInfo, pkg string, mounts []string) map[string]uint64 {
+	listFile := filepath.Join(dpkgInfo, pkg+".list")
+	if _, err := os.Stat(listFile); err != nil {
+		matches, _ := filepath.Glob(filepath.Join(dpkgInfo, pkg+":*.list"))
+		if len(matches) == 0 {
+			return nil
+		}
+		listFile = matches[0]
+	}
+	f, err := os.Open(listFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	shares := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		file := scanner.Text()
+		info, err := os.Lstat(file)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		shares[MountPoint(file, mounts)] += uint64(info.Size()) // #nosec G115
+	}
+	return shares
+}
+
+// Spread 按 shares 的比例将 size 分配到各挂载点,shares 为空时全部计入 fallback
+func Spread(size uint64, shares map[string]uint64, fallback, reason string) []Need {
+	var total uint64
+	for _, v := range shares {
+		total += v
+	}
+	if total == 0 {
+		return []Need{{Path: fallback, Size: size, Reason: reason}}
+	}
+	mounts := make([]string, 0, len(shares))
+	for mount := range shares {
+		mounts = append(mounts, mount)
+	}
+	sort.Strings(mounts)
+	var result []Need
+	var assigned uint64
+	for i, mount := range mounts {
+		part := uint64(float64(size) * float64(shares[mount]) / float64(total))
+		if i == len(mounts)-1 {
+			// 最后一项补齐取整误差
+			part = size - assigned
+		}
+		assigned += part
+		if part > 0 {
+			result = append(result, Need{Path: mount, Size: part, Reason: reason})
+		}
+	}
+	return result
+}
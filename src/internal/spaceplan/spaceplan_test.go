@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package spaceplan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	mountInfo := `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+23 22 8:1 / /boot rw,relatime shared:2 - ext4 /dev/sda1 rw
+24 22 8:3 / /data rw,relatime shared:3 - ext4 /dev/sda3 rw
+25 24 8:3 /home /home rw,relatime shared:3 - ext4 /dev/sda3 rw
+26 22 0:5 / /media/my\040disk rw - vfat /dev/sdb1 rw
+27 22 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+`
+	mounts, err := parseMountInfo(strings.NewReader(mountInfo))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/", "/boot", "/data", "/home", "/media/my disk"}, mounts)
+}
+
+func TestMountPoint(t *testing.T) {
+	mounts := []string{"/", "/boot", "/boot/efi", "/data", "/var"}
+	assert.Equal(t, "/boot", MountPoint("/boot/vmlinuz-6.6", mounts))
+	assert.Equal(t, "/boot/efi", MountPoint("/boot/efi/EFI/deepin/grubx64.efi", mounts))
+	assert.Equal(t, "/var", MountPoint("/var/cache/lastore/archives", mounts))
+	assert.Equal(t, "/var", MountPoint("/var", mounts))
+	assert.Equal(t, "/", MountPoint("/variable", mounts))
+	assert.Equal(t, "/", MountPoint("/usr/lib/libc.so.6", mounts))
+	assert.Equal(t, "/", MountPoint("/usr/lib", nil))
+}
+
+func TestPlan(t *testing.T) {
+	mounts := []string{"/", "/boot", "/var"}
+	free := map[string]uint64{"/": 100, "/boot": 30, "/var": 50}
+	needs := []Need{
+		{Path: "/usr", Size: 60, Reason: "install"},
+		{Path: "/boot", Size: 40, Reason: "install"},
+		{Path: "/var/cache/lastore/archives", Size: 50, Reason: "archives"},
+		{Path: "/var/lib/dpkg", Size: 10, Reason: "install"},
+		{Path: "/", Size: 30, Reason: "backup"},
+		{Path: "/opt", Size: 0, Reason: "install"},
+	}
+	report, err := Plan(needs, mounts, func(mount string) (uint64, error) {
+		return free[mount], nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []MountPlan{
+		{Mount: "/", Free: 100, Need: 90, Reasons: map[string]uint64{"install": 60, "backup": 30}},
+		{Mount: "/boot", Free: 30, Need: 40, Shortfall: 10, Reasons: map[string]uint64{"install": 40}},
+		{Mount: "/var", Free: 50, Need: 60, Shortfall: 10, Reasons: map[string]uint64{"archives": 50, "install": 10}},
+	}, report.Mounts)
+	assert.Len(t, report.Shortfalls(), 2)
+	assert.Equal(t, "/boot needs 10B more (free 30B, need 40B); /var needs 10B more (free 50B, need 60B)", report.String())
+	assert.Equal(t, uint64(50), report.Lookup("/var").Free)
+	assert.Nil(t, report.Lookup("/data"))
+
+	_, err = Plan(needs, mounts, func(string) (uint64, error) {
+		return 0, errors.New("statfs failed")
+	})
+	assert.Error(t, err)
+}
+
+func TestPackageShares(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	boot := filepath.Join(root, "boot")
+	require.NoError(t, os.MkdirAll(boot, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(boot, "vmlinuz"), make([]byte, 300), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "module.ko"), make([]byte, 100), 0644))
+	list := strings.Join([]string{root, boot, filepath.Join(boot, "vmlinuz"), filepath.Join(root, "module.ko"),
+		filepath.Join(root, "missing")}, "\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux-image:amd64.list"), []byte(list), 0644))
+
+	mounts := []string{"/", boot}
+	shares := PackageShares(dir, "linux-image", mounts)
+	assert.Equal(t, map[string]uint64{boot: 300, "/": 100}, shares)
+	assert.Nil(t, PackageShares(dir, "not-installed", mounts))
+
+	assert.Equal(t, []Need{
+		{Path: "/", Size: 250, Reason: "install"},
+		{Path: boot, Size: 750, Reason: "install"},
+	}, Spread(1000, shares, "/usr", "install"))
+	assert.Equal(t, []Need{{Path: "/usr", Size: 1000, Reason: "install"}}, Spread(1000, nil, "/usr", "install"))
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512B", FormatSize(512))
+	assert.Equal(t, "1.5KB", FormatSize(1536))
+	assert.Equal(t, "2.0GB", FormatSize(2<<30))
+}
@@ -0,0 +1,268 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package supportbundle 收集现场问题分析需要的日志、配置和状态,脱敏后打包为本地的 tar.gz 文件,
+// 包中的 manifest.json 记录每个文件的来源、大小以及是否脱敏、截断或收集失败
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDir lastore-daemon 保存诊断包的目录
+	DefaultDir = "/var/cache/lastore/support-bundle"
+	// ManifestName 诊断包中的清单文件
+	ManifestName = "manifest.json"
+	// DefaultKeep 目录中保留的诊断包数量
+	DefaultKeep = 3
+
+	manifestVersion = 1
+	filePrefix      = "lastore-support-"
+	fileSuffix      = ".tar.gz"
+)
+
+var (
+	// maxEntrySize 单个文件的最大长度,超过时只保留末尾的内容
+	maxEntrySize int64 = 32 << 20
+	// commandTimeout 收集命令输出的超时时间
+	commandTimeout = 30 * time.Second
+)
+
+// Manifest 诊断包的清单
+type Manifest struct {
+	Version   int
+	Created   int64
+	Collector string // 生成诊断包的程序
+	Entries   []Entry
+}
+
+// Entry 诊断包中的一个文件
+type Entry struct {
+	Name      string
+	Source    string // 来源文件或命令
+	Size      int64  `json:",omitempty"`
+	Redacted  bool   `json:",omitempty"` // 内容是否经过脱敏修改
+	Truncated bool   `json:",omitempty"` // 超过长度限制,只保留了末尾的内容
+	Error     string `json:",omitempty"` // 收集失败的原因,此时包中没有该文件
+}
+
+// FileName 按时间生成诊断包的文件名
+func FileName(now time.Time) string {
+	return filePrefix + now.Format("20060102-150405") + fileSuffix
+}
+
+// Bundle 正在生成的诊断包,收集单个文件失败时记录在清单中,不影响其他文件
+type Bundle struct {
+	file     *os.File
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	redactor *Redactor
+	manifest Manifest
+	err      error
+}
+
+// Create 创建诊断包,包含设备的日志和配置,只有所有者可以读取
+func Create(file string, collector string, redactor *Redactor) (*Bundle, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if redactor == nil {
+		redactor = NewRedactor()
+	}
+	gz := gzip.NewWriter(f)
+	return &Bundle{
+		file:     f,
+		gz:       gz,
+		tw:       tar.NewWriter(gz),
+		redactor: redactor,
+		manifest: Manifest{
+			Version:   manifestVersion,
+			Created:   time.Now().Unix(),
+			Collector: collector,
+		},
+	}, nil
+}
+
+// AddData 脱敏后写入数据
+func (b *Bundle) AddData(name, source string, data []byte) {
+	b.add(Entry{Name: name, Source: source}, data)
+}
+
+// AddError 记录无法收集的内容
+func (b *Bundle) AddError(name, source string, err error) {
+	b.manifest.Entries = append(b.manifest.Entries, Entry{Name: name, Source: source, Error: err.Error()})
+}
+
+// AddJSON 写入 json 数据
+func (b *Bundle) AddJSON(name, source string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.AddError(name, source, err)
+		return
+	}
+	b.AddData(name, source, append(data, '\n'))
+}
+
+// AddFile 写入文件,超过长度限制时只保留末尾的内容
+func (b *Bundle) AddFile(name, file string) {
+	data, truncated, err := readTail(file, maxEntrySize)
+	if err != nil {
+		b.AddError(name, file, err)
+		return
+	}
+	b.add(Entry{Name: name, Source: file, Truncated: truncated}, data)
+}
+
+// AddGlob 将匹配的文件写入 dir 目录
+func (b *Bundle) AddGlob(dir, pattern string) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		b.AddError(dir, pattern, err)
+		return
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			b.AddFile(path.Join(dir, filepath.Base(file)), file)
+		}
+	}
+}
+
+// AddCommand 写入命令的输出,命令失败时同时记录失败原因
+func (b *Bundle) AddCommand(name string, args ...string) {
+	source := strings.Join(args, " ")
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) == 0 {
+		b.AddError(name, source, err)
+		return
+	}
+	truncated := false
+	if int64(len(output)) > maxEntrySize {
+		output = output[int64(len(output))-maxEntrySize:]
+		truncated = true
+	}
+	entry := Entry{Name: name, Source: source, Truncated: truncated}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	b.add(entry, output)
+}
+
+func (b *Bundle) add(entry Entry, data []byte) {
+	redacted := b.redactor.Redact(data)
+	entry.Redacted = !bytes.Equal(redacted, data)
+	entry.Size = int64(len(redacted))
+	if err := b.write(entry.Name, redacted); err != nil {
+		entry.Error = err.Error()
+	}
+	b.manifest.Entries = append(b.manifest.Entries, entry)
+}
+
+func (b *Bundle) write(name string, data []byte) error {
+	if b.err != nil {
+		return b.err
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Unix(b.manifest.Created, 0),
+	}
+	if err := b.tw.WriteHeader(header); err != nil {
+		b.err = err
+		return err
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		b.err = err
+		return err
+	}
+	return nil
+}
+
+// Manifest 返回当前的清单
+func (b *Bundle) Manifest() Manifest {
+	return b.manifest
+}
+
+// Close 写入清单并关闭诊断包
+func (b *Bundle) Close() error {
+	data, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err == nil {
+		err = b.write(ManifestName, append(data, '\n'))
+	}
+	for _, closer := range []io.Closer{b.tw, b.gz, b.file} {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = b.err
+	}
+	if err != nil {
+		_ = os.Remove(b.file.Name())
+	}
+	return err
+}
+
+// readTail 读取文件,超过 max 时只读取末尾的 max 字节
+func readTail(file string, max int64) ([]byte, bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, false, fmt.Errorf("%s is not a regular file", file)
+	}
+	truncated := info.Size() > max
+	if truncated {
+		if _, err := f.Seek(-max, io.SeekEnd); err != nil {
+			return nil, false, err
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(f, max))
+	return data, truncated, err
+}
+
+// Prune 删除目录中较早的诊断包,只保留最近 keep 个
+func Prune(dir string, keep int) error {
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return err
+	}
+	// 文件名中的时间按字典序排列
+	sort.Strings(files)
+	for len(files) > keep {
+		if err := os.Remove(files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
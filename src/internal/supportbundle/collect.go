@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package supportbundle
+
+import (
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformhttp"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/policypush"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
+)
+
+// File 收集的文件,Name 为在诊断包中的路径
+type File struct {
+	Name string
+	Path string
+}
+
+// Glob 收集的一组文件,写入诊断包中的 Dir 目录
+type Glob struct {
+	Dir     string
+	Pattern string
+}
+
+// Command 收集输出的命令
+type Command struct {
+	Name string
+	Args []string
+}
+
+// SystemFiles lastore-daemon 和 lastore-tools 都可以直接读取的文件
+var SystemFiles = []File{
+	{"logs/apt-history.log", "/var/log/apt/history.log"},
+	{"logs/apt-term.log", "/var/log/apt/term.log"},
+	{"logs/dpkg.log", "/var/log/dpkg.log"},
+	{"sources/sources.list", "/etc/apt/sources.list"},
+	{"sources/platform.list", "/var/lib/lastore/platform.list"},
+	{"state/upgrade-record.json", "/var/cache/lastore/upgrade_record.json"},
+	{"state/saved-jobs.json", "/tmp/lastoreJobCache.json"},
+	{"state/check-config.yaml", "/var/lib/lastore/check/config.yaml"},
+	{"platform/requests.jsonl", platformhttp.DefaultTraceFile},
+	{"platform/endpoints.json", platformhttp.DefaultEndpointStateFile},
+	{"platform/policy-push.json", policypush.DefaultStatusFile},
+	{"platform/telemetry-audit.jsonl", telemetry.DefaultAuditFile},
+}
+
+// SystemGlobs 收集的配置目录
+var SystemGlobs = []Glob{
+	{"sources/sources.list.d", "/etc/apt/sources.list.d/*"},
+	{"sources/oem-repo.conf.d", "/etc/deepin/lastore-daemon/oem-repo.conf.d/*"},
+	{"config/apt.conf.d", "/etc/apt/apt.conf.d/*"},
+	{"config/lastore", "/var/lib/lastore/*.conf"},
+}
+
+// SystemCommands 收集日志和磁盘占用的命令
+var SystemCommands = []Command{
+	{"logs/journal-lastore.log", []string{"journalctl", "--no-pager", "-o", "short-iso", "--since", "-7d", "-u", "lastore-*"}},
+	{"logs/journal-warning.log", []string{"journalctl", "--no-pager", "-o", "short-iso", "-b", "-p", "warning", "-n", "5000"}},
+	{"disk/df.txt", []string{"df", "-h", "-T"}},
+	{"disk/df-inodes.txt", []string{"df", "-i"}},
+	{"disk/cache-usage.txt", []string{"du", "-sh", "/var/cache/lastore", "/var/cache/apt/archives", "/var/lib/lastore", "/var/lib/apt/lists"}},
+}
+
+// CollectSystem 收集系统日志、apt 和 dpkg 日志、仓库配置、保存的任务和更新记录、平台访问记录以及磁盘占用
+func CollectSystem(b *Bundle) {
+	for _, f := range SystemFiles {
+		b.AddFile(f.Name, f.Path)
+	}
+	for _, g := range SystemGlobs {
+		b.AddGlob(g.Dir, g.Pattern)
+	}
+	for _, c := range SystemCommands {
+		b.AddCommand(c.Name, c.Args...)
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package supportbundle
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Mask 脱敏后的值
+const Mask = "***"
+
+// minSecretLen 短于该长度的值不按原文替换,避免误替换普通内容
+const minSecretLen = 6
+
+type redactRule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// 日志、配置和状态中可能出现的 token、设备标识和凭据
+var redactRules = []redactRule{
+	// apt 配置中的 Acquire::SmartMirrors::Token
+	{regexp.MustCompile(`(Acquire::SmartMirrors::Token\s+")[^"]*`), "${1}" + Mask},
+	// 请求头,包括日志中的 map 和 json 格式
+	{regexp.MustCompile(`(?i)((?:X-Repo-Token|X-MachineID)"?\s*[:=]?\s*\[?\s*"?)[^"\s,;\]]+`), "${1}" + Mask},
+	// token 中的设备标识、序列号和 MAC 地址
+	{regexp.MustCompile(`((?:^|[;"'\s])(?:i|sn|mac)=)[^;"'\s]+`), "${1}" + Mask},
+	// json 中的设备标识和 token
+	{regexp.MustCompile(`("(?i:machineId|hardwareId|sn|mac|boardSerial|token)"\s*:\s*")[^"]*`), "${1}" + Mask},
+	// 地址中的用户名和密码
+	{regexp.MustCompile(`(://)[^/\s:@"]+:[^/\s@"]+@`), "${1}" + Mask + "@"},
+	// apt history.log 中的用户信息(用户名，uid)
+	{regexp.MustCompile(`Requested-By: (.+?) \((.+?)\)`), "Requested-By: " + Mask + " (" + Mask + ")"},
+}
+
+// Redactor 删除收集的内容中的 token、设备标识等信息
+type Redactor struct {
+	secrets [][]byte
+}
+
+// NewRedactor 创建 Redactor,secrets 为需要按原文替换的值,如设备标识和 token
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLen {
+			r.secrets = append(r.secrets, []byte(secret))
+		}
+	}
+	return r
+}
+
+// Redact 返回脱敏后的内容
+func (r *Redactor) Redact(data []byte) []byte {
+	for _, secret := range r.secrets {
+		data = bytes.ReplaceAll(data, secret, []byte(Mask))
+	}
+	for _, rule := range redactRules {
+		data = rule.re.ReplaceAll(data, []byte(rule.repl))
+	}
+	return data
+}
@@ -573,6 +573,10 @@ func (p *APTSystem) initSource(nonUnknownList []string, otherList []string) {
 	if err != nil {
 		logger.Warning(err)
 	}
+	err = system.UpdateCategorySourceDirs()
+	if err != nil {
+		logger.Warning(err)
+	}
 }
 
 func ListInstallPackages(packages []string) ([]string, error) {
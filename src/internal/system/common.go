@@ -136,8 +136,11 @@ const (
 	ErrorIO                      JobErrorType = "ioError"
 	ErrorDamagePackage           JobErrorType = "damagePackage" // 包损坏,需要删除后重新下载或者安装
 	ErrorInvalidSourcesList      JobErrorType = "invalidSourceList"
+	ErrorMissingRepoKey          JobErrorType = "missingRepoKey" // 仓库通过 Signed-By 指定的公钥不存在或已过期
 	ErrorPlatformUnreachable     JobErrorType = "platformUnreachable"
 	ErrorImmutableRefreshFailed  JobErrorType = "immutableRefreshFailed"
+	ErrorKernelNotBootable       JobErrorType = "kernelNotBootable"    // 新内核缺少 initramfs 或 GRUB 启动项
+	ErrorUntrustedCheckScript    JobErrorType = "untrustedCheckScript" // 更新平台下发的检查脚本没有签名或签名无效
 
 	ErrorMissCoreFile  JobErrorType = "missCoreFile"
 	ErrorScript        JobErrorType = "scriptError"
@@ -0,0 +1,393 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" // #nosec G505 v4 公钥指纹按 RFC 4880 使用 sha1
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 读取公钥信息不依赖 gpg 命令(apt 只依赖 gpgv),只解析 OpenPGP 公钥文件中的数据包,见 RFC 4880 和 RFC 9580。
+// 不校验签名,自签名按签发者判断,只用于展示和提前发现公钥问题,仓库签名仍然由 apt 校验
+
+const (
+	armorBegin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	armorEnd   = "-----END PGP PUBLIC KEY BLOCK-----"
+
+	pgpTagSignature = 2
+	pgpTagPublicKey = 6
+	pgpTagUserID    = 13
+	pgpTagSubkey    = 14
+
+	pgpSigDirectKey    = 0x1f
+	pgpSigKeyRevoke    = 0x20
+	pgpSigCertRevoke   = 0x30
+	pgpSubCreationTime = 2
+	pgpSubKeyExpire    = 9
+	pgpSubIssuer       = 16
+	pgpSubIssuerFpr    = 33
+)
+
+// dearmor 将 ascii armor 格式的公钥转换为二进制格式,包含多个公钥块时依次拼接,不是 armor 格式时返回原数据
+func dearmor(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte(armorBegin)) {
+		return data, nil
+	}
+	var out []byte
+	var body strings.Builder
+	inBlock, inHeader := false, false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == armorBegin:
+			inBlock, inHeader = true, true
+			body.Reset()
+		case !inBlock:
+		case line == armorEnd:
+			inBlock = false
+			block, err := base64.StdEncoding.DecodeString(body.String())
+			if err != nil {
+				return nil, fmt.Errorf("invalid armored key: %w", err)
+			}
+			out = append(out, block...)
+		case inHeader:
+			// 头部如 Comment: 与数据之间以空行分隔,没有头部时直接开始数据
+			if line == "" || !strings.Contains(line, ": ") {
+				inHeader = false
+				body.WriteString(line)
+			}
+		case strings.HasPrefix(line, "="):
+			// crc24 校验行
+		default:
+			body.WriteString(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inBlock {
+		return nil, errors.New("invalid armored key: missing end line")
+	}
+	return out, nil
+}
+
+type pgpPacket struct {
+	tag  int
+	body []byte
+}
+
+// readPgpPackets 按新旧两种包头格式拆分数据包
+func readPgpPackets(data []byte) ([]pgpPacket, error) {
+	var packets []pgpPacket
+	for len(data) > 0 {
+		hdr := data[0]
+		if hdr&0x80 == 0 {
+			return nil, errors.New("invalid openpgp packet header")
+		}
+		data = data[1:]
+		var p pgpPacket
+		if hdr&0x40 == 0 {
+			p.tag = int(hdr>>2) & 0xf
+			var n int
+			switch hdr & 3 {
+			case 0:
+				n = 1
+			case 1:
+				n = 2
+			case 2:
+				n = 4
+			default:
+				// 长度不确定,直到数据结束
+				p.body, data = data, nil
+				packets = append(packets, p)
+				continue
+			}
+			if len(data) < n {
+				return nil, errors.New("truncated openpgp packet")
+			}
+			var length uint64
+			for _, b := range data[:n] {
+				length = length<<8 | uint64(b)
+			}
+			data = data[n:]
+			if uint64(len(data)) < length {
+				return nil, errors.New("truncated openpgp packet")
+			}
+			p.body, data = data[:length], data[length:]
+			packets = append(packets, p)
+			continue
+		}
+		p.tag = int(hdr & 0x3f)
+		for {
+			if len(data) == 0 {
+				return nil, errors.New("truncated openpgp packet")
+			}
+			var length uint64
+			partial := false
+			switch o := data[0]; {
+			case o < 192:
+				length, data = uint64(o), data[1:]
+			case o < 224:
+				if len(data) < 2 {
+					return nil, errors.New("truncated openpgp packet")
+				}
+				length, data = uint64(o-192)<<8+uint64(data[1])+192, data[2:]
+			case o == 255:
+				if len(data) < 5 {
+					return nil, errors.New("truncated openpgp packet")
+				}
+				length, data = uint64(binary.BigEndian.Uint32(data[1:5])), data[5:]
+			default:
+				length, data, partial = 1<<(o&0x1f), data[1:], true
+			}
+			if uint64(len(data)) < length {
+				return nil, errors.New("truncated openpgp packet")
+			}
+			p.body, data = append(p.body, data[:length]...), data[length:]
+			if !partial {
+				break
+			}
+		}
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
+
+// parsePgpPublicKey 解析公钥包,返回指纹和创建时间,不支持的版本返回错误
+func parsePgpPublicKey(body []byte) (string, time.Time, error) {
+	if len(body) < 6 {
+		return "", time.Time{}, errors.New("truncated public key packet")
+	}
+	created := time.Unix(int64(binary.BigEndian.Uint32(body[1:5])), 0).UTC()
+	var fpr []byte
+	switch body[0] {
+	case 4:
+		h := sha1.New() // #nosec G401
+		h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+		h.Write(body)
+		fpr = h.Sum(nil)
+	case 5, 6:
+		prefix := byte(0x9a)
+		if body[0] == 6 {
+			prefix = 0x9b
+		}
+		h := sha256.New()
+		h.Write([]byte{prefix})
+		_ = binary.Write(h, binary.BigEndian, uint32(len(body)))
+		h.Write(body)
+		fpr = h.Sum(nil)
+	default:
+		return "", time.Time{}, fmt.Errorf("unsupported public key version %d", body[0])
+	}
+	return strings.ToUpper(hex.EncodeToString(fpr)), created, nil
+}
+
+type pgpSignature struct {
+	sigType   byte
+	created   time.Time
+	keyExpire *uint32 // 公钥有效期,相对公钥创建时间的秒数,0 表示永不过期
+	issuer    string  // 签发者的 keyid 或指纹
+}
+
+// parsePgpSignature 解析签名包中需要的子包,不校验签名
+func parsePgpSignature(body []byte) (*pgpSignature, error) {
+	if len(body) < 1 {
+		return nil, errors.New("truncated signature packet")
+	}
+	sig := &pgpSignature{}
+	if body[0] == 3 || body[0] == 2 {
+		if len(body) < 15 {
+			return nil, errors.New("truncated signature packet")
+		}
+		sig.sigType = body[2]
+		sig.created = time.Unix(int64(binary.BigEndian.Uint32(body[3:7])), 0).UTC()
+		sig.issuer = strings.ToUpper(hex.EncodeToString(body[7:15]))
+		return sig, nil
+	}
+	if body[0] < 4 || body[0] > 6 || len(body) < 4 {
+		return nil, fmt.Errorf("unsupported signature version %d", body[0])
+	}
+	sig.sigType = body[1]
+	rest := body[4:]
+	lenSize := 2
+	if body[0] == 6 {
+		lenSize = 4
+	}
+	// 依次为签名数据包含的子包和不包含的子包,签发者可能在后者中
+	for i := 0; i < 2; i++ {
+		if len(rest) < lenSize {
+			return nil, errors.New("truncated signature packet")
+		}
+		var n int
+		if lenSize == 2 {
+			n = int(binary.BigEndian.Uint16(rest))
+		} else {
+			n = int(binary.BigEndian.Uint32(rest))
+		}
+		rest = rest[lenSize:]
+		if n < 0 || len(rest) < n {
+			return nil, errors.New("truncated signature packet")
+		}
+		if err := sig.parseSubpackets(rest[:n], i == 0); err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+	}
+	return sig, nil
+}
+
+func (sig *pgpSignature) parseSubpackets(data []byte, hashed bool) error {
+	for len(data) > 0 {
+		var length int
+		switch o := data[0]; {
+		case o < 192:
+			length, data = int(o), data[1:]
+		case o < 255:
+			if len(data) < 2 {
+				return errors.New("truncated signature subpacket")
+			}
+			length, data = int(o-192)<<8+int(data[1])+192, data[2:]
+		default:
+			if len(data) < 5 {
+				return errors.New("truncated signature subpacket")
+			}
+			length, data = int(binary.BigEndian.Uint32(data[1:5])), data[5:]
+		}
+		if length < 1 || len(data) < length {
+			return errors.New("truncated signature subpacket")
+		}
+		typ, value := data[0]&0x7f, data[1:length]
+		data = data[length:]
+		switch {
+		case typ == pgpSubCreationTime && hashed && len(value) == 4:
+			sig.created = time.Unix(int64(binary.BigEndian.Uint32(value)), 0).UTC()
+		case typ == pgpSubKeyExpire && hashed && len(value) == 4:
+			v := binary.BigEndian.Uint32(value)
+			sig.keyExpire = &v
+		case typ == pgpSubIssuerFpr && len(value) > 1:
+			sig.issuer = strings.ToUpper(hex.EncodeToString(value[1:]))
+		case typ == pgpSubIssuer && len(value) == 8 && sig.issuer == "":
+			sig.issuer = strings.ToUpper(hex.EncodeToString(value))
+		}
+	}
+	return nil
+}
+
+// pgpKeyBuilder 按数据包的顺序组装一个公钥
+type pgpKeyBuilder struct {
+	key        RepoKey
+	inPrimary  bool // 当前位置是否属于主密钥(子密钥之前)
+	uid        string
+	uidRevoked bool
+	directSig  *pgpSignature // 最新的直接签名
+	uidSig     *pgpSignature // 最新的 uid 自签名
+}
+
+func (b *pgpKeyBuilder) isSelf(sig *pgpSignature) bool {
+	// 没有签发者信息时视为自签名;v4 的 keyid 为指纹的后 8 字节,v5、v6 为前 8 字节
+	return sig.issuer == "" || strings.HasSuffix(b.key.Fingerprint, sig.issuer) || strings.HasPrefix(b.key.Fingerprint, sig.issuer)
+}
+
+func (b *pgpKeyBuilder) flushUID() {
+	if b.uid != "" && !b.uidRevoked {
+		b.key.UserIDs = append(b.key.UserIDs, b.uid)
+	}
+	b.uid, b.uidRevoked = "", false
+}
+
+func (b *pgpKeyBuilder) addSignature(sig *pgpSignature) {
+	if !b.inPrimary || !b.isSelf(sig) {
+		return
+	}
+	switch {
+	case sig.sigType == pgpSigKeyRevoke:
+		b.key.Revoked = true
+	case sig.sigType == pgpSigCertRevoke && b.uid != "":
+		b.uidRevoked = true
+	case sig.sigType == pgpSigDirectKey:
+		if b.directSig == nil || !sig.created.Before(b.directSig.created) {
+			b.directSig = sig
+		}
+	case sig.sigType >= 0x10 && sig.sigType <= 0x13 && b.uid != "":
+		if b.uidSig == nil || !sig.created.Before(b.uidSig.created) {
+			b.uidSig = sig
+		}
+	}
+}
+
+func (b *pgpKeyBuilder) finish() RepoKey {
+	b.flushUID()
+	// 与 gpg 相同,uid 自签名中的有效期优先,没有时使用直接签名中的有效期
+	var expire *uint32
+	for _, sig := range []*pgpSignature{b.directSig, b.uidSig} {
+		if sig != nil && sig.keyExpire != nil {
+			expire = sig.keyExpire
+		}
+	}
+	if expire != nil && *expire != 0 {
+		b.key.Expires = b.key.Created.Add(time.Duration(*expire) * time.Second)
+	}
+	return b.key
+}
+
+// parsePgpKeys 解析二进制格式的公钥文件中的所有公钥
+func parsePgpKeys(data []byte) ([]RepoKey, error) {
+	packets, err := readPgpPackets(data)
+	if err != nil {
+		return nil, err
+	}
+	var keys []RepoKey
+	var cur *pgpKeyBuilder
+	for _, p := range packets {
+		switch p.tag {
+		case pgpTagPublicKey:
+			if cur != nil {
+				keys = append(keys, cur.finish())
+			}
+			cur = nil
+			fpr, created, err := parsePgpPublicKey(p.body)
+			if err != nil {
+				// 跳过不支持的公钥,如已经废弃的 v3 公钥
+				logger.Warning(err)
+				continue
+			}
+			cur = &pgpKeyBuilder{key: RepoKey{Fingerprint: fpr, Created: created}, inPrimary: true}
+		case pgpTagUserID:
+			if cur != nil && cur.inPrimary {
+				cur.flushUID()
+				cur.uid = string(p.body)
+			}
+		case pgpTagSubkey:
+			if cur != nil {
+				cur.flushUID()
+				cur.inPrimary = false
+			}
+		case pgpTagSignature:
+			if cur == nil {
+				continue
+			}
+			sig, err := parsePgpSignature(p.body)
+			if err != nil {
+				logger.Warning(err)
+				continue
+			}
+			cur.addSignature(sig)
+		}
+	}
+	if cur != nil {
+		keys = append(keys, cur.finish())
+	}
+	return keys, nil
+}
@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 管理员可以在 /etc/deepin/lastore-daemon/profile.conf.d/*.json 中声明本机期望的软件包状态,
+// 格式与更新平台下发的基线软件包清单相同,例如:
+//
+//	{
+//		"core": [{"name": "vim", "version": [{"version": "2:9.0.1378-2", "arch": "amd64"}], "need": "strict"}],
+//		"select": [{"name": "htop"}],
+//		"freeze": [{"name": "linux-image-amd64"}],
+//		"purge": [{"name": "nano"}]
+//	}
+const PackageProfileConfigDir = "/etc/deepin/lastore-daemon/profile.conf.d/"
+
+// PackageProfile 期望的软件包状态
+type PackageProfile struct {
+	Core   []PlatformPackageInfo `json:"core"`   // 必须安装,指定版本且 need 不为 skipversion 和 exist 时还需要版本一致
+	Select []PlatformPackageInfo `json:"select"` // 可选安装,不会主动安装或删除
+	Freeze []PlatformPackageInfo `json:"freeze"` // 禁止修改,已安装时固定为当前版本
+	Purge  []PlatformPackageInfo `json:"purge"`  // 必须删除
+}
+
+var (
+	profilePkgNameReg    = regexp.MustCompile(`^[a-z0-9][a-z0-9+.\-]*$`)
+	profilePkgVersionReg = regexp.MustCompile(`^[a-zA-Z0-9.+~:-]+$`)
+)
+
+func (p *PackageProfile) validate() error {
+	lists := [][]PlatformPackageInfo{p.Core, p.Select, p.Freeze, p.Purge}
+	for _, list := range lists {
+		for _, pkg := range list {
+			if !profilePkgNameReg.MatchString(pkg.Name) {
+				return fmt.Errorf("invalid package name %q", pkg.Name)
+			}
+			for _, v := range pkg.AllArchVersion {
+				if v.Version != "" && !profilePkgVersionReg.MatchString(v.Version) {
+					return fmt.Errorf("package %v: invalid version %q", pkg.Name, v.Version)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LoadPackageProfiles 按文件名顺序读取 dir 中的软件包清单并合并,无效的文件会被忽略。
+// 同一个软件包在多个文件中出现时,后加载的文件中的 core、select、purge 声明覆盖之前的声明,freeze 声明与其它声明独立合并
+func LoadPackageProfiles(dir string) PackageProfile {
+	var result PackageProfile
+	infos, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warningf("failed to read dir %v, error is %v", dir, err)
+		}
+		return result
+	}
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+
+	const (
+		kindCore = iota
+		kindSelect
+		kindPurge
+	)
+	type declared struct {
+		kind int
+		info PlatformPackageInfo
+	}
+	desired := make(map[string]declared)
+	frozen := make(map[string]PlatformPackageInfo)
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logger.Warningf("failed to read package profile %v: %v", file, err)
+			continue
+		}
+		var profile PackageProfile
+		err = json.Unmarshal(content, &profile)
+		if err == nil {
+			err = profile.validate()
+		}
+		if err != nil {
+			logger.Warningf("ignore invalid package profile %v: %v", file, err)
+			continue
+		}
+		for _, pkg := range profile.Core {
+			desired[pkg.Name] = declared{kind: kindCore, info: pkg}
+		}
+		for _, pkg := range profile.Select {
+			desired[pkg.Name] = declared{kind: kindSelect, info: pkg}
+		}
+		for _, pkg := range profile.Purge {
+			desired[pkg.Name] = declared{kind: kindPurge, info: pkg}
+		}
+		for _, pkg := range profile.Freeze {
+			frozen[pkg.Name] = pkg
+		}
+	}
+
+	var desiredNames, frozenNames []string
+	for name := range desired {
+		desiredNames = append(desiredNames, name)
+	}
+	for name := range frozen {
+		frozenNames = append(frozenNames, name)
+	}
+	sort.Strings(desiredNames)
+	sort.Strings(frozenNames)
+	for _, name := range desiredNames {
+		d := desired[name]
+		switch d.kind {
+		case kindCore:
+			result.Core = append(result.Core, d.info)
+		case kindSelect:
+			result.Select = append(result.Select, d.info)
+		case kindPurge:
+			result.Purge = append(result.Purge, d.info)
+		}
+	}
+	for _, name := range frozenNames {
+		result.Freeze = append(result.Freeze, frozen[name])
+	}
+	return result
+}
+
+// ReconcileItem 收敛计划中的一个软件包
+type ReconcileItem struct {
+	Name      string
+	Version   string `json:",omitempty"` // 目标版本,为空时不限制版本
+	Installed string `json:",omitempty"` // 当前安装的版本,未安装时为空
+	Reason    string `json:",omitempty"` // 跳过的原因,只用于 Conflicts
+}
+
+// ReconcilePlan 将系统收敛到 PackageProfile 描述的状态需要执行的操作
+type ReconcilePlan struct {
+	Install   []ReconcileItem // 需要安装的软件包
+	Change    []ReconcileItem // 需要修改版本的软件包
+	Remove    []ReconcileItem // 需要删除的软件包
+	Freeze    []ReconcileItem // 固定为当前版本的软件包
+	Conflicts []ReconcileItem // 与禁止修改清单冲突而跳过的操作
+}
+
+// Empty 系统已经处于期望的状态,没有需要执行的操作
+func (p *ReconcilePlan) Empty() bool {
+	return len(p.Install) == 0 && len(p.Change) == 0 && len(p.Remove) == 0
+}
+
+// Args 执行计划使用的 apt-get install 参数,删除的软件包以 - 结尾,固定的软件包指定为当前版本,
+// 避免作为依赖被升级或删除
+func (p *ReconcilePlan) Args() []string {
+	if p.Empty() {
+		return nil
+	}
+	var args []string
+	for _, items := range [][]ReconcileItem{p.Install, p.Change, p.Freeze} {
+		for _, item := range items {
+			if item.Version != "" {
+				args = append(args, item.Name+"="+item.Version)
+			} else {
+				args = append(args, item.Name)
+			}
+		}
+	}
+	for _, item := range p.Remove {
+		args = append(args, item.Name+"-")
+	}
+	return args
+}
+
+// profileVersion 软件包清单中与 arch 匹配的版本,不需要检查版本时返回空
+func profileVersion(pkg PlatformPackageInfo, arch string) string {
+	if pkg.Need == "skipversion" || pkg.Need == "exist" {
+		return ""
+	}
+	for _, v := range pkg.AllArchVersion {
+		if v.Arch == arch || v.Arch == "all" || v.Arch == "" {
+			return v.Version
+		}
+	}
+	return ""
+}
+
+// ComputeReconcilePlan 比较 profile 与已安装的软件包 installed(包名 -> 版本和架构),
+// arch 为未安装的软件包使用的系统架构。禁止修改清单中的软件包不会被安装、删除或修改版本
+func ComputeReconcilePlan(profile PackageProfile, installed map[string]Version, arch string) ReconcilePlan {
+	var plan ReconcilePlan
+	frozen := make(map[string]bool)
+	for _, pkg := range profile.Freeze {
+		frozen[pkg.Name] = true
+		cur, ok := installed[pkg.Name]
+		if !ok {
+			continue
+		}
+		want := profileVersion(pkg, cur.Arch)
+		if want != "" && want != cur.Version {
+			plan.Conflicts = append(plan.Conflicts, ReconcileItem{Name: pkg.Name, Version: want, Installed: cur.Version,
+				Reason: "frozen at a different version"})
+		}
+		plan.Freeze = append(plan.Freeze, ReconcileItem{Name: pkg.Name, Version: cur.Version, Installed: cur.Version})
+	}
+
+	for _, pkg := range profile.Core {
+		cur, ok := installed[pkg.Name]
+		if !ok {
+			item := ReconcileItem{Name: pkg.Name, Version: profileVersion(pkg, arch)}
+			if frozen[pkg.Name] {
+				item.Reason = "frozen but not installed"
+				plan.Conflicts = append(plan.Conflicts, item)
+				continue
+			}
+			plan.Install = append(plan.Install, item)
+			continue
+		}
+		want := profileVersion(pkg, cur.Arch)
+		if want == "" || want == cur.Version {
+			continue
+		}
+		item := ReconcileItem{Name: pkg.Name, Version: want, Installed: cur.Version}
+		if frozen[pkg.Name] {
+			item.Reason = "frozen"
+			plan.Conflicts = append(plan.Conflicts, item)
+			continue
+		}
+		plan.Change = append(plan.Change, item)
+	}
+
+	for _, pkg := range profile.Purge {
+		cur, ok := installed[pkg.Name]
+		if !ok {
+			continue
+		}
+		item := ReconcileItem{Name: pkg.Name, Installed: cur.Version}
+		if frozen[pkg.Name] {
+			item.Reason = "frozen"
+			plan.Conflicts = append(plan.Conflicts, item)
+			continue
+		}
+		plan.Remove = append(plan.Remove, item)
+	}
+	return plan
+}
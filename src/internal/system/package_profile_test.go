@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPackageProfiles(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]string{
+		"10-base.json":      `{"core":[{"name":"vim"},{"name":"nano"}],"select":[{"name":"htop"}],"freeze":[{"name":"linux-image-amd64"}]}`,
+		"20-lab.json":       `{"core":[{"name":"htop"}],"purge":[{"name":"nano"}],"freeze":[{"name":"vim","version":[{"version":"2:9.0","arch":"amd64"}]}]}`,
+		"30-bad-name.json":  `{"core":[{"name":"Bad Name"}]}`,
+		"40-bad-ver.json":   `{"core":[{"name":"curl","version":[{"version":"1.0 ; rm"}]}]}`,
+		"50-invalid.json":   `{`,
+		"60-not-config.txt": `{"core":[{"name":"txt"}]}`,
+	}
+	for name, content := range configs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	profile := LoadPackageProfiles(dir)
+	names := func(list []PlatformPackageInfo) []string {
+		var r []string
+		for _, pkg := range list {
+			r = append(r, pkg.Name)
+		}
+		return r
+	}
+	assert.Equal(t, []string{"htop", "vim"}, names(profile.Core))
+	assert.Empty(t, profile.Select)
+	assert.Equal(t, []string{"nano"}, names(profile.Purge))
+	assert.Equal(t, []string{"linux-image-amd64", "vim"}, names(profile.Freeze))
+
+	assert.Equal(t, PackageProfile{}, LoadPackageProfiles(filepath.Join(dir, "not-exist")))
+}
+
+func TestComputeReconcilePlan(t *testing.T) {
+	profile := PackageProfile{
+		Core: []PlatformPackageInfo{
+			{Name: "vim", AllArchVersion: []Version{{Version: "2", Arch: "arm64"}, {Version: "3", Arch: "amd64"}}, Need: "strict"},
+			{Name: "curl", AllArchVersion: []Version{{Version: "8", Arch: "all"}}},
+			{Name: "htop"},
+			{Name: "git", AllArchVersion: []Version{{Version: "1", Arch: "amd64"}}, Need: "skipversion"},
+			{Name: "kernel", AllArchVersion: []Version{{Version: "6.6", Arch: "amd64"}}},
+			{Name: "wget"},
+		},
+		Select: []PlatformPackageInfo{{Name: "tree"}},
+		Freeze: []PlatformPackageInfo{{Name: "kernel"}, {Name: "wget"}, {Name: "zsh", AllArchVersion: []Version{{Version: "5.8", Arch: "amd64"}}}},
+		Purge:  []PlatformPackageInfo{{Name: "nano"}, {Name: "zsh"}, {Name: "emacs"}},
+	}
+	installed := map[string]Version{
+		"vim":    {Version: "2", Arch: "amd64"},
+		"git":    {Version: "2", Arch: "amd64"},
+		"kernel": {Version: "6.1", Arch: "amd64"},
+		"zsh":    {Version: "5.9", Arch: "amd64"},
+		"nano":   {Version: "7", Arch: "amd64"},
+	}
+
+	plan := ComputeReconcilePlan(profile, installed, "amd64")
+	assert.Equal(t, []ReconcileItem{{Name: "curl", Version: "8"}, {Name: "htop"}}, plan.Install)
+	assert.Equal(t, []ReconcileItem{{Name: "vim", Version: "3", Installed: "2"}}, plan.Change)
+	assert.Equal(t, []ReconcileItem{{Name: "nano", Installed: "7"}}, plan.Remove)
+	assert.Equal(t, []ReconcileItem{
+		{Name: "kernel", Version: "6.1", Installed: "6.1"},
+		{Name: "zsh", Version: "5.9", Installed: "5.9"},
+	}, plan.Freeze)
+	assert.Equal(t, []ReconcileItem{
+		{Name: "zsh", Version: "5.8", Installed: "5.9", Reason: "frozen at a different version"},
+		{Name: "kernel", Version: "6.6", Installed: "6.1", Reason: "frozen"},
+		{Name: "wget", Reason: "frozen but not installed"},
+		{Name: "zsh", Installed: "5.9", Reason: "frozen"},
+	}, plan.Conflicts)
+	assert.False(t, plan.Empty())
+	assert.Equal(t, []string{"curl=8", "htop", "vim=3", "kernel=6.1", "zsh=5.9", "nano-"}, plan.Args())
+
+	converged := ComputeReconcilePlan(PackageProfile{Core: []PlatformPackageInfo{{Name: "vim"}}, Freeze: []PlatformPackageInfo{{Name: "vim"}}},
+		installed, "amd64")
+	assert.True(t, converged.Empty())
+	assert.Nil(t, converged.Args())
+}
@@ -0,0 +1,458 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/linuxdeepin/go-lib/strv"
+)
+
+const (
+	// RepoKeyringDir 仓库公钥的存放路径,公钥通过 Signed-By 只对对应的仓库生效,不加入 trusted.gpg.d 全局信任
+	RepoKeyringDir     = "/etc/apt/keyrings"
+	repoKeyringPrefix  = "lastore-"
+	repoKeyringExt     = ".gpg"
+	RepoKeyExpireAhead = 30 * 24 * time.Hour // 公钥在该时间内过期时给出警告
+)
+
+// RepoKey 仓库公钥
+type RepoKey struct {
+	Fingerprint string
+	UserIDs     []string
+	Created     time.Time
+	Expires     time.Time // 零值表示永不过期
+	Revoked     bool
+}
+
+// Usable 公钥未过期且未被吊销
+func (k *RepoKey) Usable(now time.Time) bool {
+	return !k.Revoked && (k.Expires.IsZero() || now.Before(k.Expires))
+}
+
+// ExpiringSoon 公钥可用,但会在 RepoKeyExpireAhead 内过期
+func (k *RepoKey) ExpiringSoon(now time.Time) bool {
+	return k.Usable(now) && !k.Expires.IsZero() && k.Expires.Sub(now) < RepoKeyExpireAhead
+}
+
+// RepoKeyring 一个仓库的公钥文件
+type RepoKeyring struct {
+	Repo         string // 去掉协议的仓库地址,如 packages.example.com/desktop
+	Path         string
+	Keys         []RepoKey
+	Usable       bool // 至少有一个可用的公钥
+	ExpiringSoon bool // 可用的公钥都会在 RepoKeyExpireAhead 内过期
+}
+
+// repoKeyID 去掉仓库地址的协议和结尾的 /,delivery 协议的仓库和原仓库使用相同的公钥
+func repoKeyID(repoUrl string) string {
+	if idx := strings.Index(repoUrl, "://"); idx != -1 {
+		repoUrl = repoUrl[idx+3:]
+	}
+	return strings.Trim(repoUrl, "/")
+}
+
+// escapeRepoKeyID 将仓库地址转换为文件名,除字母、数字和 -._~: 外的字符编码为 %XX,可以通过 url.PathUnescape 还原
+func escapeRepoKeyID(id string) string {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~:", c) != -1 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func repoKeyringPath(dir, repoUrl string) string {
+	return filepath.Join(dir, repoKeyringPrefix+escapeRepoKeyID(repoKeyID(repoUrl))+repoKeyringExt)
+}
+
+// RepoKeyringPath 返回仓库对应的公钥文件路径
+func RepoKeyringPath(repoUrl string) string {
+	return repoKeyringPath(RepoKeyringDir, repoUrl)
+}
+
+func validateRepoUrl(repoUrl string) error {
+	id := repoKeyID(repoUrl)
+	if id == "" || strings.Contains(id, "..") || strings.ContainsAny(id, " \t\n") {
+		return fmt.Errorf("invalid repo url: %q", repoUrl)
+	}
+	return nil
+}
+
+// ReadKeyring 读取公钥文件或公钥数据中的公钥信息,支持二进制和 ascii armor 格式
+func ReadKeyring(data []byte) ([]RepoKey, error) {
+	data, err := dearmor(data)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parsePgpKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no public key found")
+	}
+	return keys, nil
+}
+
+func newRepoKeyring(repo, path string, keys []RepoKey, now time.Time) *RepoKeyring {
+	k := &RepoKeyring{Repo: repo, Path: path, Keys: keys, ExpiringSoon: true}
+	for i := range keys {
+		if keys[i].Usable(now) {
+			k.Usable = true
+			if !keys[i].ExpiringSoon(now) {
+				k.ExpiringSoon = false
+			}
+		}
+	}
+	if !k.Usable {
+		k.ExpiringSoon = false
+	}
+	return k
+}
+
+// LoadRepoKeyring 读取公钥文件
+func LoadRepoKeyring(path string) (*RepoKeyring, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ReadKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), repoKeyringPrefix), repoKeyringExt)
+	repo, err := url.PathUnescape(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid keyring name: %w", path, err)
+	}
+	return newRepoKeyring(repo, path, keys, time.Now()), nil
+}
+
+// writeRepoKeyring 检查公钥后写入仓库对应的公钥文件,ascii armor 格式会被转换为二进制格式
+func writeRepoKeyring(repoUrl string, data []byte) (*RepoKeyring, error) {
+	if err := validateRepoUrl(repoUrl); err != nil {
+		return nil, err
+	}
+	keys, err := ReadKeyring(data)
+	if err != nil {
+		return nil, err
+	}
+	keyring := newRepoKeyring(repoKeyID(repoUrl), RepoKeyringPath(repoUrl), keys, time.Now())
+	if !keyring.Usable {
+		return nil, fmt.Errorf("all keys for %s are expired or revoked", repoUrl)
+	}
+	data, err = dearmor(data)
+	if err != nil {
+		return nil, err
+	}
+	// #nosec G301
+	if err := os.MkdirAll(RepoKeyringDir, 0755); err != nil {
+		return nil, err
+	}
+	tmp := keyring.Path + ".tmp"
+	// apt 使用 _apt 用户读取公钥,需要其他用户可读
+	// #nosec G306
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, keyring.Path); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// ImportRepoKey 导入仓库公钥,仓库已有公钥时返回错误,需要使用 RotateRepoKey 替换
+func ImportRepoKey(repoUrl string, data []byte) (*RepoKeyring, error) {
+	if _, err := os.Stat(RepoKeyringPath(repoUrl)); err == nil {
+		return nil, fmt.Errorf("key for %s already exists", repoUrl)
+	}
+	return writeRepoKeyring(repoUrl, data)
+}
+
+// RotateRepoKey 使用新的公钥替换仓库已有的公钥,新公钥检查通过后才会替换
+func RotateRepoKey(repoUrl string, data []byte) (*RepoKeyring, error) {
+	if _, err := os.Stat(RepoKeyringPath(repoUrl)); err != nil {
+		return nil, fmt.Errorf("no key for %s: %w", repoUrl, err)
+	}
+	return writeRepoKeyring(repoUrl, data)
+}
+
+// RemoveRepoKey 删除仓库的公钥
+func RemoveRepoKey(repoUrl string) error {
+	if err := validateRepoUrl(repoUrl); err != nil {
+		return err
+	}
+	return os.Remove(RepoKeyringPath(repoUrl))
+}
+
+// ListRepoKeys 列出 lastore 管理的所有仓库公钥
+func ListRepoKeys() ([]*RepoKeyring, error) {
+	paths, err := filepath.Glob(filepath.Join(RepoKeyringDir, repoKeyringPrefix+"*"+repoKeyringExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	keyrings := []*RepoKeyring{}
+	for _, path := range paths {
+		keyring, err := LoadRepoKeyring(path)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		keyrings = append(keyrings, keyring)
+	}
+	return keyrings, nil
+}
+
+// applyRepoKeys 为启用且没有指定 Signed-By 的仓库设置 keyringDir 中对应的公钥,返回是否有修改
+func applyRepoKeys(f *SourceFile, keyringDir string) bool {
+	changed := false
+	for _, entry := range f.Entries() {
+		if !entry.Enabled {
+			continue
+		}
+		if _, ok := entry.Option("Signed-By"); ok {
+			continue
+		}
+		if trusted, _ := entry.Option("Trusted"); trusted == "yes" {
+			continue
+		}
+		var keyrings []string
+		for _, uri := range entry.URIs {
+			path := repoKeyringPath(keyringDir, uri)
+			if _, err := os.Stat(path); err == nil && !strv.Strv(keyrings).Contains(path) {
+				keyrings = append(keyrings, path)
+			}
+		}
+		if len(keyrings) > 0 {
+			entry.SetOption("Signed-By", strings.Join(keyrings, ","))
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ApplyRepoKeys 为仓库配置设置导入的公钥,解析失败时返回原内容
+func ApplyRepoKeys(content string, format SourceFormat) string {
+	f, err := ParseSources(content, format)
+	if err != nil {
+		logger.Warning(err)
+		return content
+	}
+	if !applyRepoKeys(f, RepoKeyringDir) {
+		return content
+	}
+	return string(f.Bytes())
+}
+
+// SourceKeyIssue 仓库公钥检查发现的问题
+type SourceKeyIssue struct {
+	File    string
+	URI     string
+	Keyring string
+	Problem string
+	Fatal   bool // 为 true 时更新该仓库一定会失败
+}
+
+func (i SourceKeyIssue) String() string {
+	return fmt.Sprintf("%s: %s %s: %s", i.File, i.URI, i.Keyring, i.Problem)
+}
+
+// sourceFilesOf 返回 path 中的仓库配置文件,path 为文件时返回自身
+func sourceFilesOf(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return []string{path}
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if IsSourceFileName(e.Name()) {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files
+}
+
+// trustedKeyrings 返回全局信任的公钥文件
+func trustedKeyrings() []string {
+	var paths []string
+	if _, err := os.Stat(trustedGpgFile); err == nil {
+		paths = append(paths, trustedGpgFile)
+	}
+	for _, pattern := range []string{"*.gpg", "*.asc"} {
+		matches, _ := filepath.Glob(filepath.Join(trustedGpgDir, pattern))
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CheckSourceKeys 检查 paths 中所有启用的仓库使用的公钥是否存在且可用:
+// Signed-By 指定公钥文件时检查该文件,指定指纹时在全局信任的公钥中查找,
+// 没有指定 Signed-By 时 apt 接受任意全局信任的公钥,无法确定对应关系,报告为无法校验
+func CheckSourceKeys(paths ...string) []SourceKeyIssue {
+	return checkSourceKeys(time.Now(), func(path string) ([]RepoKey, error) {
+		data, err := os.ReadFile(path) // #nosec G304
+		if err != nil {
+			return nil, err
+		}
+		return ReadKeyring(data)
+	}, trustedKeyrings(), paths...)
+}
+
+// matchFingerprint 判断 Signed-By 中的指纹是否与公钥匹配,支持长短 keyid 和结尾的 !
+func matchFingerprint(key RepoKey, fingerprint string) bool {
+	fingerprint = strings.ToUpper(strings.TrimSuffix(fingerprint, "!"))
+	return len(fingerprint) >= 8 && strings.HasSuffix(strings.ToUpper(key.Fingerprint), fingerprint)
+}
+
+func checkSourceKeys(now time.Time, readKeys func(path string) ([]RepoKey, error), trusted []string, paths ...string) []SourceKeyIssue {
+	var issues []SourceKeyIssue
+	cache := make(map[string]*RepoKeyring)
+	readErrs := make(map[string]error)
+	loadKeyring := func(path string) (*RepoKeyring, error) {
+		keyring, ok := cache[path]
+		if !ok {
+			keys, err := readKeys(path)
+			if err != nil {
+				readErrs[path] = err
+			} else {
+				keyring = newRepoKeyring("", path, keys, now)
+			}
+			cache[path] = keyring
+		}
+		return keyring, readErrs[path]
+	}
+	// 全局信任的公钥,有文件无法读取时 apt 仍然可能使用其中的公钥,不能确定仓库一定会失败
+	var trustedKeys []RepoKey
+	trustedLoaded, trustedFailed := false, false
+	loadTrusted := func() []RepoKey {
+		if !trustedLoaded {
+			trustedLoaded = true
+			for _, path := range trusted {
+				keyring, err := loadKeyring(path)
+				if err != nil {
+					logger.Warning(err)
+					trustedFailed = true
+					continue
+				}
+				trustedKeys = append(trustedKeys, keyring.Keys...)
+			}
+		}
+		return trustedKeys
+	}
+	// keyIssue 根据公钥的状态生成问题,公钥可用且不会很快过期时返回 false
+	keyIssue := func(issue *SourceKeyIssue, keyring *RepoKeyring) bool {
+		switch {
+		case !keyring.Usable:
+			issue.Problem = "all keys are expired or revoked"
+			issue.Fatal = true
+		case keyring.ExpiringSoon:
+			issue.Problem = "key expires soon"
+		default:
+			return false
+		}
+		return true
+	}
+	for _, path := range paths {
+		for _, file := range sourceFilesOf(path) {
+			f, err := LoadSourceFile(file)
+			if err != nil {
+				issues = append(issues, SourceKeyIssue{File: file, Problem: err.Error()})
+				continue
+			}
+			for _, entry := range f.Entries() {
+				if !entry.Enabled {
+					continue
+				}
+				if trusted, _ := entry.Option("Trusted"); trusted == "yes" {
+					continue
+				}
+				uri := strings.Join(entry.URIs, " ")
+				signedBy, ok := entry.Option("Signed-By")
+				// 内嵌的公钥由 apt 直接校验
+				if strings.Contains(signedBy, "\n") {
+					continue
+				}
+				if !ok || strings.TrimSpace(signedBy) == "" {
+					issue := SourceKeyIssue{File: file, URI: uri, Keyring: trustedGpgDir}
+					keyring := newRepoKeyring("", trustedGpgDir, loadTrusted(), now)
+					switch {
+					case !keyring.Usable && trustedFailed:
+						issue.Problem = "unverifiable: some trusted keyrings can not be read"
+					case !keyring.Usable:
+						issue.Problem = "no usable key in trusted keyrings"
+						issue.Fatal = true
+					case keyring.ExpiringSoon:
+						issue.Problem = "all trusted keys expire soon"
+					default:
+						issue.Problem = "unverifiable: no Signed-By, any trusted key is accepted"
+					}
+					issues = append(issues, issue)
+					continue
+				}
+				for _, item := range strings.FieldsFunc(signedBy, func(r rune) bool { return r == ',' || r == ' ' }) {
+					issue := SourceKeyIssue{File: file, URI: uri, Keyring: item}
+					// 指纹形式的 Signed-By 在全局信任的公钥中查找
+					if !strings.HasPrefix(item, "/") {
+						var keys []RepoKey
+						for _, key := range loadTrusted() {
+							if matchFingerprint(key, item) {
+								keys = append(keys, key)
+							}
+						}
+						if len(keys) == 0 {
+							issue.Problem = "key not found in trusted keyrings"
+							issue.Fatal = !trustedFailed
+							if trustedFailed {
+								issue.Problem = "unverifiable: key not found in readable trusted keyrings"
+							}
+							issues = append(issues, issue)
+						} else if keyIssue(&issue, newRepoKeyring("", "", keys, now)) {
+							issues = append(issues, issue)
+						}
+						continue
+					}
+					keyring, err := loadKeyring(item)
+					if err != nil {
+						// 只有公钥文件不存在时更新一定会失败,无法解析的公钥仍然交给 apt 校验
+						issue.Problem = err.Error()
+						issue.Fatal = errors.Is(err, fs.ErrNotExist)
+						if !issue.Fatal {
+							logger.Warning(err)
+						}
+						issues = append(issues, issue)
+						continue
+					}
+					if keyIssue(&issue, keyring) {
+						issues = append(issues, issue)
+					}
+				}
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadKeyring(t *testing.T) {
+	// testdata 中的公钥由 gpg 生成:第一个公钥修改过有效期、吊销了一个 uid,第二个公钥已吊销
+	data, err := os.ReadFile("testdata/repo-keys.gpg")
+	require.NoError(t, err)
+	keys, err := ReadKeyring(data)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "25797158407DCC42443F90FE8CFE6652AF6C4519", keys[0].Fingerprint)
+	assert.Equal(t, []string{"Example Archive Signing Key <archive@example.com>"}, keys[0].UserIDs)
+	assert.Equal(t, time.Unix(1792400270, 0).UTC(), keys[0].Created)
+	assert.Equal(t, time.Unix(2082801600, 0).UTC(), keys[0].Expires)
+	assert.False(t, keys[0].Revoked)
+	assert.Equal(t, "F3D56445A12118A24FE70C4F75B1B42767A3B5A1", keys[1].Fingerprint)
+	assert.True(t, keys[1].Revoked)
+	assert.True(t, keys[1].Expires.IsZero())
+
+	armored, err := os.ReadFile("testdata/repo-key.asc")
+	require.NoError(t, err)
+	armoredKeys, err := ReadKeyring(armored)
+	require.NoError(t, err)
+	assert.Equal(t, keys[:1], armoredKeys)
+
+	_, err = ReadKeyring([]byte("not a key"))
+	assert.Error(t, err)
+
+	now := time.Unix(2082801600, 0).Add(-24 * time.Hour)
+	keyring := newRepoKeyring("", "", keys, now)
+	assert.True(t, keyring.Usable)
+	assert.True(t, keyring.ExpiringSoon)
+	keyring = newRepoKeyring("", "", keys, time.Unix(2082801601, 0))
+	assert.False(t, keyring.Usable)
+	assert.False(t, keyring.ExpiringSoon)
+}
+
+func TestRepoKeyringPath(t *testing.T) {
+	assert.Equal(t, "/etc/apt/keyrings/lastore-packages.example.com%2Fdesktop.gpg",
+		RepoKeyringPath("https://packages.example.com/desktop/"))
+	assert.Equal(t, RepoKeyringPath("https://packages.example.com/desktop"),
+		RepoKeyringPath("delivery://packages.example.com/desktop"))
+	// 文件名可以还原为仓库地址,包含 _ 的地址不会与包含 / 的地址冲突
+	for _, repo := range []string{"example.com/a_b", "example.com/a/b", "example.com:8080/a%2Cb,c"} {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(repoKeyringPath("/", repo)), repoKeyringPrefix), repoKeyringExt)
+		assert.NotContains(t, name, "/")
+		assert.NotContains(t, name, ",")
+		got, err := url.PathUnescape(name)
+		require.NoError(t, err)
+		assert.Equal(t, repo, got)
+	}
+	assert.NotEqual(t, repoKeyringPath("/", "example.com/a_b"), repoKeyringPath("/", "example.com/a/b"))
+	assert.Error(t, validateRepoUrl("https://"))
+	assert.Error(t, validateRepoUrl("https://example.com/../../etc"))
+}
+
+func TestApplyRepoKeys(t *testing.T) {
+	dir := t.TempDir()
+	keyring := repoKeyringPath(dir, "https://ppa.example.com/")
+	require.NoError(t, os.WriteFile(keyring, []byte("key"), 0644))
+
+	f, err := ParseSources("deb https://ppa.example.com/ stable main\n"+
+		"deb [signed-by=/usr/share/keyrings/other.gpg] https://ppa.example.com/ testing main\n"+
+		"deb [trusted=yes] https://ppa.example.com/ unstable main\n"+
+		"deb https://other.example.com/ stable main\n", SourceFormatOneLine)
+	require.NoError(t, err)
+	assert.True(t, applyRepoKeys(f, dir))
+	assert.Equal(t, "deb [signed-by="+keyring+"] https://ppa.example.com/ stable main\n"+
+		"deb [signed-by=/usr/share/keyrings/other.gpg] https://ppa.example.com/ testing main\n"+
+		"deb [trusted=yes] https://ppa.example.com/ unstable main\n"+
+		"deb https://other.example.com/ stable main\n", string(f.Bytes()))
+	assert.False(t, applyRepoKeys(f, dir))
+}
+
+func TestCheckSourceKeys(t *testing.T) {
+	dir := t.TempDir()
+	content := "deb [signed-by=/keys/good.gpg] https://a.example.com/ stable main\n" +
+		"deb [signed-by=/keys/expired.gpg] https://b.example.com/ stable main\n" +
+		"deb [signed-by=/keys/soon.gpg] https://c.example.com/ stable main\n" +
+		"deb [signed-by=/keys/missing.gpg] https://d.example.com/ stable main\n" +
+		"deb [signed-by=/keys/missing.gpg] https://e.example.com/ stable main\n" +
+		"deb [signed-by=0123456789ABCDEF] https://f.example.com/ stable main\n" +
+		"# deb [signed-by=/keys/missing.gpg] https://g.example.com/ stable main\n" +
+		"deb https://h.example.com/ stable main\n" +
+		"deb [signed-by=89abcdef00000000!] https://i.example.com/ stable main\n" +
+		"deb [signed-by=FFFFFFFFFFFFFFFF] https://j.example.com/ stable main\n" +
+		"deb [trusted=yes] https://k.example.com/ stable main\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "custom.list"), []byte(content), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.save"), []byte("invalid"), 0644))
+
+	now := time.Unix(1700000000, 0)
+	keys := map[string][]RepoKey{
+		"/keys/good.gpg":    {{Fingerprint: "A"}},
+		"/keys/expired.gpg": {{Fingerprint: "B", Expires: now.Add(-time.Hour)}},
+		"/keys/soon.gpg":    {{Fingerprint: "C", Expires: now.Add(24 * time.Hour)}},
+		"/trusted/archive.gpg": {
+			{Fingerprint: "FFFF0123456789ABCDEF"},
+			{Fingerprint: "EEEE89ABCDEF00000000", Revoked: true},
+		},
+	}
+	readKeys := func(path string) ([]RepoKey, error) {
+		if k, ok := keys[path]; ok {
+			return k, nil
+		}
+		if strings.Contains(path, "missing") {
+			return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+		}
+		return nil, errors.New("invalid keyring")
+	}
+	issues := checkSourceKeys(now, readKeys, []string{"/trusted/archive.gpg"}, dir)
+	var uris []string
+	for _, issue := range issues {
+		uris = append(uris, issue.URI)
+	}
+	require.Equal(t, []string{"https://b.example.com/", "https://c.example.com/", "https://d.example.com/",
+		"https://e.example.com/", "https://h.example.com/", "https://i.example.com/", "https://j.example.com/"}, uris)
+	assert.True(t, issues[0].Fatal)
+	assert.False(t, issues[1].Fatal)
+	assert.True(t, issues[2].Fatal)
+	assert.True(t, issues[3].Fatal)
+	// 没有 Signed-By 的仓库无法确定公钥
+	assert.False(t, issues[4].Fatal)
+	assert.Contains(t, issues[4].Problem, "unverifiable")
+	// 指纹对应的公钥已吊销
+	assert.True(t, issues[5].Fatal)
+	// 指纹对应的公钥不在全局信任的公钥中
+	assert.True(t, issues[6].Fatal)
+	assert.Equal(t, "key not found in trusted keyrings", issues[6].Problem)
+
+	// 无法读取的公钥文件不会导致仓库被判断为一定失败
+	issues = checkSourceKeys(now, readKeys, []string{"/trusted/archive.gpg", "/trusted/broken.gpg"}, dir)
+	for _, issue := range issues {
+		if issue.URI == "https://j.example.com/" {
+			assert.False(t, issue.Fatal)
+			assert.Contains(t, issue.Problem, "unverifiable")
+		}
+	}
+	issues = checkSourceKeys(now, readKeys, []string{"/trusted/broken.gpg"}, dir)
+	for _, issue := range issues {
+		if issue.URI == "https://h.example.com/" {
+			assert.False(t, issue.Fatal)
+		}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "custom.list"), []byte("deb [signed-by=/keys/broken.gpg] https://a.example.com/ stable main\n"), 0644))
+	issues = checkSourceKeys(now, readKeys, nil, dir)
+	require.Len(t, issues, 1)
+	assert.False(t, issues[0].Fatal)
+
+	// 没有可用的全局信任公钥时,没有 Signed-By 的仓库一定会失败
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "custom.list"), []byte(content), 0644))
+	issues = checkSourceKeys(now, readKeys, nil, dir)
+	for _, issue := range issues {
+		if issue.URI == "https://h.example.com/" {
+			assert.True(t, issue.Fatal)
+		}
+	}
+}
@@ -0,0 +1,607 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每次修改仓库配置都会保存一个编号递增的版本,包括修改者、修改方式和与上一个版本的差异,
+// 用于查看仓库配置的修改历史以及回退到之前的配置
+const (
+	SourceRevisionDir  = "/var/lib/lastore/source-revisions"
+	maxSourceRevisions = 100
+)
+
+// SourceRevisionPaths 记录版本的仓库配置文件和目录,目录只记录其中的仓库文件
+func SourceRevisionPaths() []string {
+	return []string{
+		OriginSourceFile,
+		OriginSourceDir,
+		SoftLinkSystemSourceDir,
+		SecuritySourceDir,
+		PlatFormSourceFile,
+	}
+}
+
+// SourceFileState 仓库文件的内容,软链接只记录链接目标
+type SourceFileState struct {
+	Content string `json:",omitempty"`
+	Link    string `json:",omitempty"`
+}
+
+func (s SourceFileState) lines() []string {
+	if s.Link != "" {
+		return []string{"-> " + s.Link}
+	}
+	return splitLines(s.Content)
+}
+
+type SourceSnapshot struct {
+	Files    map[string]SourceFileState
+	Settings map[string]string `json:",omitempty"` // 仓库相关的 lastore 配置,如系统仓库类型和自定义仓库
+}
+
+type SourceRevision struct {
+	Rev      int
+	Time     time.Time
+	Author   string          // 修改者,如调用者的 uid 和可执行文件
+	Method   string          // 修改方式,如 SetUpdateSources
+	Diff     string          // 与上一个版本的差异
+	Good     bool            // 使用该版本检查更新成功
+	Snapshot *SourceSnapshot `json:",omitempty"`
+}
+
+type SourceRevisionStore struct {
+	trackMu sync.Mutex // 保证 Track 中修改前后的记录不被其他修改打断
+	mu      sync.Mutex
+	dir     string
+	paths   []string
+}
+
+func NewSourceRevisionStore(dir string, paths ...string) *SourceRevisionStore {
+	return &SourceRevisionStore{
+		dir:   dir,
+		paths: paths,
+	}
+}
+
+// TakeSnapshot 读取当前的仓库文件,settings 为调用者提供的仓库相关配置
+func (s *SourceRevisionStore) TakeSnapshot(settings map[string]string) (*SourceSnapshot, error) {
+	snap := &SourceSnapshot{
+		Files:    make(map[string]SourceFileState),
+		Settings: settings,
+	}
+	for _, path := range s.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.IsDir() {
+			state, err := readSourceFileState(path)
+			if err != nil {
+				return nil, err
+			}
+			snap.Files[path] = state
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !IsSourceFileName(entry.Name()) {
+				continue
+			}
+			filePath := filepath.Join(path, entry.Name())
+			state, err := readSourceFileState(filePath)
+			if err != nil {
+				return nil, err
+			}
+			snap.Files[filePath] = state
+		}
+	}
+	return snap, nil
+}
+
+func readSourceFileState(path string) (SourceFileState, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return SourceFileState{}, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		return SourceFileState{Link: target}, err
+	}
+	content, err := os.ReadFile(path)
+	return SourceFileState{Content: string(content)}, err
+}
+
+// Track 记录 change 对仓库配置的修改。修改前的配置与最新版本不同时,先记录一个外部修改的版本,
+// 保证每个版本的差异只包含一次修改;change 失败时也会记录已经发生的修改,并返回 change 的错误
+func (s *SourceRevisionStore) Track(author, method string, settings func() map[string]string, change func() error) error {
+	s.trackMu.Lock()
+	defer s.trackMu.Unlock()
+	s.record("unknown", "external", settings)
+	changeErr := change()
+	s.record(author, method, settings)
+	return changeErr
+}
+
+func (s *SourceRevisionStore) record(author, method string, settings func() map[string]string) {
+	var values map[string]string
+	if settings != nil {
+		values = settings()
+	}
+	snap, err := s.TakeSnapshot(values)
+	if err != nil {
+		logger.Warning("failed to take source snapshot:", err)
+		return
+	}
+	rev, err := s.Record(author, method, snap)
+	if err != nil {
+		logger.Warning("failed to record source revision:", err)
+		return
+	}
+	if rev != nil {
+		logger.Infof("record source revision %v by %v %v", rev.Rev, author, method)
+	}
+}
+
+// Record 保存 snap 为新的版本,与最新版本相同时不保存并返回 nil
+func (s *SourceRevisionStore) Record(author, method string, snap *SourceSnapshot) (*SourceRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs, err := s.listNoLock()
+	if err != nil {
+		return nil, err
+	}
+	var prev *SourceSnapshot
+	rev := 1
+	if len(revs) > 0 {
+		latest, err := s.getNoLock(revs[len(revs)-1])
+		if err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(normalizeSnapshot(latest.Snapshot), normalizeSnapshot(snap)) {
+			return nil, nil
+		}
+		prev = latest.Snapshot
+		rev = latest.Rev + 1
+	}
+	r := &SourceRevision{
+		Rev:      rev,
+		Time:     time.Now(),
+		Author:   author,
+		Method:   method,
+		Diff:     diffSourceSnapshots(prev, snap),
+		Snapshot: snap,
+	}
+	err = s.saveNoLock(r)
+	if err != nil {
+		return nil, err
+	}
+	// 只保留最近的版本
+	revs = append(revs, rev)
+	for len(revs) > maxSourceRevisions {
+		err = os.Remove(s.revisionPath(revs[0]))
+		if err != nil {
+			logger.Warning(err)
+		}
+		revs = revs[1:]
+	}
+	return r, nil
+}
+
+// normalizeSnapshot 将空的 map 统一为 nil,避免从文件读取的版本与当前配置比较时不相等
+func normalizeSnapshot(snap *SourceSnapshot) SourceSnapshot {
+	if snap == nil {
+		return SourceSnapshot{}
+	}
+	res := *snap
+	if len(res.Files) == 0 {
+		res.Files = nil
+	}
+	if len(res.Settings) == 0 {
+		res.Settings = nil
+	}
+	return res
+}
+
+// List 按版本号升序列出所有版本,不包含仓库文件内容
+func (s *SourceRevisionStore) List() ([]SourceRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs, err := s.listNoLock()
+	if err != nil {
+		return nil, err
+	}
+	res := []SourceRevision{}
+	for _, rev := range revs {
+		r, err := s.getNoLock(rev)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		r.Snapshot = nil
+		res = append(res, *r)
+	}
+	return res, nil
+}
+
+// Get 获取 rev 版本,包含仓库文件内容
+func (s *SourceRevisionStore) Get(rev int) (*SourceRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getNoLock(rev)
+}
+
+// Latest 获取最新的版本,没有版本时返回 nil
+func (s *SourceRevisionStore) Latest() (*SourceRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs, err := s.listNoLock()
+	if err != nil || len(revs) == 0 {
+		return nil, err
+	}
+	return s.getNoLock(revs[len(revs)-1])
+}
+
+// MarkGood 标记 rev 版本检查更新成功
+func (s *SourceRevisionStore) MarkGood(rev int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.getNoLock(rev)
+	if err != nil {
+		return err
+	}
+	if r.Good {
+		return nil
+	}
+	r.Good = true
+	return s.saveNoLock(r)
+}
+
+// LastGood 获取 rev 之前最近一个检查更新成功的版本,没有时返回 nil
+func (s *SourceRevisionStore) LastGood(rev int) (*SourceRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs, err := s.listNoLock()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(revs) - 1; i >= 0; i-- {
+		if revs[i] >= rev {
+			continue
+		}
+		r, err := s.getNoLock(revs[i])
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		if r.Good {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// Restore 将仓库文件恢复为 snap 中的内容,snap 中不存在的仓库文件会被删除;Settings 需要调用者恢复。
+// 先检查 snap 中所有的路径和内容,并将需要修改的文件写入临时文件,全部成功后再替换和删除,失败时不修改现有的仓库文件
+func (s *SourceRevisionStore) Restore(snap *SourceSnapshot) error {
+	if snap == nil {
+		return errors.New("empty snapshot")
+	}
+	for path, state := range snap.Files {
+		err := s.validateRestoreFile(path, state)
+		if err != nil {
+			return err
+		}
+	}
+	current, err := s.TakeSnapshot(nil)
+	if err != nil {
+		return err
+	}
+	staged := make(map[string]string)
+	defer func() {
+		for _, tmp := range staged {
+			_ = os.Remove(tmp)
+		}
+	}()
+	for path, state := range snap.Files {
+		if reflect.DeepEqual(current.Files[path], state) {
+			continue
+		}
+		tmp, err := stageSourceFile(path, state)
+		if err != nil {
+			return err
+		}
+		staged[path] = tmp
+	}
+	for path, tmp := range staged {
+		err = os.Rename(tmp, path)
+		if err != nil {
+			return err
+		}
+		delete(staged, path)
+	}
+	for path := range current.Files {
+		if _, ok := snap.Files[path]; !ok {
+			err = os.Remove(path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateRestoreFile 检查 path 是记录版本的仓库文件,内容是可以解析的仓库配置
+func (s *SourceRevisionStore) validateRestoreFile(path string, state SourceFileState) error {
+	if filepath.Clean(path) != path || !s.isTracked(path) {
+		return fmt.Errorf("%v is not a tracked source path", path)
+	}
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%v is a directory", path)
+	}
+	if state.Link != "" {
+		if state.Content != "" {
+			return fmt.Errorf("%v has both link and content", path)
+		}
+		return nil
+	}
+	_, err := ParseSources(state.Content, SourceFormatOf(path))
+	if err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	return nil
+}
+
+// stageSourceFile 将 state 写入 path 所在目录的临时文件,返回临时文件的路径。临时文件不是仓库文件名,不会被 apt 读取
+func stageSourceFile(path string, state SourceFileState) (string, error) {
+	dir := filepath.Dir(path)
+	// #nosec G301
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".restore-")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	if state.Link != "" {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		err = os.Symlink(state.Link, tmp)
+	} else {
+		_, err = f.WriteString(state.Content)
+		if err == nil {
+			err = f.Chmod(0644)
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+func (s *SourceRevisionStore) isTracked(path string) bool {
+	for _, p := range s.paths {
+		if path == p || (filepath.Dir(path) == p && IsSourceFileName(filepath.Base(path))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SourceRevisionStore) revisionPath(rev int) string {
+	return filepath.Join(s.dir, strconv.Itoa(rev)+".json")
+}
+
+func (s *SourceRevisionStore) listNoLock() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var revs []int
+	for _, entry := range entries {
+		rev, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		revs = append(revs, rev)
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+func (s *SourceRevisionStore) getNoLock(rev int) (*SourceRevision, error) {
+	content, err := os.ReadFile(s.revisionPath(rev))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("source revision %v not found", rev)
+		}
+		return nil, err
+	}
+	var r SourceRevision
+	err = json.Unmarshal(content, &r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source revision %v: %v", rev, err)
+	}
+	return &r, nil
+}
+
+func (s *SourceRevisionStore) saveNoLock(r *SourceRevision) error {
+	// #nosec G301
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	tmpFile := s.revisionPath(r.Rev) + ".tmp"
+	err = os.WriteFile(tmpFile, content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.revisionPath(r.Rev))
+}
+
+// diffSourceSnapshots 生成两个版本之间的差异,每个修改的文件或配置以 ---/+++ 开头,删除的行以 - 开头,新增的行以 + 开头
+func diffSourceSnapshots(prev, cur *SourceSnapshot) string {
+	type item struct {
+		name string
+		a, b []string
+	}
+	var items []item
+	var prevFiles, curFiles map[string]SourceFileState
+	var prevSettings, curSettings map[string]string
+	if prev != nil {
+		prevFiles, prevSettings = prev.Files, prev.Settings
+	}
+	if cur != nil {
+		curFiles, curSettings = cur.Files, cur.Settings
+	}
+	for _, path := range unionFileKeys(prevFiles, curFiles) {
+		a, aOk := prevFiles[path]
+		b, bOk := curFiles[path]
+		if aOk == bOk && a == b {
+			continue
+		}
+		it := item{name: path}
+		if aOk {
+			it.a = a.lines()
+		}
+		if bOk {
+			it.b = b.lines()
+		}
+		items = append(items, it)
+	}
+	for _, key := range unionSettingKeys(prevSettings, curSettings) {
+		a, aOk := prevSettings[key]
+		b, bOk := curSettings[key]
+		if aOk == bOk && a == b {
+			continue
+		}
+		items = append(items, item{name: "setting:" + key, a: splitLines(a), b: splitLines(b)})
+	}
+	var sb strings.Builder
+	for _, it := range items {
+		fmt.Fprintf(&sb, "--- %v\n+++ %v\n", it.name, it.name)
+		for _, line := range diffLines(it.a, it.b) {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func unionFileKeys(a, b map[string]SourceFileState) []string {
+	keys := make(map[string]struct{})
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return sortedKeys(keys)
+}
+
+func unionSettingKeys(a, b map[string]string) []string {
+	keys := make(map[string]struct{})
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return sortedKeys(keys)
+}
+
+func sortedKeys(keys map[string]struct{}) []string {
+	res := make([]string, 0, len(keys))
+	for k := range keys {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines 基于最长公共子序列生成行差异,只输出删除和新增的行
+func diffLines(a, b []string) []string {
+	n, m := len(a), len(b)
+	// 仓库文件通常很小,过大时直接视为全部替换
+	if n*m > 1<<22 {
+		var res []string
+		for _, line := range a {
+			res = append(res, "-"+line)
+		}
+		for _, line := range b {
+			res = append(res, "+"+line)
+		}
+		return res
+	}
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var res []string
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			res = append(res, "-"+a[i])
+			i++
+		default:
+			res = append(res, "+"+b[j])
+			j++
+		}
+	}
+	return res
+}
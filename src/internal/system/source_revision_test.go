@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceRevisionStore(t *testing.T) {
+	root := t.TempDir()
+	sourceFile := filepath.Join(root, "sources.list")
+	sourceDir := filepath.Join(root, "sources.list.d")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	require.NoError(t, os.WriteFile(sourceFile, []byte("deb http://a.com/ beige main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "ignored.txt"), []byte("x"), 0644))
+	require.NoError(t, os.Symlink(sourceFile, filepath.Join(sourceDir, "system.list")))
+
+	store := NewSourceRevisionStore(filepath.Join(root, "revisions"), sourceFile, sourceDir)
+	settings := map[string]string{"SystemRepoType": "default"}
+	getSettings := func() map[string]string { return settings }
+
+	// 第一次修改前的配置记录为外部修改
+	err := store.Track("uid 0", "SetUpdateSources", getSettings, func() error {
+		settings = map[string]string{"SystemRepoType": "custom"}
+		return os.WriteFile(filepath.Join(sourceDir, "custom.sources"), []byte("Types: deb\nURIs: http://a.com/\nSuites: beige\nComponents: main\n"), 0644)
+	})
+	require.NoError(t, err)
+	revs, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "external", revs[0].Method)
+	assert.Nil(t, revs[0].Snapshot)
+	assert.Equal(t, 2, revs[1].Rev)
+	assert.Equal(t, "uid 0", revs[1].Author)
+	assert.Equal(t, "--- "+filepath.Join(sourceDir, "custom.sources")+"\n+++ "+filepath.Join(sourceDir, "custom.sources")+"\n+Types: deb\n+URIs: http://a.com/\n+Suites: beige\n+Components: main\n"+
+		"--- setting:SystemRepoType\n+++ setting:SystemRepoType\n-default\n+custom\n", revs[1].Diff)
+	require.NoError(t, store.MarkGood(2))
+
+	// 没有修改时不记录,修改失败时仍然记录已经发生的修改
+	require.NoError(t, store.Track("uid 0", "noop", getSettings, func() error { return nil }))
+	err = store.Track("update-platform", "UpdateSourceList", getSettings, func() error {
+		_ = os.WriteFile(sourceFile, []byte("deb http://a.com/ beige main\ndeb broken\n"), 0644)
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	latest, err := store.Latest()
+	require.NoError(t, err)
+	assert.Equal(t, 3, latest.Rev)
+	assert.Contains(t, latest.Diff, "\n+deb broken\n")
+
+	good, err := store.LastGood(3)
+	require.NoError(t, err)
+	require.NotNil(t, good)
+	assert.Equal(t, 2, good.Rev)
+	good, err = store.LastGood(2)
+	require.NoError(t, err)
+	assert.Nil(t, good)
+
+	// 恢复仓库文件,软链接保持为软链接
+	require.NoError(t, os.Remove(filepath.Join(sourceDir, "system.list")))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "new.list"), []byte("deb x\n"), 0644))
+	rev, err := store.Get(2)
+	require.NoError(t, err)
+	require.NoError(t, store.Restore(rev.Snapshot))
+	snap, err := store.TakeSnapshot(rev.Snapshot.Settings)
+	require.NoError(t, err)
+	assert.Equal(t, normalizeSnapshot(rev.Snapshot), normalizeSnapshot(snap))
+	link, err := os.Readlink(filepath.Join(sourceDir, "system.list"))
+	require.NoError(t, err)
+	assert.Equal(t, sourceFile, link)
+	assert.FileExists(t, filepath.Join(sourceDir, "ignored.txt"))
+
+	_, err = store.Get(10)
+	assert.Error(t, err)
+}
+
+func TestSourceRevisionRestoreInvalid(t *testing.T) {
+	root := t.TempDir()
+	sourceFile := filepath.Join(root, "sources.list")
+	sourceDir := filepath.Join(root, "sources.list.d")
+	require.NoError(t, os.MkdirAll(sourceDir, 0755))
+	require.NoError(t, os.WriteFile(sourceFile, []byte("deb http://a.com/ beige main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "extra.list"), []byte("deb http://b.com/ beige main\n"), 0644))
+	store := NewSourceRevisionStore(filepath.Join(root, "revisions"), sourceFile, sourceDir)
+	before, err := store.TakeSnapshot(nil)
+	require.NoError(t, err)
+
+	// 任意一个文件检查失败时不修改现有的仓库文件,也不删除 snap 中不存在的文件
+	for _, files := range []map[string]SourceFileState{
+		{
+			sourceFile:                           {Content: "deb http://c.com/ beige main\n"},
+			filepath.Join(sourceDir, "bad.list"): {Content: "deb broken\n"},
+		},
+		{
+			sourceFile:                        {Content: "deb http://c.com/ beige main\n"},
+			filepath.Join(root, "other.list"): {Content: "deb http://c.com/ beige main\n"},
+		},
+		{
+			sourceFile: {Content: "deb http://c.com/ beige main\n", Link: "/dev/null"},
+		},
+	} {
+		assert.Error(t, store.Restore(&SourceSnapshot{Files: files}))
+		after, err := store.TakeSnapshot(nil)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+		entries, err := os.ReadDir(sourceDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	}
+
+	require.NoError(t, store.Restore(&SourceSnapshot{Files: map[string]SourceFileState{
+		sourceFile: {Content: "deb http://c.com/ beige main\n"},
+	}}))
+	content, err := os.ReadFile(sourceFile)
+	require.NoError(t, err)
+	assert.Equal(t, "deb http://c.com/ beige main\n", string(content))
+	assert.NoFileExists(t, filepath.Join(sourceDir, "extra.list"))
+	entries, err := os.ReadDir(sourceDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDiffLines(t *testing.T) {
+	assert.Equal(t, []string{"-b", "+c", "+d"}, diffLines([]string{"a", "b"}, []string{"a", "c", "d"}))
+	assert.Empty(t, diffLines([]string{"a"}, []string{"a"}))
+	assert.Equal(t, []string{"+a"}, diffLines(nil, []string{"a"}))
+}
@@ -0,0 +1,542 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/linuxdeepin/go-lib/strv"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformhttp"
+)
+
+const (
+	trustedGpgFile  = "/etc/apt/trusted.gpg"
+	trustedGpgDir   = "/etc/apt/trusted.gpg.d"
+	validateTimeout = 30 * time.Second
+)
+
+var errFileNotFound = errors.New("not found")
+
+// SourceValidation 一条仓库(一个地址和一个 suite)的检查结果
+type SourceValidation struct {
+	File      string `json:",omitempty"` // 检查已有的仓库文件时为文件路径
+	Line      int    // 在输入中的行号,从 1 开始,deb822 格式为段的起始行
+	Source    string // one-line 格式的仓库配置
+	URI       string
+	Suite     string
+	Skipped   bool   // 仓库被禁用,没有检查
+	Error     string // 解析失败或无法获取 Release 的原因,不为空时其他检查结果无效
+	Reachable bool
+	// 签名检查结果,Trusted 为 true 时不检查签名
+	Trusted           bool
+	SignatureVerified bool
+	SignatureError    string `json:",omitempty"`
+	ValidUntil        time.Time
+	Expired           bool // Valid-Until 已过期
+	// Release 中声明的架构和组件
+	Architectures        []string
+	Components           []string
+	MissingArchitectures []string // 需要的架构不在 Release 中,未指定 arch 选项时为 SystemArchitectures
+	MissingComponents    []string
+	Packages             int // 可用的包数量,同名包在不同架构中重复计数
+	OK                   bool
+}
+
+// releaseInfo Release 文件中用到的字段
+type releaseInfo struct {
+	Architectures []string
+	Components    []string
+	ValidUntil    time.Time
+	Files         []string // SHA256 或 MD5Sum 中列出的索引文件
+}
+
+type sourceValidator struct {
+	fetch  func(url string) ([]byte, error)
+	verify func(entry *SourceEntry, data, sig []byte) error
+	archs  []string
+	now    time.Time
+}
+
+// ValidateSources 检查仓库配置是否可用,content 为 one-line 或 deb822 格式的仓库配置。
+// 会从网络下载 InRelease 和 Packages 索引,耗时与仓库大小和网络情况有关
+func ValidateSources(content string) ([]SourceValidation, error) {
+	archs, err := SystemArchitectures()
+	if err != nil {
+		return nil, err
+	}
+	// 与 apt 使用相同的代理访问仓库
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = platformhttp.AptProxy(platformhttp.LoadAptProxy())
+	client := &http.Client{Timeout: validateTimeout, Transport: transport}
+	v := &sourceValidator{
+		fetch: func(url string) ([]byte, error) {
+			return fetchURL(client, url)
+		},
+		verify: verifyEntryRelease,
+		now:    time.Now(),
+	}
+	for _, arch := range archs {
+		v.archs = append(v.archs, string(arch))
+	}
+	return v.validate(content), nil
+}
+
+// sourceUnit 可以单独解析的一行或一段仓库配置
+type sourceUnit struct {
+	line int
+	text string
+}
+
+// splitSourceUnits 按行或段拆分仓库配置,使每条配置的错误可以单独报告
+func splitSourceUnits(content string, format SourceFormat) []sourceUnit {
+	var units []sourceUnit
+	lines := strings.Split(content, "\n")
+	if format == SourceFormatOneLine {
+		for i, line := range lines {
+			text, _, _ := strings.Cut(line, "#")
+			if strings.TrimSpace(text) != "" {
+				units = append(units, sourceUnit{line: i + 1, text: line})
+			}
+		}
+		return units
+	}
+	var cur *sourceUnit
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			units = append(units, sourceUnit{line: i + 1})
+			cur = &units[len(units)-1]
+		} else {
+			cur.text += "\n"
+		}
+		cur.text += line
+	}
+	// 去掉只有注释的段
+	var result []sourceUnit
+	for _, u := range units {
+		for _, line := range strings.Split(u.text, "\n") {
+			if !strings.HasPrefix(line, "#") {
+				result = append(result, u)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (v *sourceValidator) validate(content string) []SourceValidation {
+	format := DetectSourceFormat(content)
+	results := []SourceValidation{}
+	for _, unit := range splitSourceUnits(content, format) {
+		f, err := ParseSources(unit.text, format)
+		if err != nil {
+			results = append(results, SourceValidation{Line: unit.line, Source: unit.text, Error: err.Error()})
+			continue
+		}
+		for _, entry := range f.Entries() {
+			for _, uri := range entry.URIs {
+				for _, suite := range entry.Suites {
+					e := *entry
+					e.URIs = []string{uri}
+					e.Suites = []string{suite}
+					res := SourceValidation{
+						Line:   unit.line,
+						Source: strings.TrimPrefix(strings.Join(e.OneLine(), "\n"), "# "),
+						URI:    uri,
+						Suite:  suite,
+					}
+					if !entry.Enabled {
+						res.Skipped = true
+					} else {
+						v.validateEntry(&res, &e)
+					}
+					results = append(results, res)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// releaseBaseURL 返回 Release 所在的目录,以 / 结尾的 suite 为 flat 仓库
+func releaseBaseURL(uri, suite string) string {
+	uri = strings.TrimSuffix(uri, "/")
+	if strings.HasSuffix(suite, "/") {
+		if suite == "./" {
+			return uri + "/"
+		}
+		return uri + "/" + strings.TrimPrefix(suite, "/")
+	}
+	return uri + "/dists/" + suite + "/"
+}
+
+func (v *sourceValidator) validateEntry(res *SourceValidation, entry *SourceEntry) {
+	base := releaseBaseURL(res.URI, res.Suite)
+	var release, sig []byte
+	data, err := v.fetch(base + "InRelease")
+	if err == nil {
+		release = data
+	} else {
+		release, err = v.fetch(base + "Release")
+		if err != nil {
+			res.Error = fmt.Sprintf("failed to get Release: %v", err)
+			return
+		}
+		sig, _ = v.fetch(base + "Release.gpg")
+	}
+	res.Reachable = true
+
+	trusted, _ := entry.Option("Trusted")
+	res.Trusted = trusted == "yes"
+	if !res.Trusted {
+		if err := v.verify(entry, release, sig); err != nil {
+			res.SignatureError = err.Error()
+		} else {
+			res.SignatureVerified = true
+		}
+	}
+
+	info, err := parseRelease(release)
+	if err != nil {
+		res.Error = fmt.Sprintf("invalid Release: %v", err)
+		return
+	}
+	res.ValidUntil = info.ValidUntil
+	checkValidUntil, _ := entry.Option("Check-Valid-Until")
+	res.Expired = !info.ValidUntil.IsZero() && checkValidUntil != "no" && v.now.After(info.ValidUntil)
+	res.Architectures = info.Architectures
+	res.Components = info.Components
+
+	archs := v.archs
+	if value, ok := entry.Option("Architectures"); ok {
+		archs = strings.Fields(value)
+	}
+	var availableArchs []string
+	for _, arch := range archs {
+		// flat 仓库的 Release 通常不包含 Architectures
+		if len(info.Architectures) == 0 || strv.Strv(info.Architectures).Contains(arch) {
+			availableArchs = append(availableArchs, arch)
+		} else {
+			res.MissingArchitectures = append(res.MissingArchitectures, arch)
+		}
+	}
+	var availableComponents []string
+	for _, comp := range entry.Components {
+		if len(info.Components) == 0 || releaseHasComponent(info.Components, comp) {
+			availableComponents = append(availableComponents, comp)
+		} else {
+			res.MissingComponents = append(res.MissingComponents, comp)
+		}
+	}
+
+	hasBinary := strv.Strv(entry.Types).Contains("deb")
+	if hasBinary {
+		var indexes []string
+		if strings.HasSuffix(res.Suite, "/") {
+			indexes = []string{""}
+		} else {
+			indexArchs := append(append([]string(nil), availableArchs...), "all")
+			for _, comp := range availableComponents {
+				for _, arch := range indexArchs {
+					indexes = append(indexes, comp+"/binary-"+arch+"/")
+				}
+			}
+		}
+		for _, index := range indexes {
+			n, err := v.countPackages(base, index, info.Files)
+			if err != nil && !errors.Is(err, errFileNotFound) {
+				res.Error = fmt.Sprintf("failed to get %sPackages: %v", index, err)
+				return
+			}
+			res.Packages += n
+		}
+	}
+	res.OK = res.Reachable && (res.Trusted || res.SignatureVerified) && !res.Expired &&
+		len(res.MissingArchitectures) == 0 && len(res.MissingComponents) == 0 && (!hasBinary || res.Packages > 0)
+}
+
+// releaseHasComponent Release 中的组件可能带有前缀,如 updates/main
+func releaseHasComponent(components []string, comp string) bool {
+	for _, c := range components {
+		if c == comp || strings.HasSuffix(c, "/"+comp) {
+			return true
+		}
+	}
+	return false
+}
+
+// countPackages 下载 index 目录中的 Packages 索引并统计包数量,优先使用 Release 中列出的 gz 压缩格式
+func (v *sourceValidator) countPackages(base, index string, files []string) (int, error) {
+	candidates := []string{"Packages.gz", "Packages", "Packages.xz"}
+	if len(files) > 0 {
+		var listed []string
+		for _, name := range candidates {
+			if strv.Strv(files).Contains(index + name) {
+				listed = append(listed, name)
+			}
+		}
+		if len(listed) == 0 {
+			return 0, errFileNotFound
+		}
+		candidates = listed
+	}
+	var lastErr error = errFileNotFound
+	for _, name := range candidates {
+		data, err := v.fetch(base + index + name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		data, err = decompressIndex(name, data)
+		if err != nil {
+			return 0, err
+		}
+		return countPackageStanzas(data), nil
+	}
+	return 0, lastErr
+}
+
+func decompressIndex(name string, data []byte) ([]byte, error) {
+	switch filepath.Ext(name) {
+	case ".gz":
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case ".xz":
+		cmd := exec.Command("xz", "-dc")
+		cmd.Stdin = bytes.NewReader(data)
+		return cmd.Output()
+	}
+	return data, nil
+}
+
+func countPackageStanzas(data []byte) int {
+	n := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "Package:") {
+			n++
+		}
+	}
+	return n
+}
+
+// stripClearSign 去掉 InRelease 的签名部分,见 RFC 4880 7.1
+func stripClearSign(data []byte) []byte {
+	const header = "-----BEGIN PGP SIGNED MESSAGE-----"
+	text := string(data)
+	if !strings.HasPrefix(strings.TrimSpace(text), header) {
+		return data
+	}
+	_, body, ok := strings.Cut(text, "\n\n")
+	if !ok {
+		return data
+	}
+	body, _, _ = strings.Cut(body, "\n-----BEGIN PGP SIGNATURE-----")
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		lines = append(lines, strings.TrimPrefix(line, "- "))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func parseRelease(data []byte) (*releaseInfo, error) {
+	info := &releaseInfo{}
+	var field string
+	found := false
+	hasSHA256 := false
+	for _, line := range strings.Split(string(stripClearSign(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, " ") {
+			// SHA256 等字段的续行为 "hash size name"
+			if field == "sha256" || field == "md5sum" && !hasSHA256 {
+				if fields := strings.Fields(line); len(fields) == 3 {
+					info.Files = append(info.Files, fields[2])
+				}
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		found = true
+		field = strings.ToLower(name)
+		value = strings.TrimSpace(value)
+		switch field {
+		case "architectures":
+			info.Architectures = strings.Fields(value)
+		case "components":
+			info.Components = strings.Fields(value)
+		case "valid-until":
+			t, err := parseReleaseTime(value)
+			if err != nil {
+				return nil, err
+			}
+			info.ValidUntil = t
+		case "sha256":
+			// SHA256 优先于 MD5Sum
+			info.Files = nil
+			hasSHA256 = true
+		}
+	}
+	if !found {
+		return nil, errors.New("empty Release")
+	}
+	return info, nil
+}
+
+func parseReleaseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC1123, time.RFC1123Z, "Mon, 2 Jan 2006 15:04:05 MST", "Mon, 2 Jan 2006 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func fetchURL(client *http.Client, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file", "copy":
+		data, err := os.ReadFile(u.Path)
+		if os.IsNotExist(err) {
+			return nil, errFileNotFound
+		}
+		return data, err
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errFileNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// sourceKeyrings 返回校验仓库签名使用的公钥文件,未指定 Signed-By 或指定指纹时使用全局信任的公钥
+func sourceKeyrings(entry *SourceEntry) ([]string, func(), error) {
+	nop := func() {}
+	signedBy, _ := entry.Option("Signed-By")
+	if strings.Contains(signedBy, "\n") {
+		// 内嵌的公钥需要先写入文件
+		var key []string
+		for _, line := range strings.Split(strings.TrimSpace(signedBy), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "." {
+				line = ""
+			}
+			key = append(key, line)
+		}
+		data, err := dearmor([]byte(strings.Join(key, "\n") + "\n"))
+		if err != nil {
+			return nil, nop, err
+		}
+		f, err := os.CreateTemp("", "lastore-signed-by-*.gpg")
+		if err != nil {
+			return nil, nop, err
+		}
+		_, err = f.Write(data)
+		f.Close()
+		cleanup := func() { _ = os.Remove(f.Name()) }
+		if err != nil {
+			cleanup()
+			return nil, nop, err
+		}
+		return []string{f.Name()}, cleanup, nil
+	}
+	var keyrings []string
+	for _, path := range strings.FieldsFunc(signedBy, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if strings.HasPrefix(path, "/") {
+			keyrings = append(keyrings, path)
+		}
+	}
+	if len(keyrings) > 0 {
+		return keyrings, nop, nil
+	}
+	if _, err := os.Stat(trustedGpgFile); err == nil {
+		keyrings = append(keyrings, trustedGpgFile)
+	}
+	// gpgv 不能直接读取 ascii armor 格式的 .asc 文件
+	paths, _ := filepath.Glob(filepath.Join(trustedGpgDir, "*.gpg"))
+	keyrings = append(keyrings, paths...)
+	if len(keyrings) == 0 {
+		return nil, nop, errors.New("no trusted keyring")
+	}
+	return keyrings, nop, nil
+}
+
+// verifyEntryRelease 使用仓库对应的公钥校验 Release 的签名
+func verifyEntryRelease(entry *SourceEntry, data, sig []byte) error {
+	keyrings, cleanup, err := sourceKeyrings(entry)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return verifyRelease(data, sig, keyrings)
+}
+
+// verifyRelease 使用 gpgv 校验 InRelease,sig 不为空时校验 Release 和 Release.gpg
+func verifyRelease(data, sig []byte, keyrings []string) error {
+	dir, err := os.MkdirTemp("", "lastore-release-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	dataFile := filepath.Join(dir, "Release")
+	if err := os.WriteFile(dataFile, data, 0600); err != nil {
+		return err
+	}
+	var args []string
+	for _, k := range keyrings {
+		args = append(args, "--keyring", k)
+	}
+	if len(sig) > 0 {
+		sigFile := filepath.Join(dir, "Release.gpg")
+		if err := os.WriteFile(sigFile, sig, 0600); err != nil {
+			return err
+		}
+		args = append(args, sigFile, dataFile)
+	} else {
+		args = append(args, dataFile)
+	}
+	out, err := exec.Command("gpgv", args...).CombinedOutput() // #nosec G204
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInRelease = `-----BEGIN PGP SIGNED MESSAGE-----
+Hash: SHA256
+
+Origin: Deepin
+Suite: beige
+Codename: beige
+Date: Mon, 19 Oct 2026 08:00:00 UTC
+Valid-Until: Mon, 26 Oct 2026 08:00:00 UTC
+Architectures: amd64 arm64
+Components: main community
+MD5Sum:
+ d41d8cd98f00b204e9800998ecf8427e 20 main/binary-amd64/Packages
+SHA256:
+ e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 20 main/binary-amd64/Packages.gz
+ e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 20 main/binary-all/Packages
+-----BEGIN PGP SIGNATURE-----
+
+iQIzBAEBCAAdFiEE
+-----END PGP SIGNATURE-----
+`
+
+func gzipData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseRelease(t *testing.T) {
+	info, err := parseRelease([]byte(testInRelease))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"amd64", "arm64"}, info.Architectures)
+	assert.Equal(t, []string{"main", "community"}, info.Components)
+	assert.Equal(t, time.Date(2026, 10, 26, 8, 0, 0, 0, time.UTC), info.ValidUntil.UTC())
+	assert.Equal(t, []string{"main/binary-amd64/Packages.gz", "main/binary-all/Packages"}, info.Files)
+
+	_, err = parseRelease([]byte("Valid-Until: tomorrow\n"))
+	assert.Error(t, err)
+	_, err = parseRelease([]byte("<html>"))
+	assert.Error(t, err)
+}
+
+func TestValidateSources(t *testing.T) {
+	files := map[string][]byte{
+		"https://repo.example.com/dists/beige/InRelease":                     []byte(testInRelease),
+		"https://repo.example.com/dists/beige/main/binary-amd64/Packages.gz": gzipData(t, "Package: a\n\nPackage: b\n"),
+		"https://repo.example.com/dists/beige/main/binary-all/Packages":      []byte("Package: c\n"),
+		"https://flat.example.com/debs/Release":                              []byte("Origin: flat\n"),
+		"https://flat.example.com/debs/Packages":                             []byte("Package: d\n"),
+	}
+	v := &sourceValidator{
+		fetch: func(url string) ([]byte, error) {
+			if data, ok := files[url]; ok {
+				return data, nil
+			}
+			return nil, errFileNotFound
+		},
+		verify: func(entry *SourceEntry, data, sig []byte) error {
+			if len(sig) == 0 && !bytes.Contains(data, []byte("BEGIN PGP SIGNATURE")) {
+				return errors.New("no signature")
+			}
+			return nil
+		},
+		archs: []string{"amd64", "i386"},
+		now:   time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC),
+	}
+	content := "# comment\n" +
+		"deb https://repo.example.com beige main\n" +
+		"deb https://repo.example.com beige main non-free\n" +
+		"deb [trusted=yes] https://flat.example.com/debs/ ./\n" +
+		"deb https://missing.example.com beige main\n" +
+		"deb https://repo.example.com\n"
+	results := v.validate(content)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, 2, results[0].Line)
+	assert.True(t, results[0].Reachable)
+	assert.True(t, results[0].SignatureVerified)
+	assert.False(t, results[0].Expired)
+	assert.Equal(t, []string{"i386"}, results[0].MissingArchitectures)
+	assert.Equal(t, 3, results[0].Packages)
+	assert.False(t, results[0].OK)
+
+	assert.Equal(t, []string{"non-free"}, results[1].MissingComponents)
+
+	assert.Equal(t, 4, results[2].Line)
+	assert.True(t, results[2].Trusted)
+	assert.Equal(t, 1, results[2].Packages)
+	assert.True(t, results[2].OK)
+
+	assert.False(t, results[3].Reachable)
+	assert.NotEmpty(t, results[3].Error)
+
+	assert.Equal(t, 6, results[4].Line)
+	assert.Contains(t, results[4].Error, "missing uri or suite")
+
+	// 指定架构且 Valid-Until 过期
+	v.now = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	results = v.validate("Types: deb\nURIs: https://repo.example.com\nSuites: beige\nComponents: main\nArchitectures: amd64\n")
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].Line)
+	assert.Empty(t, results[0].MissingArchitectures)
+	assert.True(t, results[0].Expired)
+	assert.False(t, results[0].OK)
+}
+
+func TestReleaseBaseURL(t *testing.T) {
+	assert.Equal(t, "http://a.com/deepin/dists/beige/", releaseBaseURL("http://a.com/deepin/", "beige"))
+	assert.Equal(t, "http://a.com/deepin/", releaseBaseURL("http://a.com/deepin", "./"))
+	assert.Equal(t, "http://a.com/deepin/amd64/", releaseBaseURL("http://a.com/deepin", "amd64/"))
+}
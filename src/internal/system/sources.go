@@ -0,0 +1,673 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// SourceFormat 仓库配置文件的格式
+type SourceFormat int
+
+const (
+	SourceFormatOneLine SourceFormat = iota // *.list,每行一条 "deb [options] uri suite components"
+	SourceFormatDeb822                      // *.sources,每段一条,字段形式,见 sources.list(5)
+)
+
+const (
+	sourceListExt   = ".list"
+	sourceDeb822Ext = ".sources"
+)
+
+// IsSourceFileName apt 只读取 sources.list.d 中以 .list 和 .sources 结尾的文件
+func IsSourceFileName(name string) bool {
+	return strings.HasSuffix(name, sourceListExt) || strings.HasSuffix(name, sourceDeb822Ext)
+}
+
+// SourceFormatOf 根据文件名判断仓库配置的格式
+func SourceFormatOf(path string) SourceFormat {
+	if strings.HasSuffix(path, sourceDeb822Ext) {
+		return SourceFormatDeb822
+	}
+	return SourceFormatOneLine
+}
+
+// sourceFileStem 去掉 .list 或 .sources 后缀,用于在两种格式间匹配同一个仓库文件
+func sourceFileStem(name string) string {
+	name = filepath.Base(name)
+	if strings.HasSuffix(name, sourceDeb822Ext) {
+		return strings.TrimSuffix(name, sourceDeb822Ext)
+	}
+	return strings.TrimSuffix(name, sourceListExt)
+}
+
+// DetectSourceFormat 根据内容判断仓库配置的格式,用于处理没有文件名的配置(如 D-Bus 传入的自定义仓库)
+func DetectSourceFormat(content string) SourceFormat {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// one-line 格式以 deb 或 deb-src 开头,第一个冒号前一定有空格
+		name, _, ok := strings.Cut(line, ":")
+		if ok && !strings.ContainsAny(name, " \t") {
+			return SourceFormatDeb822
+		}
+		return SourceFormatOneLine
+	}
+	return SourceFormatOneLine
+}
+
+// MergeSources 将 added 中的仓库追加到 existing 之后,两者格式不同时统一转换为 deb822 格式。
+// one-line 格式可以转换为 deb822 格式,反之内嵌公钥等多行选项无法表示;转换时 one-line 格式中的注释会被丢弃
+func MergeSources(existing, added []string) ([]string, error) {
+	if len(existing) == 0 {
+		return added, nil
+	}
+	if len(added) == 0 {
+		return existing, nil
+	}
+	existingFormat := DetectSourceFormat(strings.Join(existing, "\n"))
+	addedFormat := DetectSourceFormat(strings.Join(added, "\n"))
+	if existingFormat == addedFormat {
+		merged := append([]string(nil), existing...)
+		// deb822 格式的段之间需要空行分隔
+		if existingFormat == SourceFormatDeb822 && strings.TrimSpace(existing[len(existing)-1]) != "" {
+			merged = append(merged, "")
+		}
+		return append(merged, added...), nil
+	}
+	merged, err := sourcesToDeb822(existing, existingFormat)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := sourcesToDeb822(added, addedFormat)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(merged, ""), converted...), nil
+}
+
+// sourcesToDeb822 将仓库配置转换为 deb822 格式的行
+func sourcesToDeb822(lines []string, format SourceFormat) ([]string, error) {
+	if format == SourceFormatDeb822 {
+		return lines, nil
+	}
+	f, err := ParseSources(strings.Join(lines, "\n"), format)
+	if err != nil {
+		return nil, err
+	}
+	var paragraphs []string
+	for _, entry := range f.Entries() {
+		paragraphs = append(paragraphs, entry.Deb822())
+	}
+	return strings.Split(strings.Join(paragraphs, "\n\n"), "\n"), nil
+}
+
+// SourceOption 仓库的选项,Name 统一使用 deb822 格式中的字段名,如 Signed-By、Architectures
+type SourceOption struct {
+	Name  string
+	Value string
+}
+
+// SourceEntry 一条仓库配置,对应 one-line 格式中的一行或 deb822 格式中的一段
+type SourceEntry struct {
+	Types      []string
+	URIs       []string
+	Suites     []string
+	Components []string
+	Options    []SourceOption
+	Enabled    bool
+}
+
+// one-line 格式中的选项名和 deb822 字段名的对应关系
+var oneLineOptionNames = map[string]string{
+	"arch":                        "Architectures",
+	"lang":                        "Languages",
+	"target":                      "Targets",
+	"pdiffs":                      "PDiffs",
+	"by-hash":                     "By-Hash",
+	"allow-insecure":              "Allow-Insecure",
+	"allow-weak":                  "Allow-Weak",
+	"allow-downgrade-to-insecure": "Allow-Downgrade-To-Insecure",
+	"trusted":                     "Trusted",
+	"signed-by":                   "Signed-By",
+	"check-valid-until":           "Check-Valid-Until",
+	"valid-until-min":             "Valid-Until-Min",
+	"valid-until-max":             "Valid-Until-Max",
+	"check-date":                  "Check-Date",
+	"date-max-future":             "Date-Max-Future",
+	"inrelease-path":              "InRelease-Path",
+	"snapshot":                    "Snapshot",
+}
+
+// 值为列表的选项,one-line 格式中使用逗号分隔,deb822 格式中使用空格分隔
+var listOptionNames = map[string]bool{
+	"Architectures": true,
+	"Languages":     true,
+	"Targets":       true,
+}
+
+func deb822OptionName(name string) string {
+	if n, ok := oneLineOptionNames[strings.ToLower(name)]; ok {
+		return n
+	}
+	for _, n := range oneLineOptionNames {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+	}
+	return name
+}
+
+func oneLineOptionName(name string) string {
+	for k, n := range oneLineOptionNames {
+		if strings.EqualFold(n, name) {
+			return k
+		}
+	}
+	return strings.ToLower(name)
+}
+
+// Option 返回选项的值,name 可以使用任意一种格式中的名称,不区分大小写
+func (e *SourceEntry) Option(name string) (string, bool) {
+	name = deb822OptionName(name)
+	for _, opt := range e.Options {
+		if strings.EqualFold(opt.Name, name) {
+			return opt.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetOption 设置选项的值,value 为空时删除该选项
+func (e *SourceEntry) SetOption(name, value string) {
+	name = deb822OptionName(name)
+	for i, opt := range e.Options {
+		if strings.EqualFold(opt.Name, name) {
+			if value == "" {
+				e.Options = append(e.Options[:i:i], e.Options[i+1:]...)
+			} else {
+				e.Options[i].Value = value
+			}
+			return
+		}
+	}
+	if value != "" {
+		e.Options = append(e.Options, SourceOption{Name: name, Value: value})
+	}
+}
+
+// OneLine 转换为 one-line 格式,一条 deb822 配置可能对应多行。
+// 多行的选项(如内嵌公钥的 Signed-By)无法用 one-line 格式表示,会被忽略
+func (e *SourceEntry) OneLine() []string {
+	var opts []string
+	for _, opt := range e.Options {
+		value := strings.TrimSpace(opt.Value)
+		if strings.Contains(value, "\n") {
+			continue
+		}
+		if listOptionNames[opt.Name] {
+			value = strings.Join(strings.Fields(value), ",")
+		}
+		opts = append(opts, oneLineOptionName(opt.Name)+"="+value)
+	}
+	var lines []string
+	for _, typ := range e.Types {
+		for _, uri := range e.URIs {
+			for _, suite := range e.Suites {
+				fields := []string{typ}
+				if len(opts) > 0 {
+					fields = append(fields, "["+strings.Join(opts, " ")+"]")
+				}
+				fields = append(fields, uri, suite)
+				fields = append(fields, e.Components...)
+				line := strings.Join(fields, " ")
+				if !e.Enabled {
+					line = "# " + line
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// Deb822 转换为 deb822 格式的一段
+func (e *SourceEntry) Deb822() string {
+	var lines []string
+	if !e.Enabled {
+		lines = append(lines, "Enabled: no")
+	}
+	lines = append(lines, "Types: "+strings.Join(e.Types, " "))
+	lines = append(lines, "URIs: "+strings.Join(e.URIs, " "))
+	lines = append(lines, "Suites: "+strings.Join(e.Suites, " "))
+	if len(e.Components) > 0 {
+		lines = append(lines, "Components: "+strings.Join(e.Components, " "))
+	}
+	for _, opt := range e.Options {
+		lines = append(lines, formatDeb822Field(opt.Name, opt.Value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *SourceEntry) clone() SourceEntry {
+	c := SourceEntry{
+		Types:      append([]string(nil), e.Types...),
+		URIs:       append([]string(nil), e.URIs...),
+		Suites:     append([]string(nil), e.Suites...),
+		Components: append([]string(nil), e.Components...),
+		Options:    append([]SourceOption(nil), e.Options...),
+		Enabled:    e.Enabled,
+	}
+	return c
+}
+
+func (e *SourceEntry) validate() error {
+	if len(e.Types) == 0 {
+		return fmt.Errorf("missing Types")
+	}
+	for _, typ := range e.Types {
+		if typ != "deb" && typ != "deb-src" {
+			return fmt.Errorf("unsupported type %q", typ)
+		}
+	}
+	if len(e.URIs) == 0 {
+		return fmt.Errorf("missing URIs")
+	}
+	for _, uri := range e.URIs {
+		if !strings.Contains(uri, ":") {
+			return fmt.Errorf("invalid uri %q", uri)
+		}
+	}
+	if len(e.Suites) == 0 {
+		return fmt.Errorf("missing Suites")
+	}
+	for _, suite := range e.Suites {
+		// 以 / 结尾的 suite 表示精确路径,此时不能指定 components
+		if strings.HasSuffix(suite, "/") && len(e.Components) > 0 {
+			return fmt.Errorf("suite %q is an exact path, components are not allowed", suite)
+		}
+		if !strings.HasSuffix(suite, "/") && len(e.Components) == 0 {
+			return fmt.Errorf("missing Components for suite %q", suite)
+		}
+	}
+	return nil
+}
+
+// deb822Field deb822 段中的一个字段或一行注释
+type deb822Field struct {
+	name  string   // 注释行为空
+	lines []string // 原始内容,包括续行
+}
+
+// sourceBlock 文件中的一段内容,注释和空行的 entry 为空
+type sourceBlock struct {
+	lines   []string
+	entry   *SourceEntry
+	orig    SourceEntry
+	comment string        // one-line 格式的行尾注释
+	fields  []deb822Field // deb822 格式的字段
+}
+
+// SourceFile 仓库配置文件,修改 Entries 返回的配置后使用 Bytes 写回,
+// 未修改的配置、注释和空行保持原样
+type SourceFile struct {
+	Format          SourceFormat
+	blocks          []*sourceBlock
+	trailingNewline bool
+}
+
+// LoadSourceFile 读取仓库配置文件,根据文件名判断格式
+func LoadSourceFile(path string) (*SourceFile, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseSources(string(data), SourceFormatOf(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// ParseSources 解析仓库配置
+func ParseSources(content string, format SourceFormat) (*SourceFile, error) {
+	f := &SourceFile{Format: format, trailingNewline: strings.HasSuffix(content, "\n")}
+	content = strings.TrimSuffix(content, "\n")
+	var lines []string
+	if content != "" || f.trailingNewline {
+		lines = strings.Split(content, "\n")
+	}
+	if format == SourceFormatDeb822 {
+		return f, f.parseDeb822(lines)
+	}
+	return f, f.parseOneLine(lines)
+}
+
+func (f *SourceFile) parseOneLine(lines []string) error {
+	for i, line := range lines {
+		block := &sourceBlock{lines: []string{line}}
+		f.blocks = append(f.blocks, block)
+		content, comment, _ := strings.Cut(line, "#")
+		if strings.TrimSpace(content) == "" {
+			block.parseDisabledOneLine(line)
+			continue
+		}
+		entry, err := parseOneLineEntry(content)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		block.entry = entry
+		block.orig = entry.clone()
+		if strings.Contains(line, "#") {
+			block.comment = "#" + comment
+		}
+	}
+	return nil
+}
+
+// parseDisabledOneLine 被注释的仓库行视为禁用的仓库,启用后去掉注释,其他注释保持原样
+func (b *sourceBlock) parseDisabledOneLine(line string) {
+	text := strings.TrimLeft(strings.TrimSpace(line), "# \t")
+	content, comment, hasComment := strings.Cut(text, "#")
+	fields := strings.Fields(content)
+	if len(fields) == 0 || fields[0] != "deb" && fields[0] != "deb-src" {
+		return
+	}
+	entry, err := parseOneLineEntry(content)
+	if err != nil {
+		return
+	}
+	entry.Enabled = false
+	b.entry = entry
+	b.orig = entry.clone()
+	if hasComment {
+		b.comment = "#" + comment
+	}
+}
+
+func parseOneLineEntry(line string) (*SourceEntry, error) {
+	tokens := strings.Fields(line)
+	entry := &SourceEntry{Enabled: true, Types: []string{tokens[0]}}
+	tokens = tokens[1:]
+	if len(tokens) > 0 && strings.HasPrefix(tokens[0], "[") {
+		var optTokens []string
+		closed := false
+		for len(tokens) > 0 && !closed {
+			tok := tokens[0]
+			tokens = tokens[1:]
+			if strings.HasSuffix(tok, "]") {
+				closed = true
+				tok = strings.TrimSuffix(tok, "]")
+			}
+			tok = strings.TrimPrefix(tok, "[")
+			if tok != "" {
+				optTokens = append(optTokens, tok)
+			}
+		}
+		if !closed {
+			return nil, fmt.Errorf("unterminated options")
+		}
+		for _, tok := range optTokens {
+			name, value, ok := strings.Cut(tok, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid option %q", tok)
+			}
+			name = deb822OptionName(name)
+			if listOptionNames[name] {
+				value = strings.ReplaceAll(value, ",", " ")
+			}
+			entry.Options = append(entry.Options, SourceOption{Name: name, Value: value})
+		}
+	}
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("missing uri or suite")
+	}
+	entry.URIs = []string{tokens[0]}
+	entry.Suites = []string{tokens[1]}
+	entry.Components = append([]string(nil), tokens[2:]...)
+	return entry, entry.validate()
+}
+
+func (f *SourceFile) parseDeb822(lines []string) error {
+	var cur *sourceBlock
+	var startLine int
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		block := cur
+		cur = nil
+		if err := block.parseDeb822Fields(); err != nil {
+			return fmt.Errorf("paragraph at line %d: %w", startLine, err)
+		}
+		return nil
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			if err := finish(); err != nil {
+				return err
+			}
+			f.blocks = append(f.blocks, &sourceBlock{lines: []string{line}})
+			continue
+		}
+		if cur == nil {
+			cur = &sourceBlock{}
+			startLine = i + 1
+			f.blocks = append(f.blocks, cur)
+		}
+		cur.lines = append(cur.lines, line)
+	}
+	return finish()
+}
+
+func (b *sourceBlock) parseDeb822Fields() error {
+	for _, line := range b.lines {
+		if strings.HasPrefix(line, "#") {
+			b.fields = append(b.fields, deb822Field{lines: []string{line}})
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if len(b.fields) == 0 || b.fields[len(b.fields)-1].name == "" {
+				return fmt.Errorf("unexpected continuation line %q", line)
+			}
+			last := &b.fields[len(b.fields)-1]
+			last.lines = append(last.lines, line)
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return fmt.Errorf("invalid line %q", line)
+		}
+		b.fields = append(b.fields, deb822Field{name: name, lines: []string{line}})
+	}
+	hasField := false
+	for _, field := range b.fields {
+		if field.name != "" {
+			hasField = true
+			break
+		}
+	}
+	// 只有注释的段
+	if !hasField {
+		b.fields = nil
+		return nil
+	}
+
+	entry := &SourceEntry{Enabled: true}
+	for _, field := range b.fields {
+		if field.name == "" {
+			continue
+		}
+		value := field.value()
+		switch strings.ToLower(field.name) {
+		case "types":
+			entry.Types = strings.Fields(value)
+		case "uris":
+			entry.URIs = strings.Fields(value)
+		case "suites":
+			entry.Suites = strings.Fields(value)
+		case "components":
+			entry.Components = strings.Fields(value)
+		case "enabled":
+			entry.Enabled = !strings.EqualFold(strings.TrimSpace(value), "no")
+		default:
+			entry.Options = append(entry.Options, SourceOption{Name: field.name, Value: value})
+		}
+	}
+	if err := entry.validate(); err != nil {
+		return err
+	}
+	b.entry = entry
+	b.orig = entry.clone()
+	return nil
+}
+
+// value 返回字段的值,多行的值保留续行的原始内容
+func (field *deb822Field) value() string {
+	_, first, _ := strings.Cut(field.lines[0], ":")
+	first = strings.TrimSpace(first)
+	if len(field.lines) == 1 {
+		return first
+	}
+	return first + "\n" + strings.Join(field.lines[1:], "\n")
+}
+
+func formatDeb822Field(name, value string) string {
+	if value == "" || strings.HasPrefix(value, "\n") {
+		return name + ":" + value
+	}
+	return name + ": " + value
+}
+
+// Entries 返回文件中的所有仓库配置,修改返回值会修改文件内容
+func (f *SourceFile) Entries() []*SourceEntry {
+	var entries []*SourceEntry
+	for _, b := range f.blocks {
+		if b.entry != nil {
+			entries = append(entries, b.entry)
+		}
+	}
+	return entries
+}
+
+// AddEntry 在文件末尾添加一条仓库配置
+func (f *SourceFile) AddEntry(entry SourceEntry) {
+	e := entry.clone()
+	if f.Format == SourceFormatDeb822 {
+		if n := len(f.blocks); n > 0 && strings.TrimSpace(f.blocks[n-1].lines[0]) != "" {
+			f.blocks = append(f.blocks, &sourceBlock{lines: []string{""}})
+		}
+		// orig 为空,Bytes 时会重新生成
+		f.blocks = append(f.blocks, &sourceBlock{entry: &e})
+		return
+	}
+	f.blocks = append(f.blocks, &sourceBlock{entry: &e})
+}
+
+// Bytes 返回文件内容
+func (f *SourceFile) Bytes() []byte {
+	var lines []string
+	for _, b := range f.blocks {
+		if b.entry == nil || reflect.DeepEqual(*b.entry, b.orig) {
+			lines = append(lines, b.lines...)
+			continue
+		}
+		if f.Format == SourceFormatDeb822 {
+			lines = append(lines, b.renderDeb822()...)
+		} else {
+			lines = append(lines, b.renderOneLine()...)
+		}
+	}
+	content := strings.Join(lines, "\n")
+	if f.trailingNewline || len(f.blocks) > 0 && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return []byte(content)
+}
+
+func (b *sourceBlock) renderOneLine() []string {
+	lines := b.entry.OneLine()
+	if b.comment != "" && len(lines) > 0 {
+		lines[0] += " " + b.comment
+	}
+	return lines
+}
+
+// renderDeb822 按照原有字段的顺序重新生成被修改的段,未修改的字段和注释保持原样
+func (b *sourceBlock) renderDeb822() []string {
+	if len(b.fields) == 0 {
+		return strings.Split(b.entry.Deb822(), "\n")
+	}
+	e := b.entry
+	listValue := func(name string) ([]string, []string) {
+		switch strings.ToLower(name) {
+		case "types":
+			return e.Types, b.orig.Types
+		case "uris":
+			return e.URIs, b.orig.URIs
+		case "suites":
+			return e.Suites, b.orig.Suites
+		case "components":
+			return e.Components, b.orig.Components
+		}
+		return nil, nil
+	}
+	var lines []string
+	written := make(map[string]bool)
+	for _, field := range b.fields {
+		if field.name == "" {
+			lines = append(lines, field.lines...)
+			continue
+		}
+		key := strings.ToLower(field.name)
+		written[key] = true
+		switch key {
+		case "types", "uris", "suites", "components":
+			cur, orig := listValue(key)
+			if reflect.DeepEqual(cur, orig) {
+				lines = append(lines, field.lines...)
+			} else if len(cur) > 0 {
+				lines = append(lines, field.name+": "+strings.Join(cur, " "))
+			}
+		case "enabled":
+			if e.Enabled == b.orig.Enabled {
+				lines = append(lines, field.lines...)
+			} else if e.Enabled {
+				lines = append(lines, field.name+": yes")
+			} else {
+				lines = append(lines, field.name+": no")
+			}
+		default:
+			value, ok := e.Option(field.name)
+			if !ok {
+				continue
+			}
+			written[strings.ToLower(deb822OptionName(field.name))] = true
+			if orig, _ := b.orig.Option(field.name); orig == value {
+				lines = append(lines, field.lines...)
+			} else {
+				lines = append(lines, strings.Split(formatDeb822Field(field.name, value), "\n")...)
+			}
+		}
+	}
+	if !written["enabled"] && !e.Enabled {
+		lines = append(lines, "Enabled: no")
+	}
+	if !written["components"] && len(e.Components) > 0 {
+		lines = append(lines, "Components: "+strings.Join(e.Components, " "))
+	}
+	for _, opt := range e.Options {
+		if !written[strings.ToLower(opt.Name)] {
+			lines = append(lines, strings.Split(formatDeb822Field(opt.Name, opt.Value), "\n")...)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDeb822Sources = `# deepin main repo
+Types: deb deb-src
+URIs: https://community-packages.deepin.com/beige/
+Suites: beige
+Components: main commercial community
+Architectures: amd64 i386
+# embedded key
+Signed-By:
+ -----BEGIN PGP PUBLIC KEY BLOCK-----
+ .
+ mDMEZGZ1ahYJKwYBBAHaRw8BAQdA
+ -----END PGP PUBLIC KEY BLOCK-----
+
+Enabled: no
+Types: deb
+URIs: https://ppa.example.com/
+Suites: stable
+Components: main
+`
+
+func TestParseSourcesRoundTrip(t *testing.T) {
+	oneLine := "# comment\n" +
+		"deb [arch=amd64,i386 signed-by=/usr/share/keyrings/deepin.gpg] https://community-packages.deepin.com/beige/ beige main  # inline\n" +
+		"\n" +
+		"deb-src http://example.com/ stable main\n"
+	f, err := ParseSources(oneLine, SourceFormatOneLine)
+	require.NoError(t, err)
+	assert.Equal(t, oneLine, string(f.Bytes()))
+	entries := f.Entries()
+	require.Len(t, entries, 2)
+	value, ok := entries[0].Option("Signed-By")
+	assert.True(t, ok)
+	assert.Equal(t, "/usr/share/keyrings/deepin.gpg", value)
+	value, _ = entries[0].Option("arch")
+	assert.Equal(t, "amd64 i386", value)
+
+	f, err = ParseSources(testDeb822Sources, SourceFormatDeb822)
+	require.NoError(t, err)
+	assert.Equal(t, testDeb822Sources, string(f.Bytes()))
+	entries = f.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, []string{"deb", "deb-src"}, entries[0].Types)
+	assert.Equal(t, []string{"main", "commercial", "community"}, entries[0].Components)
+	value, _ = entries[0].Option("signed-by")
+	assert.True(t, strings.HasPrefix(value, "\n -----BEGIN PGP PUBLIC KEY BLOCK-----"))
+	assert.False(t, entries[1].Enabled)
+}
+
+func TestModifySources(t *testing.T) {
+	f, err := ParseSources(testDeb822Sources, SourceFormatDeb822)
+	require.NoError(t, err)
+	entries := f.Entries()
+	entries[1].Enabled = true
+	entries[1].Suites = []string{"unstable"}
+	entries[1].SetOption("Signed-By", "/usr/share/keyrings/ppa.gpg")
+	got := string(f.Bytes())
+	// 未修改的段保持原样
+	assert.True(t, strings.HasPrefix(got, strings.SplitN(testDeb822Sources, "\n\n", 2)[0]+"\n\n"))
+	assert.True(t, strings.HasSuffix(got, "Enabled: yes\nTypes: deb\nURIs: https://ppa.example.com/\n"+
+		"Suites: unstable\nComponents: main\nSigned-By: /usr/share/keyrings/ppa.gpg\n"))
+
+	f, err = ParseSources("deb http://example.com/ stable main # keep\n", SourceFormatOneLine)
+	require.NoError(t, err)
+	f.Entries()[0].SetOption("signed-by", "/usr/share/keyrings/example.gpg")
+	f.AddEntry(SourceEntry{Types: []string{"deb"}, URIs: []string{"http://other.com/"}, Suites: []string{"stable"},
+		Components: []string{"main"}, Enabled: true, Options: []SourceOption{{Name: "Architectures", Value: "amd64 arm64"}}})
+	assert.Equal(t, "deb [signed-by=/usr/share/keyrings/example.gpg] http://example.com/ stable main # keep\n"+
+		"deb [arch=amd64,arm64] http://other.com/ stable main\n", string(f.Bytes()))
+}
+
+func TestParseSourcesError(t *testing.T) {
+	_, err := ParseSources("deb http://example.com/ stable main\ndeb http://example.com/\n", SourceFormatOneLine)
+	assert.ErrorContains(t, err, "line 2")
+	_, err = ParseSources("deb [arch=amd64 http://example.com/ stable main\n", SourceFormatOneLine)
+	assert.Error(t, err)
+	_, err = ParseSources("Types: deb\nURIs: http://example.com/\nSuites: stable/\n\nTypes: deb\nSuites: stable\n", SourceFormatDeb822)
+	assert.ErrorContains(t, err, "line 5")
+	_, err = ParseSources("Types: deb\nURIs: http://example.com/\nSuites: stable/\nComponents: main\n", SourceFormatDeb822)
+	assert.Error(t, err)
+}
+
+func TestDetectSourceFormat(t *testing.T) {
+	assert.Equal(t, SourceFormatDeb822, DetectSourceFormat("# comment\nTypes: deb\nURIs: http://example.com/"))
+	assert.Equal(t, SourceFormatOneLine, DetectSourceFormat("## repo\ndeb [trusted=yes] http://example.com/ stable main"))
+	assert.Equal(t, SourceFormatDeb822, SourceFormatOf("/etc/apt/sources.list.d/deepin.sources"))
+	assert.True(t, IsSourceFileName("deepin.sources"))
+	assert.False(t, IsSourceFileName("deepin.list.save"))
+	assert.Equal(t, "appstore", sourceFileStem("/etc/apt/sources.list.d/appstore.sources"))
+}
+
+func TestReplaceMatchedDeb822ReposWithDelivery(t *testing.T) {
+	platformRepos := []string{"deb https://community-packages.deepin.com/beige beige main"}
+	got, err := replaceMatchedDeb822ReposWithDelivery(testDeb822Sources, platformRepos)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(testDeb822Sources,
+		"URIs: https://community-packages.deepin.com/beige/",
+		"URIs: delivery://community-packages.deepin.com/beige", 1), got)
+
+	got, err = replaceMatchedDeb822ReposWithDelivery(testDeb822Sources, nil)
+	require.NoError(t, err)
+	assert.Equal(t, testDeb822Sources, got)
+}
+
+func TestDisabledOneLineSources(t *testing.T) {
+	content := "# comment\n" +
+		"# deb [arch=amd64] http://example.com/ stable main # note\n" +
+		"#deb-src http://example.com/ stable main\n" +
+		"deb http://other.com/ stable main\n"
+	f, err := ParseSources(content, SourceFormatOneLine)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(f.Bytes()))
+	entries := f.Entries()
+	require.Len(t, entries, 3)
+	assert.False(t, entries[0].Enabled)
+	assert.False(t, entries[1].Enabled)
+	assert.True(t, entries[2].Enabled)
+
+	// 启用后去掉注释,再禁用后恢复注释
+	entries[0].Enabled = true
+	entries[2].Enabled = false
+	got := string(f.Bytes())
+	assert.Equal(t, "# comment\n"+
+		"deb [arch=amd64] http://example.com/ stable main # note\n"+
+		"#deb-src http://example.com/ stable main\n"+
+		"# deb http://other.com/ stable main\n", got)
+	f, err = ParseSources(got, SourceFormatOneLine)
+	require.NoError(t, err)
+	entries = f.Entries()
+	entries[0].Enabled = false
+	entries[2].Enabled = true
+	assert.Equal(t, "# comment\n"+
+		"# deb [arch=amd64] http://example.com/ stable main # note\n"+
+		"#deb-src http://example.com/ stable main\n"+
+		"deb http://other.com/ stable main\n", string(f.Bytes()))
+}
+
+func TestMergeSources(t *testing.T) {
+	oneLine := []string{"deb http://a.com/ stable main"}
+	deb822 := []string{"Types: deb", "URIs: http://b.com/", "Suites: stable", "Components: main"}
+
+	merged, err := MergeSources(oneLine, []string{"deb http://c.com/ stable main"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deb http://a.com/ stable main", "deb http://c.com/ stable main"}, merged)
+
+	// deb822 格式的段之间使用空行分隔
+	merged, err = MergeSources(deb822, deb822)
+	require.NoError(t, err)
+	assert.Equal(t, append(append(append([]string(nil), deb822...), ""), deb822...), merged)
+
+	// 格式不同时统一转换为 deb822 格式
+	for _, c := range [][2][]string{{oneLine, deb822}, {deb822, oneLine}} {
+		merged, err = MergeSources(c[0], c[1])
+		require.NoError(t, err)
+		content := strings.Join(merged, "\n")
+		assert.Equal(t, SourceFormatDeb822, DetectSourceFormat(content))
+		f, err := ParseSources(content, SourceFormatDeb822)
+		require.NoError(t, err)
+		assert.Len(t, f.Entries(), 2)
+	}
+
+	_, err = MergeSources(deb822, []string{"deb http://c.com/"})
+	assert.Error(t, err)
+}
+
+func TestSystemDeb822SourceList(t *testing.T) {
+	dir := t.TempDir()
+	osRelease := []byte("NAME=\"UOS\"\nID=uos\nID_LIKE=\"deepin debian\"\n")
+	assert.Equal(t, "", systemDeb822SourceList(osRelease, dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "debian.sources"), nil, 0644))
+	assert.Equal(t, "debian.sources", systemDeb822SourceList(osRelease, dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deepin.sources"), nil, 0644))
+	assert.Equal(t, "deepin.sources", systemDeb822SourceList(osRelease, dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "uos.sources"), nil, 0644))
+	assert.Equal(t, "uos.sources", systemDeb822SourceList(osRelease, dir))
+}
@@ -35,6 +35,8 @@ const (
 	CleanJobType              = "clean"
 	FixErrorJobType           = "fix_error"
 	CheckSystemJobType        = "check_system"
+	ReconcileJobType          = "reconcile"   // 按照本机软件包清单安装和删除软件包
+	CVEUpgradeJobType         = "cve_upgrade" // 只升级修复指定 CVE 的软件包到固定版本
 
 	// UpgradeJobType 创建任务时会根据四种下载和安装类型,分别创建带有不同参数的下载和更新任务
 	PrepareSystemUpgradeJobType   = "prepare_system_upgrade"
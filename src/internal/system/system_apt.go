@@ -17,6 +17,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -283,6 +284,199 @@ func QueryPackageInstallable(pkgId string) bool {
 	return true
 }
 
+// QueryCandidateVersions 查询包的候选版本,没有候选版本的包不在返回值中
+func QueryCandidateVersions(pkgs ...string) (map[string]string, error) {
+	if len(pkgs) == 0 {
+		return map[string]string{}, nil
+	}
+	out, err := exec.Command("/usr/bin/apt-cache", append([]string{"-c", LastoreAptV2CommonConfPath, "policy", "--"}, pkgs...)...).Output() // #nosec G204
+	if err != nil {
+		return nil, err
+	}
+	return parseAptCachePolicy(out), nil
+}
+
+// parseAptCachePolicy 解析 apt-cache policy 的输出:
+//
+//	libssl3:
+//	  Installed: 3.0.11-1deepin2
+//	  Candidate: 3.0.11-1deepin3
+func parseAptCachePolicy(out []byte) map[string]string {
+	result := make(map[string]string)
+	var pkg string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			pkg = strings.TrimSuffix(strings.TrimSpace(line), ":")
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if pkg != "" && strings.HasPrefix(line, "Candidate:") {
+			ver := strings.TrimSpace(strings.TrimPrefix(line, "Candidate:"))
+			if ver != "(none)" && ver != "" {
+				result[pkg] = ver
+			}
+			pkg = ""
+		}
+	}
+	return result
+}
+
+// QueryInstalledPackages 返回已安装包的版本和架构
+func QueryInstalledPackages() (map[string]Version, error) {
+	out, err := exec.Command("/usr/bin/dpkg-query", "-W", "-f", "${Package} ${db:Status-Abbrev} ${Version} ${Architecture}\n").Output() // #nosec G204
+	if err != nil {
+		return nil, err
+	}
+	return parseInstalledPackages(out), nil
+}
+
+func parseInstalledPackages(out []byte) map[string]Version {
+	result := make(map[string]Version)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		// db:Status-Abbrev 第二个字符为 i 时表示已安装
+		if len(fields[1]) < 2 || fields[1][1] != 'i' {
+			continue
+		}
+		result[fields[0]] = Version{Version: fields[2], Arch: fields[3]}
+	}
+	return result
+}
+
+// QueryLocalOnlyPackages 查询已安装版本不来自任何仓库(只存在于 dpkg 状态中)的包
+func QueryLocalOnlyPackages(pkgs ...string) ([]string, error) {
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	out, err := exec.Command("/usr/bin/apt-cache", append([]string{"-c", LastoreAptV2CommonConfPath, "policy", "--"}, pkgs...)...).Output() // #nosec G204
+	if err != nil {
+		return nil, err
+	}
+	return parseAptCachePolicyLocalOnly(out), nil
+}
+
+// parseAptCachePolicyLocalOnly 解析 apt-cache policy 的版本列表,*** 标记的已安装版本只有 /var/lib/dpkg/status 一个来源时为本地安装的包:
+//
+//	foo:
+//	  Installed: 1.0
+//	  Candidate: 1.0
+//	  Version table:
+//	 *** 1.0 100
+//	        100 /var/lib/dpkg/status
+func parseAptCachePolicyLocalOnly(out []byte) []string {
+	var result []string
+	var pkg string
+	var inInstalled, fromRepo bool
+	finish := func() {
+		if pkg != "" && inInstalled && !fromRepo {
+			result = append(result, pkg)
+		}
+		inInstalled, fromRepo = false, false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0:
+			finish()
+			pkg = strings.TrimSuffix(strings.TrimSpace(line), ":")
+		case strings.HasPrefix(line, " *** "):
+			inInstalled, fromRepo = true, false
+		case indent >= 8:
+			// 来源行,属于上一个版本
+			if inInstalled && !strings.HasSuffix(line, "/var/lib/dpkg/status") {
+				fromRepo = true
+			}
+		case indent == 5:
+			// 其他版本开始,已安装版本的来源已经结束
+			if inInstalled {
+				finish()
+				pkg = ""
+			}
+		}
+	}
+	finish()
+	sort.Strings(result)
+	return result
+}
+
+// QueryCandidateInstalledSizes 查询包的候选版本安装后的大小,单位为字节,没有候选版本的包不在返回值中
+func QueryCandidateInstalledSizes(pkgs ...string) (map[string]uint64, error) {
+	if len(pkgs) == 0 {
+		return map[string]uint64{}, nil
+	}
+	out, err := exec.Command("/usr/bin/apt-cache", append([]string{"-c", LastoreAptV2CommonConfPath, "show", "--no-all-versions", "--"}, pkgs...)...).Output() // #nosec G204
+	// 部分包没有候选版本时 apt-cache 返回错误,但仍会输出其他包的信息
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return parseInstalledSizes(out), nil
+}
+
+// QueryCurrentInstalledSizes 查询已安装包的大小,单位为字节,未安装的包不在返回值中
+func QueryCurrentInstalledSizes(pkgs ...string) (map[string]uint64, error) {
+	if len(pkgs) == 0 {
+		return map[string]uint64{}, nil
+	}
+	out, err := exec.Command("/usr/bin/dpkg-query", append([]string{"-W", "-f",
+		"Package: ${Package}\nStatus: ${db:Status-Abbrev}\nInstalled-Size: ${Installed-Size}\n\n", "--"}, pkgs...)...).Output() // #nosec G204
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return parseInstalledSizes(out), nil
+}
+
+// parseInstalledSizes 解析 apt-cache show 或 dpkg-query 输出中的 Installed-Size 字段(单位为 KiB),
+// 有 Status 字段时只保留已安装的包:
+//
+//	Package: vim
+//	Status: ii
+//	Installed-Size: 4062
+func parseInstalledSizes(out []byte) map[string]uint64 {
+	result := make(map[string]uint64)
+	var pkg, status string
+	var size uint64
+	var hasSize bool
+	finish := func() {
+		if pkg != "" && hasSize && (status == "" || (len(status) >= 2 && status[1] == 'i')) {
+			result[pkg] = size
+		}
+		pkg, status, size, hasSize = "", "", 0, false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			finish()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Package":
+			pkg = value
+		case "Status":
+			status = value
+		case "Installed-Size":
+			kb, err := strconv.ParseUint(value, 10, 64)
+			if err == nil {
+				size, hasSize = kb*1024, true
+			}
+		}
+	}
+	finish()
+	return result
+}
+
 func QuerySourceAddSize(updateType UpdateType) (float64, error) {
 	startTime := time.Now()
 	addSize := new(float64)
@@ -72,3 +72,94 @@ func (*testWrap) TestParseSize(c *C.C) {
 		c.Check(s, C.Equals, d.Size)
 	}
 }
+
+func (*testWrap) TestParseAptCachePolicy(c *C.C) {
+	out := `libssl3:
+  Installed: 3.0.11-1deepin2
+  Candidate: 3.0.11-1deepin3
+  Version table:
+     3.0.11-1deepin3 500
+        500 https://community-packages.deepin.com/beige beige/main amd64 Packages
+ *** 3.0.11-1deepin2 100
+        100 /var/lib/dpkg/status
+libfoo1:
+  Installed: (none)
+  Candidate: (none)
+  Version table:
+libssl3:i386:
+  Installed: (none)
+  Candidate: 3.0.11-1deepin3
+`
+	versions := parseAptCachePolicy([]byte(out))
+	c.Check(versions, C.DeepEquals, map[string]string{
+		"libssl3":      "3.0.11-1deepin3",
+		"libssl3:i386": "3.0.11-1deepin3",
+	})
+}
+
+func (*testWrap) TestParseInstalledPackages(c *C.C) {
+	out := "vim ii 2:9.0-1 amd64\nnano rc 7.2-1 amd64\nlibc6 hi 2.36-9 amd64\nbroken line\n"
+	c.Check(parseInstalledPackages([]byte(out)), C.DeepEquals, map[string]Version{
+		"vim":   {Version: "2:9.0-1", Arch: "amd64"},
+		"libc6": {Version: "2.36-9", Arch: "amd64"},
+	})
+}
+
+func (*testWrap) TestParseAptCachePolicyLocalOnly(c *C.C) {
+	out := `libssl3:
+  Installed: 3.0.11-1deepin2
+  Candidate: 3.0.11-1deepin3
+  Version table:
+     3.0.11-1deepin3 500
+        500 https://community-packages.deepin.com/beige beige/main amd64 Packages
+ *** 3.0.11-1deepin2 100
+        100 /var/lib/dpkg/status
+foo:
+  Installed: 1.0
+  Candidate: 1.0
+  Version table:
+ *** 1.0 100
+        100 /var/lib/dpkg/status
+     0.9 500
+        500 https://example.com/repo stable/main amd64 Packages
+vim:
+  Installed: 2:9.0-1
+  Candidate: 2:9.0-1
+  Version table:
+ *** 2:9.0-1 500
+        500 https://community-packages.deepin.com/beige beige/main amd64 Packages
+        100 /var/lib/dpkg/status
+`
+	c.Check(parseAptCachePolicyLocalOnly([]byte(out)), C.DeepEquals, []string{"foo", "libssl3"})
+}
+
+func (*testWrap) TestParseInstalledSizes(c *C.C) {
+	out := `Package: vim
+Version: 2:9.0-1
+Installed-Size: 4062
+Description: Vi IMproved
+ Installed-Size: 1
+
+Package: libssl3
+Installed-Size: 6288
+`
+	c.Check(parseInstalledSizes([]byte(out)), C.DeepEquals, map[string]uint64{
+		"vim":     4062 * 1024,
+		"libssl3": 6288 * 1024,
+	})
+
+	out = `Package: vim
+Status: ii
+Installed-Size: 4062
+
+Package: nano
+Status: rc
+Installed-Size: 2800
+
+Package: htop
+Status: ii
+Installed-Size: 
+
+`
+	c.Check(parseInstalledSizes([]byte(out)), C.DeepEquals, map[string]uint64{"vim": 4062 * 1024})
+}
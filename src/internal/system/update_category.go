@@ -0,0 +1,275 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 管理员可以在 /etc/deepin/lastore-daemon/category.conf.d/*.json 中定义内置类型之外的更新分类,
+// 每个文件描述一个分类,例如:
+//
+//	{
+//		"Name": "office",
+//		"Bit": 8,
+//		"Sources": ["office.list"],
+//		"DisplayName": {"": "Office", "zh_CN": "办公套件"},
+//		"ShowUpdates": true,
+//		"AutoInstall": "never"
+//	}
+const (
+	UpdateCategoryConfigDir = "/etc/deepin/lastore-daemon/category.conf.d/"
+	CategorySourceDir       = "/var/lib/lastore/categorySource.d" // 每个分类一个子目录,存放对应仓库的软链接
+
+	minCategoryBit = 8 // 0-7 位保留给内置更新类型
+	maxCategoryBit = 63
+)
+
+type AutoInstallPolicy string
+
+const (
+	AutoInstallDefault AutoInstallPolicy = ""       // 跟随 AutoInstallUpdates 和 AutoInstallUpdateType 配置
+	AutoInstallAlways  AutoInstallPolicy = "always" // 开启自动安装时总是自动安装该分类
+	AutoInstallNever   AutoInstallPolicy = "never"  // 从不自动安装该分类
+)
+
+// UpdateCategory 管理员定义的更新分类
+type UpdateCategory struct {
+	Name        string            // 分类名,对应的 job 类型为 <Name>_upgrade
+	Bit         uint              // UpdateType 中占用的位,取值 8-63
+	Sources     []string          // 仓库文件,相对路径为 /etc/apt/sources.list.d 下的文件
+	DisplayName map[string]string // 语言 -> 显示名称,键为空字符串时为默认名称
+	ShowUpdates bool              // 是否在控制中心显示该分类的更新内容,为 false 时只检查不显示
+	AutoInstall AutoInstallPolicy
+}
+
+var categoryNameReg = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Type 分类对应的 UpdateType
+func (c *UpdateCategory) Type() UpdateType {
+	return UpdateType(1) << c.Bit
+}
+
+func (c *UpdateCategory) JobType() string {
+	return c.Name + "_upgrade"
+}
+
+// SourceDir 分类仓库软链接所在的目录
+func (c *UpdateCategory) SourceDir() string {
+	return filepath.Join(CategorySourceDir, c.Name)
+}
+
+// SourcePaths 分类仓库文件的绝对路径
+func (c *UpdateCategory) SourcePaths() []string {
+	var paths []string
+	for _, source := range c.Sources {
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(OriginSourceDir, source)
+		}
+		paths = append(paths, source)
+	}
+	return paths
+}
+
+// LocalizedName 根据 lang(如 zh_CN.UTF-8) 获取显示名称,没有对应语言时依次使用语言前缀、默认名称和分类名
+func (c *UpdateCategory) LocalizedName(lang string) string {
+	lang = strings.SplitN(lang, ".", 2)[0]
+	candidates := []string{lang, strings.SplitN(lang, "_", 2)[0], ""}
+	for _, key := range candidates {
+		if name, ok := c.DisplayName[key]; ok && name != "" {
+			return name
+		}
+	}
+	return c.Name
+}
+
+func (c *UpdateCategory) validate() error {
+	if !categoryNameReg.MatchString(c.Name) {
+		return fmt.Errorf("invalid category name %q", c.Name)
+	}
+	if c.Bit < minCategoryBit || c.Bit > maxCategoryBit {
+		return fmt.Errorf("category %v: bit %v out of range [%v, %v]", c.Name, c.Bit, minCategoryBit, maxCategoryBit)
+	}
+	for _, typ := range builtinUpdateType() {
+		if typ.JobType() == c.JobType() {
+			return fmt.Errorf("category %v: conflict with builtin update type", c.Name)
+		}
+	}
+	if len(c.Sources) == 0 {
+		return fmt.Errorf("category %v: no sources", c.Name)
+	}
+	for _, source := range c.Sources {
+		if !IsSourceFileName(source) || strings.Contains(filepath.Clean(source), "..") {
+			return fmt.Errorf("category %v: invalid source %q", c.Name, source)
+		}
+	}
+	switch c.AutoInstall {
+	case AutoInstallDefault, AutoInstallAlways, AutoInstallNever:
+	default:
+		return fmt.Errorf("category %v: invalid auto install policy %q", c.Name, c.AutoInstall)
+	}
+	return nil
+}
+
+var (
+	updateCategoriesMu sync.RWMutex
+	updateCategories   []UpdateCategory
+)
+
+// LoadUpdateCategories 读取 dir 中的分类定义,按文件名排序;无效或与已有分类名称、位冲突的定义会被忽略
+func LoadUpdateCategories(dir string) []UpdateCategory {
+	infos, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warningf("failed to read dir %v, error is %v", dir, err)
+		}
+		return nil
+	}
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	var categories []UpdateCategory
+	usedNames := make(map[string]bool)
+	usedBits := make(map[uint]bool)
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		var category UpdateCategory
+		err = json.Unmarshal(content, &category)
+		if err != nil {
+			logger.Warningf("invalid category config %v: %v", name, err)
+			continue
+		}
+		err = category.validate()
+		if err != nil {
+			logger.Warningf("invalid category config %v: %v", name, err)
+			continue
+		}
+		if usedNames[category.Name] || usedBits[category.Bit] {
+			logger.Warningf("category config %v: duplicate name %v or bit %v", name, category.Name, category.Bit)
+			continue
+		}
+		usedNames[category.Name] = true
+		usedBits[category.Bit] = true
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+// SetUpdateCategories 设置当前生效的分类,需要在 lastore-daemon 启动时设置,运行中修改会导致更新状态不一致
+func SetUpdateCategories(categories []UpdateCategory) {
+	updateCategoriesMu.Lock()
+	defer updateCategoriesMu.Unlock()
+	updateCategories = categories
+}
+
+// UpdateCategories 当前生效的分类
+func UpdateCategories() []UpdateCategory {
+	updateCategoriesMu.RLock()
+	defer updateCategoriesMu.RUnlock()
+	return append([]UpdateCategory(nil), updateCategories...)
+}
+
+// CustomCategory 获取 typ 对应的管理员定义分类,typ 需要为单个类型
+func CustomCategory(typ UpdateType) (UpdateCategory, bool) {
+	updateCategoriesMu.RLock()
+	defer updateCategoriesMu.RUnlock()
+	for _, c := range updateCategories {
+		if c.Type() == typ {
+			return c, true
+		}
+	}
+	return UpdateCategory{}, false
+}
+
+func customCategoryTypes(onlyShowUpdates bool) []UpdateType {
+	var res []UpdateType
+	for _, c := range UpdateCategories() {
+		if onlyShowUpdates && !c.ShowUpdates {
+			continue
+		}
+		res = append(res, c.Type())
+	}
+	return res
+}
+
+// categorySourceNames 分类使用的 /etc/apt/sources.list.d 下的仓库文件名,这些仓库不再属于未知来源
+func categorySourceNames() []string {
+	var names []string
+	for _, c := range UpdateCategories() {
+		for _, path := range c.SourcePaths() {
+			if filepath.Dir(path) == OriginSourceDir {
+				names = append(names, filepath.Base(path))
+			}
+		}
+	}
+	return names
+}
+
+// UpdateCategorySourceDirs 重新生成所有分类的仓库文件夹
+func UpdateCategorySourceDirs() error {
+	// 移除旧数据
+	err := os.RemoveAll(CategorySourceDir)
+	if err != nil {
+		logger.Warning(err)
+	}
+	var errList []string
+	for _, c := range UpdateCategories() {
+		// #nosec G301
+		err = os.MkdirAll(c.SourceDir(), 0755)
+		if err != nil {
+			return err
+		}
+		for _, filePath := range c.SourcePaths() {
+			if _, err := os.Stat(filePath); err != nil {
+				errList = append(errList, fmt.Sprintf("category %v: %v", c.Name, err))
+				continue
+			}
+			linkPath := filepath.Join(c.SourceDir(), filepath.Base(filePath))
+			err = os.Symlink(filePath, linkPath)
+			if err != nil {
+				errList = append(errList, fmt.Sprintf("create symlink for %q failed: %v", filePath, err))
+			}
+		}
+	}
+	if len(errList) > 0 {
+		return errors.New(strings.Join(errList, "; "))
+	}
+	return nil
+}
+
+// AllCheckUpdateMode 所有需要检查的仓库,包含管理员定义的分类
+func AllCheckUpdateMode() UpdateType {
+	mode := AllCheckUpdate
+	for _, typ := range customCategoryTypes(false) {
+		mode |= typ
+	}
+	return mode
+}
+
+// AllInstallUpdateMode 所有控制中心需要显示的仓库,包含 ShowUpdates 的管理员定义分类
+func AllInstallUpdateMode() UpdateType {
+	mode := AllInstallUpdate
+	for _, typ := range customCategoryTypes(true) {
+		mode |= typ
+	}
+	return mode
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadUpdateCategories(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]string{
+		"10-office.json":    `{"Name":"office","Bit":8,"Sources":["office.list"],"DisplayName":{"":"Office","zh_CN":"办公套件"},"ShowUpdates":true,"AutoInstall":"never"}`,
+		"20-driver.json":    `{"Name":"gpu","Bit":9,"Sources":["/etc/apt/gpu.sources"]}`,
+		"30-dup-bit.json":   `{"Name":"dup","Bit":8,"Sources":["dup.list"]}`,
+		"40-low-bit.json":   `{"Name":"low","Bit":6,"Sources":["low.list"]}`,
+		"50-builtin.json":   `{"Name":"system","Bit":10,"Sources":["system.list"]}`,
+		"60-bad-src.json":   `{"Name":"bad","Bit":11,"Sources":["../bad.list"]}`,
+		"70-bad-auto.json":  `{"Name":"auto","Bit":12,"Sources":["auto.list"],"AutoInstall":"sometimes"}`,
+		"80-invalid.json":   `{`,
+		"90-not-config.txt": `{"Name":"txt","Bit":13,"Sources":["txt.list"]}`,
+	}
+	for name, content := range configs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	categories := LoadUpdateCategories(dir)
+	require.Len(t, categories, 2)
+	office := categories[0]
+	assert.Equal(t, "office", office.Name)
+	assert.Equal(t, UpdateType(1<<8), office.Type())
+	assert.Equal(t, "office_upgrade", office.JobType())
+	assert.Equal(t, AutoInstallNever, office.AutoInstall)
+	assert.Equal(t, []string{"/etc/apt/sources.list.d/office.list"}, office.SourcePaths())
+	assert.Equal(t, "办公套件", office.LocalizedName("zh_CN.UTF-8"))
+	assert.Equal(t, "Office", office.LocalizedName("en_US.UTF-8"))
+	assert.Equal(t, "gpu", categories[1].LocalizedName("zh_CN"))
+
+	assert.Nil(t, LoadUpdateCategories(filepath.Join(dir, "not-exist")))
+}
+
+func TestCustomUpdateCategoryTypes(t *testing.T) {
+	SetUpdateCategories([]UpdateCategory{
+		{Name: "office", Bit: 8, Sources: []string{"office.list"}, ShowUpdates: true},
+		{Name: "gpu", Bit: 9, Sources: []string{"/etc/apt/gpu.sources"}},
+	})
+	defer SetUpdateCategories(nil)
+
+	office := UpdateType(1 << 8)
+	gpu := UpdateType(1 << 9)
+	assert.Equal(t, "office_upgrade", office.JobType())
+	assert.Equal(t, "", UpdateType(1<<10).JobType())
+	assert.Equal(t, SystemUpgradeJobType, SystemUpdate.JobType())
+
+	assert.Equal(t, AllInstallUpdate|office, AllInstallUpdateMode())
+	assert.Equal(t, AllCheckUpdate|office|gpu, AllCheckUpdateMode())
+	assert.Contains(t, AllInstallUpdateType(), office)
+	assert.NotContains(t, AllInstallUpdateType(), gpu)
+	assert.Equal(t, []UpdateType{SystemUpdate, office, gpu}, UpdateTypeBitToArray(SystemUpdate|office|gpu))
+
+	assert.Equal(t, filepath.Join(CategorySourceDir, "gpu"), GetCategorySourceMap()[gpu])
+	assert.Equal(t, []string{"office.list"}, categorySourceNames())
+}
@@ -59,7 +59,7 @@ func RefreshSymlinksForSourceDir(sourceDir string) {
 
 	sourceFileMap := make(map[string]bool)
 	for _, f := range sourceFiles {
-		if strings.HasSuffix(f.Name(), ".list") {
+		if IsSourceFileName(f.Name()) {
 			sourceFileMap[f.Name()] = true
 		}
 	}
@@ -93,7 +93,7 @@ func RefreshSymlinksForSourceDir(sourceDir string) {
 
 	for _, f := range sourceFiles {
 		fileName := f.Name()
-		if !strings.HasSuffix(fileName, ".list") {
+		if !IsSourceFileName(fileName) {
 			continue
 		}
 		linkPath := filepath.Join(tempDir, fileName)
@@ -122,8 +122,8 @@ const (
 	OnlySecurityUpdate UpdateType = 1 << 4 // 仅安全仓库更新，已经废弃，用于处理历史版本升级后的兼容问题
 	OtherSystemUpdate  UpdateType = 1 << 5 // 其他来源系统的仓库更新:对应dconfig non-unknown-sources 字段去掉商店和安全仓库,通常为驱动、hwe仓库(hwe仓库应该从平台获取)，检查该仓库，不显示更新内容
 
-	AllCheckUpdate   = SystemUpdate | AppStoreUpdate | SecurityUpdate | UnknownUpdate | OtherSystemUpdate | AppendUpdate // 所有需要检查的仓库 TODO 该字段变动，需要检查所有使用者
-	AllInstallUpdate = SystemUpdate | SecurityUpdate | UnknownUpdate                                                     // 所有控制中心需要显示的仓库
+	AllCheckUpdate   = SystemUpdate | AppStoreUpdate | SecurityUpdate | UnknownUpdate | OtherSystemUpdate | AppendUpdate // 所有需要检查的内置仓库,包含管理员定义分类时使用 AllCheckUpdateMode() TODO 该字段变动，需要检查所有使用者
+	AllInstallUpdate = SystemUpdate | SecurityUpdate | UnknownUpdate                                                     // 所有控制中心需要显示的内置仓库,包含管理员定义分类时使用 AllInstallUpdateMode()
 
 	AppendUpdate UpdateType = 1 << 7 // 追加仓库/etc/deepin/lastore-daemon/sources.list.d/ 用于打印管理追加离线包.检查该仓库,不显示更新内容
 )
@@ -143,6 +143,9 @@ func (m UpdateType) JobType() string {
 	case AppendUpdate:
 		return AppendUpgradeJobTye
 	default:
+		if c, ok := CustomCategory(m); ok {
+			return c.JobType()
+		}
 		return ""
 	}
 }
@@ -157,7 +160,7 @@ func UpdateTypeBitToArray(mode UpdateType) []UpdateType {
 	return res
 }
 
-func AllUpdateType() []UpdateType {
+func builtinUpdateType() []UpdateType {
 	return []UpdateType{
 		SystemUpdate,
 		AppStoreUpdate,
@@ -168,25 +171,29 @@ func AllUpdateType() []UpdateType {
 	}
 }
 
-// AllCheckUpdateType 对应 system.AllCheckUpdate
+func AllUpdateType() []UpdateType {
+	return append(builtinUpdateType(), customCategoryTypes(false)...)
+}
+
+// AllCheckUpdateType 对应 system.AllCheckUpdateMode()
 func AllCheckUpdateType() []UpdateType {
-	return []UpdateType{
+	return append([]UpdateType{
 		SystemUpdate,
 		AppStoreUpdate,
 		SecurityUpdate,
 		UnknownUpdate,
 		OtherSystemUpdate,
 		AppendUpdate,
-	}
+	}, customCategoryTypes(false)...)
 }
 
-// AllInstallUpdateType 对应 system.AllInstallUpdate
+// AllInstallUpdateType 对应 system.AllInstallUpdateMode()
 func AllInstallUpdateType() []UpdateType {
-	return []UpdateType{
+	return append([]UpdateType{
 		SystemUpdate,
 		SecurityUpdate,
 		UnknownUpdate,
-	}
+	}, customCategoryTypes(true)...)
 }
 
 const (
@@ -214,6 +221,45 @@ const (
 
 var SystemUpdateSource = SoftLinkSystemSourceDir
 
+const osReleaseFile = "/etc/os-release"
+
+// SystemDeb822SourceList 返回 sources.list.d 中 deb822 格式的系统仓库文件名,如 deepin.sources、debian.sources,
+// 按 /etc/os-release 中的 ID 和 ID_LIKE 依次查找,不存在时返回空
+func SystemDeb822SourceList() string {
+	data, err := os.ReadFile(osReleaseFile)
+	if err != nil {
+		return ""
+	}
+	return systemDeb822SourceList(data, OriginSourceDir)
+}
+
+func systemDeb822SourceList(osRelease []byte, dir string) string {
+	var ids []string
+	for _, line := range strings.Split(string(osRelease), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			ids = append([]string{value}, ids...)
+		case "ID_LIKE":
+			ids = append(ids, strings.Fields(value)...)
+		}
+	}
+	for _, id := range ids {
+		if id == "" || strings.ContainsAny(id, "/.") {
+			continue
+		}
+		name := id + sourceDeb822Ext
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
 func SetSystemUpdate(platform bool) {
 	if platform {
 		SystemUpdateSource = PlatFormSourceFile
@@ -222,9 +268,9 @@ func SetSystemUpdate(platform bool) {
 	}
 }
 
-// GetCategorySourceMap 缺省更新类型与对应仓库的map
+// GetCategorySourceMap 缺省更新类型及管理员定义分类与对应仓库的map
 func GetCategorySourceMap() map[UpdateType]string {
-	return map[UpdateType]string{
+	res := map[UpdateType]string{
 		SystemUpdate:      SystemUpdateSource,
 		AppStoreUpdate:    AppStoreSourceFile,
 		SecurityUpdate:    SecuritySourceDir,
@@ -232,6 +278,10 @@ func GetCategorySourceMap() map[UpdateType]string {
 		OtherSystemUpdate: OtherSystemSourceDir,
 		AppendUpdate:      AppendSourceDir,
 	}
+	for _, c := range UpdateCategories() {
+		res[c.Type()] = c.SourceDir()
+	}
+	return res
 }
 
 const (
@@ -253,6 +303,9 @@ func UpdateSystemDefaultSourceDir(sourceList []string) error {
 	}
 	if len(sourceList) == 0 {
 		sourceList = []string{UnstableSourceFile, OriginSourceFile, HweSourceFile}
+		if name := SystemDeb822SourceList(); name != "" {
+			sourceList = append(sourceList, filepath.Join(OriginSourceDir, name))
+		}
 	}
 	// 创建对应的软链接
 	for _, filePath := range sourceList {
@@ -275,6 +328,13 @@ func UpdateP2pDefaultSourceDir(updateType UpdateType, platformRepos []string) er
 		return fmt.Errorf("create temp file for p2p source failed: %v", err)
 	}
 	defer os.Remove(p2pSource.Name())
+	// deb822 格式的仓库不能和 one-line 格式写在同一个文件中
+	p2pDeb822Source, err := ioutil.TempFile("/tmp", "p2pSource-*.sources")
+	if err != nil {
+		return fmt.Errorf("create temp file for p2p source failed: %v", err)
+	}
+	defer os.Remove(p2pDeb822Source.Name())
+	hasDeb822 := false
 	//从SystemSource.d或SecuritySource.d中读取每个文件内容并将协议替换成delivery协议后存放到/tmp中
 	//这么做为了保证替换协议的原子性
 	files, err := ioutil.ReadDir(sourceDir)
@@ -284,6 +344,9 @@ func UpdateP2pDefaultSourceDir(updateType UpdateType, platformRepos []string) er
 	for _, file := range files {
 		var content []byte
 		filePath := filepath.Join(sourceDir, file.Name())
+		if !IsSourceFileName(file.Name()) {
+			continue
+		}
 		if utils.IsSymlink(filePath) {
 			targetPath, err := os.Readlink(filePath)
 			if err != nil {
@@ -304,12 +367,29 @@ func UpdateP2pDefaultSourceDir(updateType UpdateType, platformRepos []string) er
 				return fmt.Errorf("error reading file: %w", err)
 			}
 		}
+		if SourceFormatOf(file.Name()) == SourceFormatDeb822 {
+			newContent, err := replaceMatchedDeb822ReposWithDelivery(string(content), platformRepos)
+			if err != nil {
+				return fmt.Errorf("parse %s failed: %w", filePath, err)
+			}
+			// 段之间需要空行分隔
+			if hasDeb822 {
+				newContent = "\n" + newContent
+			}
+			hasDeb822 = true
+			_, err = p2pDeb822Source.Write([]byte(newContent))
+			if err != nil {
+				return fmt.Errorf("Error writing file: %w", err)
+			}
+			continue
+		}
 		newContent := replaceMatchedReposWithDelivery(string(content), platformRepos)
 		_, err = p2pSource.Write([]byte(newContent))
 		if err != nil {
 			return fmt.Errorf("Error writing file: %w", err)
 		}
 	}
+	p2pDeb822Source.Close()
 	//所有协议均正常替换后重新创建SystemSource.d或SecuritySource.d，再讲/tmp中的文件拷贝过去
 	err = os.RemoveAll(sourceDir)
 	if err != nil {
@@ -325,6 +405,12 @@ func UpdateP2pDefaultSourceDir(updateType UpdateType, platformRepos []string) er
 	if err != nil {
 		logger.Warning(err)
 	}
+	if hasDeb822 {
+		err = utils.MoveFile(p2pDeb822Source.Name(), filepath.Join(sourceDir, filepath.Base(p2pDeb822Source.Name())))
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
 	RefreshSymlinksForSourceDir(sourceDir)
 	return nil
 }
@@ -353,6 +439,42 @@ func replaceMatchedReposWithDelivery(content string, platformRepos []string) str
 	return strings.Join(lines, "\n")
 }
 
+// replaceMatchedDeb822ReposWithDelivery 与 replaceMatchedReposWithDelivery 相同,用于 deb822 格式的仓库,
+// 只修改匹配的 URIs,注释和 Signed-By 等选项保持不变
+func replaceMatchedDeb822ReposWithDelivery(content string, platformRepos []string) (string, error) {
+	matchedURLs := make(map[string]struct{})
+	for _, repo := range platformRepos {
+		urlPath := extractURLPathFromLine(repo)
+		if urlPath != "" {
+			matchedURLs[urlPath] = struct{}{}
+		}
+	}
+	if len(matchedURLs) == 0 {
+		return content, nil
+	}
+	f, err := ParseSources(content, SourceFormatDeb822)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range f.Entries() {
+		if !strv.Strv(entry.Types).Contains("deb") {
+			continue
+		}
+		for i, uri := range entry.URIs {
+			if _, ok := matchedURLs[extractURLPath(uri)]; !ok {
+				continue
+			}
+			for _, scheme := range []string{"https://", "http://"} {
+				if strings.HasPrefix(uri, scheme) {
+					entry.URIs[i] = "delivery://" + strings.TrimSuffix(strings.TrimPrefix(uri, scheme), "/")
+					break
+				}
+			}
+		}
+	}
+	return string(f.Bytes()), nil
+}
+
 func extractURLPathFromLine(line string) string {
 	fields := strings.Fields(line)
 	for _, field := range fields {
@@ -438,8 +560,18 @@ func UpdateSourceDirUseUrl(updateType UpdateType, repoUrl []string, fileName str
 		logger.Warning(err)
 		return err
 	}
+	repoContent := strings.Join(repoUrl, "\n")
+	format := DetectSourceFormat(repoContent)
+	// apt 根据扩展名判断格式,deb822 格式的仓库需要保存为 .sources 文件
+	if format == SourceFormatDeb822 {
+		fileName = sourceFileStem(fileName) + sourceDeb822Ext
+	} else {
+		fileName = sourceFileStem(fileName) + sourceListExt
+	}
+	// 导入过公钥的仓库通过 Signed-By 使用对应的公钥
+	repoContent = ApplyRepoKeys(repoContent, format)
 	var content string
-	content = fmt.Sprintf("## %v \n%v", annotation, strings.Join(repoUrl, "\n"))
+	content = fmt.Sprintf("## %v \n%v", annotation, repoContent)
 	return os.WriteFile(filepath.Join(sourceDir, fileName), []byte(content), 0644)
 }
 
@@ -480,11 +612,21 @@ func UpdateUnknownSourceDir(nonUnknownList strv.Strv) error {
 			UnstableSourceList,
 			HweSourceList,
 		}
+		if name := SystemDeb822SourceList(); name != "" {
+			nonUnknownList = append(nonUnknownList, name)
+		}
+	}
+	// 管理员定义分类使用的仓库由对应分类检查
+	nonUnknownList = append(nonUnknownList, categorySourceNames()...)
+	// 同名的 .list 和 .sources 文件视为同一个仓库,如配置了 appstore.list 时 appstore.sources 也不属于未知来源
+	nonUnknownStems := make(map[string]bool)
+	for _, name := range nonUnknownList {
+		nonUnknownStems[sourceFileStem(name)] = true
 	}
 	for _, fileInfo := range sourceDirFileInfos {
 		name := fileInfo.Name()
-		if strings.HasSuffix(name, ".list") {
-			if !nonUnknownList.Contains(name) {
+		if IsSourceFileName(name) {
+			if !nonUnknownStems[sourceFileStem(name)] {
 				unknownSourceFilePaths = append(unknownSourceFilePaths, filepath.Join(OriginSourceDir, name))
 			}
 		}
@@ -592,7 +734,7 @@ func CustomSourceWrapper(updateType UpdateType, doRealAction func(path string, u
 					}
 					for _, fileInfo := range allSourceDirFileInfos {
 						name := fileInfo.Name()
-						if strings.HasSuffix(name, ".list") {
+						if IsSourceFileName(name) {
 							allSourceFilePaths = append(allSourceFilePaths, filepath.Join(path, name))
 						}
 					}
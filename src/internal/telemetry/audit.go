@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAuditFile 上报记录文件,lastore-daemon 和 lastore-tools 共用
+	DefaultAuditFile = "/var/lib/lastore/telemetry-audit.jsonl"
+
+	maxAuditSize    = 4 << 20 // 记录文件超过该大小时只保留最近的 keepAuditLines 条
+	keepAuditLines  = 1000
+	maxAuditPayload = 16 << 10 // 超过该大小的数据只记录大小和 sha256
+)
+
+// 上报的数据类型
+const (
+	KindProcessEvent  = "process-event"
+	KindStatus        = "status"
+	KindLogFiles      = "log-files"
+	KindUpgradeResult = "upgrade-result"
+	KindHardware      = "hardware"
+)
+
+// Upload 一次上报的记录
+type Upload struct {
+	Time    int64
+	Kind    string
+	URL     string // 不含查询参数
+	TaskID  int    `json:",omitempty"`
+	Level   Level
+	Headers map[string]string `json:",omitempty"` // 携带设备信息的请求头,X-Repo-Token 记录解码后的内容
+	Payload json.RawMessage   `json:",omitempty"` // 加密前的 json 数据,超过 16KiB 或不是 json 时不记录
+	Files   []string          `json:",omitempty"` // 上报的文件
+	Size    int               // 加密、压缩前的数据大小
+	SHA256  string            `json:",omitempty"`
+}
+
+// deviceHeaders 携带设备信息的请求头
+var deviceHeaders = []string{"X-MachineID", "X-Repo-Token"}
+
+// NewUpload 根据请求和加密前的数据创建上报记录
+func NewUpload(kind string, level Level, request *http.Request, payload []byte) Upload {
+	u := Upload{
+		Kind:  kind,
+		Level: level,
+		Size:  len(payload),
+	}
+	if request != nil {
+		u.URL = stripURL(request.URL)
+		for _, name := range deviceHeaders {
+			value := request.Header.Get(name)
+			if value == "" {
+				continue
+			}
+			if name == "X-Repo-Token" {
+				if decoded, err := base64.RawStdEncoding.DecodeString(value); err == nil {
+					value = string(decoded)
+				}
+			}
+			if u.Headers == nil {
+				u.Headers = make(map[string]string)
+			}
+			u.Headers[name] = value
+		}
+	}
+	if len(payload) > 0 {
+		sum := sha256.Sum256(payload)
+		u.SHA256 = hex.EncodeToString(sum[:])
+		if len(payload) <= maxAuditPayload && json.Valid(payload) {
+			var buf bytes.Buffer
+			if json.Compact(&buf, payload) == nil {
+				u.Payload = buf.Bytes()
+			}
+		}
+	}
+	return u
+}
+
+func stripURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	v := *u
+	v.RawQuery = ""
+	v.User = nil
+	v.Fragment = ""
+	return v.String()
+}
+
+// AuditLog 记录每次上报的数据,用于隐私审计
+type AuditLog struct {
+	path string
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewAuditLog 创建上报记录,path 为空时不记录
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{
+		path: path,
+		now:  time.Now,
+	}
+}
+
+// Record 追加一条上报记录,记录文件过大时只保留最近的记录
+func (l *AuditLog) Record(u Upload) error {
+	if l == nil || l.path == "" {
+		return nil
+	}
+	if u.Time == 0 {
+		u.Time = l.now().Unix()
+	}
+	line, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(l.path); err == nil && info.Size() > maxAuditSize {
+		uploads, err := ReadAudit(l.path, keepAuditLines)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		for _, u := range uploads {
+			line, _ := json.Marshal(u)
+			buf.Write(append(line, '\n'))
+		}
+		return os.WriteFile(l.path, buf.Bytes(), 0600)
+	}
+	return nil
+}
+
+// ReadAudit 读取记录文件中最近的 n 条上报记录,n 小于等于 0 时返回全部
+func ReadAudit(path string, n int) ([]Upload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var uploads []Upload
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var u Upload
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			continue
+		}
+		uploads = append(uploads, u)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if n > 0 && len(uploads) > n {
+		uploads = uploads[len(uploads)-n:]
+	}
+	return uploads, nil
+}
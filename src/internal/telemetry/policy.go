@@ -0,0 +1,218 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package telemetry 决定上报给更新平台的设备信息:按遥测级别选择字段,按配置删除或哈希字段,并记录每次上报
+package telemetry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Level 遥测级别,级别越高上报的设备信息越多
+type Level string
+
+const (
+	LevelNone     Level = "none"     // 只上报获取更新所必需的系统版本信息
+	LevelMinimal  Level = "minimal"  // 增加设备标识和定制信息
+	LevelStandard Level = "standard" // 增加处理器、硬件版本和机型
+	LevelFull     Level = "full"     // 增加序列号、MAC 地址和硬件信息,与没有遥测级别时的行为一致
+)
+
+var levels = []Level{LevelNone, LevelMinimal, LevelStandard, LevelFull}
+
+// ParseLevel 解析遥测级别,为空时为 full
+func ParseLevel(value string) (Level, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return LevelFull, nil
+	}
+	for _, level := range levels {
+		if string(level) == value {
+			return level, nil
+		}
+	}
+	return LevelNone, fmt.Errorf("invalid telemetry level %q", value)
+}
+
+func (l Level) rank() int {
+	for i, level := range levels {
+		if level == l {
+			return i
+		}
+	}
+	return 0
+}
+
+// 上报的字段,token 中的字段与 updateplatform.SystemInfo 的字段名一致
+const (
+	FieldSystemName      = "SystemName"
+	FieldProductType     = "ProductType"
+	FieldEditionName     = "EditionName"
+	FieldVersion         = "Version"
+	FieldArch            = "Arch"
+	FieldCustom          = "Custom"
+	FieldBaseline        = "Baseline"
+	FieldSystemType      = "SystemType"
+	FieldHardwareId      = "HardwareId" // 同时决定 X-MachineID 请求头和更新结果中的 MachineID
+	FieldOEMID           = "OEMID"
+	FieldProjectId       = "ProjectId"
+	FieldProcessor       = "Processor"
+	FieldHardwareVersion = "HardwareVersion"
+	FieldMachineType     = "MachineType"
+	FieldSN              = "SN"
+	FieldMac             = "Mac"
+	FieldBoardSerial     = "BoardSerial" // lastore-tools posthardware 上报的主板序列号
+	FieldDisk            = "Disk"        // lastore-tools posthardware 上报的磁盘容量
+	FieldMemory          = "Memory"      // lastore-tools posthardware 上报的内存容量
+)
+
+type fieldInfo struct {
+	name     string
+	level    Level // 上报该字段需要的最低级别
+	hashable bool  // 字符串字段才能哈希
+}
+
+var fields = []fieldInfo{
+	{FieldSystemName, LevelNone, true},
+	{FieldProductType, LevelNone, true},
+	{FieldEditionName, LevelNone, true},
+	{FieldVersion, LevelNone, true},
+	{FieldArch, LevelNone, true},
+	{FieldCustom, LevelNone, true},
+	{FieldBaseline, LevelNone, true},
+	{FieldSystemType, LevelNone, true},
+	{FieldHardwareId, LevelMinimal, true},
+	{FieldOEMID, LevelMinimal, true},
+	{FieldProjectId, LevelMinimal, true},
+	{FieldProcessor, LevelStandard, true},
+	{FieldHardwareVersion, LevelStandard, true},
+	{FieldMachineType, LevelStandard, true},
+	{FieldSN, LevelFull, true},
+	{FieldMac, LevelFull, true},
+	{FieldBoardSerial, LevelFull, true},
+	{FieldDisk, LevelFull, false},
+	{FieldMemory, LevelFull, false},
+}
+
+func lookupField(name string) (fieldInfo, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+// Action 字段的脱敏方式
+type Action string
+
+const (
+	ActionKeep Action = "keep" // 原样上报
+	ActionHash Action = "hash" // 上报 sha256 值,平台仍然可以区分不同的设备,但无法得到原始值
+	ActionDrop Action = "drop" // 不上报
+)
+
+// ParseRedaction 解析字段脱敏配置,格式为字段名到脱敏方式的 json 对象,如 {"SN":"hash","Mac":"drop"}
+func ParseRedaction(value string) (map[string]Action, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var rules map[string]Action
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid telemetry redaction: %w", err)
+	}
+	for name, action := range rules {
+		f, ok := lookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid telemetry redaction: unknown field %q", name)
+		}
+		switch action {
+		case ActionKeep, ActionDrop:
+		case ActionHash:
+			if !f.hashable {
+				return nil, fmt.Errorf("invalid telemetry redaction: field %q can not be hashed", name)
+			}
+		default:
+			return nil, fmt.Errorf("invalid telemetry redaction: unknown action %q for %q", action, name)
+		}
+	}
+	return rules, nil
+}
+
+// Policy 遥测策略
+type Policy struct {
+	Level  Level
+	Redact map[string]Action
+}
+
+// NewPolicy 根据配置创建遥测策略,配置无效时返回错误和级别为 none 的策略
+func NewPolicy(level, redact string) (Policy, error) {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return Policy{Level: LevelNone}, err
+	}
+	rules, err := ParseRedaction(redact)
+	if err != nil {
+		return Policy{Level: LevelNone}, err
+	}
+	return Policy{Level: l, Redact: rules}, nil
+}
+
+// Allowed 字段是否上报
+func (p Policy) Allowed(name string) bool {
+	f, ok := lookupField(name)
+	if !ok || p.Level.rank() < f.level.rank() {
+		return false
+	}
+	return p.Redact[name] != ActionDrop
+}
+
+// Value 返回字段实际上报的值,不上报时返回空字符串
+func (p Policy) Value(name, value string) string {
+	if !p.Allowed(name) {
+		return ""
+	}
+	if p.Redact[name] == ActionHash && value != "" {
+		return Hash(value)
+	}
+	return value
+}
+
+// Hash 返回字段值的 sha256
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// FieldStatus 字段在当前策略下是否上报
+type FieldStatus struct {
+	Name   string
+	Level  Level  // 上报该字段需要的最低级别
+	Sent   bool   // 是否上报
+	Action Action `json:",omitempty"` // 上报时的脱敏方式
+}
+
+// Fields 返回所有字段在当前策略下的状态
+func (p Policy) Fields() []FieldStatus {
+	result := make([]FieldStatus, 0, len(fields))
+	for _, f := range fields {
+		status := FieldStatus{
+			Name:  f.name,
+			Level: f.level,
+			Sent:  p.Allowed(f.name),
+		}
+		if status.Sent {
+			status.Action = ActionKeep
+			if p.Redact[f.name] == ActionHash {
+				status.Action = ActionHash
+			}
+		}
+		result = append(result, status)
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package telemetry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("")
+	require.NoError(t, err)
+	assert.Equal(t, LevelFull, level)
+
+	level, err = ParseLevel(" minimal ")
+	require.NoError(t, err)
+	assert.Equal(t, LevelMinimal, level)
+
+	_, err = ParseLevel("everything")
+	assert.Error(t, err)
+}
+
+func TestPolicyLevels(t *testing.T) {
+	for _, c := range []struct {
+		level   Level
+		allowed []string
+		denied  []string
+	}{
+		{LevelNone, []string{FieldSystemName, FieldBaseline}, []string{FieldHardwareId, FieldProcessor, FieldSN}},
+		{LevelMinimal, []string{FieldHardwareId, FieldOEMID}, []string{FieldProcessor, FieldMac}},
+		{LevelStandard, []string{FieldProcessor, FieldMachineType}, []string{FieldSN, FieldDisk}},
+		{LevelFull, []string{FieldSN, FieldMac, FieldBoardSerial, FieldDisk, FieldMemory}, nil},
+	} {
+		p := Policy{Level: c.level}
+		for _, name := range c.allowed {
+			assert.True(t, p.Allowed(name), "%v %v", c.level, name)
+		}
+		for _, name := range c.denied {
+			assert.False(t, p.Allowed(name), "%v %v", c.level, name)
+			assert.Empty(t, p.Value(name, "value"))
+		}
+	}
+	assert.False(t, Policy{Level: LevelFull}.Allowed("Unknown"))
+}
+
+func TestPolicyRedaction(t *testing.T) {
+	p, err := NewPolicy("full", `{"SN":"hash","Mac":"drop","HardwareId":"keep"}`)
+	require.NoError(t, err)
+	assert.Equal(t, Hash("PF2ABCD"), p.Value(FieldSN, "PF2ABCD"))
+	assert.Len(t, p.Value(FieldSN, "PF2ABCD"), 64)
+	assert.Empty(t, p.Value(FieldSN, ""))
+	assert.Empty(t, p.Value(FieldMac, "00:11:22:33:44:55"))
+	assert.Equal(t, "id", p.Value(FieldHardwareId, "id"))
+
+	status := make(map[string]FieldStatus)
+	for _, f := range p.Fields() {
+		status[f.Name] = f
+	}
+	assert.Equal(t, ActionHash, status[FieldSN].Action)
+	assert.False(t, status[FieldMac].Sent)
+	assert.Equal(t, LevelStandard, status[FieldProcessor].Level)
+
+	for _, redact := range []string{
+		`{"Unknown":"drop"}`,
+		`{"SN":"encrypt"}`,
+		`{"Disk":"hash"}`,
+		`["SN"]`,
+	} {
+		p, err := NewPolicy("full", redact)
+		assert.Error(t, err, redact)
+		// 配置无效时不上报可选的设备信息
+		assert.Equal(t, LevelNone, p.Level)
+	}
+
+	p, err = NewPolicy("unknown", "")
+	assert.Error(t, err)
+	assert.Equal(t, LevelNone, p.Level)
+}
+
+func TestNewUpload(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPost, "https://platform.example.com/api/v1/process/events?token=secret", nil)
+	require.NoError(t, err)
+	request.Header.Set("X-MachineID", "machine")
+	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte("a=uos;i=machine")))
+
+	u := NewUpload(KindProcessEvent, LevelMinimal, request, []byte(`{ "event": 1 }`))
+	assert.Equal(t, "https://platform.example.com/api/v1/process/events", u.URL)
+	assert.Equal(t, map[string]string{"X-MachineID": "machine", "X-Repo-Token": "a=uos;i=machine"}, u.Headers)
+	assert.JSONEq(t, `{"event":1}`, string(u.Payload))
+	assert.Equal(t, 14, u.Size)
+	assert.Len(t, u.SHA256, 64)
+
+	// 非 json 和过大的数据只记录大小和 sha256
+	u = NewUpload(KindLogFiles, LevelFull, nil, []byte("tar"))
+	assert.Nil(t, u.Payload)
+	assert.NotEmpty(t, u.SHA256)
+	large := []byte(fmt.Sprintf(`{"data":%q}`, bytes.Repeat([]byte("x"), maxAuditPayload)))
+	assert.Nil(t, NewUpload(KindStatus, LevelFull, nil, large).Payload)
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry-audit.jsonl")
+	log := NewAuditLog(path)
+	log.now = func() time.Time { return time.Unix(1700000000, 0) }
+	for i := 0; i < 3; i++ {
+		require.NoError(t, log.Record(Upload{Kind: KindHardware, TaskID: i, Level: LevelFull}))
+	}
+	uploads, err := ReadAudit(path, 2)
+	require.NoError(t, err)
+	require.Len(t, uploads, 2)
+	assert.Equal(t, 2, uploads[1].TaskID)
+	assert.Equal(t, int64(1700000000), uploads[1].Time)
+
+	uploads, err = ReadAudit(path, 0)
+	require.NoError(t, err)
+	assert.Len(t, uploads, 3)
+
+	// 没有配置记录文件时不记录
+	assert.NoError(t, NewAuditLog("").Record(Upload{}))
+	var nilLog *AuditLog
+	assert.NoError(t, nilLog.Record(Upload{}))
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// DriftItem 偏离基线的软件包
+type DriftItem struct {
+	Package          string
+	ExpectedVersion  string `json:",omitempty"` // 基线清单中的版本
+	InstalledVersion string `json:",omitempty"`
+}
+
+// BaselineDrift 已安装的软件包与当前基线软件包清单的差异
+type BaselineDrift struct {
+	Baseline string
+	Missing  []DriftItem // 核心清单中未安装的包
+	Purge    []DriftItem // 删除清单中已安装的包
+	Frozen   []DriftItem // 已安装版本与禁止升级清单不一致的包
+	Unknown  []DriftItem // 不在基线清单中,已安装版本也不来自任何仓库的包
+}
+
+// Empty 系统与基线没有差异
+func (d *BaselineDrift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Purge) == 0 && len(d.Frozen) == 0 && len(d.Unknown) == 0
+}
+
+// computeBaselineDrift 比较基线清单和已安装的包,localOnly 为已安装版本不来自任何仓库的包
+func computeBaselineDrift(core, selected, freeze, purge map[string]system.PackageInfo,
+	installed map[string]system.Version, localOnly []string) *BaselineDrift {
+	drift := &BaselineDrift{}
+	for _, name := range sortedPkgNames(core) {
+		if _, ok := installed[name]; !ok {
+			drift.Missing = append(drift.Missing, DriftItem{Package: name, ExpectedVersion: core[name].Version})
+		}
+	}
+	for _, name := range sortedPkgNames(purge) {
+		if cur, ok := installed[name]; ok {
+			drift.Purge = append(drift.Purge, DriftItem{Package: name, InstalledVersion: cur.Version})
+		}
+	}
+	for _, name := range sortedPkgNames(freeze) {
+		cur, ok := installed[name]
+		expected := freeze[name].Version
+		if ok && expected != "" && cur.Version != expected {
+			drift.Frozen = append(drift.Frozen, DriftItem{Package: name, ExpectedVersion: expected, InstalledVersion: cur.Version})
+		}
+	}
+	local := append([]string(nil), localOnly...)
+	sort.Strings(local)
+	for _, name := range local {
+		if _, ok := core[name]; ok {
+			continue
+		}
+		if _, ok := selected[name]; ok {
+			continue
+		}
+		if _, ok := freeze[name]; ok {
+			continue
+		}
+		drift.Unknown = append(drift.Unknown, DriftItem{Package: name, InstalledVersion: installed[name].Version})
+	}
+	return drift
+}
+
+func sortedPkgNames(pkgs map[string]system.PackageInfo) []string {
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BaselineDrift 检查已安装的软件包与从更新平台获取的当前基线清单的差异
+func (m *UpdatePlatformManager) BaselineDrift() (*BaselineDrift, error) {
+	if len(m.BaselinePkgs) == 0 {
+		return nil, errors.New("baseline package list is not available")
+	}
+	installed, err := system.QueryInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(installed))
+	for name := range installed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	localOnly, err := system.QueryLocalOnlyPackages(names...)
+	if err != nil {
+		return nil, err
+	}
+	drift := computeBaselineDrift(m.BaselinePkgs, m.BaselineSelectPkgs, m.BaselineFreezePkgs, m.BaselinePurgePkgs,
+		installed, localOnly)
+	drift.Baseline = m.preBaseline
+	return drift, nil
+}
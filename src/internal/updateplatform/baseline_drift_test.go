@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+func TestComputeBaselineDrift(t *testing.T) {
+	core := map[string]system.PackageInfo{
+		"dde":    {Name: "dde", Version: "6.0.1"},
+		"vim":    {Name: "vim", Version: "2:9.0"},
+		"libc6":  {Name: "libc6", Version: "2.36"},
+		"kernel": {Name: "kernel", Version: "6.6"},
+	}
+	selected := map[string]system.PackageInfo{"htop": {Name: "htop"}}
+	freeze := map[string]system.PackageInfo{
+		"kernel": {Name: "kernel", Version: "6.6"},
+		"grub":   {Name: "grub"},
+		"zsh":    {Name: "zsh", Version: "5.9"},
+	}
+	purge := map[string]system.PackageInfo{"nano": {Name: "nano"}, "emacs": {Name: "emacs"}}
+	installed := map[string]system.Version{
+		"dde":    {Version: "6.0.1"},
+		"libc6":  {Version: "2.37"},
+		"kernel": {Version: "6.1"},
+		"grub":   {Version: "2.06"},
+		"nano":   {Version: "7.2"},
+		"htop":   {Version: "3.2"},
+		"custom": {Version: "1.0"},
+	}
+	localOnly := []string{"kernel", "htop", "custom"}
+
+	drift := computeBaselineDrift(core, selected, freeze, purge, installed, localOnly)
+	want := &BaselineDrift{
+		Missing: []DriftItem{{Package: "vim", ExpectedVersion: "2:9.0"}},
+		Purge:   []DriftItem{{Package: "nano", InstalledVersion: "7.2"}},
+		Frozen:  []DriftItem{{Package: "kernel", ExpectedVersion: "6.6", InstalledVersion: "6.1"}},
+		Unknown: []DriftItem{{Package: "custom", InstalledVersion: "1.0"}},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("computeBaselineDrift() = %+v, want %+v", drift, want)
+	}
+	if drift.Empty() {
+		t.Error("drift should not be empty")
+	}
+
+	drift = computeBaselineDrift(map[string]system.PackageInfo{"dde": {Name: "dde"}}, nil, nil, nil, installed, nil)
+	if !drift.Empty() {
+		t.Errorf("unexpected drift %+v", drift)
+	}
+}
@@ -0,0 +1,225 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	debVersion "pault.ag/go/debian/version"
+)
+
+// CVEFix 修复 CVE 需要升级的二进制包及最低版本
+type CVEFix struct {
+	Package          string
+	InstalledVersion string
+	FixedVersion     string // 修复所有相关 CVE 需要的最低版本
+	CVEs             []string
+}
+
+// CVEResolution 根据 CVE 元数据计算出的修复方案
+type CVEResolution struct {
+	Fixes       []CVEFix // 需要升级的包
+	Fixed       []string // 已安装的包均已修复
+	NotAffected []string // 未安装受影响的包
+	NoFix       []string // 元数据中没有修复版本
+	Unknown     []string // 没有该 CVE 的元数据
+}
+
+// UnfixedCVE 已安装的包中尚未修复的 CVE
+type UnfixedCVE struct {
+	CveId            string
+	Package          string
+	InstalledVersion string
+	FixedVersion     string
+	Severity         string
+	Score            string
+	VulName          string
+}
+
+// cveBinary CVE 元数据中的二进制包及其修复版本
+type cveBinary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// parseCVEBinaries 解析 CVE 元数据中的二进制包,新格式中包含每个二进制包的修复版本,
+// 旧格式只有包名,此时使用 CVE 的 fixedVersion
+func parseCVEBinaries(cve CEVInfo) []cveBinary {
+	raw := strings.TrimSpace(cve.Binary)
+	if strings.HasPrefix(raw, "\"") {
+		var s string
+		if json.Unmarshal([]byte(raw), &s) == nil {
+			raw = s
+		}
+	}
+	var objs []cveBinary
+	if strings.HasPrefix(raw, "[") && json.Unmarshal([]byte(raw), &objs) == nil && len(objs) > 0 {
+		var result []cveBinary
+		for _, o := range objs {
+			if o.Name == "" {
+				continue
+			}
+			if o.Version == "" {
+				o.Version = cve.FixedVersion
+			}
+			result = append(result, o)
+		}
+		return result
+	}
+	var result []cveBinary
+	for _, name := range parseBinaryPackages(cve.Binary) {
+		result = append(result, cveBinary{Name: name, Version: cve.FixedVersion})
+	}
+	return result
+}
+
+// versionLess ver1 < ver2,无法解析时认为需要升级
+func versionLess(ver1, ver2 string) bool {
+	v1, err := debVersion.Parse(ver1)
+	if err != nil {
+		return true
+	}
+	v2, err := debVersion.Parse(ver2)
+	if err != nil {
+		return true
+	}
+	return debVersion.Compare(v1, v2) < 0
+}
+
+// indexCVEs 同一个 CVE 可能对应多个源码包,需要保留所有条目
+func indexCVEs(cves []CEVInfo) map[string][]CEVInfo {
+	index := make(map[string][]CEVInfo)
+	for _, cve := range cves {
+		index[cve.CveId] = append(index[cve.CveId], cve)
+	}
+	return index
+}
+
+func (m *UpdatePlatformManager) getCVEIndex() map[string][]CEVInfo {
+	m.cveMu.Lock()
+	defer m.cveMu.Unlock()
+	if m.cveIndex == nil {
+		// 还未同步过更新平台的数据时使用本地缓存
+		if meta := getCVEData(loadLocalCVEData()); meta != nil {
+			m.cveIndex = indexCVEs(meta.Cves)
+		}
+	}
+	return m.cveIndex
+}
+
+func (m *UpdatePlatformManager) setCVEIndex(cves []CEVInfo) {
+	m.cveMu.Lock()
+	m.cveIndex = indexCVEs(cves)
+	m.cveMu.Unlock()
+}
+
+// ResolveCVEFixes 计算修复 cveIds 需要升级的最小包集合,installed 为已安装包的版本。
+// 同一个包被多个 CVE 影响时取最高的修复版本
+func (m *UpdatePlatformManager) ResolveCVEFixes(cveIds []string, installed map[string]string) CVEResolution {
+	return resolveCVEFixes(m.getCVEIndex(), cveIds, installed)
+}
+
+func resolveCVEFixes(index map[string][]CEVInfo, cveIds []string, installed map[string]string) CVEResolution {
+	var res CVEResolution
+	fixes := make(map[string]*CVEFix)
+	seen := make(map[string]bool)
+	for _, id := range cveIds {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		entries, ok := index[id]
+		if !ok {
+			res.Unknown = append(res.Unknown, id)
+			continue
+		}
+		affected := false
+		needFix := false
+		noFix := false
+		for _, entry := range entries {
+			for _, bin := range parseCVEBinaries(entry) {
+				ver, ok := installed[bin.Name]
+				if !ok {
+					continue
+				}
+				affected = true
+				if bin.Version == "" {
+					noFix = true
+					continue
+				}
+				if !versionLess(ver, bin.Version) {
+					continue
+				}
+				needFix = true
+				fix, ok := fixes[bin.Name]
+				if !ok {
+					fix = &CVEFix{Package: bin.Name, InstalledVersion: ver, FixedVersion: bin.Version}
+					fixes[bin.Name] = fix
+				} else if versionLess(fix.FixedVersion, bin.Version) {
+					fix.FixedVersion = bin.Version
+				}
+				if len(fix.CVEs) == 0 || fix.CVEs[len(fix.CVEs)-1] != id {
+					fix.CVEs = append(fix.CVEs, id)
+				}
+			}
+		}
+		switch {
+		case !affected:
+			res.NotAffected = append(res.NotAffected, id)
+		case noFix && !needFix:
+			res.NoFix = append(res.NoFix, id)
+		case !needFix:
+			res.Fixed = append(res.Fixed, id)
+		}
+	}
+	for _, fix := range fixes {
+		res.Fixes = append(res.Fixes, *fix)
+	}
+	sort.Slice(res.Fixes, func(i, j int) bool {
+		return res.Fixes[i].Package < res.Fixes[j].Package
+	})
+	return res
+}
+
+// UnfixedCVEs 列出已安装的包中尚未修复的 CVE
+func (m *UpdatePlatformManager) UnfixedCVEs(installed map[string]string) []UnfixedCVE {
+	return unfixedCVEs(m.getCVEIndex(), installed)
+}
+
+func unfixedCVEs(index map[string][]CEVInfo, installed map[string]string) []UnfixedCVE {
+	result := []UnfixedCVE{}
+	for id, entries := range index {
+		for _, entry := range entries {
+			for _, bin := range parseCVEBinaries(entry) {
+				ver, ok := installed[bin.Name]
+				if !ok {
+					continue
+				}
+				if bin.Version != "" && !versionLess(ver, bin.Version) {
+					continue
+				}
+				result = append(result, UnfixedCVE{
+					CveId:            id,
+					Package:          bin.Name,
+					InstalledVersion: ver,
+					FixedVersion:     bin.Version,
+					Severity:         entry.VulLevel,
+					Score:            entry.Score,
+					VulName:          entry.VulName,
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CveId != result[j].CveId {
+			return result[i].CveId < result[j].CveId
+		}
+		return result[i].Package < result[j].Package
+	})
+	return result
+}
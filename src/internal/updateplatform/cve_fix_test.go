@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testCVEIndex() map[string][]CEVInfo {
+	return indexCVEs([]CEVInfo{
+		{CveId: "CVE-2024-0727", Source: "openssl", FixedVersion: "3.0.11-1deepin3", VulLevel: "high", Score: "7.5",
+			Binary: `[{"name":"libssl3","version":"3.0.11-1deepin3"},{"name":"openssl","version":"3.0.11-1deepin3"}]`},
+		{CveId: "CVE-2023-6237", Source: "openssl", FixedVersion: "3.0.11-1deepin4", VulLevel: "medium",
+			Binary: "['libssl3', 'openssl']"},
+		{CveId: "CVE-2023-6237", Source: "openssl1.1", FixedVersion: "1.1.1n-1deepin2", VulLevel: "medium",
+			Binary: "['libssl1.1']"},
+		{CveId: "CVE-2021-3677", Source: "postgresql-13", FixedVersion: "13.4-1", Binary: "['libpq5']"},
+		{CveId: "CVE-2025-0001", Source: "zlib", FixedVersion: "", Binary: "['zlib1g']"},
+	})
+}
+
+func TestResolveCVEFixes(t *testing.T) {
+	installed := map[string]string{
+		"libssl3":   "3.0.11-1deepin2",
+		"openssl":   "3.0.11-1deepin2",
+		"libssl1.1": "1.1.1n-1deepin2",
+		"zlib1g":    "1:1.2.13-1",
+	}
+	res := resolveCVEFixes(testCVEIndex(), []string{"cve-2024-0727", "CVE-2023-6237", "CVE-2024-0727",
+		"CVE-2021-3677", "CVE-2025-0001", "CVE-1999-0001"}, installed)
+
+	want := []CVEFix{
+		{Package: "libssl3", InstalledVersion: "3.0.11-1deepin2", FixedVersion: "3.0.11-1deepin4", CVEs: []string{"CVE-2024-0727", "CVE-2023-6237"}},
+		{Package: "openssl", InstalledVersion: "3.0.11-1deepin2", FixedVersion: "3.0.11-1deepin4", CVEs: []string{"CVE-2024-0727", "CVE-2023-6237"}},
+	}
+	if !reflect.DeepEqual(res.Fixes, want) {
+		t.Fatalf("unexpected fixes: %+v", res.Fixes)
+	}
+	if !reflect.DeepEqual(res.NotAffected, []string{"CVE-2021-3677"}) {
+		t.Fatalf("unexpected not affected: %v", res.NotAffected)
+	}
+	if !reflect.DeepEqual(res.NoFix, []string{"CVE-2025-0001"}) {
+		t.Fatalf("unexpected no fix: %v", res.NoFix)
+	}
+	if !reflect.DeepEqual(res.Unknown, []string{"CVE-1999-0001"}) {
+		t.Fatalf("unexpected unknown: %v", res.Unknown)
+	}
+
+	installed["libssl3"] = "3.0.11-1deepin4"
+	installed["openssl"] = "3.0.11-1deepin4"
+	res = resolveCVEFixes(testCVEIndex(), []string{"CVE-2023-6237"}, installed)
+	if len(res.Fixes) != 0 || !reflect.DeepEqual(res.Fixed, []string{"CVE-2023-6237"}) {
+		t.Fatalf("expected CVE-2023-6237 fixed, got %+v", res)
+	}
+}
+
+func TestUnfixedCVEs(t *testing.T) {
+	installed := map[string]string{
+		"libssl3": "3.0.11-1deepin3",
+		"zlib1g":  "1:1.2.13-1",
+		"libpq5":  "13.4-1",
+	}
+	unfixed := unfixedCVEs(testCVEIndex(), installed)
+	want := []UnfixedCVE{
+		{CveId: "CVE-2023-6237", Package: "libssl3", InstalledVersion: "3.0.11-1deepin3", FixedVersion: "3.0.11-1deepin4", Severity: "medium"},
+		{CveId: "CVE-2025-0001", Package: "zlib1g", InstalledVersion: "1:1.2.13-1"},
+	}
+	if !reflect.DeepEqual(unfixed, want) {
+		t.Fatalf("unexpected unfixed cves: %+v", unfixed)
+	}
+}
@@ -5,100 +5,89 @@
 package updateplatform
 
 import (
-	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"errors"
-
-	"math/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
 	"strings"
-)
 
-/**
- * 对称加密
- */
-
-const (
-	BlockSize = 32
-	randomLen = 16 // 随机值长度
+	Cfg "github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
 )
 
 /**
- * 对要发送的消息进行AES-CBC加密
+ * 对称加密
  */
-var encodingAesKey = "DflXyFwTmaoGmbDkVj8uD62XGb01pkJn"
 
-func EncryptMsg(data []byte) ([]byte, error) {
-	// 获得16位随机字符串，填充到明文之前
-	replyMsgBytes, err := GetRandomBytes(randomLen)
-	if err != nil {
-		return nil, err
+// NewPayloadCipher 按配置创建上报数据的加密方式,keyVersion 为与平台协商的密钥版本,为空时使用当前密钥。
+// 每次调用都会重新读取密钥文件,轮换密钥不需要重启服务。
+// 只有配置为兼容模式或未配置时使用兼容模式;配置了 aes-256-gcm 但密钥不可用时返回错误,不降级为固定密钥的兼容模式
+func NewPayloadCipher(c *Cfg.Config, keyVersion string) (envelope.Cipher, error) {
+	if c == nil || c.PayloadEncryption == "" || c.PayloadEncryption == envelope.SchemeLegacy {
+		return envelope.Legacy(), nil
 	}
-	replyMsgBytes = append(replyMsgBytes, data...)
-	// 取秘钥的前16位作为AES-CBC加密的向量iv
-	iv := []byte(Substr(encodingAesKey, 0, 16))
-
-	// 根据 key 生成密文
-	block, err := aes.NewCipher([]byte(encodingAesKey))
+	keys, err := envelope.LoadKeySet(envelope.DefaultKeyFile)
 	if err != nil {
-		logger.Warningf("[encrypt] aes-cbc encrypt data failed,error:%v", err)
-		return nil, err
+		return nil, fmt.Errorf("payload encryption %v unavailable: %v", c.PayloadEncryption, err)
+	}
+	if _, ok := keys.Lookup(keyVersion); !ok {
+		logger.Warningf("negotiated key version %v not found, use current key", keyVersion)
+		keyVersion = ""
 	}
-	encodeBytes := replyMsgBytes
-	encodeBytes = PKCS7Encode(encodeBytes, BlockSize)
-	blockMode := cipher.NewCBCEncrypter(block, iv)
-	crypted := make([]byte, len(encodeBytes))
-	if len(crypted)%block.BlockSize() != 0 {
-		return nil, errors.New("encrypt failed, input not full blocks")
+	cipher, err := envelope.New(c.PayloadEncryption, keys, keyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("payload encryption %v unavailable: %v", c.PayloadEncryption, err)
 	}
-	blockMode.CryptBlocks(crypted, encodeBytes)
-	return crypted, nil
+	return cipher, nil
 }
 
-// PKCS7Encode 对需要加密的明文进行填充补位 * @param text 需要进行填充补位操作的明文 * @return 补齐明文字符串
-func PKCS7Encode(text []byte, blockSize int) []byte {
-	textLen := len(text)
-	padding := blockSize - (textLen % blockSize)
-	if padding == 0 {
-		padding = blockSize
+// payloadKeyVersions 返回本机持有的密钥版本,用于与平台协商
+func payloadKeyVersions(c *Cfg.Config) []string {
+	if c == nil || c.PayloadEncryption != envelope.SchemeGCM {
+		return nil
 	}
-
-	paddingByte := bytes.Repeat([]byte(string(rune(padding))), padding)
-
-	return append(text, paddingByte...)
+	keys, err := envelope.LoadKeySet(envelope.DefaultKeyFile)
+	if err != nil {
+		return nil
+	}
+	return keys.Versions()
 }
 
-// GetRandomBytes 根据需要长度,生成随机字符
-func GetRandomBytes(length uint32) ([]byte, error) {
-	res := make([]byte, length)
-	_, err := rand.Read(res)
+// NewPayloadRequest 加密 data 并以 base64 编码作为请求体,请求头中携带加密方式和密钥版本
+func NewPayloadRequest(cipher envelope.Cipher, method, url string, data []byte) (*http.Request, error) {
+	encryptMsg, err := cipher.Seal(data)
 	if err != nil {
-		logger.Warning(err)
+		logger.Warningf("[encrypt] %v encrypt data failed,error:%v", cipher.Scheme(), err)
 		return nil, err
 	}
-	return res, nil
-}
-
-// Substr 截取字符串 start 起点下标 length 需要截取的长度
-func Substr(str string, start int, length int) string {
-	if start < 0 {
-		start = 0
+	request, err := http.NewRequest(method, url, strings.NewReader(base64.StdEncoding.EncodeToString(encryptMsg)))
+	if err != nil {
+		return nil, err
 	}
-	if length < 0 {
-		length = 0
+	request.Header.Set(envelope.HeaderScheme, cipher.Scheme())
+	if cipher.KeyVersion() != "" {
+		request.Header.Set(envelope.HeaderKeyVersion, cipher.KeyVersion())
 	}
+	return request, nil
+}
 
-	strSlice := strings.Split(str, "")
-	strSliceLen := len(strSlice)
-
-	end := start + length
+// payloadCipher 返回当前上报使用的加密方式,密钥不可用时返回错误,上报数据留在上报队列中重试
+func (m *UpdatePlatformManager) payloadCipher() (envelope.Cipher, error) {
+	m.payloadMu.Lock()
+	keyVersion := m.payloadKeyVersion
+	m.payloadMu.Unlock()
+	return NewPayloadCipher(m.config, keyVersion)
+}
 
-	if end > strSliceLen {
-		end = strSliceLen
+// setPayloadKeyVersion 记录平台在响应中选择的密钥版本
+func (m *UpdatePlatformManager) setPayloadKeyVersion(response *http.Response) {
+	keyVersion := response.Header.Get(envelope.HeaderKeyVersion)
+	if keyVersion == "" {
+		return
 	}
-	if start > strSliceLen {
-		start = strSliceLen
+	m.payloadMu.Lock()
+	defer m.payloadMu.Unlock()
+	if m.payloadKeyVersion != keyVersion {
+		logger.Info("update platform selected payload key version:", keyVersion)
+		m.payloadKeyVersion = keyVersion
 	}
-
-	return strings.Join(strSlice[start:end], "")
 }
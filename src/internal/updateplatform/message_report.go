@@ -34,8 +34,12 @@ import (
 	"github.com/linuxdeepin/go-lib/strv"
 	"github.com/linuxdeepin/go-lib/utils"
 	Cfg "github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformhttp"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/ratelimit"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/scriptsign"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
 )
 
 var logger = log.NewLogger("lastore/messageReport")
@@ -76,16 +80,19 @@ const (
 )
 
 type ShellCheck struct {
-	Name  string `json:"name"`  //检查脚本的名字
-	Shell string `json:"shell"` //检查脚本的内容
+	Name      string `json:"name"`      //检查脚本的名字
+	Shell     string `json:"shell"`     //检查脚本的内容
+	Signature string `json:"signature"` //脚本内容的 ed25519 签名,base64 编码
+	KeyID     string `json:"keyId"`     //签名使用的公钥
 }
 
 // 发送给更新平台的状态信息
 type StatusMessage struct {
-	Type           string `json:"type"`           //消息类型，info,warning,error
-	UpdateType     string `json:"updateType"`     //system.UpdateType类型
-	JobDescription string `json:"jobDescription"` //job.Description
-	Detail         string `json:"detail"`         //消息详情
+	Type           string         `json:"type"`            //消息类型，info,warning,error
+	UpdateType     string         `json:"updateType"`      //system.UpdateType类型
+	JobDescription string         `json:"jobDescription"`  //job.Description
+	Detail         string         `json:"detail"`          //消息详情
+	Drift          *BaselineDrift `json:"drift,omitempty"` //基线偏离报告,开启 UploadBaselineDrift 时附带
 }
 
 type UpdatePlatformManager struct {
@@ -116,10 +123,21 @@ type UpdatePlatformManager struct {
 	FreezePkgs        map[string]system.PackageInfo // 禁止升级包清单
 	PurgePkgs         map[string]system.PackageInfo // 删除软件包清单
 
+	BaselineSelectPkgs map[string]system.PackageInfo // 当前版本的可选软件包清单
+	BaselineFreezePkgs map[string]system.PackageInfo // 当前版本的禁止升级包清单
+	BaselinePurgePkgs  map[string]system.PackageInfo // 当前版本的删除软件包清单
+
+	driftMu   sync.Mutex
+	drift     *BaselineDrift // 本次检查或更新中上报状态时附带的基线偏离报告,每次检查或更新只计算一次
+	driftErr  error
+	driftDone bool
+
 	repoInfos        []repoInfo      // 从更新平台获取的仓库信息
 	SystemUpdateLogs []UpdateLogMeta // 更新注记
 	cveDataTime      string
-	cvePkgs          map[string][]string // cve信息 pkgname:[cveid...]
+	cvePkgs          map[string][]string  // cve信息 pkgname:[cveid...]
+	cveIndex         map[string][]CEVInfo // cve信息 cveid:[所有源码包的条目]
+	cveMu            sync.Mutex
 
 	packagesPrefixList []string // 根据仓库
 
@@ -135,6 +153,11 @@ type UpdatePlatformManager struct {
 
 	jobPostMsgMap     map[string]*UpgradePostMsg
 	jobPostMsgMapMu   sync.Mutex
+	outbox            *Outbox                 // 所有上报数据的持久化队列
+	transport         *platformhttp.Transport // 访问更新平台的公共传输层
+	responseCache     *ResponseCache          // 平台响应缓存,用于条件请求和离线回退
+	payloadMu         sync.Mutex
+	payloadKeyVersion string // 平台选择的上报数据加密密钥版本
 	TimerHasChanged   bool
 	inhibitAutoQuit   func()
 	UnInhibitAutoQuit func()
@@ -153,6 +176,10 @@ type platformCacheContent struct {
 	PostDownloadCheck []ShellCheck
 	PreBackupCheck    []ShellCheck
 	PostBackupCheck   []ShellCheck
+
+	BaselineSelectPkgs map[string]system.PackageInfo
+	BaselineFreezePkgs map[string]system.PackageInfo
+	BaselinePurgePkgs  map[string]system.PackageInfo
 }
 
 // 需要注意cache文件的同步时机，所有数据应该不会从os-version和os-baseline获取
@@ -189,7 +216,7 @@ func NewUpdatePlatformManager(c *Cfg.Config, updateToken bool) *UpdatePlatformMa
 	}
 	var token string
 	if updateToken {
-		token = UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper) // update source时生成即可,初始化时由于授权服务返回SN非常慢(超过25s),因此不在初始化时生成
+		token = UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper, TelemetryPolicy(c)) // update source时生成即可,初始化时由于授权服务返回SN非常慢(超过25s),因此不在初始化时生成
 	}
 	cache := platformCacheContent{}
 	if strings.TrimSpace(c.OnlineCache) != "" {
@@ -204,7 +231,7 @@ func NewUpdatePlatformManager(c *Cfg.Config, updateToken bool) *UpdatePlatformMa
 		systemType = getCurrentSystemType()
 	}
 
-	return &UpdatePlatformManager{
+	m := &UpdatePlatformManager{
 		config:                            c,
 		allowPostSystemUpgradeMessageType: system.SystemUpdate,
 		preBuild:                          genPreBuild(),
@@ -223,6 +250,9 @@ func NewUpdatePlatformManager(c *Cfg.Config, updateToken bool) *UpdatePlatformMa
 		TargetCorePkgs:                    cache.CoreListPkgs,
 		BaselinePkgs:                      cache.BaselinePkgs,
 		SelectPkgs:                        cache.SelectPkgs,
+		BaselineSelectPkgs:                cache.BaselineSelectPkgs,
+		BaselineFreezePkgs:                cache.BaselineFreezePkgs,
+		BaselinePurgePkgs:                 cache.BaselinePurgePkgs,
 		PreUpgradeCheck:                   cache.PreUpgradeCheck,
 		MidUpgradeCheck:                   cache.MidUpgradeCheck,
 		PostUpgradeCheck:                  cache.PostUpgradeCheck,
@@ -233,6 +263,13 @@ func NewUpdatePlatformManager(c *Cfg.Config, updateToken bool) *UpdatePlatformMa
 		PreBackupCheck:                    cache.PreBackupCheck,
 		PostBackupCheck:                   cache.PostBackupCheck,
 	}
+	m.transport = NewPlatformTransport(c)
+	m.responseCache = NewResponseCache(responseCacheDir, c.PlatformCacheMaxStale)
+	if m.requestUrl == "" && m.transport.Endpoints() != nil {
+		m.requestUrl = m.transport.Endpoints().Home()
+	}
+	m.outbox = NewOutbox(outboxDir, defaultOutboxLimits, m.sendOutboxItem)
+	return m
 }
 
 func (m *UpdatePlatformManager) GetCVEUpdateLogs(pkgs []string) map[string]CEVInfo {
@@ -621,43 +658,40 @@ const secret = "DflXyFwTmaoGmbDkVj8uD62XGb01pkJn"
 
 func (m *UpdatePlatformManager) getToken() string {
 	if len(m.Token) == 0 {
-		m.Token = UpdateTokenConfigFile(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper)
+		m.Token = UpdateTokenConfigFile(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper, TelemetryPolicy(m.config))
 	}
 	return m.Token
 }
 
 func (m *UpdatePlatformManager) genVersionResponse() (*http.Response, error) {
 	policyUrl := m.requestUrl + Urls[GetVersion].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	request, err := http.NewRequest(Urls[GetVersion].method, policyUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", GetVersion.string(), err.Error())
 	}
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
 	request.Header.Set("X-Packages", base64.RawStdEncoding.EncodeToString([]byte(getClientPackageInfo(m.config.ClientPackageName))))
-	return client.Do(request)
+	if versions := payloadKeyVersions(m.config); len(versions) != 0 {
+		request.Header.Set(envelope.HeaderKeyVersions, strings.Join(versions, ","))
+	}
+	return m.doCachedRequest(client, GetVersion, request)
 }
 
 func (m *UpdatePlatformManager) genThrottlingResponse() (*http.Response, error) {
 	throttlingUrl := m.requestUrl + Urls[GetThrottling].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	request, err := http.NewRequest(Urls[GetThrottling].method, throttlingUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", GetThrottling.string(), err.Error())
 	}
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
-	return client.Do(request)
+	return m.doCachedRequest(client, GetThrottling, request)
 }
 
 func (m *UpdatePlatformManager) genTargetPkgListsResponse() (*http.Response, error) {
 	policyUrl := m.requestUrl + Urls[GetTargetPkgLists].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	values := url.Values{}
 	values.Add("baseline", m.targetBaseline)
 	policyUrl = policyUrl + "?" + values.Encode()
@@ -667,14 +701,12 @@ func (m *UpdatePlatformManager) genTargetPkgListsResponse() (*http.Response, err
 		return nil, fmt.Errorf("%v new request failed: %v ", GetTargetPkgLists.string(), err.Error())
 	}
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
-	return client.Do(request)
+	return m.doCachedRequest(client, GetTargetPkgLists, request)
 }
 
 func (m *UpdatePlatformManager) genCurrentPkgListsResponse() (*http.Response, error) {
 	policyUrl := m.requestUrl + Urls[GetCurrentPkgLists].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	values := url.Values{}
 	values.Add("baseline", m.preBaseline)
 	policyUrl = policyUrl + "?" + values.Encode()
@@ -683,14 +715,12 @@ func (m *UpdatePlatformManager) genCurrentPkgListsResponse() (*http.Response, er
 		return nil, fmt.Errorf("%v new request failed: %v ", GetCurrentPkgLists.string(), err.Error())
 	}
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
-	return client.Do(request)
+	return m.doCachedRequest(client, GetCurrentPkgLists, request)
 }
 
 func (m *UpdatePlatformManager) genCVEInfoResponse(syncTime string) (*http.Response, error) {
 	policyUrl := m.requestUrl + Urls[GetPkgCVEs].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	values := url.Values{}
 	values.Add("synctime", syncTime)
 	policyUrl = policyUrl + "?" + values.Encode()
@@ -699,14 +729,12 @@ func (m *UpdatePlatformManager) genCVEInfoResponse(syncTime string) (*http.Respo
 		return nil, fmt.Errorf("%v new request failed: %v ", GetPkgCVEs.string(), err.Error())
 	}
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
-	return client.Do(request)
+	return m.doCachedRequest(client, GetPkgCVEs, request)
 }
 
 func (m *UpdatePlatformManager) genUpdateLogResponse() (*http.Response, error) {
 	policyUrl := m.requestUrl + Urls[GetUpdateLog].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	values := url.Values{}
 	values.Add("baseline", m.targetBaseline)
 	values.Add("isUnstable", fmt.Sprintf("%d", isUnstable()))
@@ -716,17 +744,16 @@ func (m *UpdatePlatformManager) genUpdateLogResponse() (*http.Response, error) {
 		return nil, fmt.Errorf("%v new request failed: %v ", GetUpdateLog.string(), err.Error())
 	}
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
-	return client.Do(request)
+	return m.doCachedRequest(client, GetUpdateLog, request)
 }
 
 // genPostProcessResponse 生成数据，发送请求，并返回response.
 // buf: 数据输入,io.Reader.
 // filePath: 生成的xz压缩的中间文件.
-func (m *UpdatePlatformManager) genPostProcessResponse(buf io.Reader, filePath string) (*http.Response, error) {
+// record: 发送前记录上报的数据.
+func (m *UpdatePlatformManager) genPostProcessResponse(taskID int, buf io.Reader, filePath string, record func(request *http.Request)) (*http.Response, error) {
 	policyUrl := m.requestUrl + Urls[PostProcess].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.taskHTTPClient(40*time.Second, taskID)
 	if log.LevelDebug != logger.GetLogLevel() {
 		defer os.RemoveAll(filePath)
 	}
@@ -761,15 +788,16 @@ func (m *UpdatePlatformManager) genPostProcessResponse(buf io.Reader, filePath s
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", PostProcess.string(), err.Error())
 	}
-	hardwareId := GetHardwareId(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper)
-
-	request.Header.Set("X-MachineID", hardwareId)
+	if machineID := m.machineID(); machineID != "" {
+		request.Header.Set("X-MachineID", machineID)
+	}
 	request.Header.Set("X-CurrentBaseline", m.preBaseline)
 	request.Header.Set("X-Baseline", m.targetBaseline)
 	request.Header.Set("X-Time", xTime)
 	request.Header.Set("X-Sign", sign)
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
 	logger.Debug("genPostProcessResponse:", request.Header)
+	record(request)
 	return client.Do(request)
 }
 
@@ -790,14 +818,12 @@ func (m *UpdatePlatformManager) genIpfsConfigResponse() (*http.Response, error)
 	q := uri.Query()
 	q.Set("id", m.IPFSConfig.ID)
 	uri.RawQuery = q.Encode()
-	client := &http.Client{
-		Timeout: 40 * time.Second,
-	}
+	client := m.httpClient(40 * time.Second)
 	request, err := http.NewRequest(Urls[GetIPFSConfig].method, uri.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %w", GetIPFSConfig.string(), err)
 	}
-	return client.Do(request)
+	return m.doCachedRequest(client, GetIPFSConfig, request)
 }
 
 // getResponseData 解析 HTTP 响应数据，提取响应体中的 JSON 数据
@@ -982,6 +1008,7 @@ func (m *UpdatePlatformManager) getUpdateMessageWithRetry(retryCount int) (*upda
 	if err != nil {
 		return nil, fmt.Errorf("failed get version data %v", err)
 	}
+	m.setPayloadKeyVersion(response)
 	data, _, code, err := getResponseData(response, GetVersion)
 	if err != nil {
 		if code == 416 {
@@ -1285,6 +1312,26 @@ func (m *UpdatePlatformManager) updateCurrentPreInstalledPkgMetaSync() error {
 			}
 		}
 	}
+	// 可选、禁止升级和删除清单用于基线偏离检查,没有对应架构的版本时只记录包名
+	for _, list := range []struct {
+		pkgs   []system.PlatformPackageInfo
+		result map[string]system.PackageInfo
+	}{
+		{pkgs.Packages.Select, m.BaselineSelectPkgs},
+		{pkgs.Packages.Freeze, m.BaselineFreezePkgs},
+		{pkgs.Packages.Purge, m.BaselinePurgePkgs},
+	} {
+		for _, pkg := range list.pkgs {
+			info := system.PackageInfo{Name: pkg.Name, Need: pkg.Need}
+			for _, v := range pkg.AllArchVersion {
+				if v.Arch == m.arch {
+					info.Version = v.Version
+					break
+				}
+			}
+			list.result[pkg.Name] = info
+		}
+	}
 
 	return nil
 }
@@ -1387,7 +1434,15 @@ func (m *UpdatePlatformManager) updateCVEMetaDataSync() error {
 	localData := loadLocalCVEData()
 	localCVE := getCVEData(localData)
 	response, err := m.genCVEInfoResponse(localCVE.DateTime)
+	if err == nil && response.StatusCode >= http.StatusInternalServerError {
+		_ = response.Body.Close()
+		err = fmt.Errorf("response code=%d", response.StatusCode)
+	}
 	if err != nil {
+		// CVE 数据是增量同步的,请求地址每次都不同,无法访问平台时继续使用本地数据
+		if m.useLocalCVEData(localCVE, err) {
+			return nil
+		}
 		return fmt.Errorf("failed get cve meta info %v", err)
 	}
 	data, _, _, err := getResponseData(response, GetPkgCVEs)
@@ -1400,16 +1455,37 @@ func (m *UpdatePlatformManager) updateCVEMetaDataSync() error {
 	}
 	cves.Cves = append(cves.Cves, localCVE.Cves...)
 	saveCEVData(*cves)
+	m.loadCVEs(cves)
+	return nil
+}
+
+func (m *UpdatePlatformManager) useLocalCVEData(localCVE *CVEMeta, cause error) bool {
+	if m.responseCache == nil || localCVE.DateTime == "" {
+		return false
+	}
+	info, err := os.Stat(cveLocalInfo)
+	if err != nil {
+		return false
+	}
+	if !m.responseCache.useLocal(responseCacheNames[GetPkgCVEs], cveLocalInfo, info.ModTime(), cause) {
+		return false
+	}
+	m.loadCVEs(localCVE)
+	return true
+}
+
+// loadCVEs 使用 cves 重建 CVE 索引
+func (m *UpdatePlatformManager) loadCVEs(cves *CVEMeta) {
 	// 重置CVEs
 	CVEs = make(map[string]CEVInfo)
 	m.cveDataTime = cves.DateTime
+	m.setCVEIndex(cves.Cves)
 	for _, cve := range cves.Cves {
 		CVEs[cve.CveId] = cve
 		for _, binary := range parseBinaryPackages(cve.Binary) {
 			m.cvePkgs[binary] = append(m.cvePkgs[binary], cve.CveId)
 		}
 	}
-	return nil
 }
 
 func (m *UpdatePlatformManager) GetSystemMeta() map[string]system.PackageInfo {
@@ -1563,9 +1639,7 @@ func getAptAuthConf(domain string) (user, password string) {
 // 校验InRelease文件，如果平台和本地不同，则删除
 func (m *UpdatePlatformManager) checkInReleaseFromPlatform() {
 	// 更新获取InRelease文件
-	client := &http.Client{
-		Timeout: 4 * time.Second,
-	}
+	client := m.httpClient(4 * time.Second)
 	// 用于Debug时查看重定向地址
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		logger.Info("CheckRedirect  :", req.Response.Header)
@@ -1683,6 +1757,10 @@ func (m *UpdatePlatformManager) UpdateAllPlatformDataSync() error {
 	m.SelectPkgs = make(map[string]system.PackageInfo)
 	m.FreezePkgs = make(map[string]system.PackageInfo)
 	m.PurgePkgs = make(map[string]system.PackageInfo)
+	m.BaselineSelectPkgs = make(map[string]system.PackageInfo)
+	m.BaselineFreezePkgs = make(map[string]system.PackageInfo)
+	m.BaselinePurgePkgs = make(map[string]system.PackageInfo)
+	m.ResetBaselineDrift()
 	if (m.config.PlatformDisabled & Cfg.DisabledUpdateLog) == 0 {
 		syncFuncList = append(syncFuncList, m.updateLogMetaSync) // 日志
 	}
@@ -1737,37 +1815,92 @@ func (m *UpdatePlatformManager) PostProcessEventMessage(body ProcessEvent) {
 		logger.Warning("JSON marshaling error:", err)
 		return
 	}
-	policyUrl := m.requestUrl + Urls[PostProcessEvent].path
-	client := &http.Client{
-		Timeout: 40 * time.Second,
+	m.postByOutbox(OutboxProcessEvent, body.TaskID, "", jsonData, nil)
+}
+
+// postByOutbox 将数据写入上报队列,由 lastore-daemon 在后台发送和重试
+func (m *UpdatePlatformManager) postByOutbox(kind OutboxKind, taskID int, key string, payload []byte, files []string) {
+	err := m.outbox.Enqueue(kind, taskID, key, payload, files)
+	if err != nil {
+		logger.Warningf("enqueue %v failed: %v", kind, err)
+	}
+}
+
+// sendOutboxItem 发送上报队列中的一条记录
+func (m *UpdatePlatformManager) sendOutboxItem(item *OutboxItem) error {
+	switch item.Kind {
+	case OutboxProcessEvent:
+		return m.sendProcessEvent(item.TaskID, item.Payload)
+	case OutboxStatusMsg:
+		return m.sendStatusMessage(item.TaskID, item.Payload)
+	case OutboxLogFiles:
+		return m.sendUpdateLogFiles(item.TaskID, item.Files)
+	case OutboxUpgradeResult:
+		return m.sendSystemUpgradeMessage(item.TaskID, item.Payload)
+	default:
+		return dropOutboxItem(fmt.Errorf("unknown outbox item kind %v", item.Kind))
+	}
+}
+
+// checkPostResponse 检查上报的响应,服务端拒绝的数据重试也不会成功,返回 dropOutboxItem 标记的错误
+func checkPostResponse(response *http.Response, reqType requestType) error {
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	_, _, _, err := getResponseData(response, reqType)
+	if err == nil {
+		return nil
+	}
+	code := response.StatusCode
+	if code == http.StatusOK || (code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests) {
+		return dropOutboxItem(err)
 	}
+	return err
+}
+
+func (m *UpdatePlatformManager) sendProcessEvent(taskID int, jsonData []byte) error {
+	policyUrl := m.requestUrl + Urls[PostProcessEvent].path
+	client := m.taskHTTPClient(40*time.Second, taskID)
 
 	logger.Debugf("upgrade post process event msg is %v", string(jsonData))
-	encryptMsg, err := EncryptMsg(jsonData)
+	cipher, err := m.payloadCipher()
 	if err != nil {
-		logger.Warning(err)
-		return
+		return err
 	}
-	request, err := http.NewRequest(Urls[PostProcessEvent].method, policyUrl, strings.NewReader(base64.StdEncoding.EncodeToString(encryptMsg)))
+	request, err := NewPayloadRequest(cipher, Urls[PostProcessEvent].method, policyUrl, jsonData)
 	if err != nil {
-		logger.Warningf("%v new request failed: %v ", PostProcessEvent.string(), err.Error())
-		return
+		return fmt.Errorf("%v new request failed: %v ", PostProcessEvent.string(), err.Error())
+	}
+	if machineID := m.machineID(); machineID != "" {
+		request.Header.Set("X-MachineID", machineID)
 	}
-	hardwareId := GetHardwareId(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper)
-
-	request.Header.Set("X-MachineID", hardwareId)
 	request.Header.Set("X-CurrentBaseline", m.preBaseline)
 	request.Header.Set("X-Baseline", m.targetBaseline)
 	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
+	m.recordUpload(telemetry.KindProcessEvent, taskID, request, jsonData, nil)
 	response, err := client.Do(request)
 	if err != nil {
-		logger.Warningf("post process event msg failed:%v", err)
-		return
+		return fmt.Errorf("post process event msg failed:%v", err)
 	}
-	_, _, _, err = getResponseData(response, PostProcessEvent)
-	if err != nil {
-		logger.Warningf("get post process event msg response failed:%v", err)
+	return checkPostResponse(response, PostProcessEvent)
+}
+
+// ResetBaselineDrift 开始检查或更新、安装结束时清除缓存的基线偏离报告,下一次上报状态时重新计算
+func (m *UpdatePlatformManager) ResetBaselineDrift() {
+	m.driftMu.Lock()
+	m.drift, m.driftErr, m.driftDone = nil, nil, false
+	m.driftMu.Unlock()
+}
+
+// cachedBaselineDrift 返回缓存的基线偏离报告,计算需要查询所有已安装的包,每次检查或更新只计算一次
+func (m *UpdatePlatformManager) cachedBaselineDrift() (*BaselineDrift, error) {
+	m.driftMu.Lock()
+	defer m.driftMu.Unlock()
+	if !m.driftDone {
+		m.drift, m.driftErr = m.BaselineDrift()
+		m.driftDone = true
 	}
+	return m.drift, m.driftErr
 }
 
 // PostStatusMessage 将检查\下载\安装过程中所有异常状态和每个阶段成功的正常状态上报
@@ -1785,6 +1918,15 @@ func (m *UpdatePlatformManager) PostStatusMessage(message StatusMessage, forceUp
 		return
 	}
 
+	if m.config.UploadBaselineDrift && message.Drift == nil {
+		drift, err := m.cachedBaselineDrift()
+		if err != nil {
+			logger.Warning("get baseline drift failed:", err)
+		} else {
+			message.Drift = drift
+		}
+	}
+
 	msg, err := json.Marshal(message)
 	if err != nil {
 		logger.Warningf("marshal status message failed:%v", err)
@@ -1792,20 +1934,19 @@ func (m *UpdatePlatformManager) PostStatusMessage(message StatusMessage, forceUp
 	}
 
 	logger.Debugf("post status msg:%s", msg)
+	m.postByOutbox(OutboxStatusMsg, m.taskID, "", msg, nil)
+}
 
-	buf := bytes.NewBufferString(string(msg))
-	filePath := fmt.Sprintf("/tmp/%s_%s.xz", "update", time.Now().Format("20231019102233444"))
-	response, err := m.genPostProcessResponse(buf, filePath)
+func (m *UpdatePlatformManager) sendStatusMessage(taskID int, msg []byte) error {
+	buf := bytes.NewBuffer(msg)
+	filePath := fmt.Sprintf("/tmp/%s_%s.xz", "update", utils.GenUuid())
+	response, err := m.genPostProcessResponse(taskID, buf, filePath, func(request *http.Request) {
+		m.recordUpload(telemetry.KindStatus, taskID, request, msg, nil)
+	})
 	if err != nil {
-		logger.Warningf("post status message failed:%v", err)
-		return
+		return fmt.Errorf("post status message failed:%v", err)
 	}
-	data, _, _, err := getResponseData(response, PostProcess)
-	if err != nil {
-		logger.Warningf("get post status response failed:%v", err)
-		return
-	}
-	logger.Info(string(data))
+	return checkPostResponse(response, PostProcess)
 }
 
 func tarFiles(files []string, outFile string) error {
@@ -1871,23 +2012,25 @@ func (m *UpdatePlatformManager) PostUpdateLogFiles(files []string) {
 		logger.Warningf("tar log files failed:%v", err)
 		return
 	}
-	tarFile, err := os.Open(outFilename)
-	if err != nil {
-		logger.Warning("open file failed:", err)
-		return
+	m.postByOutbox(OutboxLogFiles, m.taskID, filepath.Base(outFilename), nil, []string{outFilename})
+}
+
+func (m *UpdatePlatformManager) sendUpdateLogFiles(taskID int, files []string) error {
+	if len(files) == 0 {
+		return dropOutboxItem(errors.New("log files are missing"))
 	}
-	defer tarFile.Close()
-	response, err := m.genPostProcessResponse(tarFile, outFilename+".xz")
+	tarFile, err := os.Open(files[0])
 	if err != nil {
-		logger.Warningf("post status message failed:%v", err)
-		return
+		return dropOutboxItem(err)
 	}
-	data, _, _, err := getResponseData(response, PostProcess)
+	defer tarFile.Close()
+	response, err := m.genPostProcessResponse(taskID, tarFile, filepath.Join("/tmp", filepath.Base(files[0])+".xz"), func(request *http.Request) {
+		m.recordUpload(telemetry.KindLogFiles, taskID, request, nil, files)
+	})
 	if err != nil {
-		logger.Warningf("get post status response failed:%v", err)
-		return
+		return fmt.Errorf("post log files failed:%v", err)
 	}
-	logger.Info(string(data))
+	return checkPostResponse(response, PostProcess)
 }
 
 func (m *UpdatePlatformManager) needPostSystemUpgradeMessage(mode system.UpdateType) bool {
@@ -1928,8 +2071,7 @@ func (m *UpdatePlatformManager) SaveJobPostMsgByUUID(uuid string, upgradeStatus
 		if upgradeStatus == UpgradeFailed || upgradeStatus == CheckFailed {
 			upgradeErrorMsg = Description
 		}
-		hardwareId := GetHardwareId(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper)
-		msg.MachineID = hardwareId
+		msg.MachineID = m.machineID()
 		msg.UpgradeStatus = upgradeStatus
 		msg.UpgradeErrorMsg = upgradeErrorMsg
 		msg.PreBuild = m.preBuild
@@ -1941,104 +2083,145 @@ func (m *UpdatePlatformManager) SaveJobPostMsgByUUID(uuid string, upgradeStatus
 	}
 }
 
-// PostSystemUpgradeMessage 发送系统更新成功或失败的状态
+// PostSystemUpgradeMessage 发送系统更新成功或失败的状态,更新结果转入上报队列后不再保存在 jobPostMsgMap 中
 func (m *UpdatePlatformManager) PostSystemUpgradeMessage(uuid string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.jobPostMsgMapMu.Lock()
-	defer m.jobPostMsgMapMu.Unlock()
 	msg, ok := m.jobPostMsgMap[uuid]
 	if !ok {
+		m.jobPostMsgMapMu.Unlock()
 		return
 	}
-	msg.updateTimeStamp()
 	content, err := json.Marshal(msg)
 	if err != nil {
+		m.jobPostMsgMapMu.Unlock()
 		logger.Warning(err)
 		return
 	}
-
-	logger.Debugf("upgrade post content is %v", string(content))
-	encryptMsg, err := EncryptMsg(content)
+	err = m.outbox.Enqueue(OutboxUpgradeResult, msg.TaskId, uuid, content, nil)
 	if err != nil {
+		m.jobPostMsgMapMu.Unlock()
 		logger.Warning(err)
 		return
 	}
-	base64EncodeString := base64.StdEncoding.EncodeToString(encryptMsg)
-	client := &http.Client{
-		Timeout: 4 * time.Second,
+	msg.remove()
+	delete(m.jobPostMsgMap, uuid)
+	m.jobPostMsgMapMu.Unlock()
+}
+
+func (m *UpdatePlatformManager) sendSystemUpgradeMessage(taskID int, content []byte) error {
+	msg := &UpgradePostMsg{}
+	if err := json.Unmarshal(content, msg); err != nil {
+		return dropOutboxItem(err)
+	}
+	msg.updateTimeStamp()
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return dropOutboxItem(err)
 	}
-	requestUrl := m.requestUrl + "/api/v1/update/status"
-	request, err := http.NewRequest("POST", requestUrl, strings.NewReader(base64EncodeString))
+
+	logger.Debugf("upgrade post content is %v", string(content))
+	client := m.taskHTTPClient(4*time.Second, taskID)
+	requestUrl := m.requestUrl + Urls[PostResult].path
+	cipher, err := m.payloadCipher()
 	if err != nil {
-		logger.Warning(err)
-		return
+		return err
 	}
+	request, err := NewPayloadRequest(cipher, Urls[PostResult].method, requestUrl, content)
+	if err != nil {
+		return err
+	}
+	m.recordUpload(telemetry.KindUpgradeResult, taskID, request, content, nil)
 	response, err := client.Do(request)
-	if err == nil {
-		defer func() {
-			_ = response.Body.Close()
-		}()
-		body, _ := io.ReadAll(response.Body)
-		logger.Debug("postSystemUpgradeMessage response is:", string(body))
-		msg.updatePostStatus(PostSuccess)
-		delete(m.jobPostMsgMap, uuid)
-	} else {
-		logger.Warning("post upgrade message failed:", err)
+	if err != nil {
+		return fmt.Errorf("post upgrade message failed: %v", err)
 	}
+	return checkPostResponse(response, PostResult)
 }
 
+// RetryPostHistory 将待上报的更新结果转入上报队列
 func (m *UpdatePlatformManager) RetryPostHistory() {
+	var uuids []string
+	m.jobPostMsgMapMu.Lock()
 	for _, v := range m.jobPostMsgMap {
 		if v.PostStatus == WaitPost || v.PostStatus == PostFailure {
-			m.PostSystemUpgradeMessage(v.Uuid)
+			uuids = append(uuids, v.Uuid)
 		}
 	}
-	return
+	m.jobPostMsgMapMu.Unlock()
+	for _, uuid := range uuids {
+		m.PostSystemUpgradeMessage(uuid)
+	}
 }
 
-// PrepareCheckScripts decodes and deploys base64-encoded check scripts to the filesystem.
-// It processes multiple types of check scripts, including:
-//   - PreCheck/MidCheck/PostCheck: system update checks
-//   - preUpdateCheck/postUpdateCheck: before/after checking updates
-//   - preDownloadCheck/postDownloadCheck: before/after downloading updates
-//   - preBackupCheck/postBackupCheck: before/after backup
-//
-// The function performs the following operations:
-//  1. Cleans up existing script directories to remove stale scripts
-//  2. Creates fresh directories only when scripts exist for a stage
-//  3. Decodes base64-encoded script content from memory
-//  4. Writes executable script files to the filesystem with 0755 permissions
-//
-// Script files are organized under /var/lib/lastore/check/ in subdirectories:
-//   - pre_check/: for pre-update validation scripts (created when present)
-//   - mid_check/: for mid-update validation scripts (created when present)
-//   - post_check/: for post-update validation scripts (created when present)
-//   - pre_update_check/: for pre-update-check validation scripts (created when present)
-//   - post_update_check/: for post-update-check validation scripts (created when present)
-//   - pre_download_check/: for pre-download validation scripts (created when present)
-//   - post_download_check/: for post-download validation scripts (created when present)
-//   - pre_backup_check/: for pre-backup validation scripts (created when present)
-//   - post_backup_check/: for post-backup validation scripts (created when present)
+// StartOutbox 在后台发送上报队列中的记录,只由 lastore-daemon 调用
+func (m *UpdatePlatformManager) StartOutbox() {
+	m.outbox.Start()
+}
+
+// StopOutbox 停止后台发送上报队列
+func (m *UpdatePlatformManager) StopOutbox() {
+	m.outbox.Stop()
+}
+
+// WakeOutbox 网络恢复时立即发送上报队列中的记录
+func (m *UpdatePlatformManager) WakeOutbox() {
+	m.outbox.Wake()
+}
+
+// GetOutboxStatus 返回上报队列的状态
+func (m *UpdatePlatformManager) GetOutboxStatus() OutboxStatus {
+	return m.outbox.Status()
+}
+
+// GetPlatformDataStatus 返回从更新平台获取的数据的来源和时效
+func (m *UpdatePlatformManager) GetPlatformDataStatus() PlatformDataStatus {
+	if m.responseCache == nil {
+		return PlatformDataStatus{}
+	}
+	return m.responseCache.Status()
+}
+
+// PrepareCheckScripts 清空各检查目录,将更新平台下发的检查脚本 base64 解码后以 0755 权限写入
+// /var/lib/lastore/check 下对应阶段的目录,没有脚本的阶段不创建目录:
+//   - pre_upgrade_check/mid_upgrade_check/post_upgrade_check: 更新前、更新后和更新完成重启后
+//   - pre_update_check/post_update_check: 检查更新前后
+//   - pre_download_check/post_download_check: 下载更新前后
+//   - pre_backup_check/post_backup_check: 备份前后
 //
-// Any decode or file write errors are logged as warnings but do not stop
-// the processing of remaining scripts.
-func (m *UpdatePlatformManager) PrepareCheckScripts() {
+// 脚本名必须是不含路径的文件名,签名须覆盖阶段、脚本名和脚本内容,见 scriptsign.Message。
+// 名字非法、没有签名或签名无效的脚本不会写入,有脚本被拒绝时返回错误;解码和写入失败只记录日志
+func (m *UpdatePlatformManager) PrepareCheckScripts() error {
+	keyDirs := []string{scriptsign.DefaultKeyDir}
+	if m.config.CheckScriptTestKeys {
+		logger.Warning("check scripts signed by test keys are trusted")
+		keyDirs = append(keyDirs, scriptsign.TestKeyDir)
+	}
+	keyRing, err := scriptsign.LoadKeyRing(keyDirs...)
+	if err != nil {
+		// 公钥无法加载时所有脚本都会被拒绝
+		logger.Warning("load check script keys failed:", err)
+		keyRing = scriptsign.KeyRing{}
+	}
+	var refused []string
+
 	type checkGroup struct {
-		list []ShellCheck
-		dir  string
+		list  []ShellCheck
+		stage string // 平台响应中的字段名,参与签名
+		dir   string
 	}
 
 	checkGroups := []checkGroup{
-		{list: m.PreUpgradeCheck, dir: filepath.Join(check.CheckBaseDir, "pre_upgrade_check")},
-		{list: m.MidUpgradeCheck, dir: filepath.Join(check.CheckBaseDir, "mid_upgrade_check")},
-		{list: m.PostUpgradeCheck, dir: filepath.Join(check.CheckBaseDir, "post_upgrade_check")},
-		{list: m.PreUpdateCheck, dir: filepath.Join(check.CheckBaseDir, "pre_update_check")},
-		{list: m.PostUpdateCheck, dir: filepath.Join(check.CheckBaseDir, "post_update_check")},
-		{list: m.PreDownloadCheck, dir: filepath.Join(check.CheckBaseDir, "pre_download_check")},
-		{list: m.PostDownloadCheck, dir: filepath.Join(check.CheckBaseDir, "post_download_check")},
-		{list: m.PreBackupCheck, dir: filepath.Join(check.CheckBaseDir, "pre_backup_check")},
-		{list: m.PostBackupCheck, dir: filepath.Join(check.CheckBaseDir, "post_backup_check")},
+		{list: m.PreUpgradeCheck, stage: "preCheck", dir: filepath.Join(check.CheckBaseDir, "pre_upgrade_check")},
+		{list: m.MidUpgradeCheck, stage: "midCheck", dir: filepath.Join(check.CheckBaseDir, "mid_upgrade_check")},
+		{list: m.PostUpgradeCheck, stage: "postCheck", dir: filepath.Join(check.CheckBaseDir, "post_upgrade_check")},
+		{list: m.PreUpdateCheck, stage: "preUpdateCheck", dir: filepath.Join(check.CheckBaseDir, "pre_update_check")},
+		{list: m.PostUpdateCheck, stage: "postUpdateCheck", dir: filepath.Join(check.CheckBaseDir, "post_update_check")},
+		{list: m.PreDownloadCheck, stage: "preDownloadCheck", dir: filepath.Join(check.CheckBaseDir, "pre_download_check")},
+		{list: m.PostDownloadCheck, stage: "postDownloadCheck", dir: filepath.Join(check.CheckBaseDir, "post_download_check")},
+		{list: m.PreBackupCheck, stage: "preBackupCheck", dir: filepath.Join(check.CheckBaseDir, "pre_backup_check")},
+		{list: m.PostBackupCheck, stage: "postBackupCheck", dir: filepath.Join(check.CheckBaseDir, "post_backup_check")},
 	}
 
 	for _, g := range checkGroups {
@@ -2056,6 +2239,11 @@ func (m *UpdatePlatformManager) PrepareCheckScripts() {
 		}
 
 		for _, c := range g.list {
+			if err := scriptsign.ValidName(c.Name); err != nil {
+				logger.Warningf("refuse check script in %s: %v", g.dir, err)
+				refused = append(refused, fmt.Sprintf("%s: %v", g.dir, err))
+				continue
+			}
 			filePath := filepath.Join(g.dir, c.Name)
 			// Remove padding for StdEncoding, "IyEvYmluL2Jhc2gKCmVjaG8gImhlbGxvIGRlZXBpbiI="
 			shell := strings.TrimRight(c.Shell, "=")
@@ -2064,12 +2252,21 @@ func (m *UpdatePlatformManager) PrepareCheckScripts() {
 				logger.Warningf("decode shell for %s failed: %v", c.Name, err)
 				continue
 			}
+			if err := keyRing.VerifyScript(c.KeyID, g.stage, c.Name, content, c.Signature); err != nil {
+				logger.Warningf("refuse check script %s: %v", filePath, err)
+				refused = append(refused, fmt.Sprintf("%s: %v", filePath, err))
+				continue
+			}
 			if err := utils.SyncWriteFile(filePath, content, 0755); err != nil {
 				logger.Warningf("write file %s failed: %v", filePath, err)
 				continue
 			}
 		}
 	}
+	if len(refused) != 0 {
+		return fmt.Errorf("untrusted check scripts: %s", strings.Join(refused, "; "))
+	}
+	return nil
 }
 
 func (m *UpdatePlatformManager) SaveCache(c *Cfg.Config) {
@@ -2077,6 +2274,9 @@ func (m *UpdatePlatformManager) SaveCache(c *Cfg.Config) {
 	cache.CoreListPkgs = m.TargetCorePkgs
 	cache.BaselinePkgs = m.BaselinePkgs
 	cache.SelectPkgs = m.SelectPkgs
+	cache.BaselineSelectPkgs = m.BaselineSelectPkgs
+	cache.BaselineFreezePkgs = m.BaselineFreezePkgs
+	cache.BaselinePurgePkgs = m.BaselinePurgePkgs
 	cache.PreUpgradeCheck = m.PreUpgradeCheck
 	cache.MidUpgradeCheck = m.MidUpgradeCheck
 	cache.PostUpgradeCheck = m.PostUpgradeCheck
@@ -2147,6 +2347,9 @@ func getTaskId() int {
 // UpdateRequestUrl 更新平台请求地址
 func (m *UpdatePlatformManager) UpdateRequestUrl(url string) {
 	m.requestUrl = url
+	if m.transport != nil && m.transport.Endpoints() != nil {
+		m.transport.Endpoints().SetHome(url)
+	}
 }
 
 func updateNoLimitConfigIfChanged(currentValue string, setFunc func(string) error) error {
@@ -2490,4 +2693,9 @@ func (m *UpdatePlatformManager) resetIntranetUpdateSettingsAfterUnregister() {
 	if err := m.UpdateDeliverySpeedLimitWithConfig(noRemoteIPFSLimitConfig()); err != nil {
 		logger.Warningf("failed to reset delivery speed limit: %v", err)
 	}
+
+	// 内网平台的策略不能在离线时继续使用
+	if m.responseCache != nil {
+		m.responseCache.Clear()
+	}
 }
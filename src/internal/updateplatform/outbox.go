@@ -0,0 +1,568 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxdeepin/go-lib/utils"
+)
+
+// OutboxKind 上报数据的类型
+type OutboxKind string
+
+const (
+	OutboxUpgradeResult OutboxKind = "result" // 更新结果
+	OutboxProcessEvent  OutboxKind = "event"  // 更新过程事件
+	OutboxStatusMsg     OutboxKind = "status" // 更新过程状态
+	OutboxLogFiles      OutboxKind = "logs"   // 更新日志文件
+)
+
+// outboxPriority 数值越小越先发送,超出容量限制时优先丢弃数值大的
+var outboxPriority = map[OutboxKind]int{
+	OutboxUpgradeResult: 0,
+	OutboxProcessEvent:  1,
+	OutboxStatusMsg:     2,
+	OutboxLogFiles:      3,
+}
+
+var outboxDir = filepath.Join("/var/cache/lastore", "outbox")
+
+// outboxPollInterval 后台发送检查队列目录的间隔,用于发送其他进程写入的记录
+const outboxPollInterval = 10 * time.Minute
+
+// OutboxLimits 队列的容量和重试限制
+type OutboxLimits struct {
+	MaxItems   int
+	MaxBytes   int64
+	MaxAge     time.Duration // 超过该时间仍未发送成功的记录会被丢弃
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+var defaultOutboxLimits = OutboxLimits{
+	MaxItems:   500,
+	MaxBytes:   64 << 20,
+	MaxAge:     7 * 24 * time.Hour,
+	MinBackoff: 30 * time.Second,
+	MaxBackoff: 6 * time.Hour,
+}
+
+// OutboxItem 待上报的记录,每条记录持久化为队列目录中的一个 json 文件
+type OutboxItem struct {
+	Id        string
+	Kind      OutboxKind
+	TaskID    int
+	Seq       uint64 // 入队顺序,同一 TaskID 的记录按 Seq 依次发送
+	Gen       uint64 `json:",omitempty"` // 内容更新次数,发送期间被更新的记录不会在发送后删除
+	Key       string // 去重键,队列中 Kind 和 Key 相同的记录只保留一条
+	Payload   json.RawMessage
+	Files     []string `json:",omitempty"` // 随记录上传的文件,保存在队列目录中
+	Size      int64
+	CreatedAt int64
+	Attempts  int
+	NextAt    int64  // 下次发送时间,为 0 时立即发送
+	LastError string `json:",omitempty"`
+}
+
+// OutboxStatus 队列状态
+type OutboxStatus struct {
+	Depth     int
+	Bytes     int64
+	Kinds     map[OutboxKind]int `json:",omitempty"`
+	Oldest    int64              `json:",omitempty"` // 最早的记录的入队时间
+	NextRetry int64              `json:",omitempty"` // 最近的重试时间
+	LastError string             `json:",omitempty"`
+	LastSent  int64              `json:",omitempty"` // 最近一次发送成功的时间
+	Dropped   int                // 因超出容量或过期丢弃的记录数
+}
+
+type outboxDropError struct {
+	err error
+}
+
+func (e *outboxDropError) Error() string {
+	return e.err.Error()
+}
+
+// dropOutboxItem 标记重试也不会成功的错误,发送失败时直接丢弃记录
+func dropOutboxItem(err error) error {
+	return &outboxDropError{err: err}
+}
+
+// Outbox 持久化的上报队列,上报更新平台的数据都先写入队列再发送,发送失败时按指数退避重试。
+// 只有调用了 Start 的进程(lastore-daemon)在后台发送,其他进程只写入队列目录
+type Outbox struct {
+	dir    string
+	limits OutboxLimits
+	send   func(item *OutboxItem) error
+	now    func() time.Time
+
+	mu        sync.Mutex
+	items     []*OutboxItem
+	seq       uint64
+	draining  bool
+	sending   *OutboxItem // 正在发送的记录,其文件在发送结束前不能删除
+	stale     []string    // 发送期间被替换的文件,发送结束后删除
+	timer     *time.Timer
+	wakeCh    chan struct{} // 通知后台发送,Start 之前为 nil
+	stopCh    chan struct{}
+	lastError string
+	lastSent  int64
+	dropped   int
+}
+
+// NewOutbox 创建队列并加载 dir 中未发送的记录
+func NewOutbox(dir string, limits OutboxLimits, send func(item *OutboxItem) error) *Outbox {
+	o := &Outbox{
+		dir:    dir,
+		limits: limits,
+		send:   send,
+		now:    time.Now,
+	}
+	o.mu.Lock()
+	o.reload()
+	o.enforceLimits()
+	o.mu.Unlock()
+	return o
+}
+
+func (o *Outbox) itemPath(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+// reload 加载队列目录中尚未加载的记录,其他进程(如 lastore-tools)也会写入该目录
+func (o *Outbox) reload() {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning(err)
+		}
+		return
+	}
+	loaded := make(map[string]bool)
+	for _, item := range o.items {
+		loaded[item.Id] = true
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") || loaded[strings.TrimSuffix(name, ".json")] {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(o.dir, name))
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		item := &OutboxItem{}
+		if err := json.Unmarshal(content, item); err != nil || item.Id == "" {
+			logger.Warningf("invalid outbox item %v: %v", name, err)
+			_ = os.Remove(filepath.Join(o.dir, name))
+			continue
+		}
+		o.items = append(o.items, item)
+		if item.Seq > o.seq {
+			o.seq = item.Seq
+		}
+	}
+	o.sortItems()
+}
+
+func (o *Outbox) sortItems() {
+	sort.SliceStable(o.items, func(i, j int) bool {
+		if o.items[i].Seq != o.items[j].Seq {
+			return o.items[i].Seq < o.items[j].Seq
+		}
+		return o.items[i].CreatedAt < o.items[j].CreatedAt
+	})
+}
+
+func (o *Outbox) save(item *OutboxItem) {
+	if err := utils.EnsureDirExist(o.dir); err != nil {
+		logger.Warning(err)
+		return
+	}
+	content, err := json.Marshal(item)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.WriteFile(o.itemPath(item.Id), content, 0600); err != nil {
+		logger.Warning(err)
+	}
+}
+
+func (o *Outbox) remove(item *OutboxItem) {
+	for i, v := range o.items {
+		if v == item {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+			break
+		}
+	}
+	o.removeFiles(item)
+	_ = os.Remove(o.itemPath(item.Id))
+}
+
+// removeFiles 删除记录的文件,记录正在发送时推迟到发送结束后删除
+func (o *Outbox) removeFiles(item *OutboxItem) {
+	if item == o.sending {
+		o.stale = append(o.stale, item.Files...)
+		return
+	}
+	for _, file := range item.Files {
+		_ = os.Remove(file)
+	}
+}
+
+func (o *Outbox) contains(item *OutboxItem) bool {
+	for _, v := range o.items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Enqueue 将数据写入队列,key 为空时按内容去重;files 会被移动到队列目录中
+func (o *Outbox) Enqueue(kind OutboxKind, taskID int, key string, payload []byte, files []string) error {
+	if key == "" {
+		sum := sha256.Sum256(payload)
+		key = hex.EncodeToString(sum[:8])
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := utils.EnsureDirExist(o.dir); err != nil {
+		return err
+	}
+	var item *OutboxItem
+	for _, v := range o.items {
+		if v.Kind == kind && v.Key == key {
+			item = v
+			break
+		}
+	}
+	if item == nil {
+		o.seq++
+		item = &OutboxItem{
+			Id:        utils.GenUuid(),
+			Kind:      kind,
+			TaskID:    taskID,
+			Seq:       o.seq,
+			Key:       key,
+			CreatedAt: o.now().Unix(),
+		}
+		o.items = append(o.items, item)
+	} else {
+		// 相同的记录更新内容后保持原来的顺序,并立即重试
+		o.removeFiles(item)
+		item.Gen++
+		item.Attempts, item.NextAt, item.LastError = 0, 0, ""
+	}
+	item.Payload = payload
+	item.Files = nil
+	item.Size = int64(len(payload))
+	for i, file := range files {
+		dst := filepath.Join(o.dir, fmt.Sprintf("%v-%d-%d%v", item.Id, item.Gen, i, filepath.Ext(file)))
+		if err := moveFile(file, dst); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		item.Files = append(item.Files, dst)
+		if info, err := os.Stat(dst); err == nil {
+			item.Size += info.Size()
+		}
+	}
+	o.save(item)
+	o.enforceLimits()
+	o.kick()
+	return nil
+}
+
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	// 跨文件系统时复制后删除
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// enforceLimits 丢弃过期的记录,超出数量或大小限制时按优先级从低到高、从旧到新丢弃
+func (o *Outbox) enforceLimits() {
+	now := o.now()
+	for _, item := range append([]*OutboxItem(nil), o.items...) {
+		if o.limits.MaxAge > 0 && now.Sub(time.Unix(item.CreatedAt, 0)) > o.limits.MaxAge {
+			logger.Infof("drop expired outbox item %v %v", item.Kind, item.Id)
+			o.remove(item)
+			o.dropped++
+		}
+	}
+	for {
+		var total int64
+		for _, item := range o.items {
+			total += item.Size
+		}
+		overItems := o.limits.MaxItems > 0 && len(o.items) > o.limits.MaxItems
+		overBytes := o.limits.MaxBytes > 0 && total > o.limits.MaxBytes
+		if len(o.items) == 0 || (!overItems && !overBytes) {
+			return
+		}
+		victim := o.items[0]
+		for _, item := range o.items[1:] {
+			if outboxPriority[item.Kind] > outboxPriority[victim.Kind] {
+				victim = item
+			}
+		}
+		logger.Infof("outbox is full, drop %v %v", victim.Kind, victim.Id)
+		o.remove(victim)
+		o.dropped++
+	}
+}
+
+// nextDue 返回下一条可以发送的记录:同一 TaskID 的记录按入队顺序发送,前面的未发送时后面的不发送,
+// 不同 TaskID 之间按优先级选择
+func (o *Outbox) nextDue() *OutboxItem {
+	now := o.now().Unix()
+	blocked := make(map[int]bool)
+	var due *OutboxItem
+	for _, item := range o.items {
+		if blocked[item.TaskID] {
+			continue
+		}
+		blocked[item.TaskID] = true
+		if item.NextAt > now {
+			continue
+		}
+		if due == nil || outboxPriority[item.Kind] < outboxPriority[due.Kind] {
+			due = item
+		}
+	}
+	return due
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.limits.MinBackoff
+	for i := 1; i < attempts && d < o.limits.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > o.limits.MaxBackoff {
+		d = o.limits.MaxBackoff
+	}
+	return d
+}
+
+// Start 启动后台发送,写入、重试时间到达、Wake 以及每隔 outboxPollInterval 时发送到期的记录
+func (o *Outbox) Start() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.wakeCh != nil {
+		return
+	}
+	o.wakeCh = make(chan struct{}, 1)
+	o.stopCh = make(chan struct{})
+	go o.loop(o.wakeCh, o.stopCh)
+	o.kick()
+}
+
+// Stop 停止后台发送,未发送的记录保留在队列目录中
+func (o *Outbox) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stopCh == nil {
+		return
+	}
+	close(o.stopCh)
+	o.wakeCh, o.stopCh = nil, nil
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+}
+
+func (o *Outbox) loop(wake, stop <-chan struct{}) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+		o.Drain()
+	}
+}
+
+// kick 通知后台发送,调用时需要持有 o.mu
+func (o *Outbox) kick() {
+	if o.wakeCh == nil {
+		return
+	}
+	select {
+	case o.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Drain 依次发送到期的记录,由后台发送调用,已有发送在进行时直接返回
+func (o *Outbox) Drain() {
+	o.mu.Lock()
+	if o.draining {
+		o.mu.Unlock()
+		return
+	}
+	o.draining = true
+	o.reload()
+	o.enforceLimits()
+	o.mu.Unlock()
+
+	for {
+		o.mu.Lock()
+		item := o.nextDue()
+		if item == nil {
+			o.mu.Unlock()
+			break
+		}
+		// 发送期间 Enqueue 可能更新记录,发送持锁时复制的快照
+		snapshot := *item
+		snapshot.Files = append([]string(nil), item.Files...)
+		o.sending = item
+		o.mu.Unlock()
+		var err error
+		if _, statErr := os.Stat(o.itemPath(snapshot.Id)); os.IsNotExist(statErr) {
+			// 已被其他进程发送
+			err = nil
+		} else {
+			err = o.send(&snapshot)
+		}
+		o.mu.Lock()
+		o.sending = nil
+		for _, file := range o.stale {
+			_ = os.Remove(file)
+		}
+		o.stale = nil
+		var dropErr *outboxDropError
+		switch {
+		case !o.contains(item):
+			// 发送期间已因超出限制被丢弃
+		case item.Gen != snapshot.Gen:
+			// 发送期间内容已更新,保留记录并立即发送新的内容
+			item.NextAt = 0
+			o.save(item)
+		case err == nil:
+			o.lastSent = o.now().Unix()
+			o.remove(item)
+		case errors.As(err, &dropErr):
+			logger.Warningf("drop outbox item %v %v: %v", item.Kind, item.Id, err)
+			o.lastError = err.Error()
+			o.remove(item)
+			o.dropped++
+		default:
+			item.Attempts++
+			item.LastError = err.Error()
+			item.NextAt = o.now().Add(o.backoff(item.Attempts)).Unix()
+			o.lastError = err.Error()
+			logger.Warningf("post %v %v failed, retry at %v: %v", item.Kind, item.Id,
+				time.Unix(item.NextAt, 0).Format(time.RFC3339), err)
+			o.save(item)
+		}
+		o.mu.Unlock()
+	}
+
+	o.mu.Lock()
+	o.draining = false
+	o.schedule()
+	o.mu.Unlock()
+}
+
+// schedule 在最近的重试时间再次发送
+func (o *Outbox) schedule() {
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+	var next int64
+	for _, item := range o.items {
+		if next == 0 || item.NextAt < next {
+			next = item.NextAt
+		}
+	}
+	if len(o.items) == 0 {
+		return
+	}
+	d := time.Until(time.Unix(next, 0))
+	if d < time.Second {
+		d = time.Second
+	}
+	o.timer = time.AfterFunc(d, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.kick()
+	})
+}
+
+// Wake 网络恢复时调用,取消所有记录的退避等待并通知后台发送
+func (o *Outbox) Wake() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, item := range o.items {
+		if item.NextAt != 0 {
+			item.NextAt = 0
+			o.save(item)
+		}
+	}
+	o.kick()
+}
+
+// Status 返回队列状态
+func (o *Outbox) Status() OutboxStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	status := OutboxStatus{
+		Depth:     len(o.items),
+		Kinds:     make(map[OutboxKind]int),
+		LastError: o.lastError,
+		LastSent:  o.lastSent,
+		Dropped:   o.dropped,
+	}
+	for _, item := range o.items {
+		status.Bytes += item.Size
+		status.Kinds[item.Kind]++
+		if status.Oldest == 0 || item.CreatedAt < status.Oldest {
+			status.Oldest = item.CreatedAt
+		}
+		if item.NextAt != 0 && (status.NextRetry == 0 || item.NextAt < status.NextRetry) {
+			status.NextRetry = item.NextAt
+		}
+	}
+	return status
+}
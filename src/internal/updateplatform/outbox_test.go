@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type outboxRecorder struct {
+	sent []string
+	fail map[string]error
+}
+
+func (r *outboxRecorder) send(item *OutboxItem) error {
+	if err, ok := r.fail[string(item.Payload)]; ok {
+		return err
+	}
+	r.sent = append(r.sent, string(item.Payload))
+	return nil
+}
+
+func newTestOutbox(t *testing.T, limits OutboxLimits, r *outboxRecorder) (*Outbox, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	o := NewOutbox(t.TempDir(), limits, r.send)
+	o.now = func() time.Time {
+		return now
+	}
+	return o, &now
+}
+
+func (o *Outbox) stopTimer() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+}
+
+func TestOutboxOrder(t *testing.T) {
+	r := &outboxRecorder{}
+	o, _ := newTestOutbox(t, defaultOutboxLimits, r)
+	_ = o.Enqueue(OutboxStatusMsg, 1, "", []byte(`"status1"`), nil)
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event1"`), nil)
+	_ = o.Enqueue(OutboxUpgradeResult, 1, "uuid", []byte(`"result"`), nil)
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event2"`), nil)
+	_ = o.Enqueue(OutboxStatusMsg, 2, "", []byte(`"status2"`), nil)
+	_ = o.Enqueue(OutboxUpgradeResult, 3, "uuid3", []byte(`"result3"`), nil)
+	// 重复的记录只保留一条
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event1"`), nil)
+	o.Drain()
+	// 同一 TaskID 的记录按入队顺序发送,不同 TaskID 之间按优先级发送
+	want := []string{`"result3"`, `"status1"`, `"event1"`, `"result"`, `"event2"`, `"status2"`}
+	if !reflect.DeepEqual(r.sent, want) {
+		t.Errorf("sent = %v, want %v", r.sent, want)
+	}
+	if o.Status().Depth != 0 {
+		t.Errorf("depth = %v, want 0", o.Status().Depth)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	r := &outboxRecorder{fail: map[string]error{`"event1"`: errors.New("network unreachable")}}
+	o, now := newTestOutbox(t, defaultOutboxLimits, r)
+	defer o.stopTimer()
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event1"`), nil)
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event2"`), nil)
+	_ = o.Enqueue(OutboxProcessEvent, 2, "", []byte(`"event3"`), nil)
+	o.Drain()
+	// 同一 TaskID 的后续记录等待前面的记录发送成功
+	if want := []string{`"event3"`}; !reflect.DeepEqual(r.sent, want) {
+		t.Errorf("sent = %v, want %v", r.sent, want)
+	}
+	status := o.Status()
+	if status.Depth != 2 || status.NextRetry != now.Add(defaultOutboxLimits.MinBackoff).Unix() {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	*now = now.Add(defaultOutboxLimits.MinBackoff)
+	o.Drain()
+	if status := o.Status(); status.NextRetry != now.Add(2*defaultOutboxLimits.MinBackoff).Unix() {
+		t.Errorf("second retry at %v, want %v", status.NextRetry, now.Add(2*defaultOutboxLimits.MinBackoff).Unix())
+	}
+
+	delete(r.fail, `"event1"`)
+	o.Wake()
+	o.Drain()
+	if want := []string{`"event3"`, `"event1"`, `"event2"`}; !reflect.DeepEqual(r.sent, want) {
+		t.Errorf("sent = %v, want %v", r.sent, want)
+	}
+}
+
+func TestOutboxStart(t *testing.T) {
+	sent := make(chan string, 1)
+	o := NewOutbox(t.TempDir(), defaultOutboxLimits, func(item *OutboxItem) error {
+		sent <- string(item.Payload)
+		return nil
+	})
+	// 没有启动后台发送时只写入队列
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event1"`), nil)
+	select {
+	case v := <-sent:
+		t.Fatalf("unexpected send %v before start", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	o.Start()
+	defer o.Stop()
+	for _, want := range []string{`"event1"`, `"event2"`} {
+		if want == `"event2"` {
+			_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(want), nil)
+		}
+		select {
+		case v := <-sent:
+			if v != want {
+				t.Errorf("sent %v, want %v", v, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v is not sent", want)
+		}
+	}
+}
+
+func TestOutboxDrop(t *testing.T) {
+	r := &outboxRecorder{fail: map[string]error{`"bad"`: dropOutboxItem(errors.New("bad request"))}}
+	o, _ := newTestOutbox(t, defaultOutboxLimits, r)
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"bad"`), nil)
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"good"`), nil)
+	o.Drain()
+	if want := []string{`"good"`}; !reflect.DeepEqual(r.sent, want) {
+		t.Errorf("sent = %v, want %v", r.sent, want)
+	}
+	if status := o.Status(); status.Depth != 0 || status.Dropped != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestOutboxLimits(t *testing.T) {
+	limits := defaultOutboxLimits
+	limits.MaxItems = 2
+	r := &outboxRecorder{}
+	o, now := newTestOutbox(t, limits, r)
+	_ = o.Enqueue(OutboxUpgradeResult, 1, "uuid", []byte(`"result"`), nil)
+	_ = o.Enqueue(OutboxLogFiles, 1, "", []byte(`"logs"`), nil)
+	_ = o.Enqueue(OutboxProcessEvent, 1, "", []byte(`"event"`), nil)
+	status := o.Status()
+	if status.Depth != 2 || status.Kinds[OutboxLogFiles] != 0 || status.Dropped != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	*now = now.Add(limits.MaxAge + time.Second)
+	o.Drain()
+	if len(r.sent) != 0 || o.Status().Depth != 0 {
+		t.Errorf("expired items should be dropped, sent %v", r.sent)
+	}
+}
+
+func TestOutboxPersist(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(t.TempDir(), "update.tar")
+	if err := os.WriteFile(logFile, []byte("logs"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := NewOutbox(dir, defaultOutboxLimits, func(*OutboxItem) error {
+		return errors.New("offline")
+	})
+	_ = o.Enqueue(OutboxLogFiles, 1, "update.tar", nil, []string{logFile})
+	_ = o.Enqueue(OutboxUpgradeResult, 1, "uuid", []byte(`"result"`), nil)
+	if _, err := os.Stat(logFile); !os.IsNotExist(err) {
+		t.Errorf("log file should be moved into outbox")
+	}
+
+	r := &outboxRecorder{}
+	reloaded := NewOutbox(dir, defaultOutboxLimits, func(item *OutboxItem) error {
+		if item.Kind == OutboxLogFiles {
+			content, err := os.ReadFile(item.Files[0])
+			if err != nil || string(content) != "logs" {
+				t.Errorf("unexpected log file %v: %v", string(content), err)
+			}
+		}
+		return r.send(item)
+	})
+	if status := reloaded.Status(); status.Depth != 2 || status.Bytes != int64(len(`"result"`)+len("logs")) {
+		t.Errorf("unexpected status %+v", status)
+	}
+	reloaded.Drain()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("outbox dir should be empty, got %v entries", len(entries))
+	}
+}
+
+func TestOutboxEnqueueWhileDraining(t *testing.T) {
+	dir := t.TempDir()
+	var o *Outbox
+	enqueue := func(payload string) {
+		file := filepath.Join(t.TempDir(), "update.tar")
+		if err := os.WriteFile(file, []byte(payload), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_ = o.Enqueue(OutboxLogFiles, 1, "update.tar", []byte(payload), []string{file})
+	}
+	sending := make(chan struct{})
+	updated := make(chan struct{})
+	var sent []string
+	o = NewOutbox(dir, defaultOutboxLimits, func(item *OutboxItem) error {
+		if len(sent) == 0 {
+			// 第一次发送过程中更新同一条记录
+			close(sending)
+			<-updated
+		}
+		// 发送的快照与其文件必须一致,不能被并发的 Enqueue 修改或删除
+		content, err := os.ReadFile(item.Files[0])
+		if err != nil || string(content) != string(item.Payload) {
+			t.Errorf("inconsistent item %v: %v %v", string(item.Payload), string(content), err)
+		}
+		sent = append(sent, string(item.Payload))
+		return nil
+	})
+	enqueue(`"logs1"`)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.Drain()
+	}()
+	<-sending
+	enqueue(`"logs2"`)
+	close(updated)
+	wg.Wait()
+	o.stopTimer()
+	// 发送期间更新的内容不会因发送成功而被删除
+	want := []string{`"logs1"`, `"logs2"`}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("outbox dir should be empty, got %v entries", len(entries))
+	}
+}
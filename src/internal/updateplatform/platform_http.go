@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	Cfg "github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformhttp"
+)
+
+// NewPlatformTransport 按配置创建访问更新平台的传输层,lastore-daemon 和 lastore-tools 共用。
+// CA 或客户端证书无法加载时忽略证书配置,只使用系统 CA
+func NewPlatformTransport(c *Cfg.Config) *platformhttp.Transport {
+	opts := platformhttp.Options{
+		TraceFile: platformhttp.DefaultTraceFile,
+	}
+	if c != nil {
+		opts.CAFile = c.PlatformCAFile
+		opts.CertFile = c.PlatformClientCert
+		opts.KeyFile = c.PlatformClientKey
+		opts.Endpoints = newPlatformEndpoints(c)
+	}
+	transport, err := platformhttp.NewTransport(opts)
+	if err != nil {
+		logger.Warning("load platform tls config failed:", err)
+		opts.CAFile, opts.CertFile, opts.KeyFile = "", "", ""
+		transport, _ = platformhttp.NewTransport(opts)
+	}
+	return transport
+}
+
+// newPlatformEndpoints 按配置创建多个更新平台地址,没有配置或配置错误时返回 nil
+func newPlatformEndpoints(c *Cfg.Config) *platformhttp.Endpoints {
+	endpoints, err := platformhttp.ParseEndpoints(c.PlatformEndpoints)
+	if err != nil {
+		logger.Warning(err)
+		return nil
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+	// 同一优先级的地址按 machine-id 固定选择
+	machineID, _ := os.ReadFile("/etc/machine-id")
+	return platformhttp.NewEndpoints(endpoints, c.PlatformUrl, strings.TrimSpace(string(machineID)), platformhttp.DefaultEndpointStateFile)
+}
+
+// httpClient 返回使用公共传输层的客户端,未通过 NewUpdatePlatformManager 创建时使用默认传输层
+func (m *UpdatePlatformManager) httpClient(timeout time.Duration) *http.Client {
+	if m.transport == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	return m.transport.Client(timeout)
+}
+
+// doCachedRequest 发送 GET 请求,使用响应缓存支持条件请求和无法访问平台时的回退
+func (m *UpdatePlatformManager) doCachedRequest(client *http.Client, reqType requestType, request *http.Request) (*http.Response, error) {
+	name, ok := responseCacheNames[reqType]
+	if m.responseCache == nil || !ok {
+		return client.Do(request)
+	}
+	return m.responseCache.Do(client, name, request)
+}
+
+// taskHTTPClient 返回发送 taskID 相关上报的客户端,同一任务的上报发送到同一个更新平台地址
+func (m *UpdatePlatformManager) taskHTTPClient(timeout time.Duration, taskID int) *http.Client {
+	if m.transport == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	// 只有平台下发的当前任务绑定地址,没有任务或调用方使用的占位任务 ID 不绑定
+	if taskID != m.taskID {
+		taskID = 0
+	}
+	return m.transport.TaskClient(timeout, taskID)
+}
+
+// GetPlatformEndpoints 返回更新平台地址的状态,没有配置多个地址时只返回当前地址
+func (m *UpdatePlatformManager) GetPlatformEndpoints() platformhttp.EndpointsStatus {
+	if m.transport == nil || m.transport.Endpoints() == nil {
+		return platformhttp.EndpointsStatus{
+			Active: m.requestUrl,
+			Reason: "single endpoint configured",
+			Endpoints: []platformhttp.EndpointStatus{{
+				Endpoint: platformhttp.Endpoint{URL: m.requestUrl, Weight: 1},
+				Healthy:  true,
+			}},
+		}
+	}
+	return m.transport.Endpoints().Status()
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/policypush"
+)
+
+// pushEventRequests 平台通知的事件对应的请求,收到通知后该请求的缓存不再有效
+var pushEventRequests = map[string]requestType{
+	policypush.EventPolicy:     GetVersion,
+	policypush.EventThrottling: GetThrottling,
+	policypush.EventIPFSConfig: GetIPFSConfig,
+}
+
+// NewPolicyPushChannel 创建接收平台策略、限速和 ipfs 配置变化通知的长连接,没有配置 platform-push-url 时返回 nil。
+// 处理事件前先使对应请求的缓存失效,保证处理函数从平台获取最新的数据
+func (m *UpdatePlatformManager) NewPolicyPushChannel(handlers map[string]func()) *policypush.Channel {
+	if strings.TrimSpace(m.config.PlatformPushUrl) == "" {
+		return nil
+	}
+	wrapped := make(map[string]func(), len(handlers))
+	for event, fn := range handlers {
+		event, fn := event, fn
+		wrapped[event] = func() {
+			logger.Infof("platform pushed %v change", event)
+			if reqType, ok := pushEventRequests[event]; ok && m.responseCache != nil {
+				m.responseCache.Expire(responseCacheNames[reqType])
+			}
+			fn()
+		}
+	}
+	return policypush.New(policypush.Options{
+		URL: m.policyPushURL,
+		// 长连接不能设置超时,由空闲超时判断连接是否可用
+		Client: m.httpClient(0),
+		Header: func(request *http.Request) {
+			request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(m.getToken())))
+		},
+		Handlers:   wrapped,
+		StatusFile: policypush.DefaultStatusFile,
+	})
+}
+
+// policyPushURL 长连接地址,相对路径时基于当前的更新平台地址
+func (m *UpdatePlatformManager) policyPushURL() string {
+	pushUrl := strings.TrimSpace(m.config.PlatformPushUrl)
+	if strings.HasPrefix(pushUrl, "http://") || strings.HasPrefix(pushUrl, "https://") {
+		return pushUrl
+	}
+	if m.requestUrl == "" {
+		return ""
+	}
+	return strings.TrimSuffix(m.requestUrl, "/") + "/" + strings.TrimPrefix(pushUrl, "/")
+}
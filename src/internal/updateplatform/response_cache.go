@@ -0,0 +1,349 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/envelope"
+)
+
+var responseCacheDir = filepath.Join("/var/lib/lastore", "platform-cache")
+
+// responseCacheNames 需要缓存的请求,每种请求只缓存最后一次有效的响应
+var responseCacheNames = map[requestType]string{
+	GetVersion:         "version",
+	GetTargetPkgLists:  "target-packages",
+	GetCurrentPkgLists: "current-packages",
+	GetUpdateLog:       "update-log",
+	GetPkgCVEs:         "cve",
+	GetThrottling:      "throttling",
+	GetIPFSConfig:      "ipfs-config",
+}
+
+// identityHeaders 携带设备身份的请求头,平台根据这些请求头返回不同的数据
+var identityHeaders = []string{"X-Repo-Token", "X-MachineID"}
+
+// ResponseSource 响应数据的来源
+type ResponseSource string
+
+const (
+	SourceNetwork     ResponseSource = "network"      // 平台返回了新数据
+	SourceNotModified ResponseSource = "not-modified" // 平台返回 304,使用缓存数据
+	SourceFresh       ResponseSource = "fresh"        // 缓存在平台给出的有效期内,没有请求平台
+	SourceOffline     ResponseSource = "offline"      // 无法访问平台,使用最后一次有效的缓存数据
+)
+
+// CachedResponse 平台最后一次返回的有效响应,每种请求持久化为缓存目录中的一个 json 文件
+type CachedResponse struct {
+	Name         string
+	URL          string // 请求地址不同时(如基线变化)缓存无效
+	Identity     string `json:",omitempty"` // 身份请求头的摘要,token 变化时缓存无效
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	KeyVersion   string `json:",omitempty"` // 平台选择的上报数据加密密钥版本
+	FetchedAt    int64  // 最近一次获取到新数据的时间
+	ValidatedAt  int64  // 最近一次平台确认数据有效的时间
+	FreshUntil   int64  `json:",omitempty"` // 平台通过 Cache-Control: max-age 给出的有效期
+	Body         json.RawMessage
+}
+
+// ResponseCacheStatus 最近一次请求使用的数据
+type ResponseCacheStatus struct {
+	Name        string
+	URL         string
+	Source      ResponseSource
+	UsedAt      int64
+	FetchedAt   int64  `json:",omitempty"`
+	ValidatedAt int64  `json:",omitempty"`
+	Age         int64  // 距平台最近一次确认数据有效的秒数
+	Error       string `json:",omitempty"` // 使用离线缓存时,访问平台的错误
+}
+
+// PlatformDataStatus 从更新平台获取的数据的状态
+type PlatformDataStatus struct {
+	Offline  bool // 存在使用离线缓存的请求
+	Requests []ResponseCacheStatus
+}
+
+// ResponseCache 缓存更新平台 GET 请求的有效响应,用于条件请求和无法访问平台时的回退
+type ResponseCache struct {
+	dir      string
+	maxStale time.Duration
+
+	mu     sync.Mutex
+	usages map[string]ResponseCacheStatus
+}
+
+// NewResponseCache 创建响应缓存,maxStale 为无法访问平台时可以使用的缓存的最长时间,为 0 时不回退
+func NewResponseCache(dir string, maxStale time.Duration) *ResponseCache {
+	return &ResponseCache{
+		dir:      dir,
+		maxStale: maxStale,
+		usages:   make(map[string]ResponseCacheStatus),
+	}
+}
+
+func (c *ResponseCache) path(name string) string {
+	return filepath.Join(c.dir, name+".json")
+}
+
+// requestIdentity 返回 request 中身份请求头的摘要,缓存中只保存摘要
+func requestIdentity(request *http.Request) string {
+	hash := sha256.New()
+	for _, key := range identityHeaders {
+		for _, value := range request.Header.Values(key) {
+			_, _ = fmt.Fprintf(hash, "%s: %s\n", key, value)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// load 读取请求地址为 url、身份摘要为 identity 的缓存
+func (c *ResponseCache) load(path, url, identity string) *CachedResponse {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry CachedResponse
+	if err := json.Unmarshal(content, &entry); err != nil {
+		logger.Warningf("invalid platform response cache %v: %v", path, err)
+		_ = os.Remove(path)
+		return nil
+	}
+	if entry.URL != url || entry.Identity != identity {
+		return nil
+	}
+	return &entry
+}
+
+func (c *ResponseCache) save(path string, entry *CachedResponse) {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		logger.Warning(err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		logger.Warning(err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logger.Warning(err)
+		_ = os.Remove(tmp)
+	}
+}
+
+// Do 发送请求:携带 If-None-Match 和 If-Modified-Since,平台返回 304 时使用缓存数据;
+// 网络错误或平台返回 5xx 时,缓存没有超过 maxStale 则使用缓存数据。使用缓存数据时返回构造的 200 响应。
+// 请求地址或身份请求头与缓存不同时不使用缓存
+func (c *ResponseCache) Do(client *http.Client, name string, request *http.Request) (*http.Response, error) {
+	path := c.path(name)
+	identity := requestIdentity(request)
+	entry := c.load(path, request.URL.String(), identity)
+	now := time.Now()
+	if entry != nil && now.Unix() < entry.FreshUntil {
+		c.use(name, entry, SourceFresh, nil)
+		return entry.response(request), nil
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			request.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			request.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	response, err := client.Do(request)
+	if err == nil && response.StatusCode == http.StatusOK {
+		var body []byte
+		body, err = io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err == nil {
+			response.Body = io.NopCloser(bytes.NewReader(body))
+			if resultOK(body) {
+				entry = &CachedResponse{
+					Name:         name,
+					URL:          request.URL.String(),
+					Identity:     identity,
+					ETag:         response.Header.Get("ETag"),
+					LastModified: response.Header.Get("Last-Modified"),
+					KeyVersion:   response.Header.Get(envelope.HeaderKeyVersion),
+					FetchedAt:    now.Unix(),
+					ValidatedAt:  now.Unix(),
+					FreshUntil:   freshUntil(response.Header, now),
+					Body:         body,
+				}
+				c.save(path, entry)
+				c.use(name, entry, SourceNetwork, nil)
+			}
+			return response, nil
+		}
+	}
+	if err == nil && response.StatusCode == http.StatusNotModified && entry != nil {
+		_ = response.Body.Close()
+		entry.ValidatedAt = now.Unix()
+		entry.FreshUntil = freshUntil(response.Header, now)
+		if etag := response.Header.Get("ETag"); etag != "" {
+			entry.ETag = etag
+		}
+		if keyVersion := response.Header.Get(envelope.HeaderKeyVersion); keyVersion != "" {
+			entry.KeyVersion = keyVersion
+		}
+		c.save(path, entry)
+		c.use(name, entry, SourceNotModified, nil)
+		return entry.response(request), nil
+	}
+	if err == nil && response.StatusCode < http.StatusInternalServerError {
+		return response, nil
+	}
+
+	// 无法访问平台,使用最后一次有效的数据
+	cause := err
+	if cause == nil {
+		cause = fmt.Errorf("response code=%d", response.StatusCode)
+	}
+	if entry != nil && c.maxStale > 0 && now.Sub(time.Unix(entry.ValidatedAt, 0)) <= c.maxStale {
+		if response != nil {
+			_ = response.Body.Close()
+		}
+		logger.Warningf("%v: platform unreachable (%v), use cached response validated at %v", name, cause,
+			time.Unix(entry.ValidatedAt, 0).Format(time.RFC3339))
+		c.use(name, entry, SourceOffline, cause)
+		return entry.response(request), nil
+	}
+	return response, err
+}
+
+// resultOK 平台返回的数据结果为成功时才缓存
+func resultOK(body []byte) bool {
+	msg := &tokenMessage{}
+	return json.Unmarshal(body, msg) == nil && msg.Result
+}
+
+// freshUntil 解析 Cache-Control 的 max-age,no-cache 或没有 max-age 时每次都需要向平台确认
+func freshUntil(header http.Header, now time.Time) int64 {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && seconds > 0 {
+				return now.Unix() + seconds
+			}
+		}
+	}
+	return 0
+}
+
+func (entry *CachedResponse) response(request *http.Request) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if entry.KeyVersion != "" {
+		header.Set(envelope.HeaderKeyVersion, entry.KeyVersion)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       request,
+	}
+}
+
+func (c *ResponseCache) use(name string, entry *CachedResponse, source ResponseSource, cause error) {
+	status := ResponseCacheStatus{
+		Name:        name,
+		URL:         entry.URL,
+		Source:      source,
+		UsedAt:      time.Now().Unix(),
+		FetchedAt:   entry.FetchedAt,
+		ValidatedAt: entry.ValidatedAt,
+	}
+	if cause != nil {
+		status.Error = cause.Error()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usages[name] = status
+}
+
+// useLocal 无法访问平台时使用本地保存的数据,数据没有超过 maxStale 时记录并返回 true
+func (c *ResponseCache) useLocal(name, path string, validatedAt time.Time, cause error) bool {
+	if c.maxStale <= 0 || time.Since(validatedAt) > c.maxStale {
+		return false
+	}
+	logger.Warningf("%v: platform unreachable (%v), use local data %v", name, cause, path)
+	c.use(name, &CachedResponse{URL: path, ValidatedAt: validatedAt.Unix()}, SourceOffline, cause)
+	return true
+}
+
+// Status 返回每种请求最近一次使用的数据及其时效
+func (c *ResponseCache) Status() PlatformDataStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var status PlatformDataStatus
+	now := time.Now().Unix()
+	for _, usage := range c.usages {
+		usage.Age = now - usage.ValidatedAt
+		if usage.Source == SourceOffline {
+			status.Offline = true
+		}
+		status.Requests = append(status.Requests, usage)
+	}
+	sort.Slice(status.Requests, func(i, j int) bool {
+		return status.Requests[i].Name < status.Requests[j].Name
+	})
+	return status
+}
+
+// Expire 忽略缓存的有效期,下次请求时向平台确认,平台通知数据变化时使用
+func (c *ResponseCache) Expire(name string) {
+	path := c.path(name)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var entry CachedResponse
+	if err := json.Unmarshal(content, &entry); err != nil || entry.FreshUntil == 0 {
+		return
+	}
+	entry.FreshUntil = 0
+	c.save(path, &entry)
+}
+
+// Clear 删除所有缓存,切换更新平台时使用
+func (c *ResponseCache) Clear() {
+	c.mu.Lock()
+	c.usages = make(map[string]ResponseCacheStatus)
+	c.mu.Unlock()
+	if err := os.RemoveAll(c.dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warning(err)
+	}
+}
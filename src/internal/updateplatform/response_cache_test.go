@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type policyServer struct {
+	body         string
+	etag         string
+	cacheControl string
+	requests     int
+	conditional  int
+	fail         bool
+}
+
+func (s *policyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	if s.fail {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	if s.cacheControl != "" {
+		w.Header().Set("Cache-Control", s.cacheControl)
+	}
+	w.Header().Set("ETag", s.etag)
+	if r.Header.Get("If-None-Match") == s.etag {
+		s.conditional++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_, _ = w.Write([]byte(s.body))
+}
+
+func doPolicyRequest(t *testing.T, cache *ResponseCache, url string) (string, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := cache.Do(&http.Client{Timeout: time.Second}, "version", request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", &statusError{response.StatusCode}
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), nil
+}
+
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return http.StatusText(e.code)
+}
+
+func lastSource(cache *ResponseCache) ResponseSource {
+	status := cache.Status()
+	if len(status.Requests) == 0 {
+		return ""
+	}
+	return status.Requests[0].Source
+}
+
+func TestResponseCacheConditional(t *testing.T) {
+	server := &policyServer{body: `{"result":true,"code":0,"data":{"v":1}}`, etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+
+	body, err := doPolicyRequest(t, cache, ts.URL+"/api/v1/version")
+	if err != nil || body != server.body {
+		t.Fatalf("first request: %q %v", body, err)
+	}
+	if source := lastSource(cache); source != SourceNetwork {
+		t.Errorf("source = %v, want %v", source, SourceNetwork)
+	}
+
+	// 平台返回 304 时使用缓存的数据
+	body, err = doPolicyRequest(t, cache, ts.URL+"/api/v1/version")
+	if err != nil || body != server.body {
+		t.Fatalf("conditional request: %q %v", body, err)
+	}
+	if server.conditional != 1 || lastSource(cache) != SourceNotModified {
+		t.Errorf("conditional = %v, source = %v", server.conditional, lastSource(cache))
+	}
+
+	// 策略变化后获取新数据
+	server.body = `{"result":true,"code":0,"data":{"v":2}}`
+	server.etag = `"v2"`
+	body, err = doPolicyRequest(t, cache, ts.URL+"/api/v1/version")
+	if err != nil || body != server.body {
+		t.Fatalf("changed request: %q %v", body, err)
+	}
+
+	// 请求地址不同时不使用缓存
+	server.requests = 0
+	server.conditional = 0
+	_, err = doPolicyRequest(t, cache, ts.URL+"/api/v1/version?baseline=other")
+	if err != nil || server.conditional != 0 {
+		t.Errorf("other url: conditional = %v, err = %v", server.conditional, err)
+	}
+}
+
+func TestResponseCacheOffline(t *testing.T) {
+	want := `{"result":true,"code":0,"data":{"v":1}}`
+	server := &policyServer{body: want, etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	url := ts.URL + "/api/v1/version"
+	dir := t.TempDir()
+	cache := NewResponseCache(dir, time.Hour)
+	if _, err := doPolicyRequest(t, cache, url); err != nil {
+		t.Fatal(err)
+	}
+
+	// 平台返回 5xx 时使用最后一次有效的数据
+	server.fail = true
+	body, err := doPolicyRequest(t, cache, url)
+	if err != nil || body != want {
+		t.Fatalf("5xx fallback: %q %v", body, err)
+	}
+	status := cache.Status()
+	if !status.Offline || status.Requests[0].Source != SourceOffline || status.Requests[0].Error == "" {
+		t.Errorf("status = %+v", status)
+	}
+
+	// 网络不可用时使用最后一次有效的数据
+	ts.Close()
+	body, err = doPolicyRequest(t, cache, url)
+	if err != nil || body != want {
+		t.Fatalf("network fallback: %q %v", body, err)
+	}
+
+	// 不允许使用过期数据时返回错误
+	_, err = doPolicyRequest(t, NewResponseCache(dir, 0), url)
+	if err == nil {
+		t.Error("expect error without fallback")
+	}
+}
+
+func TestResponseCacheNotCached(t *testing.T) {
+	server := &policyServer{body: `{"result":false,"code":416,"msg":"unregistered"}`, etag: `"e"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+
+	// 平台返回失败结果时不缓存
+	for i := 0; i < 2; i++ {
+		if _, err := doPolicyRequest(t, cache, ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if server.conditional != 0 || len(cache.Status().Requests) != 0 {
+		t.Errorf("failed result cached: conditional = %v", server.conditional)
+	}
+}
+
+func TestResponseCacheFresh(t *testing.T) {
+	server := &policyServer{body: `{"result":true,"code":0,"data":{}}`, etag: `"v1"`, cacheControl: "max-age=600"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if _, err := doPolicyRequest(t, cache, ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if server.requests != 1 || lastSource(cache) != SourceFresh {
+		t.Errorf("requests = %v, source = %v", server.requests, lastSource(cache))
+	}
+
+	cache.Clear()
+	if _, err := doPolicyRequest(t, cache, ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if server.requests != 2 {
+		t.Errorf("requests after clear = %v", server.requests)
+	}
+}
+
+func TestResponseCacheIdentity(t *testing.T) {
+	server := &policyServer{body: `{"result":true,"code":0,"data":{}}`, etag: `"v1"`, cacheControl: "max-age=600"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+
+	do := func(token string) {
+		request, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("X-Repo-Token", token)
+		response, err := cache.Do(&http.Client{Timeout: time.Second}, "version", request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+	}
+	do("a")
+	do("a")
+	if server.requests != 1 || lastSource(cache) != SourceFresh {
+		t.Errorf("requests = %v, source = %v", server.requests, lastSource(cache))
+	}
+	// token 变化后不使用缓存,也不发送条件请求
+	do("b")
+	if server.requests != 2 || server.conditional != 0 || lastSource(cache) != SourceNetwork {
+		t.Errorf("requests = %v, conditional = %v, source = %v", server.requests, server.conditional, lastSource(cache))
+	}
+}
+
+func TestResponseCacheExpire(t *testing.T) {
+	server := &policyServer{body: `{"result":true,"code":0,"data":{}}`, etag: `"v1"`, cacheControl: "max-age=600"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	cache := NewResponseCache(t.TempDir(), time.Hour)
+	if _, err := doPolicyRequest(t, cache, ts.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// 平台通知数据变化后,有效期内也向平台确认
+	cache.Expire("version")
+	if _, err := doPolicyRequest(t, cache, ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if server.requests != 2 || server.conditional != 1 || lastSource(cache) != SourceNotModified {
+		t.Errorf("requests = %v, conditional = %v, source = %v", server.requests, server.conditional, lastSource(cache))
+	}
+	cache.Expire("not-cached")
+}
+
+func TestFreshUntil(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for value, want := range map[string]int64{
+		"":                     0,
+		"max-age=60":           now.Unix() + 60,
+		"private, max-age=120": now.Unix() + 120,
+		"no-cache, max-age=60": 0,
+		"max-age=abc":          0,
+	} {
+		header := make(http.Header)
+		header.Set("Cache-Control", value)
+		if got := freshUntil(header, now); got != want {
+			t.Errorf("freshUntil(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+
+	Cfg "github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/supportbundle"
+)
+
+// SupportBundleRedactor 返回诊断包的脱敏规则,除通用规则外,按原文删除本机的设备标识、序列号、MAC 地址和 token
+func SupportBundleRedactor(c *Cfg.Config) *supportbundle.Redactor {
+	info := getSystemInfo(c.IncludeDiskInfo, c.GetHardwareIdByHelper)
+	token := info.token()
+	machineID, _ := os.ReadFile("/etc/machine-id")
+	return supportbundle.NewRedactor(
+		token,
+		base64.RawStdEncoding.EncodeToString([]byte(token)),
+		info.HardwareId,
+		info.SN,
+		info.Mac,
+		strings.TrimSpace(string(machineID)),
+	)
+}
+
+// CollectSupportBundleConfig 在诊断包中写入 lastore 配置和 dconfig 配置的值
+func CollectSupportBundleConfig(b *supportbundle.Bundle, c *Cfg.Config) {
+	b.AddJSON("config/config.json", "lastore config", c)
+	values, err := c.DSettingsValues()
+	if err != nil {
+		b.AddError("config/dconfig.json", "dconfig org.deepin.dde.lastore", err)
+		return
+	}
+	b.AddJSON("config/dconfig.json", "dconfig org.deepin.dde.lastore", values)
+}
+
+// CollectSupportBundle 在诊断包中写入更新平台数据的来源、平台地址和上报队列的状态
+func (m *UpdatePlatformManager) CollectSupportBundle(b *supportbundle.Bundle) {
+	b.AddJSON("platform/data-status.json", "GetPlatformDataStatus", m.GetPlatformDataStatus())
+	b.AddJSON("platform/endpoints-status.json", "GetPlatformEndpoints", m.GetPlatformEndpoints())
+	b.AddJSON("platform/upload-queue.json", "GetUploadQueue", m.GetOutboxStatus())
+}
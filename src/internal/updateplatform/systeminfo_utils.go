@@ -25,6 +25,7 @@ import (
 	"github.com/jouyouyun/hardware/utils"
 	utils2 "github.com/linuxdeepin/go-lib/utils"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
 
 	hhardware "github.com/jouyouyun/hardware"
 )
@@ -523,35 +524,17 @@ func getMachineType() string {
 
 var _tokenUpdateMu sync.Mutex
 
-// UpdateTokenConfigFile 更新 99lastore-token.conf 文件的内容
-func UpdateTokenConfigFile(includeDiskInfo bool, getHardwareIdByHelper bool) string {
-	logger.Infof("UpdateTokenConfigFile includeDiskInfo: %v, getHardwareIdByHelper: %v", includeDiskInfo, getHardwareIdByHelper)
+// UpdateTokenConfigFile 更新 99lastore-token.conf 文件的内容,token 中的字段按遥测策略删除或哈希
+func UpdateTokenConfigFile(includeDiskInfo bool, getHardwareIdByHelper bool, policy telemetry.Policy) string {
+	logger.Infof("UpdateTokenConfigFile includeDiskInfo: %v, getHardwareIdByHelper: %v, telemetry level: %v", includeDiskInfo, getHardwareIdByHelper, policy.Level)
 	logger.Debug("start updateTokenConfigFile")
 	_tokenUpdateMu.Lock()
 	defer _tokenUpdateMu.Unlock()
 	logger.Debug("start getSystemInfo")
-	systemInfo := getSystemInfo(includeDiskInfo, getHardwareIdByHelper)
+	systemInfo := getSystemInfo(includeDiskInfo, getHardwareIdByHelper).redact(policy)
 	logger.Debug("end getSystemInfo")
 	tokenPath := "/etc/apt/apt.conf.d/99lastore-token.conf"
-	var tokenSlice []string
-	tokenSlice = append(tokenSlice, "a="+systemInfo.SystemName)
-	tokenSlice = append(tokenSlice, "b="+systemInfo.ProductType)
-	tokenSlice = append(tokenSlice, "c="+systemInfo.EditionName)
-	tokenSlice = append(tokenSlice, "v="+systemInfo.Version)
-	tokenSlice = append(tokenSlice, "i="+systemInfo.HardwareId)
-	tokenSlice = append(tokenSlice, "m="+systemInfo.Processor)
-	tokenSlice = append(tokenSlice, "ac="+systemInfo.Arch)
-	tokenSlice = append(tokenSlice, "cu="+systemInfo.Custom)
-	tokenSlice = append(tokenSlice, "sn="+systemInfo.SN)
-	tokenSlice = append(tokenSlice, "vs="+systemInfo.HardwareVersion)
-	tokenSlice = append(tokenSlice, "oid="+systemInfo.OEMID)
-	tokenSlice = append(tokenSlice, "pid="+systemInfo.ProjectId)
-	tokenSlice = append(tokenSlice, "baseline="+systemInfo.Baseline)
-	tokenSlice = append(tokenSlice, "st="+systemInfo.SystemType)
-	tokenSlice = append(tokenSlice, "mt="+systemInfo.MachineType)
-	tokenSlice = append(tokenSlice, "mac="+systemInfo.Mac)
-	token := strings.Join(tokenSlice, ";")
-	token = strings.Replace(token, "\n", "", -1)
+	token := systemInfo.token()
 	tokenContent := []byte("Acquire::SmartMirrors::Token \"" + token + "\";\n")
 	existingContent, err := os.ReadFile(tokenPath)
 	if err == nil && bytes.Equal(existingContent, tokenContent) {
@@ -7,6 +7,7 @@ package updateplatform
 import (
 	"testing"
 
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,3 +47,32 @@ Destination     Gateway         Genmask         Flags Metric Ref    Use Iface
 		})
 	}
 }
+
+func TestSystemInfoToken(t *testing.T) {
+	info := SystemInfo{
+		SystemName:  "uos",
+		Version:     "20.100.1",
+		HardwareId:  "machine",
+		Processor:   "cpu",
+		Custom:      OemNotCustomState,
+		SN:          "PF2ABCD",
+		Baseline:    "1070",
+		MachineType: "desktop\n",
+		Mac:         "00:11:22:33:44:55",
+	}
+	assert.Equal(t, "a=uos;b=;c=;v=20.100.1;i=machine;m=cpu;ac=;cu=0;sn=PF2ABCD;vs=;oid=;pid=;baseline=1070;st=;mt=desktop;mac=00:11:22:33:44:55",
+		info.redact(telemetry.Policy{Level: telemetry.LevelFull}).token())
+
+	// 不上报的字段保留名称、值为空
+	assert.Equal(t, "a=uos;b=;c=;v=20.100.1;i=;m=;ac=;cu=0;sn=;vs=;oid=;pid=;baseline=1070;st=;mt=;mac=",
+		info.redact(telemetry.Policy{Level: telemetry.LevelNone}).token())
+
+	policy := telemetry.Policy{
+		Level:  telemetry.LevelFull,
+		Redact: map[string]telemetry.Action{telemetry.FieldSN: telemetry.ActionHash, telemetry.FieldMac: telemetry.ActionDrop},
+	}
+	redacted := info.redact(policy)
+	assert.Equal(t, telemetry.Hash("PF2ABCD"), redacted.SN)
+	assert.Empty(t, redacted.Mac)
+	assert.Equal(t, "PF2ABCD", info.SN)
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package updateplatform
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	Cfg "github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
+)
+
+var telemetryAudit = telemetry.NewAuditLog(telemetry.DefaultAuditFile)
+
+// TelemetryPolicy 根据配置返回遥测策略,配置无效时只上报获取更新所必需的信息
+func TelemetryPolicy(c *Cfg.Config) telemetry.Policy {
+	policy, err := telemetry.NewPolicy(c.TelemetryLevel, c.TelemetryRedact)
+	if err != nil {
+		logger.Warningf("%v, use telemetry level %v", err, policy.Level)
+	}
+	return policy
+}
+
+// RecordUpload 在上报记录中追加一次上报
+func RecordUpload(u telemetry.Upload) {
+	if err := telemetryAudit.Record(u); err != nil {
+		logger.Warning("record telemetry upload failed:", err)
+	}
+}
+
+type tokenField struct {
+	key   string // token 中的名称
+	name  string // 遥测字段名
+	value *string
+}
+
+func (info *SystemInfo) tokenFields() []tokenField {
+	return []tokenField{
+		{"a", telemetry.FieldSystemName, &info.SystemName},
+		{"b", telemetry.FieldProductType, &info.ProductType},
+		{"c", telemetry.FieldEditionName, &info.EditionName},
+		{"v", telemetry.FieldVersion, &info.Version},
+		{"i", telemetry.FieldHardwareId, &info.HardwareId},
+		{"m", telemetry.FieldProcessor, &info.Processor},
+		{"ac", telemetry.FieldArch, &info.Arch},
+		{"cu", telemetry.FieldCustom, &info.Custom},
+		{"sn", telemetry.FieldSN, &info.SN},
+		{"vs", telemetry.FieldHardwareVersion, &info.HardwareVersion},
+		{"oid", telemetry.FieldOEMID, &info.OEMID},
+		{"pid", telemetry.FieldProjectId, &info.ProjectId},
+		{"baseline", telemetry.FieldBaseline, &info.Baseline},
+		{"st", telemetry.FieldSystemType, &info.SystemType},
+		{"mt", telemetry.FieldMachineType, &info.MachineType},
+		{"mac", telemetry.FieldMac, &info.Mac},
+	}
+}
+
+// redact 按遥测策略删除或哈希字段,不上报的字段为空
+func (info SystemInfo) redact(policy telemetry.Policy) SystemInfo {
+	for _, f := range info.tokenFields() {
+		*f.value = policy.Value(f.name, *f.value)
+	}
+	return info
+}
+
+// token 生成 X-Repo-Token 的内容,不上报的字段保留名称、值为空,平台解析的格式不变
+func (info SystemInfo) token() string {
+	var tokenSlice []string
+	for _, f := range info.tokenFields() {
+		tokenSlice = append(tokenSlice, f.key+"="+*f.value)
+	}
+	return strings.Replace(strings.Join(tokenSlice, ";"), "\n", "", -1)
+}
+
+// TelemetryPreview 按当前遥测策略上报给更新平台的设备信息
+type TelemetryPreview struct {
+	Level     telemetry.Level
+	Fields    []telemetry.FieldStatus
+	Token     string            // X-Repo-Token 和 apt 请求携带的 Acquire::SmartMirrors::Token 的内容
+	Headers   map[string]string // 访问更新平台时携带的设备信息请求头
+	Hardware  json.RawMessage   `json:",omitempty"` // lastore-tools posthardware 上报的数据,只由 lastore-tools 生成
+	AuditFile string            // 上报记录文件
+}
+
+// PreviewTelemetry 生成按当前遥测策略上报的数据,不修改 token 文件
+func PreviewTelemetry(c *Cfg.Config) TelemetryPreview {
+	policy := TelemetryPolicy(c)
+	token := getSystemInfo(c.IncludeDiskInfo, c.GetHardwareIdByHelper).redact(policy).token()
+	preview := TelemetryPreview{
+		Level:  policy.Level,
+		Fields: policy.Fields(),
+		Token:  token,
+		Headers: map[string]string{
+			"X-Repo-Token": base64.RawStdEncoding.EncodeToString([]byte(token)),
+		},
+		AuditFile: telemetry.DefaultAuditFile,
+	}
+	if machineID := policy.Value(telemetry.FieldHardwareId, GetHardwareId(c.IncludeDiskInfo, c.GetHardwareIdByHelper)); machineID != "" {
+		preview.Headers["X-MachineID"] = machineID
+	}
+	return preview
+}
+
+// machineID 返回上报过程数据和更新结果时使用的设备标识,遥测策略不允许时为空
+func (m *UpdatePlatformManager) machineID() string {
+	return TelemetryPolicy(m.config).Value(telemetry.FieldHardwareId, GetHardwareId(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper))
+}
+
+// recordUpload 记录发送给更新平台的数据,payload 为加密、压缩前的数据
+func (m *UpdatePlatformManager) recordUpload(kind string, taskID int, request *http.Request, payload []byte, files []string) {
+	u := telemetry.NewUpload(kind, TelemetryPolicy(m.config).Level, request, payload)
+	u.TaskID = taskID
+	u.Files = files
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			u.Size += int(info.Size())
+		}
+	}
+	RecordUpload(u)
+}
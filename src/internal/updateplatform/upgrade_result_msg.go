@@ -109,6 +109,14 @@ func (u *UpgradePostMsg) updatePostStatus(postStatus MsgPostStatus) {
 	u.save()
 }
 
+// remove 更新结果转入上报队列后删除本地缓存
+func (u *UpgradePostMsg) remove() {
+	err := os.RemoveAll(filepath.Join(postContentCacheDir, u.Uuid))
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
 func (u *UpgradePostMsg) addFailedCount() {
 	u.RetryCount++
 	u.save()
@@ -0,0 +1,335 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package vulnscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FeedFormat 漏洞数据的格式
+type FeedFormat string
+
+const (
+	FormatOSV           FeedFormat = "osv"            // https://ossf.github.io/osv-schema/
+	FormatDebianTracker FeedFormat = "debian-tracker" // https://security-tracker.debian.org/tracker/data/json
+)
+
+// maxFeedFileSize 单个数据文件的最大长度,Debian 安全追踪器的完整导出约 50M
+const maxFeedFileSize = 512 * 1024 * 1024
+
+// Advisory 从漏洞数据中整理出的一条记录,只保留匹配需要的信息
+type Advisory struct {
+	ID       string
+	Aliases  []string
+	Summary  string
+	Severity string
+	Source   string // 源码包名
+	// Ranges 受影响的版本区间,任意一个区间命中即认为受影响
+	Ranges []VersionRange
+	// Versions 明确列出的受影响版本
+	Versions []string
+}
+
+// VersionRange 受影响的版本区间 [Introduced, Fixed) 或 [Introduced, LastAffected]
+type VersionRange struct {
+	Introduced   string // 空或 "0" 表示所有版本
+	Fixed        string
+	LastAffected string
+}
+
+// osvRecord OSV 格式中匹配需要的字段
+type osvRecord struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases"`
+	Summary  string   `json:"summary"`
+	Details  string   `json:"details"`
+	Severity []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string              `json:"type"`
+			Events []map[string]string `json:"events"`
+		} `json:"ranges"`
+		Versions          []string               `json:"versions"`
+		EcosystemSpecific map[string]interface{} `json:"ecosystem_specific"`
+		DatabaseSpecific  map[string]interface{} `json:"database_specific"`
+	} `json:"affected"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific"`
+}
+
+// trackerIssue Debian 安全追踪器导出数据中的一条记录,
+// 结构为 {"<源码包>": {"<CVE>": trackerIssue}}
+type trackerIssue struct {
+	Description string `json:"description"`
+	Releases    map[string]struct {
+		Status       string `json:"status"`
+		FixedVersion string `json:"fixed_version"`
+		Urgency      string `json:"urgency"`
+	} `json:"releases"`
+}
+
+// Feed 加载后的漏洞数据
+type Feed struct {
+	Files      []string
+	Advisories []Advisory
+}
+
+// LoadOptions 加载漏洞数据的选项
+type LoadOptions struct {
+	// Release Debian 安全追踪器数据中使用的发行版代号,如 bookworm
+	Release string
+	// Ecosystem 只使用 OSV 数据中 ecosystem 以此为前缀的条目,为空时不过滤
+	Ecosystem string
+}
+
+// LoadFeed 加载漏洞数据,path 可以是单个文件,也可以是包含多个 json 文件的目录
+func LoadFeed(path string, opts LoadOptions) (*Feed, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	} else {
+		files = []string{path}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no vulnerability feed found in %s", path)
+	}
+	feed := &Feed{}
+	for _, file := range files {
+		advisories, err := loadFeedFile(file, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", file, err)
+		}
+		feed.Files = append(feed.Files, file)
+		feed.Advisories = append(feed.Advisories, advisories...)
+	}
+	return feed, nil
+}
+
+func loadFeedFile(file string, opts LoadOptions) ([]Advisory, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxFeedFileSize {
+		return nil, fmt.Errorf("feed file too large: %d bytes", info.Size())
+	}
+	data, err := os.ReadFile(file) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	return ParseFeed(data, opts)
+}
+
+// ParseFeed 根据内容识别数据格式并解析
+func ParseFeed(data []byte, opts LoadOptions) ([]Advisory, error) {
+	format, err := detectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatOSV:
+		return parseOSV(data, opts)
+	default:
+		if opts.Release == "" {
+			return nil, errors.New("release is required for debian security tracker data")
+		}
+		return parseDebianTracker(data, opts.Release)
+	}
+}
+
+// detectFormat OSV 数据为单个对象或对象数组,且包含 id 和 affected 字段;
+// Debian 安全追踪器数据为以源码包名为键的对象
+func detectFormat(data []byte) (FeedFormat, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "", errors.New("empty feed")
+	}
+	if data[0] == '[' {
+		return FormatOSV, nil
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return "", err
+	}
+	if _, ok := probe["affected"]; ok {
+		return FormatOSV, nil
+	}
+	if _, ok := probe["id"]; ok {
+		return FormatOSV, nil
+	}
+	return FormatDebianTracker, nil
+}
+
+func parseOSV(data []byte, opts LoadOptions) ([]Advisory, error) {
+	var records []osvRecord
+	data = bytes.TrimSpace(data)
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, err
+		}
+	} else {
+		var record osvRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	var advisories []Advisory
+	for _, record := range records {
+		summary := record.Summary
+		if summary == "" {
+			summary = firstLine(record.Details)
+		}
+		for _, affected := range record.Affected {
+			if affected.Package.Name == "" {
+				continue
+			}
+			if opts.Ecosystem != "" && !strings.HasPrefix(affected.Package.Ecosystem, opts.Ecosystem) {
+				continue
+			}
+			adv := Advisory{
+				ID:       record.ID,
+				Aliases:  record.Aliases,
+				Summary:  summary,
+				Source:   affected.Package.Name,
+				Versions: affected.Versions,
+				Severity: osvSeverity(record, affected.EcosystemSpecific, affected.DatabaseSpecific),
+			}
+			for _, r := range affected.Ranges {
+				// GIT 类型的区间使用提交号,无法和 Debian 版本比较
+				if r.Type != "ECOSYSTEM" {
+					continue
+				}
+				adv.Ranges = append(adv.Ranges, osvEventsToRanges(r.Events)...)
+			}
+			if len(adv.Ranges) == 0 && len(adv.Versions) == 0 {
+				continue
+			}
+			advisories = append(advisories, adv)
+		}
+	}
+	return advisories, nil
+}
+
+// osvEventsToRanges 将按顺序排列的 introduced/fixed/last_affected 事件转换为区间
+func osvEventsToRanges(events []map[string]string) []VersionRange {
+	var ranges []VersionRange
+	var cur *VersionRange
+	for _, event := range events {
+		if v, ok := event["introduced"]; ok {
+			if cur != nil {
+				ranges = append(ranges, *cur)
+			}
+			cur = &VersionRange{Introduced: v}
+			continue
+		}
+		if cur == nil {
+			cur = &VersionRange{}
+		}
+		if v, ok := event["fixed"]; ok {
+			cur.Fixed = v
+		} else if v, ok := event["last_affected"]; ok {
+			cur.LastAffected = v
+		} else {
+			continue
+		}
+		ranges = append(ranges, *cur)
+		cur = nil
+	}
+	if cur != nil {
+		ranges = append(ranges, *cur)
+	}
+	return ranges
+}
+
+// osvSeverity Debian 和 Ubuntu 的 OSV 数据在 ecosystem_specific 或 database_specific 中提供 urgency/severity,
+// 没有时使用 CVSS 向量
+func osvSeverity(record osvRecord, specifics ...map[string]interface{}) string {
+	specifics = append(specifics, record.DatabaseSpecific)
+	for _, specific := range specifics {
+		for _, key := range []string{"urgency", "severity"} {
+			if v, ok := specific[key].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+	if len(record.Severity) > 0 {
+		return record.Severity[0].Score
+	}
+	return ""
+}
+
+func parseDebianTracker(data []byte, release string) ([]Advisory, error) {
+	var tracker map[string]map[string]trackerIssue
+	if err := json.Unmarshal(data, &tracker); err != nil {
+		return nil, err
+	}
+	var advisories []Advisory
+	for source, issues := range tracker {
+		for id, issue := range issues {
+			rel, ok := issue.Releases[release]
+			if !ok {
+				continue
+			}
+			adv := Advisory{
+				ID:       id,
+				Summary:  firstLine(issue.Description),
+				Severity: strings.TrimRight(rel.Urgency, "*"),
+				Source:   source,
+			}
+			switch rel.Status {
+			case "open":
+				adv.Ranges = []VersionRange{{}}
+			case "resolved":
+				// fixed_version 为 0 表示该发行版不受影响
+				if rel.FixedVersion == "" || rel.FixedVersion == "0" {
+					continue
+				}
+				adv.Ranges = []VersionRange{{Fixed: rel.FixedVersion}}
+			default:
+				// undetermined 等状态无法判断是否受影响
+				continue
+			}
+			advisories = append(advisories, adv)
+		}
+	}
+	sort.Slice(advisories, func(i, j int) bool {
+		if advisories[i].Source != advisories[j].Source {
+			return advisories[i].Source < advisories[j].Source
+		}
+		return advisories[i].ID < advisories[j].ID
+	})
+	return advisories, nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
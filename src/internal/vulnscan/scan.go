@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package vulnscan 使用离线导入的漏洞数据(OSV 或 Debian 安全追踪器导出)扫描已安装的包,
+// 不依赖更新平台下发的 CVE 信息
+package vulnscan
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	debVersion "pault.ag/go/debian/version"
+)
+
+const (
+	// DefaultFeedPath 默认的漏洞数据目录,管理员将导出的 json 文件放到该目录下
+	DefaultFeedPath = "/var/lib/lastore/vulnfeed"
+	osReleasePath   = "/etc/os-release"
+)
+
+// InstalledPackage dpkg 中已安装的包
+type InstalledPackage struct {
+	Name          string
+	Version       string
+	Source        string
+	SourceVersion string
+}
+
+// Finding 一个已安装的包受一条漏洞记录影响
+type Finding struct {
+	ID               string
+	Aliases          []string `json:",omitempty"`
+	Summary          string
+	Severity         string
+	Package          string
+	InstalledVersion string
+	Source           string
+	SourceVersion    string
+	FixedVersion     string // 为空表示还没有修复版本
+}
+
+// Report 扫描结果
+type Report struct {
+	ScanTime   time.Time
+	Feeds      []string
+	Release    string
+	Advisories int // 加载的漏洞记录数量
+	Packages   int // 扫描的已安装包数量
+	Findings   []Finding
+}
+
+// LoadInstalledPackages 读取 dpkg 中已安装的包及其源码包版本
+func LoadInstalledPackages() ([]InstalledPackage, error) {
+	out, err := exec.Command("dpkg-query", "-W", "-f",
+		"${db:Status-Abbrev}\t${Package}\t${Version}\t${source:Package}\t${source:Version}\n").Output() // #nosec G204
+	if err != nil {
+		return nil, err
+	}
+	return parseDpkgQuery(out), nil
+}
+
+func parseDpkgQuery(out []byte) []InstalledPackage {
+	var pkgs []InstalledPackage
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		// db:Status-Abbrev 第二个字符为 i 时表示已安装
+		status := fields[0]
+		if len(status) < 2 || status[1] != 'i' {
+			continue
+		}
+		pkg := InstalledPackage{
+			Name:          fields[1],
+			Version:       fields[2],
+			Source:        fields[3],
+			SourceVersion: fields[4],
+		}
+		if pkg.Source == "" {
+			pkg.Source = pkg.Name
+		}
+		if pkg.SourceVersion == "" {
+			pkg.SourceVersion = pkg.Version
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// DefaultRelease 返回当前系统的发行版代号,用于匹配 Debian 安全追踪器数据
+func DefaultRelease() string {
+	data, err := os.ReadFile(osReleasePath)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && key == "VERSION_CODENAME" {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+func compareVersion(a, b string) (int, bool) {
+	va, err := debVersion.Parse(a)
+	if err != nil {
+		return 0, false
+	}
+	vb, err := debVersion.Parse(b)
+	if err != nil {
+		return 0, false
+	}
+	return debVersion.Compare(va, vb), true
+}
+
+// affects 判断 version 是否在受影响的版本中,返回对应的修复版本
+func (a *Advisory) affects(version string) (bool, string) {
+	for _, v := range a.Versions {
+		if c, ok := compareVersion(version, v); ok && c == 0 {
+			return true, ""
+		}
+	}
+	for _, r := range a.Ranges {
+		if r.Introduced != "" && r.Introduced != "0" {
+			if c, ok := compareVersion(version, r.Introduced); !ok || c < 0 {
+				continue
+			}
+		}
+		if r.Fixed != "" {
+			if c, ok := compareVersion(version, r.Fixed); !ok || c >= 0 {
+				continue
+			}
+			return true, r.Fixed
+		}
+		if r.LastAffected != "" {
+			if c, ok := compareVersion(version, r.LastAffected); !ok || c > 0 {
+				continue
+			}
+		}
+		return true, ""
+	}
+	return false, ""
+}
+
+// Scan 使用漏洞数据匹配已安装的包,漏洞数据中的包名和版本为源码包的名称和版本
+func Scan(advisories []Advisory, pkgs []InstalledPackage) []Finding {
+	bySource := make(map[string][]*Advisory)
+	for i := range advisories {
+		adv := &advisories[i]
+		bySource[adv.Source] = append(bySource[adv.Source], adv)
+	}
+	seen := make(map[string]bool)
+	findings := []Finding{}
+	for _, pkg := range pkgs {
+		for _, adv := range bySource[pkg.Source] {
+			affected, fixed := adv.affects(pkg.SourceVersion)
+			if !affected {
+				continue
+			}
+			// 多个数据文件中可能包含相同的记录
+			key := adv.ID + "\x00" + pkg.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			findings = append(findings, Finding{
+				ID:               adv.ID,
+				Aliases:          adv.Aliases,
+				Summary:          adv.Summary,
+				Severity:         adv.Severity,
+				Package:          pkg.Name,
+				InstalledVersion: pkg.Version,
+				Source:           pkg.Source,
+				SourceVersion:    pkg.SourceVersion,
+				FixedVersion:     fixed,
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].ID != findings[j].ID {
+			return findings[i].ID < findings[j].ID
+		}
+		return findings[i].Package < findings[j].Package
+	})
+	return findings
+}
+
+// ScanSystem 加载 feedPath 中的漏洞数据并扫描当前系统
+func ScanSystem(feedPath string, opts LoadOptions) (*Report, error) {
+	if feedPath == "" {
+		feedPath = DefaultFeedPath
+	}
+	if opts.Release == "" {
+		opts.Release = DefaultRelease()
+	}
+	feed, err := LoadFeed(feedPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := LoadInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		ScanTime:   time.Now(),
+		Feeds:      feed.Files,
+		Release:    opts.Release,
+		Advisories: len(feed.Advisories),
+		Packages:   len(pkgs),
+		Findings:   Scan(feed.Advisories, pkgs),
+	}, nil
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package vulnscan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOSV = `[{
+  "id": "DSA-5621-1",
+  "aliases": ["CVE-2024-0727"],
+  "summary": "openssl - security update",
+  "affected": [{
+    "package": {"ecosystem": "Debian:12", "name": "openssl"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.13-1~deb12u1"}]}],
+    "ecosystem_specific": {"urgency": "medium"}
+  }, {
+    "package": {"ecosystem": "Ubuntu:22.04:LTS", "name": "openssl"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.2-0ubuntu1.14"}]}]
+  }]
+}, {
+  "id": "DLA-0001-1",
+  "details": "zlib - range introduced later\nmore details",
+  "affected": [{
+    "package": {"ecosystem": "Debian:12", "name": "zlib"},
+    "ranges": [
+      {"type": "GIT", "events": [{"introduced": "abcdef"}]},
+      {"type": "ECOSYSTEM", "events": [{"introduced": "1:1.2.13"}, {"last_affected": "1:1.2.13.dfsg-1"}]}
+    ]
+  }]
+}]`
+
+const testTracker = `{
+  "openssl": {
+    "CVE-2024-0727": {
+      "description": "Issue summary: Processing a maliciously formatted PKCS12 file",
+      "releases": {
+        "bookworm": {"status": "resolved", "fixed_version": "3.0.13-1~deb12u1", "urgency": "low"},
+        "bullseye": {"status": "resolved", "fixed_version": "1.1.1w-0+deb11u2", "urgency": "low"}
+      }
+    },
+    "CVE-2010-0001": {
+      "releases": {"bookworm": {"status": "resolved", "fixed_version": "0", "urgency": "unimportant"}}
+    }
+  },
+  "curl": {
+    "CVE-2024-9999": {
+      "description": "open issue",
+      "releases": {"bookworm": {"status": "open", "urgency": "medium**"}}
+    },
+    "CVE-2024-9998": {
+      "releases": {"bookworm": {"status": "undetermined"}}
+    }
+  }
+}`
+
+var testPackages = []InstalledPackage{
+	{Name: "libssl3", Version: "3.0.11-1~deb12u2", Source: "openssl", SourceVersion: "3.0.11-1~deb12u2"},
+	{Name: "openssl", Version: "3.0.13-1~deb12u1", Source: "openssl", SourceVersion: "3.0.13-1~deb12u1"},
+	{Name: "zlib1g", Version: "1:1.2.13.dfsg-1", Source: "zlib", SourceVersion: "1:1.2.13.dfsg-1"},
+	{Name: "libcurl4", Version: "7.88.1-10+deb12u5", Source: "curl", SourceVersion: "7.88.1-10+deb12u5"},
+}
+
+func TestScanOSV(t *testing.T) {
+	advisories, err := ParseFeed([]byte(testOSV), LoadOptions{Ecosystem: "Debian"})
+	require.NoError(t, err)
+	require.Len(t, advisories, 2)
+	assert.Equal(t, "zlib - range introduced later", advisories[1].Summary)
+
+	findings := Scan(advisories, testPackages)
+	require.Len(t, findings, 2)
+	assert.Equal(t, Finding{
+		ID:               "DLA-0001-1",
+		Summary:          "zlib - range introduced later",
+		Package:          "zlib1g",
+		InstalledVersion: "1:1.2.13.dfsg-1",
+		Source:           "zlib",
+		SourceVersion:    "1:1.2.13.dfsg-1",
+	}, findings[0])
+	assert.Equal(t, "DSA-5621-1", findings[1].ID)
+	assert.Equal(t, "libssl3", findings[1].Package)
+	assert.Equal(t, "3.0.13-1~deb12u1", findings[1].FixedVersion)
+	assert.Equal(t, "medium", findings[1].Severity)
+	assert.Equal(t, []string{"CVE-2024-0727"}, findings[1].Aliases)
+}
+
+func TestScanDebianTracker(t *testing.T) {
+	_, err := ParseFeed([]byte(testTracker), LoadOptions{})
+	assert.Error(t, err)
+
+	advisories, err := ParseFeed([]byte(testTracker), LoadOptions{Release: "bookworm"})
+	require.NoError(t, err)
+	require.Len(t, advisories, 2)
+
+	findings := Scan(advisories, testPackages)
+	require.Len(t, findings, 2)
+	assert.Equal(t, "CVE-2024-0727", findings[0].ID)
+	assert.Equal(t, "libssl3", findings[0].Package)
+	assert.Equal(t, "low", findings[0].Severity)
+	assert.Equal(t, "CVE-2024-9999", findings[1].ID)
+	assert.Equal(t, "libcurl4", findings[1].Package)
+	assert.Equal(t, "", findings[1].FixedVersion)
+	assert.Equal(t, "medium", findings[1].Severity)
+}
+
+func TestLoadFeedDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "osv.json"), []byte(testOSV), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tracker.json"), []byte(testTracker), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0644))
+
+	feed, err := LoadFeed(dir, LoadOptions{Release: "bookworm", Ecosystem: "Debian"})
+	require.NoError(t, err)
+	assert.Len(t, feed.Files, 2)
+	assert.Len(t, feed.Advisories, 4)
+
+	_, err = LoadFeed(t.TempDir(), LoadOptions{})
+	assert.Error(t, err)
+}
+
+func TestParseDpkgQuery(t *testing.T) {
+	out := "ii \tlibssl3\t3.0.11-1\topenssl\t3.0.11-1\n" +
+		"rc \told-pkg\t1.0\told-pkg\t1.0\n" +
+		"ii \tbash\t5.2.15-2+b2\t\t\n" +
+		"broken line\n"
+	pkgs := parseDpkgQuery([]byte(out))
+	assert.Equal(t, []InstalledPackage{
+		{Name: "libssl3", Version: "3.0.11-1", Source: "openssl", SourceVersion: "3.0.11-1"},
+		{Name: "bash", Version: "5.2.15-2+b2", Source: "bash", SourceVersion: "5.2.15-2+b2"},
+	}, pkgs)
+}
@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+const (
+	dbusServiceName      = "org.deepin.dde.Lastore1"
+	dbusPath             = "/org/deepin/dde/Lastore1"
+	dbusInterfaceManager = "org.deepin.dde.Lastore1.Manager"
+	dbusInterfaceUpdater = "org.deepin.dde.Lastore1.Updater"
+	dbusInterfaceJob     = "org.deepin.dde.Lastore1.Job"
+)
+
+// lastoreClient 直接使用 D-Bus 调用 lastore-daemon,不依赖 go-dbus-factory 中生成的接口,
+// 以便使用 DistUpgradePartly 等较新的方法
+type lastoreClient struct {
+	conn *dbus.Conn
+	obj  dbus.BusObject
+}
+
+func newLastoreClient() (*lastoreClient, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, wrapDBusError(err)
+	}
+	return &lastoreClient{
+		conn: conn,
+		obj:  conn.Object(dbusServiceName, dbusPath),
+	}, nil
+}
+
+func (c *lastoreClient) callManager(method string, ret interface{}, args ...interface{}) error {
+	call := c.obj.Call(dbusInterfaceManager+"."+method, 0, args...)
+	if call.Err != nil {
+		return wrapDBusError(call.Err)
+	}
+	if ret == nil {
+		return nil
+	}
+	return wrapDBusError(call.Store(ret))
+}
+
+func (c *lastoreClient) callUpdater(method string, ret []interface{}, args ...interface{}) error {
+	call := c.obj.Call(dbusInterfaceUpdater+"."+method, 0, args...)
+	if call.Err != nil {
+		return wrapDBusError(call.Err)
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return wrapDBusError(call.Store(ret...))
+}
+
+func (c *lastoreClient) property(iface, name string, ret interface{}) error {
+	v, err := c.obj.GetProperty(iface + "." + name)
+	if err != nil {
+		return wrapDBusError(err)
+	}
+	return wrapDBusError(v.Store(ret))
+}
+
+func (c *lastoreClient) UpdateSource() (dbus.ObjectPath, error) {
+	var job dbus.ObjectPath
+	err := c.callManager("UpdateSource", &job)
+	return job, err
+}
+
+func (c *lastoreClient) PrepareDistUpgradePartly(mode system.UpdateType) (dbus.ObjectPath, error) {
+	var job dbus.ObjectPath
+	err := c.callManager("PrepareDistUpgradePartly", &job, mode)
+	return job, err
+}
+
+func (c *lastoreClient) DistUpgradePartly(mode system.UpdateType, needBackup bool) (dbus.ObjectPath, error) {
+	var job dbus.ObjectPath
+	err := c.callManager("DistUpgradePartly", &job, mode, needBackup)
+	return job, err
+}
+
+func (c *lastoreClient) PauseJob(jobId string) error {
+	return c.callManager("PauseJob", nil, jobId)
+}
+
+func (c *lastoreClient) StartJob(jobId string) error {
+	return c.callManager("StartJob", nil, jobId)
+}
+
+func (c *lastoreClient) CleanJob(jobId string) error {
+	return c.callManager("CleanJob", nil, jobId)
+}
+
+func (c *lastoreClient) GetHistoryLogs() (string, error) {
+	var logs string
+	err := c.callManager("GetHistoryLogs", &logs)
+	return logs, err
+}
+
+func (c *lastoreClient) CanRollback() (bool, string, error) {
+	var can bool
+	var info string
+	err := c.callUpdater("CanRollback", []interface{}{&can, &info})
+	return can, info, err
+}
+
+func (c *lastoreClient) ConfirmRollback(confirm bool) error {
+	return c.callUpdater("ConfirmRollback", nil, confirm)
+}
+
+func (c *lastoreClient) JobList() ([]dbus.ObjectPath, error) {
+	var list []dbus.ObjectPath
+	err := c.property(dbusInterfaceManager, "JobList", &list)
+	return list, err
+}
+
+func (c *lastoreClient) UpdateStatus() (string, error) {
+	var status string
+	err := c.property(dbusInterfaceManager, "UpdateStatus", &status)
+	return status, err
+}
+
+func (c *lastoreClient) UpdateMode() (system.UpdateType, error) {
+	var mode system.UpdateType
+	err := c.property(dbusInterfaceManager, "UpdateMode", &mode)
+	return mode, err
+}
+
+func (c *lastoreClient) SystemOnChanging() (bool, error) {
+	var changing bool
+	err := c.property(dbusInterfaceManager, "SystemOnChanging", &changing)
+	return changing, err
+}
+
+func (c *lastoreClient) ClassifiedUpdatablePackages() (map[string][]string, error) {
+	var pkgs map[string][]string
+	err := c.property(dbusInterfaceUpdater, "ClassifiedUpdatablePackages", &pkgs)
+	return pkgs, err
+}
+
+// jobInfo 任务属性快照
+type jobInfo struct {
+	Path        dbus.ObjectPath `json:"path"`
+	Id          string          `json:"id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Status      string          `json:"status"`
+	Progress    float64         `json:"progress"`
+	Speed       int64           `json:"speed"`
+	Cancelable  bool            `json:"cancelable"`
+	CreateTime  int64           `json:"create_time"`
+	Description string          `json:"description,omitempty"`
+	Packages    []string        `json:"packages,omitempty"`
+}
+
+func (c *lastoreClient) JobInfo(path dbus.ObjectPath) (*jobInfo, error) {
+	obj := c.conn.Object(dbusServiceName, path)
+	var props map[string]dbus.Variant
+	err := obj.Call("org.freedesktop.DBus.Properties.GetAll", 0, dbusInterfaceJob).Store(&props)
+	if err != nil {
+		return nil, wrapDBusError(err)
+	}
+	info := &jobInfo{Path: path}
+	for key, ptr := range map[string]interface{}{
+		"Id":          &info.Id,
+		"Name":        &info.Name,
+		"Type":        &info.Type,
+		"Status":      &info.Status,
+		"Progress":    &info.Progress,
+		"Speed":       &info.Speed,
+		"Cancelable":  &info.Cancelable,
+		"CreateTime":  &info.CreateTime,
+		"Description": &info.Description,
+		"Packages":    &info.Packages,
+	} {
+		if v, ok := props[key]; ok {
+			_ = v.Store(ptr)
+		}
+	}
+	return info, nil
+}
+
+// findJob 根据任务 id 或 D-Bus 路径查找任务
+func (c *lastoreClient) findJob(idOrPath string) (*jobInfo, error) {
+	if dbus.ObjectPath(idOrPath).IsValid() && len(idOrPath) > len(dbusPath) {
+		return c.JobInfo(dbus.ObjectPath(idOrPath))
+	}
+	list, err := c.JobList()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		info, err := c.JobInfo(p)
+		if err != nil {
+			continue
+		}
+		if info.Id == idOrPath {
+			return info, nil
+		}
+	}
+	return nil, &cliError{Code: exitUsage, Err: system.NotFoundError("job " + idOrPath)}
+}
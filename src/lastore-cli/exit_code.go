@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// 退出码,脚本可以根据退出码区分失败原因
+const (
+	exitOK              = 0
+	exitFailed          = 1  // 未分类的错误
+	exitUsage           = 2  // 参数错误
+	exitDaemon          = 3  // 无法连接 lastore-daemon 或调用失败
+	exitPermission      = 4  // 没有权限
+	exitPaused          = 5  // 任务被暂停
+	exitNetwork         = 10 // 网络或仓库索引下载失败
+	exitNoSpace         = 11 // 磁盘空间不足
+	exitDependency      = 12 // 依赖错误或找不到包
+	exitDpkg            = 13 // dpkg 执行失败或包损坏
+	exitUntrustedSource = 14 // 仓库配置错误、包或检查脚本未认证
+	exitCheckFailed     = 15 // 更新前后的检查项失败
+	exitImmutable       = 16 // 不可变系统刷新失败
+)
+
+const exitCodeHelp = `EXIT CODES:
+    0   success
+    1   unclassified error
+    2   invalid arguments
+    3   lastore-daemon unreachable
+    4   permission denied
+    5   job paused
+    10  network failure (fetchFailed, IndexDownloadFailed, platformUnreachable)
+    11  insufficient disk space
+    12  dependency problem or package not found
+    13  dpkg failure or damaged package
+    14  invalid sources list, unauthenticated packages, missing repository key or untrusted check script
+    15  pre/post update check failed or new kernel not bootable
+    16  immutable system refresh failed
+`
+
+var jobErrorExitCodes = map[system.JobErrorType]int{
+	system.ErrorFetchFailed:             exitNetwork,
+	system.ErrorIndexDownloadFailed:     exitNetwork,
+	system.ErrorPlatformUnreachable:     exitNetwork,
+	system.ErrorInsufficientSpace:       exitNoSpace,
+	system.ErrorCheckSysDiskOutSpace:    exitNoSpace,
+	system.ErrorDependenciesBroken:      exitDependency,
+	system.ErrorUnmetDependencies:       exitDependency,
+	system.ErrorNoInstallationCandidate: exitDependency,
+	system.ErrorPkgNotFound:             exitDependency,
+	system.ErrorDpkgError:               exitDpkg,
+	system.ErrorDpkgInterrupted:         exitDpkg,
+	system.ErrorDamagePackage:           exitDpkg,
+	system.ErrorIO:                      exitDpkg,
+	system.ErrorInvalidSourcesList:      exitUntrustedSource,
+	system.ErrorUnauthenticatedPackages: exitUntrustedSource,
+	system.ErrorMissingRepoKey:          exitUntrustedSource,
+	system.ErrorUntrustedCheckScript:    exitUntrustedSource,
+	system.ErrorOperationNotPermitted:   exitPermission,
+	system.ErrorImmutableRefreshFailed:  exitImmutable,
+	system.ErrorKernelNotBootable:       exitCheckFailed,
+}
+
+// exitCodeOfJobError 返回 JobErrorType 对应的退出码,检查项相关的错误统一为 exitCheckFailed
+func exitCodeOfJobError(errType system.JobErrorType) int {
+	if code, ok := jobErrorExitCodes[errType]; ok {
+		return code
+	}
+	s := errType.String()
+	if strings.HasPrefix(s, "ErrorCheck") || strings.HasSuffix(s, "CheckScriptsFailed") ||
+		errType == system.ErrorProgressCheck || errType == system.ErrorMissCoreFile || errType == system.ErrorNeedCheck {
+		return exitCheckFailed
+	}
+	return exitFailed
+}
+
+// cliError 携带退出码的错误,JobErr 非空时表示任务失败
+type cliError struct {
+	Code   int
+	Err    error
+	JobErr *system.JobError
+}
+
+func (e *cliError) Error() string {
+	if e.JobErr != nil {
+		if e.JobErr.ErrDetail != "" {
+			return string(e.JobErr.ErrType) + ": " + e.JobErr.ErrDetail
+		}
+		return string(e.JobErr.ErrType)
+	}
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func newUsageError(err error) error {
+	return &cliError{Code: exitUsage, Err: err}
+}
+
+// parseJobError lastore-daemon 会把 JobError 序列化成 json 放到任务描述或 D-Bus 错误信息中
+func parseJobError(s string) *system.JobError {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return nil
+	}
+	var jobErr system.JobError
+	if err := json.Unmarshal([]byte(s), &jobErr); err != nil || jobErr.ErrType == "" {
+		return nil
+	}
+	return &jobErr
+}
+
+func newJobError(jobErr *system.JobError) error {
+	return &cliError{Code: exitCodeOfJobError(jobErr.ErrType), JobErr: jobErr}
+}
+
+// wrapDBusError 将 D-Bus 调用错误转换为带退出码的错误
+func wrapDBusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *cliError
+	if errors.As(err, &ce) {
+		return err
+	}
+	var busErr dbus.Error
+	if errors.As(err, &busErr) {
+		if len(busErr.Body) > 0 {
+			if msg, ok := busErr.Body[0].(string); ok {
+				if jobErr := parseJobError(msg); jobErr != nil {
+					return newJobError(jobErr)
+				}
+			}
+		}
+		switch {
+		case strings.HasSuffix(busErr.Name, ".AccessDenied"), strings.Contains(busErr.Name, "NotAuthorized"):
+			return &cliError{Code: exitPermission, Err: err}
+		case strings.HasSuffix(busErr.Name, ".ServiceUnknown"), strings.HasSuffix(busErr.Name, ".NoReply"),
+			strings.HasSuffix(busErr.Name, ".UnknownObject"), strings.HasSuffix(busErr.Name, ".UnknownMethod"):
+			return &cliError{Code: exitDaemon, Err: err}
+		}
+		return &cliError{Code: exitFailed, Err: err}
+	}
+	return &cliError{Code: exitDaemon, Err: err}
+}
+
+func exitCodeOf(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ce *cliError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return exitFailed
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCodeOfJobError(t *testing.T) {
+	tests := map[system.JobErrorType]int{
+		system.ErrorFetchFailed:                    exitNetwork,
+		system.ErrorInsufficientSpace:              exitNoSpace,
+		system.ErrorUnmetDependencies:              exitDependency,
+		system.ErrorDamagePackage:                  exitDpkg,
+		system.ErrorInvalidSourcesList:             exitUntrustedSource,
+		system.ErrorMissingRepoKey:                 exitUntrustedSource,
+		system.ErrorUntrustedCheckScript:           exitUntrustedSource,
+		system.ErrorPreUpdateCheckScriptsFailed:    exitCheckFailed,
+		system.ErrorCheckPkgVersion:                exitCheckFailed,
+		system.ErrorImmutableRefreshFailed:         exitImmutable,
+		system.ErrorUnknown:                        exitFailed,
+		system.JobErrorType("someNewErrorType"):    exitFailed,
+		system.ErrorPostDownloadCheckScriptsFailed: exitCheckFailed,
+	}
+	for errType, code := range tests {
+		assert.Equal(t, code, exitCodeOfJobError(errType), errType)
+	}
+}
+
+func TestParseJobError(t *testing.T) {
+	jobErr := parseJobError(`{"ErrType":"insufficientSpace","ErrDetail":"You don't have enough free space to download","IsCheckError":true}`)
+	require.NotNil(t, jobErr)
+	assert.Equal(t, system.ErrorInsufficientSpace, jobErr.ErrType)
+	assert.True(t, jobErr.IsCheckError)
+
+	assert.Nil(t, parseJobError("plain message"))
+	assert.Nil(t, parseJobError(`{"foo":"bar"}`))
+}
+
+func TestWrapDBusError(t *testing.T) {
+	err := wrapDBusError(dbus.Error{
+		Name: "com.deepin.DBus.Error.Unnamed",
+		Body: []interface{}{`{"ErrType":"fetchFailed","ErrDetail":"network"}`},
+	})
+	assert.Equal(t, exitNetwork, exitCodeOf(err))
+	assert.Equal(t, "fetchFailed: network", err.Error())
+
+	err = wrapDBusError(dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied", Body: []interface{}{"denied"}})
+	assert.Equal(t, exitPermission, exitCodeOf(err))
+
+	err = wrapDBusError(dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown", Body: []interface{}{"gone"}})
+	assert.Equal(t, exitDaemon, exitCodeOf(err))
+
+	err = wrapDBusError(errors.New("connection refused"))
+	assert.Equal(t, exitDaemon, exitCodeOf(err))
+
+	assert.Equal(t, exitOK, exitCodeOf(nil))
+	assert.Equal(t, exitFailed, exitCodeOf(errors.New("other")))
+}
+
+func TestParseUpdateMode(t *testing.T) {
+	mode, err := parseUpdateMode("system,security")
+	require.NoError(t, err)
+	assert.Equal(t, system.SystemUpdate|system.SecurityUpdate, mode)
+
+	mode, err = parseUpdateMode("5")
+	require.NoError(t, err)
+	assert.Equal(t, system.SystemUpdate|system.SecurityUpdate, mode)
+
+	mode, err = parseUpdateMode("")
+	require.NoError(t, err)
+	assert.Equal(t, system.AllInstallUpdate, mode)
+
+	_, err = parseUpdateMode("system,foo")
+	assert.Error(t, err)
+	_, err = parseUpdateMode("0")
+	assert.Error(t, err)
+
+	system.SetUpdateCategories([]system.UpdateCategory{{Name: "office", Bit: 8, Sources: []string{"office.list"}, ShowUpdates: true}})
+	defer system.SetUpdateCategories(nil)
+	mode, err = parseUpdateMode("system,office")
+	require.NoError(t, err)
+	assert.Equal(t, system.SystemUpdate|system.UpdateType(1<<8), mode)
+	mode, err = parseUpdateMode("all")
+	require.NoError(t, err)
+	assert.Equal(t, system.AllInstallUpdate|system.UpdateType(1<<8), mode)
+}
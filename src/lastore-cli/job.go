@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+const jobPollInterval = 200 * time.Millisecond
+
+// progressRenderer 终端中使用单行进度条,非终端时只在状态变化时输出一行
+type progressRenderer struct {
+	out      io.Writer
+	tty      bool
+	disabled bool
+	lastLine string
+}
+
+func newProgressRenderer(quiet bool) *progressRenderer {
+	r := &progressRenderer{out: os.Stderr, disabled: quiet}
+	if fi, err := os.Stderr.Stat(); err == nil {
+		r.tty = fi.Mode()&os.ModeCharDevice != 0
+	}
+	return r
+}
+
+func formatSpeed(speed int64) string {
+	switch {
+	case speed <= 0:
+		return ""
+	case speed >= 1024*1024:
+		return fmt.Sprintf("%.1fMB/s", float64(speed)/1024/1024)
+	case speed >= 1024:
+		return fmt.Sprintf("%.1fKB/s", float64(speed)/1024)
+	default:
+		return fmt.Sprintf("%dB/s", speed)
+	}
+}
+
+func progressBar(progress float64, width int) string {
+	if progress < 0 {
+		progress = 0
+	}
+	if progress > 1 {
+		progress = 1
+	}
+	filled := int(progress * float64(width))
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+func (r *progressRenderer) render(info *jobInfo) {
+	if r.disabled {
+		return
+	}
+	if r.tty {
+		line := fmt.Sprintf("%s %-10s %s %5.1f%% %s", info.Id, info.Status, progressBar(info.Progress, 30), info.Progress*100, formatSpeed(info.Speed))
+		if line != r.lastLine {
+			// \033[K 清除行尾残留字符
+			fmt.Fprintf(r.out, "\r%s\033[K", line)
+			r.lastLine = line
+		}
+		return
+	}
+	// 非终端时按 10% 的粒度输出,避免日志刷屏
+	line := fmt.Sprintf("%s %s %d%%", info.Id, info.Status, int(info.Progress*10)*10)
+	if line != r.lastLine {
+		fmt.Fprintln(r.out, line)
+		r.lastLine = line
+	}
+}
+
+func (r *progressRenderer) finish() {
+	if !r.disabled && r.tty && r.lastLine != "" {
+		fmt.Fprintln(r.out)
+	}
+	r.lastLine = ""
+}
+
+func isUnknownObject(err error) bool {
+	var busErr dbus.Error
+	var ce *cliError
+	if errors.As(err, &ce) && ce.Err != nil {
+		err = ce.Err
+	}
+	return errors.As(err, &busErr) && strings.HasSuffix(busErr.Name, ".UnknownObject")
+}
+
+// waitJob 轮询任务状态直到结束。任务成功后会被 lastore-daemon 清理掉,
+// 因此任务对象消失且最后状态不是 failed 时视为成功
+func (c *lastoreClient) waitJob(path dbus.ObjectPath, r *progressRenderer) (*jobInfo, error) {
+	defer r.finish()
+	var last *jobInfo
+	for {
+		info, err := c.JobInfo(path)
+		if err != nil {
+			if isUnknownObject(err) && last != nil {
+				last.Status = string(system.EndStatus)
+				last.Progress = 1
+				r.render(last)
+				return last, nil
+			}
+			return last, err
+		}
+		last = info
+		r.render(info)
+		switch system.Status(info.Status) {
+		case system.SucceedStatus, system.EndStatus:
+			return info, nil
+		case system.FailedStatus:
+			if jobErr := parseJobError(info.Description); jobErr != nil {
+				return info, newJobError(jobErr)
+			}
+			return info, &cliError{Code: exitFailed, Err: fmt.Errorf("job %v failed: %v", info.Id, info.Description)}
+		case system.PausedStatus:
+			return info, &cliError{Code: exitPaused, Err: fmt.Errorf("job %v paused", info.Id)}
+		}
+		time.Sleep(jobPollInterval)
+	}
+}
+
+// waitJobChain 下载和更新任务可能由多个串联的任务组成,前一个任务结束后
+// lastore-daemon 才会导出下一个任务,因此需要在 JobList 中寻找新出现的任务继续等待
+func (c *lastoreClient) waitJobChain(path dbus.ObjectPath, r *progressRenderer) ([]*jobInfo, error) {
+	known := make(map[dbus.ObjectPath]bool)
+	if list, err := c.JobList(); err == nil {
+		for _, p := range list {
+			known[p] = true
+		}
+	}
+	var infos []*jobInfo
+	for path != "" {
+		known[path] = true
+		info, err := c.waitJob(path, r)
+		if info != nil {
+			infos = append(infos, info)
+		}
+		if err != nil {
+			return infos, err
+		}
+		path = c.findNewJob(known)
+	}
+	return infos, nil
+}
+
+func (c *lastoreClient) findNewJob(known map[dbus.ObjectPath]bool) dbus.ObjectPath {
+	for i := 0; i < 10; i++ {
+		list, err := c.JobList()
+		if err != nil {
+			return ""
+		}
+		for _, p := range list {
+			if !known[p] {
+				return p
+			}
+		}
+		time.Sleep(jobPollInterval)
+	}
+	return ""
+}
@@ -0,0 +1,443 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+/*
+lastore-cli 是 lastore-daemon 的命令行客户端,用于在没有 DDE 的环境中完成
+检查、下载、安装、回滚等完整的更新流程。
+
+Run with `lastore-cli [--json] check|list|download|upgrade|status|jobs|pause|resume|cancel|rollback|history`
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+var updateTypeNames = map[string]system.UpdateType{
+	"system":   system.SystemUpdate,
+	"appstore": system.AppStoreUpdate,
+	"security": system.SecurityUpdate,
+	"unknown":  system.UnknownUpdate,
+	"other":    system.OtherSystemUpdate,
+}
+
+// lookupUpdateType 查找内置类型或管理员定义分类的名称
+func lookupUpdateType(name string) (system.UpdateType, bool) {
+	if name == "all" {
+		return system.AllInstallUpdateMode(), true
+	}
+	if typ, ok := updateTypeNames[name]; ok {
+		return typ, true
+	}
+	for _, c := range system.UpdateCategories() {
+		if c.Name == name {
+			return c.Type(), true
+		}
+	}
+	return 0, false
+}
+
+// parseUpdateMode 支持 system,security 形式的名称列表或者数字
+func parseUpdateMode(s string) (system.UpdateType, error) {
+	if s == "" {
+		return system.AllInstallUpdateMode(), nil
+	}
+	if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+		if n == 0 {
+			return 0, fmt.Errorf("invalid update mode: %q", s)
+		}
+		return system.UpdateType(n), nil
+	}
+	var mode system.UpdateType
+	for _, name := range strings.Split(s, ",") {
+		typ, ok := lookupUpdateType(strings.TrimSpace(name))
+		if !ok {
+			return 0, fmt.Errorf("invalid update mode: %q", name)
+		}
+		mode |= typ
+	}
+	return mode, nil
+}
+
+var modeFlag = cli.StringFlag{
+	Name:  "mode,m",
+	Value: "all",
+	Usage: "update types: system,security,unknown,appstore,other,all, names in " + system.UpdateCategoryConfigDir + " or a bitmask",
+}
+
+// output 根据 --json 选择输出格式
+func output(c *cli.Context, v interface{}, text func()) error {
+	if c.GlobalBool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text()
+	return nil
+}
+
+func connect(c *cli.Context) (*lastoreClient, *progressRenderer, error) {
+	client, err := newLastoreClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, newProgressRenderer(c.GlobalBool("json") || c.GlobalBool("quiet")), nil
+}
+
+type jobResult struct {
+	Jobs  []*jobInfo        `json:"jobs"`
+	Error *system.JobError  `json:"error,omitempty"`
+	Mode  system.UpdateType `json:"mode,omitempty"`
+}
+
+// runJob 等待任务链结束并输出结果,失败时同样输出 json 以便脚本解析
+func runJob(c *cli.Context, client *lastoreClient, r *progressRenderer, mode system.UpdateType, createJob func() (dbus.ObjectPath, error)) error {
+	path, err := createJob()
+	if err != nil {
+		return err
+	}
+	result := &jobResult{Mode: mode}
+	if path != "" && path != "/" {
+		var infos []*jobInfo
+		infos, err = client.waitJobChain(path, r)
+		result.Jobs = infos
+	}
+	var ce *cliError
+	if errors.As(err, &ce) {
+		result.Error = ce.JobErr
+	}
+	if c.GlobalBool("json") {
+		_ = output(c, result, nil)
+	} else if err == nil {
+		fmt.Println("done")
+	}
+	return err
+}
+
+var cmdCheck = cli.Command{
+	Name:  "check",
+	Usage: "refresh package indexes and check for updates",
+	Action: func(c *cli.Context) error {
+		client, r, err := connect(c)
+		if err != nil {
+			return err
+		}
+		return runJob(c, client, r, 0, func() (dbus.ObjectPath, error) {
+			return client.UpdateSource()
+		})
+	},
+}
+
+var cmdList = cli.Command{
+	Name:  "list",
+	Usage: "list upgradable packages by update type",
+	Flags: []cli.Flag{modeFlag},
+	Action: func(c *cli.Context) error {
+		mode, err := parseUpdateMode(c.String("mode"))
+		if err != nil {
+			return newUsageError(err)
+		}
+		client, _, err := connect(c)
+		if err != nil {
+			return err
+		}
+		classified, err := client.ClassifiedUpdatablePackages()
+		if err != nil {
+			return err
+		}
+		result := make(map[string][]string)
+		for _, typ := range system.UpdateTypeBitToArray(mode) {
+			if pkgs, ok := classified[typ.JobType()]; ok && len(pkgs) > 0 {
+				result[typ.JobType()] = pkgs
+			}
+		}
+		return output(c, result, func() {
+			var keys []string
+			for k := range result {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Printf("%s (%d):\n", k, len(result[k]))
+				for _, pkg := range result[k] {
+					fmt.Println("  " + pkg)
+				}
+			}
+			if len(keys) == 0 {
+				fmt.Println("no upgradable packages")
+			}
+		})
+	},
+}
+
+var cmdDownload = cli.Command{
+	Name:  "download",
+	Usage: "download updates",
+	Flags: []cli.Flag{modeFlag},
+	Action: func(c *cli.Context) error {
+		mode, err := parseUpdateMode(c.String("mode"))
+		if err != nil {
+			return newUsageError(err)
+		}
+		client, r, err := connect(c)
+		if err != nil {
+			return err
+		}
+		return runJob(c, client, r, mode, func() (dbus.ObjectPath, error) {
+			return client.PrepareDistUpgradePartly(mode)
+		})
+	},
+}
+
+var cmdUpgrade = cli.Command{
+	Name:  "upgrade",
+	Usage: "install downloaded updates",
+	Flags: []cli.Flag{
+		modeFlag,
+		cli.BoolFlag{
+			Name:  "backup",
+			Usage: "back up the system before installing",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		mode, err := parseUpdateMode(c.String("mode"))
+		if err != nil {
+			return newUsageError(err)
+		}
+		client, r, err := connect(c)
+		if err != nil {
+			return err
+		}
+		return runJob(c, client, r, mode, func() (dbus.ObjectPath, error) {
+			return client.DistUpgradePartly(mode, c.Bool("backup"))
+		})
+	},
+}
+
+type statusResult struct {
+	UpdateMode       system.UpdateType          `json:"update_mode"`
+	SystemOnChanging bool                       `json:"system_on_changing"`
+	Status           map[string]json.RawMessage `json:"status"`
+	Jobs             int                        `json:"jobs"`
+}
+
+var cmdStatus = cli.Command{
+	Name:  "status",
+	Usage: "show update status of every update type",
+	Action: func(c *cli.Context) error {
+		client, _, err := connect(c)
+		if err != nil {
+			return err
+		}
+		var result statusResult
+		result.UpdateMode, err = client.UpdateMode()
+		if err != nil {
+			return err
+		}
+		result.SystemOnChanging, _ = client.SystemOnChanging()
+		if list, err := client.JobList(); err == nil {
+			result.Jobs = len(list)
+		}
+		status, err := client.UpdateStatus()
+		if err != nil {
+			return err
+		}
+		_ = json.Unmarshal([]byte(status), &result.Status)
+		return output(c, result, func() {
+			fmt.Printf("UpdateMode: %d\n", result.UpdateMode)
+			fmt.Printf("SystemOnChanging: %v\n", result.SystemOnChanging)
+			fmt.Printf("Jobs: %d\n", result.Jobs)
+			var keys []string
+			for k := range result.Status {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Printf("%s: %s\n", k, string(result.Status[k]))
+			}
+		})
+	},
+}
+
+var cmdJobs = cli.Command{
+	Name:  "jobs",
+	Usage: "list jobs of lastore-daemon",
+	Action: func(c *cli.Context) error {
+		client, _, err := connect(c)
+		if err != nil {
+			return err
+		}
+		list, err := client.JobList()
+		if err != nil {
+			return err
+		}
+		infos := make([]*jobInfo, 0, len(list))
+		for _, p := range list {
+			info, err := client.JobInfo(p)
+			if err != nil {
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return output(c, infos, func() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tTYPE\tSTATUS\tPROGRESS\tCREATED")
+			for _, info := range infos {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%.1f%%\t%s\n", info.Id, info.Type, info.Status, info.Progress*100,
+					time.Unix(info.CreateTime, 0).Format(time.DateTime))
+			}
+			_ = w.Flush()
+		})
+	},
+}
+
+func jobCommand(name, usage string, fn func(client *lastoreClient, jobId string) error) cli.Command {
+	return cli.Command{
+		Name:      name,
+		Usage:     usage,
+		ArgsUsage: "JOB_ID",
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return newUsageError(fmt.Errorf("%s requires exactly one job id", name))
+			}
+			client, _, err := connect(c)
+			if err != nil {
+				return err
+			}
+			info, err := client.findJob(c.Args().First())
+			if err != nil {
+				return err
+			}
+			err = fn(client, info.Id)
+			if err != nil {
+				return err
+			}
+			return output(c, map[string]string{"id": info.Id, "action": name}, func() {
+				fmt.Printf("%s %s\n", name, info.Id)
+			})
+		},
+	}
+}
+
+var cmdPause = jobCommand("pause", "pause a running job", (*lastoreClient).PauseJob)
+var cmdResume = jobCommand("resume", "resume a paused or failed job", (*lastoreClient).StartJob)
+var cmdCancel = jobCommand("cancel", "cancel and clean a job", (*lastoreClient).CleanJob)
+
+var cmdRollback = cli.Command{
+	Name:  "rollback",
+	Usage: "roll back the last upgrade on immutable systems",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "yes,y",
+			Usage: "confirm the rollback, otherwise only show whether rollback is possible",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		client, _, err := connect(c)
+		if err != nil {
+			return err
+		}
+		can, info, err := client.CanRollback()
+		if err != nil {
+			return err
+		}
+		result := map[string]interface{}{"can_rollback": can, "info": info, "confirmed": false}
+		if can && c.Bool("yes") {
+			err = client.ConfirmRollback(true)
+			if err != nil {
+				return err
+			}
+			result["confirmed"] = true
+		}
+		err = output(c, result, func() {
+			fmt.Printf("CanRollback: %v\n", can)
+			if info != "" {
+				fmt.Println(info)
+			}
+			if result["confirmed"] == true {
+				fmt.Println("rollback confirmed")
+			} else if can {
+				fmt.Println("run with --yes to roll back")
+			}
+		})
+		if err == nil && !can && c.Bool("yes") {
+			return &cliError{Code: exitFailed, Err: errors.New("rollback is not available")}
+		}
+		return err
+	},
+}
+
+var cmdHistory = cli.Command{
+	Name:  "history",
+	Usage: "show upgrade history",
+	Action: func(c *cli.Context) error {
+		client, _, err := connect(c)
+		if err != nil {
+			return err
+		}
+		logs, err := client.GetHistoryLogs()
+		if err != nil {
+			return err
+		}
+		if c.GlobalBool("json") {
+			if json.Valid([]byte(logs)) {
+				_, err = fmt.Fprintln(os.Stdout, logs)
+				return err
+			}
+			return output(c, map[string]string{"history": logs}, nil)
+		}
+		fmt.Println(logs)
+		return nil
+	},
+}
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "lastore-cli"
+	app.Usage = "command line client of lastore-daemon"
+	app.Version = "0.1.0"
+	app.CustomAppHelpTemplate = cli.AppHelpTemplate + "\n" + exitCodeHelp
+	system.SetUpdateCategories(system.LoadUpdateCategories(system.UpdateCategoryConfigDir))
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json,j",
+			Usage: "print machine readable json to stdout",
+		},
+		cli.BoolFlag{
+			Name:  "quiet,q",
+			Usage: "do not render job progress",
+		},
+	}
+	app.Commands = []cli.Command{
+		cmdCheck,
+		cmdList,
+		cmdDownload,
+		cmdUpgrade,
+		cmdStatus,
+		cmdJobs,
+		cmdPause,
+		cmdResume,
+		cmdCancel,
+		cmdRollback,
+		cmdHistory,
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "E:", err)
+	}
+	os.Exit(exitCodeOf(err))
+}
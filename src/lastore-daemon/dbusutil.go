@@ -313,6 +313,32 @@ func (v *Job) emitPropChangedDescription(value string) error {
 	return v.service.EmitPropertyChanged(v, "Description", value)
 }
 
+func (v *Job) setPropRestartInfo(value string) (changed bool) {
+	if v.RestartInfo != value {
+		v.RestartInfo = value
+		v.emitPropChangedRestartInfo(value)
+		return true
+	}
+	return false
+}
+
+func (v *Job) emitPropChangedRestartInfo(value string) error {
+	return v.service.EmitPropertyChanged(v, "RestartInfo", value)
+}
+
+func (v *Job) setPropKernelInfo(value string) (changed bool) {
+	if v.KernelInfo != value {
+		v.KernelInfo = value
+		v.emitPropChangedKernelInfo(value)
+		return true
+	}
+	return false
+}
+
+func (v *Job) emitPropChangedKernelInfo(value string) error {
+	return v.service.EmitPropertyChanged(v, "KernelInfo", value)
+}
+
 func (v *Job) setPropSpeed(value int64) (changed bool) {
 	if v.Speed != value {
 		v.Speed = value
@@ -56,6 +56,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetArchivesInfo,
 			OutArgs: []string{"info"},
 		},
+		{
+			Name:    "GetBaselineDrift",
+			Fn:      v.GetBaselineDrift,
+			OutArgs: []string{"report"},
+		},
 		{
 			Name:    "GetHistoryLogs",
 			Fn:      v.GetHistoryLogs,
@@ -67,11 +72,69 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"updateType"},
 			OutArgs: []string{"changeLogs"},
 		},
+		{
+			Name:    "GetPackageChangelogs",
+			Fn:      v.GetPackageChangelogs,
+			InArgs:  []string{"updateType"},
+			OutArgs: []string{"changeLogs"},
+		},
+		{
+			Name:    "GetUnfixedCVEs",
+			Fn:      v.GetUnfixedCVEs,
+			OutArgs: []string{"report"},
+		},
+		{
+			Name:    "GetReconcilePlan",
+			Fn:      v.GetReconcilePlan,
+			OutArgs: []string{"plan"},
+		},
+		{
+			Name:    "GetSpacePlan",
+			Fn:      v.GetSpacePlan,
+			InArgs:  []string{"updateType"},
+			OutArgs: []string{"plan"},
+		},
+		{
+			Name:    "GetUpdateCategories",
+			Fn:      v.GetUpdateCategories,
+			OutArgs: []string{"categories"},
+		},
+		{
+			Name:    "GetUploadQueue",
+			Fn:      v.GetUploadQueue,
+			OutArgs: []string{"status"},
+		},
+		{
+			Name:    "GetPlatformDataStatus",
+			Fn:      v.GetPlatformDataStatus,
+			OutArgs: []string{"status"},
+		},
+		{
+			Name:    "GetPlatformEndpoints",
+			Fn:      v.GetPlatformEndpoints,
+			OutArgs: []string{"status"},
+		},
+		{
+			Name:    "PreviewTelemetry",
+			Fn:      v.PreviewTelemetry,
+			OutArgs: []string{"preview"},
+		},
+		{
+			Name:    "CollectSupportBundle",
+			Fn:      v.CollectSupportBundle,
+			OutArgs: []string{"file"},
+		},
 		{
 			Name:   "HandleSystemEvent",
 			Fn:     v.HandleSystemEvent,
 			InArgs: []string{"eventType"},
 		},
+		{
+			Name:    "ImportRepoKey",
+			Fn:      v.ImportRepoKey,
+			InArgs:  []string{"repoUrl", "key"},
+			OutArgs: []string{"keyring"},
+		},
 		{
 			Name:    "InstallPackage",
 			Fn:      v.InstallPackage,
@@ -84,6 +147,16 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"jobName", "sourceListPath", "repoListPath", "cachePath", "packageName"},
 			OutArgs: []string{"jobPath"},
 		},
+		{
+			Name:    "ListRepoKeys",
+			Fn:      v.ListRepoKeys,
+			OutArgs: []string{"keys"},
+		},
+		{
+			Name:    "ListSourceRevisions",
+			Fn:      v.ListSourceRevisions,
+			OutArgs: []string{"revisions"},
+		},
 		{
 			Name:    "PackageExists",
 			Fn:      v.PackageExists,
@@ -130,17 +203,47 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"mode"},
 			OutArgs: []string{"outArg0"},
 		},
+		{
+			Name:    "Reconcile",
+			Fn:      v.Reconcile,
+			OutArgs: []string{"job", "plan"},
+		},
 		{
 			Name:   "RegisterAgent",
 			Fn:     v.RegisterAgent,
 			InArgs: []string{"path"},
 		},
+		{
+			Name:   "RemoveRepoKey",
+			Fn:     v.RemoveRepoKey,
+			InArgs: []string{"repoUrl"},
+		},
+		{
+			Name:    "RestartAffectedServices",
+			Fn:      v.RestartAffectedServices,
+			OutArgs: []string{"services"},
+		},
+		{
+			Name:   "RevertSources",
+			Fn:     v.RevertSources,
+			InArgs: []string{"rev"},
+		},
+		{
+			Name:   "RotateRepoKey",
+			Fn:     v.RotateRepoKey,
+			InArgs: []string{"repoUrl", "key"},
+		},
 		{ // TODO
 			Name:    "RemovePackage",
 			Fn:      v.RemovePackage,
 			InArgs:  []string{"jobName", "packages"},
 			OutArgs: []string{"job"},
 		},
+		{
+			Name:    "ScanVulnerabilities",
+			Fn:      v.ScanVulnerabilities,
+			OutArgs: []string{"report"},
+		},
 		{
 			Name:   "SetAutoClean",
 			Fn:     v.SetAutoClean,
@@ -151,6 +254,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetUpdateSources,
 			InArgs: []string{"updateType", "repoType", "repoConfig", "isReset"},
 		},
+		{
+			Name:    "ValidateSources",
+			Fn:      v.ValidateSources,
+			InArgs:  []string{"updateType", "lines"},
+			OutArgs: []string{"id"},
+		},
 		{
 			Name:   "StartJob",
 			Fn:     v.StartJob,
@@ -161,6 +270,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.UnRegisterAgent,
 			InArgs: []string{"path"},
 		},
+		{
+			Name:    "UpgradeForCVEs",
+			Fn:      v.UpgradeForCVEs,
+			InArgs:  []string{"cveIds"},
+			OutArgs: []string{"job", "resolution"},
+		},
 		{
 			Name:    "UpdateSource",
 			Fn:      v.UpdateSource,
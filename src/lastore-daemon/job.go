@@ -38,6 +38,10 @@ type Job struct {
 
 	Progress    float64
 	Description string
+	// 安装更新后的重启分析结果,为 restartcheck.Result 的 json 数据
+	RestartInfo string
+	// 本次更新的内核变化,为 kernel.Change 的 json 数据
+	KernelInfo string
 
 	// completed bytes per second
 	Speed                   int64
@@ -245,6 +245,21 @@ func (jm *JobManager) CreateJob(jobName, jobType string, packages []string, envi
 		job._InitProgressRange(0, 0.99)
 	case system.CheckSystemJobType:
 		job = NewJob(jm.service, genJobId(jobType), jobName, nil, system.CheckSystemJobType, SystemChangeQueue, environ)
+	case system.ReconcileJobType:
+		// 收敛计划通过 apt-get install 执行,packages 中包含需要删除(pkg-)和固定版本(pkg=ver)的软件包
+		job = NewJob(jm.service, genJobId(jobType), jobName, packages, system.InstallJobType, LockQueue, environ)
+		job.option = map[string]string{
+			"APT::Get::Purge":            "true",
+			"APT::Get::allow-downgrades": "true",
+		}
+		job._InitProgressRange(0, 0.99)
+	case system.CVEUpgradeJobType:
+		// packages 为 pkg=ver 形式的固定版本,只升级已安装的包,不安装新包
+		job = NewJob(jm.service, genJobId(jobType), jobName, packages, system.InstallJobType, LockQueue, environ)
+		job.option = map[string]string{
+			"APT::Get::Only-Upgrade": "true",
+		}
+		job._InitProgressRange(0, 0.99)
 	case system.BackupJobType:
 		job = NewJob(jm.service, genJobId(jobType), jobName, packages, system.BackupJobType, LockQueue, environ)
 	default:
@@ -648,7 +663,8 @@ var genJobId = func() func(string) string {
 		case system.PrepareDistUpgradeJobType, system.DistUpgradeJobType, system.BackupJobType,
 			system.UpdateSourceJobType, system.CleanJobType, system.PrepareSystemUpgradeJobType,
 			system.PrepareAppStoreUpgradeJobType, system.PrepareSecurityUpgradeJobType, system.PrepareUnknownUpgradeJobType,
-			system.SystemUpgradeJobType, system.AppStoreUpgradeJobType, system.SecurityUpgradeJobType, system.UnknownUpgradeJobType, system.CheckSystemJobType:
+			system.SystemUpgradeJobType, system.AppStoreUpgradeJobType, system.SecurityUpgradeJobType, system.UnknownUpgradeJobType, system.CheckSystemJobType,
+			system.ReconcileJobType, system.CVEUpgradeJobType:
 			return jobType
 		default:
 			__count++
@@ -85,6 +85,8 @@ func main() {
 		}()
 	}
 
+	// 管理员定义的更新分类需要在初始化仓库文件夹前加载
+	system.SetUpdateCategories(system.LoadUpdateCategories(system.UpdateCategoryConfigDir))
 	aptImpl := dut.NewSystem(config.NonUnknownList, config.OtherSourceList, config.UseIncrementalUpdate())
 	system.SetSystemUpdate(config.PlatformUpdate) // 设置是否通过平台更新
 	// 安装/卸载接口不再追加可执行路径白名单，改由 allow-caller、特殊 uid 和 polkit 共同鉴权。
@@ -164,6 +166,7 @@ func main() {
 	}
 	manager.PropsMu.RUnlock()
 	manager.startOfflineTask()
+	manager.startPolicyPush()
 	// Ensure that the systemd timer configuration is consistent with the current configuration.
 	err = updater.applyIdleDownloadConfig(updater.idleDownloadConfigObj, time.Time{}, true)
 	if err != nil {
@@ -176,6 +179,8 @@ func main() {
 	}
 	service.SetAutoQuitHandler(autoQuitTime, manager.canAutoQuit)
 	service.Wait()
+	manager.stopPolicyPush()
+	manager.updatePlatform.StopOutbox()
 	manager.saveLastoreCache()
 }
 
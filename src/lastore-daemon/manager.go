@@ -17,6 +17,8 @@ import (
 	"time"
 
 	"github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/notify"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/policypush"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system/dut"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
@@ -28,6 +30,7 @@ import (
 	power "github.com/linuxdeepin/go-dbus-factory/system/org.deepin.dde.power1"
 	ofdbus "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.dbus"
 	login1 "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.login1"
+	networkmanager "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.networkmanager"
 	systemd1 "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.systemd1"
 
 	"github.com/linuxdeepin/go-lib/dbusutil"
@@ -72,6 +75,7 @@ type Manager struct {
 	updateSourceOnce bool
 
 	sysPower   power.Power
+	nmManager  networkmanager.Manager
 	signalLoop *dbusutil.SignalLoop
 
 	UpdateMode      system.UpdateType `prop:"access:rw"` // 更新设置的内容
@@ -99,6 +103,9 @@ type Manager struct {
 	statusManager    *UpdateModeStatusManager
 	updatePlatform   *updateplatform.UpdatePlatformManager
 	immutableManager *immutableManager
+	notifyDispatcher *notify.Dispatcher
+	sourceRevisions  *system.SourceRevisionStore // 仓库配置的修改记录
+	policyPush       *policypush.Channel         // 接收平台策略变化通知的长连接,没有配置时为 nil
 
 	rebootTimeoutTimer *time.Timer
 
@@ -113,10 +120,25 @@ type Manager struct {
 	logFdsMu   sync.Mutex
 	logTmpFile *os.File
 
+	removedKernels   []string // 更新前清理的旧内核版本,更新结束时设置到内核变化中
+	removedKernelsMu sync.Mutex
+
 	isAutoCheckTimerFirstRun bool
 	allowCallServiceList     strv.Strv
 	// 特殊 uid 调用方直接放行，当前用于兼容 lightdm greeter 场景。
 	trustedCallerUIDs map[uint32]struct{}
+
+	validateSourcesCount uint32 // 用于生成仓库检查的 id
+
+	//nolint
+	signals *struct {
+		// SourcesValidated 仓库检查完成,report 为 []system.SourceValidation 的 json 数据,检查失败时 err 不为空
+		SourcesValidated struct {
+			id     string
+			report string
+			err    string
+		}
+	}
 }
 
 /*
@@ -143,12 +165,15 @@ func NewManager(service *dbusutil.Service, updateApi system.System, c *config.Co
 		signalLoop:              dbusutil.NewSignalLoop(service.Conn(), 10),
 		systemd:                 systemd1.NewManager(service.Conn()),
 		sysPower:                power.NewPower(service.Conn()),
+		nmManager:               networkmanager.NewManager(service.Conn()),
 		securitySourceConfig:    make(UpdateSourceConfig),
 		systemSourceConfig:      make(UpdateSourceConfig),
 		DownloadLimitOnChanging: false,
 		trustedCallerUIDs:       initTrustedCallerUIDs(),
+		sourceRevisions:         system.NewSourceRevisionStore(system.SourceRevisionDir, system.SourceRevisionPaths()...),
 	}
 	m.reloadOemConfig(true)
+	m.initNotifyDispatcher()
 	m.signalLoop.Start()
 	m.jobManager = NewJobManager(service, updateApi, m.updateJobList, m.processLogFds)
 	m.immutableManager = newImmutableManager(m.jobManager.handleJobProgressInfo)
@@ -217,6 +242,11 @@ func (m *Manager) initDbusSignalListen() {
 		logger.Warning(err)
 	}
 	m.sysPower.InitSignalExt(m.signalLoop, true)
+	m.nmManager.InitSignalExt(m.signalLoop, true)
+	_, err = m.nmManager.ConnectStateChanged(m.handleNetworkStateChanged)
+	if err != nil {
+		logger.Warning(err)
+	}
 }
 
 func (m *Manager) syncHardwareRelatedData() {
@@ -228,7 +258,7 @@ func (m *Manager) syncHardwareRelatedData() {
 	includeDiskInfo := m.config.IncludeDiskInfo
 	getHardwareIdByHelper := m.config.GetHardwareIdByHelper
 
-	token := updateplatform.UpdateTokenConfigFile(includeDiskInfo, getHardwareIdByHelper)
+	token := updateplatform.UpdateTokenConfigFile(includeDiskInfo, getHardwareIdByHelper, updateplatform.TelemetryPolicy(m.config))
 	if m.updatePlatform != nil {
 		m.updatePlatform.Token = token
 	}
@@ -311,6 +341,15 @@ func (m *Manager) initDSettingsChangedHandle() {
 		logger.Infof("GetHardwareIdByHelper changed: %v -> %v", oldValue, newValue)
 		m.syncHardwareRelatedData()
 	})
+	// 遥测级别和脱敏配置变化后重新生成 token
+	m.config.ConnectConfigChanged(config.DSettingsKeyTelemetryLevel, func(oldValue, newValue interface{}) {
+		logger.Infof("TelemetryLevel changed: %v -> %v", oldValue, newValue)
+		m.syncHardwareRelatedData()
+	})
+	m.config.ConnectConfigChanged(config.DSettingsKeyTelemetryRedact, func(oldValue, newValue interface{}) {
+		logger.Infof("TelemetryRedact changed: %v -> %v", oldValue, newValue)
+		m.syncHardwareRelatedData()
+	})
 }
 
 // recreateSystem 重新创建system对象，用于incremental-update热更新
@@ -366,6 +405,7 @@ func (m *Manager) initAgent() {
 
 func (m *Manager) initPlatformManager() {
 	m.updatePlatform = updateplatform.NewUpdatePlatformManager(m.config, false)
+	m.updatePlatform.StartOutbox()
 	if isFirstBoot() || m.config.IntranetUpdate {
 		// 不能阻塞初始化流程,防止dbus服务激活超时
 		go m.updatePlatform.RetryPostHistory() // 此处调用还没有export以及dispatch job,因此可以判断是否需要check.
@@ -503,7 +543,7 @@ func (m *Manager) installPkg(jobName, packages string, environ map[string]string
 	var job *Job
 	var isExist bool
 	var err error
-	err = system.CustomSourceWrapper(system.AllCheckUpdate, func(path string, unref func()) error {
+	err = system.CustomSourceWrapper(system.AllCheckUpdateMode(), func(path string, unref func()) error {
 		m.do.Lock()
 		defer m.do.Unlock()
 		isExist, job, err = m.jobManager.CreateJob(jobName, system.InstallJobType, pList, environ, nil)
@@ -587,7 +627,7 @@ func (m *Manager) removePackage(sender dbus.Sender, jobName string, packages str
 	job.setPreHooks(map[string]func() error{
 		string(system.SucceedStatus): func() error {
 			msg := gettext.Tr("Removed successfully")
-			go m.sendNotify(system.GetAppStoreAppName(), 0, "deepin-appstore", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+			go m.notify(newUpdateEvent(notify.EventRemoved, 0, system.GetAppStoreAppName(), "deepin-appstore", "", msg, nil, nil, system.NotifyExpireTimeoutDefault))
 			return nil
 		},
 		string(system.FailedStatus): func() error {
@@ -601,7 +641,7 @@ func (m *Manager) removePackage(sender dbus.Sender, jobName string, packages str
 			hints := map[string]dbus.Variant{
 				"x-deepin-action-retry":  dbus.MakeVariant(fmt.Sprintf("dbus-send,--system,--print-reply,--dest=org.deepin.dde.Lastore1,/org/deepin/dde/Lastore1,org.deepin.dde.Lastore1.Manager.StartJob,string:%s", job.Id)),
 				"x-deepin-action-cancel": dbus.MakeVariant(fmt.Sprintf("dbus-send,--system,--print-reply,--dest=org.deepin.dde.Lastore1,/org/deepin/dde/Lastore1,org.deepin.dde.Lastore1.Manager.CleanJob,string:%s", job.Id))}
-			go m.sendNotify(system.GetAppStoreAppName(), 0, "deepin-appstore", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+			go m.notify(newUpdateEvent(notify.EventFailed, 0, system.GetAppStoreAppName(), "deepin-appstore", "", msg, action, hints, system.NotifyExpireTimeoutDefault))
 			return nil
 		},
 	})
@@ -643,7 +683,7 @@ func (m *Manager) cleanArchives(needNotify bool) (*Job, error) {
 		string(system.EndStatus): func() error {
 			// 清理完成的通知
 			msg := gettext.Tr("Package cache wiped")
-			go m.sendNotify(updateNotifyShow, 0, "", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+			go m.notify(newUpdateEvent(notify.EventCleaned, 0, updateNotifyShow, "", "", msg, nil, nil, system.NotifyExpireTimeoutDefault))
 			return nil
 		},
 	})
@@ -772,6 +812,15 @@ func (m *Manager) categorySupportAutoInstall(category system.UpdateType) bool {
 	autoInstallUpdates := m.updater.AutoInstallUpdates
 	autoInstallUpdateType := m.updater.AutoInstallUpdateType
 	m.updater.PropsMu.RUnlock()
+	// 管理员定义的分类可以通过 AutoInstall 覆盖 AutoInstallUpdateType 的配置
+	if c, ok := system.CustomCategory(category); ok {
+		switch c.AutoInstall {
+		case system.AutoInstallAlways:
+			return autoInstallUpdates
+		case system.AutoInstallNever:
+			return false
+		}
+	}
 	return autoInstallUpdates && (autoInstallUpdateType&category != 0)
 }
 
@@ -1226,7 +1275,10 @@ func (m *Manager) installSpecialPackageSync(pkgName string, option map[string]st
 func (m *Manager) reloadOemConfig(reloadSourceDir bool) {
 	// 更新仓库Dir
 	if reloadSourceDir {
-		m.config.ReloadSourcesDir()
+		_ = m.trackSourceChange(daemonSourceAuthor, "reloadOemConfig", func() error {
+			m.config.ReloadSourcesDir()
+			return nil
+		})
 	}
 
 	// 更新 dbus 属性
@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+// GetBaselineDrift 比较已安装的软件包与当前基线的软件包清单,report 为 updateplatform.BaselineDrift 的 json 数据,
+// 包括核心清单中缺失的包、删除清单中已安装的包、版本与禁止升级清单不一致的包以及来源未知的包
+func (m *Manager) GetBaselineDrift(sender dbus.Sender) (report string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	drift, err := m.updatePlatform.BaselineDrift()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(drift)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
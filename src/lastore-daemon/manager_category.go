@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/procfs"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// updateCategoryInfo GetUpdateCategories 返回的条目
+type updateCategoryInfo struct {
+	system.UpdateCategory
+	Type          system.UpdateType
+	JobType       string
+	LocalizedName string // 根据调用者语言选择的显示名称
+}
+
+// GetUpdateCategories 列出管理员定义的更新分类,categories 为 []updateCategoryInfo 的 json 数据,
+// 分类的 Type 可以和内置类型一样用于 UpdateMode、CheckUpdateMode 和 DistUpgradePartly
+func (m *Manager) GetUpdateCategories(sender dbus.Sender) (categories string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	var lang string
+	pid, err := m.service.GetConnPID(string(sender))
+	if err == nil {
+		envVars, err := procfs.Process(pid).Environ()
+		if err == nil {
+			lang = getLang(envVars)
+		}
+	}
+	infos := []updateCategoryInfo{}
+	for _, c := range system.UpdateCategories() {
+		infos = append(infos, updateCategoryInfo{
+			UpdateCategory: c,
+			Type:           c.Type(),
+			JobType:        c.JobType(),
+			LocalizedName:  c.LocalizedName(lang),
+		})
+	}
+	data, err := json.Marshal(infos)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
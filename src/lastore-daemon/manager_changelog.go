@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/changelog"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// aptArchivesDir apt 默认的下载目录,用户手动执行 apt 下载的包也可以读取 changelog
+const aptArchivesDir = "/var/cache/apt/archives"
+
+var packageChangelogCache = changelog.NewCache()
+
+// GetPackageChangelogs changeLogs json解析后数据结构
+//
+//	map[system.UpdateType][]struct {
+//		Package          string
+//		InstalledVersion string
+//		CandidateVersion string
+//		Entries          []struct {
+//			Version      string
+//			Distribution string
+//			Urgency      string
+//			Changes      string
+//			Maintainer   string
+//			Date         string
+//			Bugs         []string
+//			CVEs         []string
+//		}
+//		Bugs []string
+//		CVEs []string
+//	}
+//
+// 只包含已经下载到归档目录中的包,未下载或 deb 包中没有 changelog 的包会被忽略
+func (m *Manager) GetPackageChangelogs(sender dbus.Sender, updateType system.UpdateType) (changeLogs string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	res, err := m.getPackageChangelogs(updateType)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	logs, err := json.Marshal(res)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(logs), nil
+}
+
+func (m *Manager) getPackageChangelogs(updateType system.UpdateType) (map[system.UpdateType][]*changelog.PackageChangelog, error) {
+	if updateType&system.AllInstallUpdateMode() == 0 {
+		return nil, errors.New("unknown update type")
+	}
+	_, pkgsMap := m.updater.getUpdatablePackagesWithClassification(updateType)
+	debs := changelog.ListDebFiles(system.LocalCachePath, aptArchivesDir)
+	packageChangelogCache.Prune(debs)
+
+	statusVersions, err := loadPkgStatusVersion()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[system.UpdateType][]*changelog.PackageChangelog)
+	for _, t := range system.AllInstallUpdateType() {
+		if updateType&t == 0 {
+			continue
+		}
+		logs := []*changelog.PackageChangelog{}
+		for _, pkg := range pkgsMap[t] {
+			deb, ok := debs[pkg]
+			if !ok {
+				continue
+			}
+			installed := statusVersions[pkg].version
+			if installed != "" && compareVersionsGe(installed, deb.Version) {
+				// 归档目录中残留的旧版本包
+				continue
+			}
+			c, err := packageChangelogCache.Load(deb, installed)
+			if err != nil {
+				logger.Debugf("failed to load changelog of %s: %v", deb.Path, err)
+				continue
+			}
+			logs = append(logs, c)
+		}
+		res[t] = logs
+	}
+	return res, nil
+}
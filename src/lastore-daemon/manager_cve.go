@@ -0,0 +1,256 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/vulnscan"
+)
+
+const cveUpgradeJobName = "lastore-cve-upgrade"
+
+// cveUpgradePlan UpgradeForCVEs 返回的修复方案
+type cveUpgradePlan struct {
+	updateplatform.CVEResolution
+	Unavailable []updateplatform.CVEFix // 仓库中的候选版本低于修复版本
+	Targets     map[string]string       // Fixes 中每个包升级到的候选版本
+}
+
+// pins 返回 pkg=ver 形式的固定版本
+func (p *cveUpgradePlan) pins() []string {
+	var pins []string
+	for _, fix := range p.Fixes {
+		pins = append(pins, fix.Package+"="+p.Targets[fix.Package])
+	}
+	return pins
+}
+
+// unfixedCVEReport GetUnfixedCVEs 返回的条目
+type unfixedCVEReport struct {
+	updateplatform.UnfixedCVE
+	CandidateVersion string
+	FixAvailable     bool // 仓库中的候选版本可以修复该 CVE
+}
+
+// loadInstalledVersions 返回已安装包的版本
+func loadInstalledVersions() (map[string]string, error) {
+	statusVersions, err := loadPkgStatusVersion()
+	if err != nil {
+		return nil, err
+	}
+	installed := make(map[string]string)
+	for pkg, sv := range statusVersions {
+		// db:Status-Abbrev 第二个字符为 i 时表示已安装
+		if len(sv.status) >= 2 && sv.status[1] == 'i' {
+			installed[pkg] = sv.version
+		}
+	}
+	return installed, nil
+}
+
+// UpgradeForCVEs 只下载和安装修复指定 CVE 所需的最小包集合,不升级其他包。
+// 修复方案中的包固定到计算方案时的候选版本,并且只升级已安装的包。
+// 软件源索引还没有刷新过时先检查更新,返回检查更新的 job,cve_upgrade job 串联在其后,
+// 检查更新结束后开始,开始时再计算修复方案,此时 resolution 为空。
+// resolution 为修复方案的 json 数据,结构为 cveUpgradePlan
+func (m *Manager) UpgradeForCVEs(sender dbus.Sender, cveIds []string) (job dbus.ObjectPath, resolution string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "/", "", dbusutil.ToError(err)
+	}
+	if len(cveIds) == 0 {
+		return "/", "", dbusutil.ToError(errors.New("empty cve list"))
+	}
+	environ, err := makeEnvironWithSender(m, sender)
+	if err != nil {
+		return "/", "", dbusutil.ToError(err)
+	}
+	// 候选版本来自软件源索引,需要在计算方案前刷新
+	m.PropsMu.RLock()
+	updateOnce := m.updateSourceOnce
+	m.PropsMu.RUnlock()
+	if !updateOnce {
+		updateJob, err := m.upgradeForCVEsAfterUpdate(sender, cveIds, environ)
+		if err != nil {
+			return "/", "", dbusutil.ToError(err)
+		}
+		return updateJob.getPath(), "", nil
+	}
+	plan, data, err := m.resolveCVEUpgrade(cveIds)
+	if err != nil {
+		return "/", data, dbusutil.ToError(err)
+	}
+	jobObj, err := m.createCustomSourceJob(cveUpgradeJobName, system.CVEUpgradeJobType, plan.pins(), environ, nil, nil)
+	if err != nil {
+		return "/", data, dbusutil.ToError(err)
+	}
+	return jobObj.getPath(), data, nil
+}
+
+// upgradeForCVEsAfterUpdate 检查更新并在检查更新 job 之后串联 cve_upgrade job,返回检查更新 job。
+// 修复方案在 cve_upgrade job 开始时根据刷新后的索引计算,没有可安装的修复时 job 失败
+func (m *Manager) upgradeForCVEsAfterUpdate(sender dbus.Sender, cveIds []string, environ map[string]string) (*Job, error) {
+	updateJob, err := m.updateSource(sender)
+	if err != nil {
+		return nil, err
+	}
+	if updateJob == nil {
+		return nil, errors.New("failed to start updating package lists")
+	}
+	var job *Job
+	job, err = m.createCustomSourceJob(cveUpgradeJobName, system.CVEUpgradeJobType, nil, environ, updateJob,
+		map[string]func() error{
+			string(system.RunningStatus): func() error {
+				m.PropsMu.RLock()
+				updateOnce := m.updateSourceOnce
+				m.PropsMu.RUnlock()
+				if !updateOnce {
+					return &system.JobError{
+						ErrType:   system.ErrorUnknown,
+						ErrDetail: "failed to update package lists before cve upgrade",
+					}
+				}
+				plan, data, err := m.resolveCVEUpgrade(cveIds)
+				if err != nil {
+					return &system.JobError{
+						ErrType:   system.ErrorUnknown,
+						ErrDetail: fmt.Sprintf("%v, resolution: %v", err, data),
+					}
+				}
+				job.PropsMu.Lock()
+				job.setPropPackages(plan.pins())
+				job.PropsMu.Unlock()
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
+	return updateJob, nil
+}
+
+// resolveCVEUpgrade 计算修复方案,data 为方案的 json 数据,没有可安装的修复时返回错误
+func (m *Manager) resolveCVEUpgrade(cveIds []string) (*cveUpgradePlan, string, error) {
+	plan, err := m.resolveCVEUpgradePlan(cveIds)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return nil, "", err
+	}
+	logger.Infof("cve upgrade plan: %s", data)
+	if len(plan.Fixes) == 0 {
+		return nil, string(data), fmt.Errorf("no installable fix for %v", strings.Join(cveIds, ","))
+	}
+	return plan, string(data), nil
+}
+
+// resolveCVEUpgradePlan 根据 CVE 元数据计算修复方案,并去掉仓库中还没有修复版本的包
+func (m *Manager) resolveCVEUpgradePlan(cveIds []string) (*cveUpgradePlan, error) {
+	installed, err := loadInstalledVersions()
+	if err != nil {
+		return nil, err
+	}
+	res := m.updatePlatform.ResolveCVEFixes(cveIds, installed)
+	plan := &cveUpgradePlan{CVEResolution: res, Targets: make(map[string]string)}
+	plan.Fixes = nil
+	if len(res.Fixes) == 0 {
+		return plan, nil
+	}
+	var pkgs []string
+	for _, fix := range res.Fixes {
+		pkgs = append(pkgs, fix.Package)
+	}
+	candidates, err := system.QueryCandidateVersions(pkgs...)
+	if err != nil {
+		return nil, err
+	}
+	for _, fix := range res.Fixes {
+		candidate, ok := candidates[fix.Package]
+		if !ok || !compareVersionsGe(candidate, fix.FixedVersion) {
+			plan.Unavailable = append(plan.Unavailable, fix)
+			continue
+		}
+		plan.Fixes = append(plan.Fixes, fix)
+		plan.Targets[fix.Package] = candidate
+	}
+	return plan, nil
+}
+
+// GetUnfixedCVEs 列出已安装的包中已知但尚未修复的 CVE,report 为 []unfixedCVEReport 的 json 数据
+func (m *Manager) GetUnfixedCVEs(sender dbus.Sender) (report string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	result, err := m.getUnfixedCVEs()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) getUnfixedCVEs() ([]unfixedCVEReport, error) {
+	installed, err := loadInstalledVersions()
+	if err != nil {
+		return nil, err
+	}
+	unfixed := m.updatePlatform.UnfixedCVEs(installed)
+	pkgSet := make(map[string]bool)
+	var pkgs []string
+	for _, item := range unfixed {
+		if !pkgSet[item.Package] {
+			pkgSet[item.Package] = true
+			pkgs = append(pkgs, item.Package)
+		}
+	}
+	candidates, err := system.QueryCandidateVersions(pkgs...)
+	if err != nil {
+		logger.Warning("failed to query candidate versions:", err)
+		candidates = map[string]string{}
+	}
+	result := make([]unfixedCVEReport, 0, len(unfixed))
+	for _, item := range unfixed {
+		candidate := candidates[item.Package]
+		result = append(result, unfixedCVEReport{
+			UnfixedCVE:       item,
+			CandidateVersion: candidate,
+			FixAvailable: candidate != "" && item.FixedVersion != "" &&
+				compareVersionsGe(candidate, item.FixedVersion),
+		})
+	}
+	return result, nil
+}
+
+// ScanVulnerabilities 使用离线导入到 vulnscan.DefaultFeedPath 中的漏洞数据扫描已安装的包,
+// 不依赖更新平台,report 为 vulnscan.Report 的 json 数据
+func (m *Manager) ScanVulnerabilities(sender dbus.Sender) (report string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	result, err := vulnscan.ScanSystem(vulnscan.DefaultFeedPath, vulnscan.LoadOptions{})
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
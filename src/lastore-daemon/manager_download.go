@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/linuxdeepin/lastore-daemon/src/internal/notify"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system/dut"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
@@ -172,14 +173,22 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 	// 不再处理needDownloadSize == 0的情况,因为有可能是其他仓库包含了该仓库的包,导致该仓库无需下载,可以直接继续后续流程,用来切换该仓库的状态
 	// 下载前检查/var分区的磁盘空间是否足够下载,
 	isInsufficientSpace := false
-	var spaceNum int
+	var needFreeSize float64
 	if totalNeedDownloadSize > 0 {
-		var err error
-		spaceNum, err = system.GetFreeSpace("/var")
+		// 按下载缓存所在的挂载点检查,不足时先清理可删除的下载缓存
+		shortfall, err := m.checkDownloadSpace(uint64(totalNeedDownloadSize))
 		if err != nil {
 			logger.Warning(err)
+			spaceNum, err := system.GetFreeSpace("/var")
+			if err != nil {
+				logger.Warning(err)
+			} else {
+				isInsufficientSpace = spaceNum < int(totalNeedDownloadSize)
+				needFreeSize = totalNeedDownloadSize - float64(spaceNum)
+			}
 		} else {
-			isInsufficientSpace = spaceNum < int(totalNeedDownloadSize)
+			isInsufficientSpace = shortfall > 0
+			needFreeSize = float64(shortfall)
 		}
 	}
 
@@ -189,9 +198,10 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 			ErrDetail:    "You don't have enough free space to download",
 			IsCheckError: true,
 		}
-		needFreeSize := totalNeedDownloadSize - float64(spaceNum)
 		msg := fmt.Sprintf(gettext.Tr("Downloading updates failed. Please free up %s disk space first."), formatSize(needFreeSize))
-		go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+		ev := newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+		ev.ErrType = dbusError.ErrType.String()
+		go m.notify(ev)
 		logger.Warning(dbusError.Error())
 		errStr, _ := json.Marshal(dbusError)
 		m.statusManager.SetUpdateStatus(mode, system.IsDownloading)
@@ -241,7 +251,7 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 		msg := gettext.Tr("New version available! The download of the update package will begin shortly")
 		if totalNeedDownloadSize > 0 {
 			// 只有下载大小大于0时才发送通知
-			go m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
+			go m.notify(newUpdateEvent(notify.EventDownloading, mode, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate))
 		}
 		m.updatePlatform.PostProcessEventMessage(updateplatform.ProcessEvent{
 			TaskID:       1,
@@ -278,10 +288,10 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 						gettext.Tr("View"),
 					}
 					if m.config.IntranetUpdate {
-						go m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
+						go m.notify(newUpdateEvent(notify.EventDownloading, mode, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate))
 					} else {
 						hints := map[string]dbus.Variant{"x-deepin-action-view": dbus.MakeVariant(actionShowUpdateModule)}
-						go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						go m.notify(newUpdateEvent(notify.EventDownloading, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault))
 					}
 				})
 			}
@@ -338,7 +348,9 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 								needFreeSize = needFreeSize - float64(spaceNum)
 								if needFreeSize > 0 {
 									msg := fmt.Sprintf(gettext.Tr("Downloading updates failed. Please free up %s disk space first."), formatSize(needFreeSize))
-									go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+									ev := newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+									ev.ErrType = errorContent.ErrType.String()
+									go m.notify(ev)
 								}
 							}
 						}
@@ -348,13 +360,17 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 						msg := gettext.Tr("Updates failed: damaged files. Please update again.")
 						action := []string{"retry", gettext.Tr("Try Again")}
 						hints := map[string]dbus.Variant{"x-deepin-action-retry": dbus.MakeVariant(actionShowUpdateModule)}
-						go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						ev := newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						ev.ErrType = errorContent.ErrType.String()
+						go m.notify(ev)
 					} else if strings.Contains(errorContent.ErrType.String(), system.ErrorFetchFailed.String()) {
 						// 网络原因下载更新失败
 						msg := gettext.Tr("Downloading updates failed. Please check your network.")
 						action := []string{"view", gettext.Tr("View")}
 						hints := map[string]dbus.Variant{"x-deepin-action-view": dbus.MakeVariant("dde-control-center,-m,network")}
-						go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						ev := newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						ev.ErrType = errorContent.ErrType.String()
+						go m.notify(ev)
 					}
 				}
 
@@ -414,8 +430,11 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 					go func() {
 						m.inhibitAutoQuitCountAdd()
 						defer m.inhibitAutoQuitCountSub()
+						// 立即更新时不发桌面气泡,但其他通知后端仍需收到下载完成事件
+						downloadedEvent := notify.Event{Type: notify.EventDownloaded, UpdateType: mode.JobType()}
 						if !m.updatePlatform.UpdateNowForce {
 							msg := gettext.Tr("Downloading completed. You can install updates when shutdown or reboot.")
+							downloadedEvent.Body = msg
 							action := []string{
 								"updateNow",
 								gettext.Tr("Update Now"),
@@ -429,18 +448,19 @@ func (m *Manager) prepareDistUpgrade(sender dbus.Sender, origin system.UpdateTyp
 										m.updateTime = timeStr
 									}
 									msg = fmt.Sprintf(gettext.Tr("Downloading completed. The computer will be updated at %s"), m.updateTime)
-									go m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
+									downloadedEvent = newUpdateEvent(notify.EventDownloaded, mode, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
 								}
 								if m.updatePlatform.Tp == updateplatform.UpdateShutdown {
-									m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
+									downloadedEvent = newUpdateEvent(notify.EventDownloaded, mode, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
 								}
 							} else {
 								hints := map[string]dbus.Variant{"x-deepin-action-updateNow": dbus.MakeVariant(actionShowUpdateModule)}
 
-								m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+								downloadedEvent = newUpdateEvent(notify.EventDownloaded, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
 							}
 
 						}
+						m.notify(downloadedEvent)
 						m.reportLog(downloadStatusReport, true, "")
 					}()
 
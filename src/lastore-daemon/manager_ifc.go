@@ -12,8 +12,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/godbus/dbus/v5"
 	agent "github.com/linuxdeepin/go-dbus-factory/session/org.deepin.dde.lastore1.agent"
@@ -193,7 +195,7 @@ func (m *Manager) PackagesSize(sender dbus.Sender, packages []string) (int64, *d
 		}
 	} else {
 		// 查询包(可能不止一个)的大小,即使当前开启的仓库没有包含该包,依旧返回该包的大小
-		_, allPackageSize, err = system.QueryPackageDownloadSize(system.AllInstallUpdate, packages...)
+		_, allPackageSize, err = system.QueryPackageDownloadSize(system.AllInstallUpdateMode(), packages...)
 	}
 	if err != nil || allPackageSize == system.SizeUnknown {
 		logger.Warningf("PackagesDownloadSize(%q)=%0.2f %v\n", strings.Join(packages, " "), allPackageSize, err)
@@ -618,8 +620,18 @@ func (m *Manager) SetUpdateSources(sender dbus.Sender, updateType system.UpdateT
 			logger.Warning("custom repo config is invalid")
 			return dbusutil.ToError(errors.New("custom repo config is invalid"))
 		}
+		// apt 根据扩展名判断仓库格式,deb822 格式需要使用 .sources 文件
+		format := system.DetectSourceFormat(strings.Join(repoConfig, "\n"))
+		pattern := "custom_repo_*.list"
+		if format == system.SourceFormatDeb822 {
+			pattern = "custom_repo_*.sources"
+		}
+		if _, err := system.ParseSources(strings.Join(repoConfig, "\n"), format); err != nil {
+			logger.Warning(err)
+			return dbusutil.ToError(fmt.Errorf("repo format error:%v", err))
+		}
 		// 使用apt-get check 检查仓库时候合规
-		tmpFile, err := os.CreateTemp("/tmp", "custom_repo_*")
+		tmpFile, err := os.CreateTemp("/tmp", pattern)
 		if err != nil {
 			logger.Warning(err)
 		} else {
@@ -639,50 +651,153 @@ func (m *Manager) SetUpdateSources(sender dbus.Sender, updateType system.UpdateT
 			}
 		}
 	}
+	err = m.trackSourceChange(m.senderAuthor(sender), "SetUpdateSources", func() error {
+		return m.setUpdateSources(updateType, repoType, repoConfig, isReset)
+	})
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.reloadOemConfig(false)
+	return nil
+}
+
+// setUpdateSources 修改系统或安全仓库的配置,仓库文件在下一次检查更新时才会重新生成
+func (m *Manager) setUpdateSources(updateType system.UpdateType, repoType config.RepoType, repoConfig []string, isReset bool) error {
 	// 判断是系统或安全仓库，分别设置配置
 	switch updateType {
 	case system.SystemUpdate:
 		err := m.config.SetSystemRepoType(repoType)
 		if err != nil {
 			logger.Warning(err)
-			return dbusutil.ToError(err)
+			return err
 		}
 		if repoType == config.CustomRepo {
 			if isReset {
 				err = m.config.SetSystemCustomSource(repoConfig)
 			} else {
-				err = m.config.SetSystemCustomSource(append(m.config.SystemCustomSource, repoConfig...))
+				// 追加的仓库与已有仓库格式不同时统一转换为 deb822 格式,避免两种格式写在同一个文件中
+				var merged []string
+				merged, err = system.MergeSources(m.config.SystemCustomSource, repoConfig)
+				if err == nil {
+					err = m.config.SetSystemCustomSource(merged)
+				}
 			}
 			if err != nil {
 				logger.Warning(err)
-				return dbusutil.ToError(err)
+				return err
 			}
 		}
 	case system.SecurityUpdate:
 		err := m.config.SetSecurityRepoType(repoType)
 		if err != nil {
 			logger.Warning(err)
-			return dbusutil.ToError(err)
+			return err
 		}
 		m.config.SecurityRepoType = repoType
 		if repoType == config.CustomRepo {
 			if isReset {
 				err = m.config.SetSecurityCustomSource(repoConfig)
 			} else {
-				err = m.config.SetSecurityCustomSource(append(m.config.SecurityCustomSource, repoConfig...))
+				// 追加的仓库与已有仓库格式不同时统一转换为 deb822 格式,避免两种格式写在同一个文件中
+				var merged []string
+				merged, err = system.MergeSources(m.config.SecurityCustomSource, repoConfig)
+				if err == nil {
+					err = m.config.SetSecurityCustomSource(merged)
+				}
 			}
 			if err != nil {
 				logger.Warning(err)
-				return dbusutil.ToError(err)
+				return err
 			}
 		}
 	default:
-		return dbusutil.ToError(fmt.Errorf("not supported update type: %v to set source", updateType))
+		return fmt.Errorf("not supported update type: %v to set source", updateType)
 	}
-	m.reloadOemConfig(false)
 	return nil
 }
 
+// ValidateSources 在应用仓库配置前检查仓库是否可用,lines 为 one-line 或 deb822 格式的仓库配置,
+// 为空时检查 updateType 当前使用的仓库。检查需要下载仓库索引,在后台进行,id 为本次检查的标识,
+// 完成后发送 SourcesValidated 信号,报告为 []system.SourceValidation 的 json 数据,
+// 包括仓库是否可访问、签名是否有效、Valid-Until 是否过期、架构和组件是否存在以及可用的包数量。
+// 每个返回了 id 的调用都会发送一次信号,调用方需要在调用前订阅信号并按 id 匹配,约定见 docs/仓库检查.md
+func (m *Manager) ValidateSources(sender dbus.Sender, updateType system.UpdateType, lines []string) (id string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	err := m.checkInvokePermission(sender)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	if updateType != system.SystemUpdate && updateType != system.SecurityUpdate {
+		return "", dbusutil.ToError(fmt.Errorf("not supported update type: %v to validate source", updateType))
+	}
+	sourcePath := system.GetCategorySourceMap()[updateType]
+	id = fmt.Sprintf("validate-sources-%d", atomic.AddUint32(&m.validateSourcesCount, 1))
+	m.inhibitAutoQuitCountAdd()
+	go func() {
+		defer m.inhibitAutoQuitCountSub()
+		var result []system.SourceValidation
+		var err error
+		if len(lines) > 0 {
+			result, err = system.ValidateSources(strings.Join(lines, "\n"))
+		} else {
+			result, err = validateSourcePath(sourcePath)
+		}
+		var report, errMsg string
+		if err == nil {
+			var data []byte
+			data, err = json.Marshal(result)
+			report = string(data)
+		}
+		if err != nil {
+			logger.Warning(err)
+			errMsg = err.Error()
+		}
+		if err := m.service.Emit(m, "SourcesValidated", id, report, errMsg); err != nil {
+			logger.Warning(err)
+		}
+	}()
+	return id, nil
+}
+
+// validateSourcePath 检查仓库文件或目录中的所有仓库文件
+func validateSourcePath(path string) ([]system.SourceValidation, error) {
+	var files []string
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if system.IsSourceFileName(e.Name()) {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	} else {
+		files = append(files, path)
+	}
+	result := []system.SourceValidation{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		items, err := system.ValidateSources(string(content))
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].File = file
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
 func (m *Manager) ConfirmRollback(sender dbus.Sender, confirm bool) *dbus.Error {
 	err := m.checkInvokePermission(sender)
 	if err != nil {
@@ -0,0 +1,202 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/linuxdeepin/lastore-daemon/src/internal/kernel"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/spaceplan"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// bootSpaceMargin 安装新内核需要的 /boot 空间按当前内核占用空间的倍数估算,
+// 新内核的 initramfs 通常与当前内核相当,留出余量避免 update-initramfs 失败
+const bootSpaceMargin = 1.5
+
+// kernelState 更新前的内核状态
+type kernelState struct {
+	running string
+	before  []kernel.Kernel
+	removed []string
+}
+
+// recordKernels 在安装更新前记录内核状态,获取内核信息失败时返回 nil,不影响更新
+func (m *Manager) recordKernels() *kernelState {
+	running, err := kernel.RunningVersion()
+	if err != nil {
+		logger.Warning(err)
+		return nil
+	}
+	kernels, err := kernel.ListKernels(kernel.DefaultBootDir)
+	if err != nil {
+		logger.Warning(err)
+		return nil
+	}
+	return &kernelState{
+		running: running,
+		before:  kernels,
+	}
+}
+
+// newKernelBootSize 估算安装一个新内核需要的 /boot 空间
+func newKernelBootSize(state *kernelState) uint64 {
+	if state == nil {
+		return 0
+	}
+	return uint64(float64(kernel.Footprint(kernel.DefaultBootDir, state.running)) * bootSpaceMargin)
+}
+
+// reclaimableBootSize 按保留策略清理旧内核后可以释放的 /boot 空间
+func (m *Manager) reclaimableBootSize(state *kernelState) uint64 {
+	if state == nil {
+		return 0
+	}
+	var size uint64
+	for _, k := range kernel.Removable(state.before, state.running, m.config.KeepKernels) {
+		size += kernel.Footprint(kernel.DefaultBootDir, k.Version)
+	}
+	return size
+}
+
+// newRemoveKernelsJob 安装 packages 中的新内核 /boot 空间不足时,创建按保留策略清理旧内核的 remove 任务,
+// 任务结束后开始 next;不需要清理时返回 nil
+func (m *Manager) newRemoveKernelsJob(packages []string, next *Job) (*Job, error) {
+	state := m.recordKernels()
+	if state == nil {
+		return nil, nil
+	}
+	removable := kernel.Removable(state.before, state.running, m.config.KeepKernels)
+	if len(removable) == 0 {
+		return nil, nil
+	}
+	report, err := m.planSpace(func(mounts []string) ([]spaceplan.Need, error) {
+		return installSpaceNeeds(packages, mounts, state, false)
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	boot := report.Lookup(bootMount())
+	if boot == nil || boot.Shortfall == 0 {
+		return nil, nil
+	}
+	var pkgs []string
+	for _, k := range removable {
+		pkgs = append(pkgs, k.Package)
+	}
+	isExist, job, err := m.jobManager.CreateJob("", system.RemoveJobType, pkgs, next.environ, nil)
+	if err != nil {
+		return nil, err
+	}
+	if isExist {
+		return nil, JobExistError
+	}
+	versions := kernel.Versions(removable)
+	logger.Info("remove old kernels before upgrade:", versions)
+	// 清理失败时不重试,由更新任务安装前的空间检查报告空间不足
+	job.retry = 0
+	job.next = next
+	job.setAfterHooks(map[string]func() error{
+		string(system.SucceedStatus): func() error {
+			m.removedKernelsMu.Lock()
+			m.removedKernels = append(m.removedKernels, versions...)
+			m.removedKernelsMu.Unlock()
+			return nil
+		},
+		string(system.FailedStatus): func() error {
+			logger.Warning("remove old kernels failed:", versions)
+			go func() {
+				// 结束失败的任务会断开与 next 的关联,需要单独开始 next
+				_ = m.cleanJob(job.Id)
+				m.do.Lock()
+				defer m.do.Unlock()
+				if err := m.jobManager.MarkStart(next.Id); err != nil {
+					logger.Warning(err)
+				}
+			}()
+			return nil
+		},
+	})
+	return job, nil
+}
+
+// takeRemovedKernels 返回并清空更新前清理的旧内核版本
+func (m *Manager) takeRemovedKernels() []string {
+	m.removedKernelsMu.Lock()
+	defer m.removedKernelsMu.Unlock()
+	removed := m.removedKernels
+	m.removedKernels = nil
+	return removed
+}
+
+// verifyKernels 在提示重启前确认新安装的内核和默认启动的内核可以启动,并将内核变化设置到 job 上
+func (m *Manager) verifyKernels(state *kernelState, jobs ...*Job) error {
+	if state == nil {
+		return nil
+	}
+	kernels, err := kernel.ListKernels(kernel.DefaultBootDir)
+	if err != nil {
+		logger.Warning(err)
+		return nil
+	}
+	change := kernel.Change{
+		Running: state.running,
+		Removed: state.removed,
+	}
+	if len(kernels) != 0 {
+		change.Default = kernels[0].Version
+	}
+	before := make(map[string]bool)
+	for _, k := range state.before {
+		before[k.Version] = true
+	}
+	var verify []string
+	for _, k := range kernels {
+		if !before[k.Version] {
+			change.Installed = append(change.Installed, k.Version)
+			verify = append(verify, k.Version)
+		}
+	}
+	if change.Default != "" && before[change.Default] && change.Default != state.running {
+		verify = append(verify, change.Default)
+	}
+	var failed []string
+	for _, version := range verify {
+		repaired, err := kernel.EnsureBootable(kernel.DefaultBootDir, kernel.DefaultGrubCfg, version)
+		if repaired {
+			change.Repaired = append(change.Repaired, version)
+		}
+		if err != nil {
+			logger.Warning(err)
+			failed = append(failed, err.Error())
+		}
+	}
+	m.setJobKernelInfo(change, jobs...)
+	if len(failed) != 0 {
+		return &system.JobError{
+			ErrType:   system.ErrorKernelNotBootable,
+			ErrDetail: strings.Join(failed, "; "),
+		}
+	}
+	return nil
+}
+
+func (m *Manager) setJobKernelInfo(change kernel.Change, jobs ...*Job) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	logger.Infof("kernel change after upgrade: %s", data)
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		job.PropsMu.Lock()
+		job.setPropKernelInfo(string(data))
+		job.PropsMu.Unlock()
+	}
+}
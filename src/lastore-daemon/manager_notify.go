@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/notify"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// desktopNotifyBackend 原有的桌面气泡通知,通过会话中的 lastore agent 发送
+type desktopNotifyBackend struct {
+	m *Manager
+}
+
+func (b *desktopNotifyBackend) Send(msg *notify.Message) error {
+	opts := msg.Desktop
+	// 没有桌面参数的事件只面向其他后端(如自动下载时的可用更新事件)
+	if opts == nil {
+		return nil
+	}
+	b.m.sendNotify(opts.AppName, 0, opts.AppIcon, msg.Title, msg.Text, opts.Actions, opts.Hints, opts.ExpireTimeout)
+	return nil
+}
+
+// initNotifyDispatcher 加载通知后端配置,未配置桌面后端时默认启用并接收所有事件
+func (m *Manager) initNotifyDispatcher() {
+	if m.notifyDispatcher == nil {
+		m.notifyDispatcher = notify.NewDispatcher()
+	} else {
+		m.notifyDispatcher.Reset()
+	}
+	hasDesktop := false
+	for _, cfg := range notify.LoadBackendConfigs(notify.ConfigDirPath) {
+		if cfg.Type == notify.DesktopBackend {
+			hasDesktop = true
+			m.notifyDispatcher.Register(cfg, &desktopNotifyBackend{m: m})
+			continue
+		}
+		backend, err := notify.NewBackend(cfg)
+		if err != nil {
+			logger.Warningf("skip notify backend %v: %v", cfg.Name, err)
+			continue
+		}
+		logger.Infof("notify backend %v(%v) enabled, events: %v", cfg.Name, cfg.Type, cfg.Events)
+		m.notifyDispatcher.Register(cfg, backend)
+	}
+	if !hasDesktop {
+		m.notifyDispatcher.Register(notify.BackendConfig{Name: "desktop", Type: notify.DesktopBackend}, &desktopNotifyBackend{m: m})
+	}
+}
+
+// notify 分发更新事件到所有通知后端,会阻塞到各后端发送完成,调用方按需放到 goroutine 中执行
+func (m *Manager) notify(ev notify.Event) {
+	if m.notifyDispatcher == nil {
+		// 未初始化时只保留桌面通知
+		if ev.Desktop != nil {
+			m.sendNotify(ev.Desktop.AppName, 0, ev.Desktop.AppIcon, ev.Summary, ev.Body, ev.Desktop.Actions, ev.Desktop.Hints, ev.Desktop.ExpireTimeout)
+		}
+		return
+	}
+	m.notifyDispatcher.Dispatch(ev)
+}
+
+// newUpdateEvent 构造带桌面气泡参数的更新事件,参数与 sendNotify 保持一致
+func newUpdateEvent(typ notify.EventType, mode system.UpdateType, appName, appIcon, summary, body string,
+	actions []string, hints map[string]dbus.Variant, expireTimeout int32) notify.Event {
+	ev := notify.Event{
+		Type:    typ,
+		Summary: summary,
+		Body:    body,
+		Desktop: &notify.DesktopOptions{
+			AppName:       appName,
+			AppIcon:       appIcon,
+			Actions:       actions,
+			Hints:         hints,
+			ExpireTimeout: expireTimeout,
+		},
+	}
+	if mode != 0 {
+		ev.UpdateType = mode.JobType()
+	}
+	return ev
+}
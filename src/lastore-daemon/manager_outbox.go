@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+// nmStateConnectedGlobal NetworkManager 的 NM_STATE_CONNECTED_GLOBAL 状态
+const nmStateConnectedGlobal = 70
+
+// handleNetworkStateChanged 网络恢复时立即发送上报队列中等待重试的记录
+func (m *Manager) handleNetworkStateChanged(state uint32) {
+	if state != nmStateConnectedGlobal || m.updatePlatform == nil {
+		return
+	}
+	logger.Info("network connected, wake update platform outbox")
+	go m.updatePlatform.WakeOutbox()
+}
+
+// GetUploadQueue 获取更新平台上报队列的状态,status 为 updateplatform.OutboxStatus 的 json 数据
+func (m *Manager) GetUploadQueue(sender dbus.Sender) (status string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(m.updatePlatform.GetOutboxStatus())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+// GetPlatformDataStatus 获取从更新平台获取的数据的来源和时效,status 为 updateplatform.PlatformDataStatus 的 json 数据,
+// 无法访问平台时使用的是最后一次有效的数据,Age 为距平台最近一次确认数据有效的秒数
+func (m *Manager) GetPlatformDataStatus(sender dbus.Sender) (status string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(m.updatePlatform.GetPlatformDataStatus())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// GetPlatformEndpoints 获取更新平台地址的状态,status 为 platformhttp.EndpointsStatus 的 json 数据,
+// Active 为当前使用的地址,Reason 为选择该地址的原因
+func (m *Manager) GetPlatformEndpoints(sender dbus.Sender) (status string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(m.updatePlatform.GetPlatformEndpoints())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/policypush"
+)
+
+// startPolicyPush 配置了 platform-push-url 时连接更新平台,收到通知后立即处理策略、限速和 ipfs 配置的变化。
+// 连接期间 lastore-daemon 不自动退出;连接不可用时由 checkpolicy 定时轮询
+func (m *Manager) startPolicyPush() {
+	if m.updatePlatform == nil {
+		return
+	}
+	channel := m.updatePlatform.NewPolicyPushChannel(map[string]func(){
+		policypush.EventPolicy:     m.handlePolicyPushed,
+		policypush.EventThrottling: m.handleThrottlingPushed,
+		policypush.EventIPFSConfig: m.handleIPFSConfigPushed,
+	})
+	if channel == nil {
+		return
+	}
+	m.policyPush = channel
+	m.inhibitAutoQuitCountAdd()
+	channel.Start()
+}
+
+func (m *Manager) stopPolicyPush() {
+	if m.policyPush == nil {
+		return
+	}
+	m.policyPush.Stop()
+	m.inhibitAutoQuitCountSub()
+}
+
+// handlePolicyPushed 更新策略变化时,与 checkpolicy 发现策略变化相同,开始检查更新,检查任务中调用 GenUpdatePolicyByToken
+func (m *Manager) handlePolicyPushed() {
+	_, err := m.updateSource(dbus.Sender(m.service.Conn().Names()[0]))
+	if err != nil {
+		logger.Warning("check update for pushed policy failed:", err)
+	}
+}
+
+// handleThrottlingPushed 限速配置变化时,与检查更新时相同,只在内网更新时应用平台下发的限速
+func (m *Manager) handleThrottlingPushed() {
+	if !m.config.IntranetUpdate {
+		return
+	}
+	if err := m.refreshThrottlingFromPlatform(); err != nil {
+		logger.Warning("updatePlatform gen download speed limit failed", err)
+	}
+}
+
+// handleIPFSConfigPushed ipfs 配置变化时,与检查更新时相同,只在内网更新时应用
+func (m *Manager) handleIPFSConfigPushed() {
+	if !m.config.IntranetUpdate {
+		return
+	}
+	if err := m.updatePlatform.GenIpfsConfig(); err != nil {
+		logger.Warningf("failed to gen ipfs config: %v", err)
+	} else if err := m.updatePlatform.UpdateDeliverySpeedLimit(); err != nil {
+		logger.Warningf("failed to update delivery speed limit: %v", err)
+	}
+}
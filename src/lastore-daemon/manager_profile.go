@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/go-lib/utils"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+const reconcileJobName = "reconcile"
+
+// reconcilePlan 根据 profile.conf.d 中的软件包清单和 dpkg 状态计算收敛计划
+func (m *Manager) reconcilePlan() (*system.ReconcilePlan, error) {
+	installed, err := system.QueryInstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+	var arch string
+	if len(m.SystemArchitectures) > 0 {
+		arch = string(m.SystemArchitectures[0])
+	}
+	plan := system.ComputeReconcilePlan(system.LoadPackageProfiles(system.PackageProfileConfigDir), installed, arch)
+	return &plan, nil
+}
+
+// GetReconcilePlan 预览将系统收敛到本机软件包清单需要执行的操作,plan 为 system.ReconcilePlan 的 json 数据
+func (m *Manager) GetReconcilePlan(sender dbus.Sender) (plan string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	p, err := m.reconcilePlan()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// Reconcile 创建 reconcile 任务,安装和删除软件包使系统与本机软件包清单一致,禁止修改清单中的软件包保持当前版本。
+// plan 为本次执行的 system.ReconcilePlan 的 json 数据
+func (m *Manager) Reconcile(sender dbus.Sender) (job dbus.ObjectPath, plan string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "/", "", dbusutil.ToError(err)
+	}
+	p, err := m.reconcilePlan()
+	if err != nil {
+		return "/", "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "/", "", dbusutil.ToError(err)
+	}
+	logger.Infof("reconcile plan: %s", data)
+	if p.Empty() {
+		return "/", string(data), dbusutil.ToError(errors.New("system already matches package profiles"))
+	}
+
+	m.ensureUpdateSourceOnce()
+	environ, err := makeEnvironWithSender(m, sender)
+	if err != nil {
+		return "/", string(data), dbusutil.ToError(err)
+	}
+	jobObj, err := m.reconcile(p.Args(), environ)
+	if err != nil {
+		return "/", string(data), dbusutil.ToError(err)
+	}
+	return jobObj.getPath(), string(data), nil
+}
+
+func (m *Manager) reconcile(args []string, environ map[string]string) (*Job, error) {
+	return m.createCustomSourceJob(reconcileJobName, system.ReconcileJobType, args, environ, nil, nil)
+}
+
+// createCustomSourceJob 使用所有检查更新的仓库创建任务,jobType 的任务选项由 CreateJob 设置。
+// prev 不为空且还未结束时,任务串联在 prev 之后,prev 结束后才加入队列开始;hooks 为额外的状态切换前的 hook
+func (m *Manager) createCustomSourceJob(jobName, jobType string, args []string, environ map[string]string,
+	prev *Job, hooks map[string]func() error) (*Job, error) {
+	var job *Job
+	var isExist bool
+	var err error
+	err = system.CustomSourceWrapper(system.AllCheckUpdateMode(), func(path string, unref func()) error {
+		m.do.Lock()
+		defer m.do.Unlock()
+		isExist, job, err = m.jobManager.CreateJob(jobName, jobType, args, environ, nil)
+		if err != nil || isExist {
+			if unref != nil {
+				unref()
+			}
+			if isExist {
+				return JobExistError
+			}
+			return err
+		}
+		if utils.IsDir(path) {
+			job.option["Dir::Etc::SourceList"] = "/dev/null"
+			job.option["Dir::Etc::SourceParts"] = path
+		} else {
+			job.option["Dir::Etc::SourceList"] = path
+			job.option["Dir::Etc::SourceParts"] = "/dev/null"
+		}
+		job.setPreHooks(map[string]func() error{
+			string(system.EndStatus): func() error {
+				if unref != nil {
+					unref()
+				}
+				return nil
+			},
+		})
+		job.wrapPreHooks(hooks)
+		if prev != nil {
+			prev.PropsMu.Lock()
+			chained := prev.Status != system.EndStatus
+			if chained && prev.next != nil {
+				prev.PropsMu.Unlock()
+				if unref != nil {
+					unref()
+				}
+				return fmt.Errorf("job %v is already followed by job %v", prev.Id, prev.next.Id)
+			}
+			if chained {
+				// 由 dispatch 在 prev 结束后加入队列并开始
+				prev.next = job
+			}
+			prev.PropsMu.Unlock()
+			if chained {
+				return nil
+			}
+		}
+		if err = m.jobManager.addJob(job); err != nil {
+			if unref != nil {
+				unref()
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, JobExistError) {
+		logger.Warningf("%s %q error: %v", jobType, strings.Join(args, " "), err)
+		return nil, err
+	}
+	return job, nil
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+// 仓库公钥导入、替换或删除后,在下一次检查更新重新生成系统和安全仓库时才会设置到仓库的 Signed-By 中,
+// 保证检查、下载、安装过程中仓库配置的一致性
+
+// ImportRepoKey 导入 repoUrl 仓库的公钥,key 为二进制或 ascii armor 格式的公钥数据,
+// 公钥只对该仓库生效,keyring 为公钥文件路径
+func (m *Manager) ImportRepoKey(sender dbus.Sender, repoUrl string, key []byte) (keyring string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	k, err := system.ImportRepoKey(repoUrl, key)
+	if err != nil {
+		logger.Warning(err)
+		return "", dbusutil.ToError(err)
+	}
+	logger.Infof("import key for %s: %s", repoUrl, k.Path)
+	return k.Path, nil
+}
+
+// RotateRepoKey 使用新的公钥替换 repoUrl 仓库已有的公钥
+func (m *Manager) RotateRepoKey(sender dbus.Sender, repoUrl string, key []byte) *dbus.Error {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return dbusutil.ToError(err)
+	}
+	k, err := system.RotateRepoKey(repoUrl, key)
+	if err != nil {
+		logger.Warning(err)
+		return dbusutil.ToError(err)
+	}
+	logger.Infof("rotate key for %s: %s", repoUrl, k.Path)
+	return nil
+}
+
+// RemoveRepoKey 删除 repoUrl 仓库的公钥
+func (m *Manager) RemoveRepoKey(sender dbus.Sender, repoUrl string) *dbus.Error {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return dbusutil.ToError(err)
+	}
+	if err := system.RemoveRepoKey(repoUrl); err != nil {
+		logger.Warning(err)
+		return dbusutil.ToError(err)
+	}
+	logger.Infof("remove key for %s", repoUrl)
+	return nil
+}
+
+// ListRepoKeys 列出已导入的仓库公钥,keys 为 []system.RepoKeyring 的 json 数据,
+// ExpiringSoon 为 true 的公钥需要尽快替换
+func (m *Manager) ListRepoKeys(sender dbus.Sender) (keys string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	keyrings, err := system.ListRepoKeys()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(keyrings)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// checkSourceKeys 检查更新前确认系统和安全仓库指定的公钥可用,避免到 apt update 时才报未认证的错误
+func checkSourceKeys(updateType system.UpdateType) error {
+	var paths []string
+	for _, t := range []system.UpdateType{system.SystemUpdate, system.SecurityUpdate} {
+		if updateType&t != 0 {
+			paths = append(paths, system.GetCategorySourceMap()[t])
+		}
+	}
+	var fatal []string
+	for _, issue := range system.CheckSourceKeys(paths...) {
+		logger.Warning("source key check:", issue)
+		if issue.Fatal {
+			fatal = append(fatal, issue.String())
+		}
+	}
+	if len(fatal) > 0 {
+		return &system.JobError{
+			ErrType:   system.ErrorMissingRepoKey,
+			ErrDetail: strings.Join(fatal, "\n"),
+		}
+	}
+	return nil
+}
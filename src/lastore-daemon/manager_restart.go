@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/restartcheck"
+)
+
+const lastoreDaemonUnit = "lastore-daemon.service"
+
+// analyzeRestart 分析安装 packages 后需要重启系统,还是只需要重启使用了旧文件的服务
+func (m *Manager) analyzeRestart(packages []string) (*restartcheck.Result, error) {
+	return restartcheck.Analyze(restartcheck.Options{
+		ChangedPackages: packages,
+		CoreList:        m.coreList,
+		SelfUnit:        lastoreDaemonUnit,
+	})
+}
+
+// setJobRestartInfo 将重启分析结果设置到 job 上,前端可以据此跳过不必要的重启
+func (m *Manager) setJobRestartInfo(packages []string, jobs ...*Job) {
+	result, err := m.analyzeRestart(packages)
+	if err != nil {
+		logger.Warning("analyze restart failed:", err)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	logger.Infof("restart info after upgrade: %s", data)
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		job.PropsMu.Lock()
+		job.setPropRestartInfo(string(data))
+		job.PropsMu.Unlock()
+	}
+}
+
+// RestartAffectedServices 重启仍在使用已删除或被替换文件的系统服务,不能安全重启的服务需要重启系统后生效。
+// services 为已重启的服务
+func (m *Manager) RestartAffectedServices(sender dbus.Sender) (services []string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	result, err := m.analyzeRestart(nil)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	var failed []string
+	for _, unit := range result.Services {
+		_, err := m.systemd.RestartUnit(0, unit, "replace")
+		if err != nil {
+			logger.Warningf("restart %v failed: %v", unit, err)
+			failed = append(failed, unit)
+			continue
+		}
+		logger.Info("restart affected service:", unit)
+		services = append(services, unit)
+	}
+	if len(failed) != 0 {
+		return services, dbusutil.ToError(fmt.Errorf("failed to restart %v", strings.Join(failed, ",")))
+	}
+	return services, nil
+}
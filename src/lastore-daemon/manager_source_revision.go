@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/config"
+)
+
+const (
+	daemonSourceAuthor   = "lastore-daemon"
+	platformSourceAuthor = "update-platform"
+
+	sourceSettingSystemRepoType       = "SystemRepoType"
+	sourceSettingSecurityRepoType     = "SecurityRepoType"
+	sourceSettingSystemCustomSource   = "SystemCustomSource"
+	sourceSettingSecurityCustomSource = "SecurityCustomSource"
+)
+
+// sourceSettings 仓库相关的配置,检查更新时会根据这些配置重新生成系统和安全仓库,因此需要和仓库文件一起记录
+func (m *Manager) sourceSettings() map[string]string {
+	return map[string]string{
+		sourceSettingSystemRepoType:       string(m.config.SystemRepoType),
+		sourceSettingSecurityRepoType:     string(m.config.SecurityRepoType),
+		sourceSettingSystemCustomSource:   strings.Join(m.config.SystemCustomSource, "\n"),
+		sourceSettingSecurityCustomSource: strings.Join(m.config.SecurityCustomSource, "\n"),
+	}
+}
+
+func splitSourceSetting(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
+// restoreSourceSettings 恢复 sourceSettings 记录的配置,没有记录的配置保持不变
+func (m *Manager) restoreSourceSettings(settings map[string]string) error {
+	if v, ok := settings[sourceSettingSystemRepoType]; ok && v != string(m.config.SystemRepoType) {
+		if err := m.config.SetSystemRepoType(config.RepoType(v)); err != nil {
+			return err
+		}
+	}
+	if v, ok := settings[sourceSettingSecurityRepoType]; ok && v != string(m.config.SecurityRepoType) {
+		if err := m.config.SetSecurityRepoType(config.RepoType(v)); err != nil {
+			return err
+		}
+	}
+	if v, ok := settings[sourceSettingSystemCustomSource]; ok && v != strings.Join(m.config.SystemCustomSource, "\n") {
+		if err := m.config.SetSystemCustomSource(splitSourceSetting(v)); err != nil {
+			return err
+		}
+	}
+	if v, ok := settings[sourceSettingSecurityCustomSource]; ok && v != strings.Join(m.config.SecurityCustomSource, "\n") {
+		if err := m.config.SetSecurityCustomSource(splitSourceSetting(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackSourceChange 执行 change 并将其对仓库配置的修改记录为新的版本
+func (m *Manager) trackSourceChange(author, method string, change func() error) error {
+	if m.sourceRevisions == nil {
+		return change()
+	}
+	return m.sourceRevisions.Track(author, method, m.sourceSettings, change)
+}
+
+// currentSourceRevision 当前仓库配置的版本号,没有记录时为 0
+func (m *Manager) currentSourceRevision() int {
+	if m.sourceRevisions == nil {
+		return 0
+	}
+	r, err := m.sourceRevisions.Latest()
+	if err != nil || r == nil {
+		if err != nil {
+			logger.Warning(err)
+		}
+		return 0
+	}
+	return r.Rev
+}
+
+// senderAuthor 记录仓库修改者使用的调用者信息
+func (m *Manager) senderAuthor(sender dbus.Sender) string {
+	uid, err := m.service.GetConnUID(string(sender))
+	if err != nil {
+		return string(sender)
+	}
+	author := fmt.Sprintf("uid %d", uid)
+	pid, err := m.service.GetConnPID(string(sender))
+	if err == nil {
+		exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if err == nil {
+			author += " " + exe
+		}
+	}
+	return author
+}
+
+// ListSourceRevisions 列出仓库配置的修改记录,revisions 为 []system.SourceRevision 的 json 数据,
+// 包括版本号、修改时间、修改者、修改方式、与上一个版本的差异以及是否使用该版本检查更新成功
+func (m *Manager) ListSourceRevisions(sender dbus.Sender) (revisions string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	revs, err := m.sourceRevisions.List()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(revs)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// RevertSources 将仓库文件和仓库配置恢复为 rev 版本的内容,恢复操作本身也会记录为新的版本
+func (m *Manager) RevertSources(sender dbus.Sender, rev uint32) *dbus.Error {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return dbusutil.ToError(err)
+	}
+	err := m.revertSources(m.senderAuthor(sender), int(rev))
+	if err != nil {
+		logger.Warning(err)
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
+
+func (m *Manager) revertSources(author string, rev int) error {
+	r, err := m.sourceRevisions.Get(rev)
+	if err != nil {
+		return err
+	}
+	if r.Snapshot == nil {
+		return fmt.Errorf("source revision %v has no snapshot", rev)
+	}
+	err = m.trackSourceChange(author, fmt.Sprintf("RevertSources(%d)", rev), func() error {
+		if err := m.sourceRevisions.Restore(r.Snapshot); err != nil {
+			return err
+		}
+		return m.restoreSourceSettings(r.Snapshot.Settings)
+	})
+	// 修改仓库后，重置可安装状态
+	if err := m.config.UpdateLastoreDaemonStatus(config.CanUpgrade, false); err != nil {
+		logger.Warning(err)
+	}
+	m.reloadOemConfig(false)
+	if err == nil {
+		logger.Infof("revert sources to revision %v by %v", rev, author)
+	}
+	return err
+}
+
+// autoRevertSources 使用 rev 版本检查更新时仓库配置无效,回退到 rev 之前最近一个检查更新成功的版本
+func (m *Manager) autoRevertSources(rev int) {
+	r, err := m.sourceRevisions.Get(rev)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	// 该版本曾经检查更新成功,说明不是仓库配置修改导致的错误
+	if r.Good {
+		return
+	}
+	good, err := m.sourceRevisions.LastGood(rev)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if good == nil {
+		logger.Warningf("source revision %v is invalid, but no good revision to revert", rev)
+		return
+	}
+	logger.Warningf("source revision %v is invalid, revert to revision %v", rev, good.Rev)
+	err = m.revertSources(daemonSourceAuthor, good.Rev)
+	if err != nil {
+		logger.Warning("auto revert sources failed:", err)
+	}
+}
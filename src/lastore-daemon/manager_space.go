@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/kernel"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/spaceplan"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+)
+
+const (
+	spaceReasonInstall  = "install"
+	spaceReasonArchives = "archives"
+	spaceReasonBackup   = "backup"
+
+	// 新安装的软件包没有文件列表,文件默认计入 /usr
+	defaultInstallDir = "/usr"
+	// 不可变系统的备份保存在 /sysroot 所在的分区
+	backupDir = "/sysroot"
+)
+
+func trimArch(pkg string) string {
+	if i := strings.IndexAny(pkg, ":="); i >= 0 {
+		return pkg[:i]
+	}
+	return pkg
+}
+
+// installSpaceNeeds 估算安装 packages 需要的空间:已安装的包按现有文件的分布计算新旧版本的大小差异,
+// 新安装的包计入 /usr,新内核额外需要 /boot 空间;needBackup 时被替换的旧版本文件计入备份分区
+func installSpaceNeeds(packages []string, mounts []string, kernels *kernelState, needBackup bool) ([]spaceplan.Need, error) {
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, trimArch(pkg))
+	}
+	candidate, err := system.QueryCandidateInstalledSizes(names...)
+	if err != nil {
+		return nil, err
+	}
+	current, err := system.QueryCurrentInstalledSizes(names...)
+	if err != nil {
+		return nil, err
+	}
+	var needs []spaceplan.Need
+	var backupSize uint64
+	for _, pkg := range names {
+		newSize, ok := candidate[pkg]
+		if !ok {
+			continue
+		}
+		oldSize, installed := current[pkg]
+		if installed {
+			backupSize += oldSize
+		}
+		if newSize <= oldSize {
+			continue
+		}
+		delta := newSize - oldSize
+		if installed {
+			shares := spaceplan.PackageShares(spaceplan.DefaultDpkgInfo, pkg, mounts)
+			needs = append(needs, spaceplan.Spread(delta, shares, defaultInstallDir, spaceReasonInstall)...)
+			continue
+		}
+		if kernel.IsKernelPackage(pkg) {
+			needs = append(needs, spaceplan.Need{Path: kernel.DefaultBootDir, Size: newKernelBootSize(kernels), Reason: spaceReasonInstall})
+		}
+		needs = append(needs, spaceplan.Need{Path: defaultInstallDir, Size: delta, Reason: spaceReasonInstall})
+	}
+	if needBackup {
+		needs = append(needs, spaceplan.Need{Path: backupDir, Size: backupSize, Reason: spaceReasonBackup})
+	}
+	return needs, nil
+}
+
+// archivesDir lastore 的下载缓存目录
+func archivesDir() string {
+	dir, err := system.GetArchivesDir(system.LastoreAptV2CommonConfPath)
+	if err != nil {
+		logger.Warning(err)
+		return system.LocalCachePath
+	}
+	return dir
+}
+
+// archivesSpaceNeeds 下载 downloadSize 字节需要的下载缓存空间
+func archivesSpaceNeeds(downloadSize uint64) []spaceplan.Need {
+	return []spaceplan.Need{{Path: archivesDir(), Size: downloadSize, Reason: spaceReasonArchives}}
+}
+
+// planSpace 按挂载点汇总 needsFn 返回的需求,autoFix 时空间不足会先自动修复再重新规划
+func (m *Manager) planSpace(needsFn func(mounts []string) ([]spaceplan.Need, error), autoFix bool) (*spaceplan.Report, error) {
+	mounts, err := spaceplan.ReadMountPoints(spaceplan.DefaultMountInfo)
+	if err != nil {
+		return nil, err
+	}
+	needs, err := needsFn(mounts)
+	if err != nil {
+		return nil, err
+	}
+	report, err := spaceplan.Plan(needs, mounts, spaceplan.FreeSpace)
+	if err != nil {
+		return nil, err
+	}
+	if !autoFix || len(report.Shortfalls()) == 0 {
+		return report, nil
+	}
+	fixes := m.fixSpace(report, mounts)
+	if len(fixes) == 0 {
+		return report, nil
+	}
+	report, err = spaceplan.Plan(needs, mounts, spaceplan.FreeSpace)
+	if err != nil {
+		return nil, err
+	}
+	report.Fixes = fixes
+	return report, nil
+}
+
+// fixSpace 针对空间不足的挂载点执行自动修复:清理可删除的下载缓存。/boot 的空间由更新前的清理旧内核任务释放
+func (m *Manager) fixSpace(report *spaceplan.Report, mounts []string) []string {
+	var fixes []string
+	short := make(map[string]bool)
+	for _, mp := range report.Shortfalls() {
+		short[mp.Mount] = true
+	}
+	if short[spaceplan.MountPoint(archivesDir(), mounts)] {
+		// lastore-apt-clean 只删除已安装或过期的 deb 包,不影响待安装的包
+		out, err := exec.Command("/usr/bin/lastore-apt-clean").CombinedOutput()
+		if err != nil {
+			logger.Warningf("clean archives failed: %v: %s", err, out)
+		} else {
+			fixes = append(fixes, "clean archives")
+		}
+	}
+	if len(fixes) != 0 {
+		logger.Info("fix insufficient space:", fixes)
+	}
+	return fixes
+}
+
+func insufficientSpaceError(report *spaceplan.Report) *system.JobError {
+	return &system.JobError{
+		ErrType:      system.ErrorInsufficientSpace,
+		ErrDetail:    fmt.Sprintf("There is not enough space on the disk to upgrade: %v", report),
+		IsCheckError: true,
+	}
+}
+
+// checkUpgradeSpace 安装或备份前按挂载点检查空间,仍然不足时返回 ErrorInsufficientSpace。无法规划时使用 apt 估算的总大小检查 /usr。
+// autoFix 时空间不足会先自动修复;否则只规划和报告,/boot 的缺口可以由更新前清理旧内核补足时不视为不足
+func (m *Manager) checkUpgradeSpace(mode system.UpdateType, packages []string, needBackup bool, kernels *kernelState, autoFix bool) error {
+	report, err := m.planSpace(func(mounts []string) ([]spaceplan.Need, error) {
+		return installSpaceNeeds(packages, mounts, kernels, needBackup)
+	}, autoFix)
+	if err != nil {
+		logger.Warning("plan upgrade space failed:", err)
+		if !system.CheckInstallAddSize(mode) {
+			return &system.JobError{
+				ErrType:      system.ErrorInsufficientSpace,
+				ErrDetail:    "There is not enough space on the disk to upgrade",
+				IsCheckError: true,
+			}
+		}
+		return nil
+	}
+	data, _ := json.Marshal(report)
+	logger.Infof("upgrade space plan: %s", data)
+	shortfalls := report.Shortfalls()
+	if !autoFix && len(shortfalls) == 1 && shortfalls[0].Mount == bootMount() &&
+		shortfalls[0].Shortfall <= m.reclaimableBootSize(kernels) {
+		logger.Infof("%v is short of %v, old kernels will be removed before upgrade", shortfalls[0].Mount,
+			spaceplan.FormatSize(shortfalls[0].Shortfall))
+		return nil
+	}
+	if len(shortfalls) != 0 {
+		return insufficientSpaceError(report)
+	}
+	return nil
+}
+
+// bootMount 返回 /boot 所在的挂载点
+func bootMount() string {
+	mounts, err := spaceplan.ReadMountPoints(spaceplan.DefaultMountInfo)
+	if err != nil {
+		logger.Warning(err)
+		return ""
+	}
+	return spaceplan.MountPoint(kernel.DefaultBootDir, mounts)
+}
+
+// checkDownloadSpace 下载前检查下载缓存所在挂载点的空间,不足时先清理可删除的下载缓存,
+// 返回仍然需要释放的空间,无法规划时返回错误
+func (m *Manager) checkDownloadSpace(downloadSize uint64) (uint64, error) {
+	report, err := m.planSpace(func(mounts []string) ([]spaceplan.Need, error) {
+		return archivesSpaceNeeds(downloadSize), nil
+	}, true)
+	if err != nil {
+		return 0, err
+	}
+	var shortfall uint64
+	for _, mp := range report.Shortfalls() {
+		shortfall += mp.Shortfall
+	}
+	if shortfall != 0 {
+		logger.Warning("insufficient space to download:", report)
+	}
+	return shortfall, nil
+}
+
+// GetSpacePlan 预览下载和安装 updateType 类型的更新时各挂载点的空间需求和缺口,
+// plan 为 spaceplan.Report 的 json 数据,不会执行自动修复
+func (m *Manager) GetSpacePlan(sender dbus.Sender, updateType system.UpdateType) (plan string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	packages, updatablePkgsMap := m.updater.getUpdatablePackagesWithClassification(updateType)
+	downloadSize, _ := calculateTotalDownloadSize(updateType, updatablePkgsMap)
+	kernels := m.recordKernels()
+	report, err := m.planSpace(func(mounts []string) ([]spaceplan.Need, error) {
+		needs, err := installSpaceNeeds(packages, mounts, kernels, false)
+		if err != nil {
+			return nil, err
+		}
+		return append(needs, archivesSpaceNeeds(uint64(downloadSize))...), nil
+	}, false)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
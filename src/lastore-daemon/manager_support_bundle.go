@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/supportbundle"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
+)
+
+// supportBundleState 诊断包中 lastore-daemon 的状态
+type supportBundleState struct {
+	UpdateMode            system.UpdateType
+	CheckUpdateMode       system.UpdateType
+	UpdateStatus          json.RawMessage // 每一个更新项的状态
+	UpgradeStatus         system.UpgradeStatusAndReason
+	SystemOnChanging      bool
+	ImmutableAutoRecovery bool
+	UpgradableApps        []string
+	LastCheckTime         time.Time
+	PolicyPush            interface{} `json:",omitempty"` // 平台推送长连接的状态
+}
+
+// CollectSupportBundle 收集分析现场问题需要的日志、配置和状态,脱敏后保存为 tar.gz 文件,file 为文件路径。
+// 文件保存在 /var/cache/lastore/support-bundle 中,只有 root 可以读取,目录中只保留最近的 3 个
+func (m *Manager) CollectSupportBundle(sender dbus.Sender) (file string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	m.inhibitAutoQuitCountAdd()
+	defer m.inhibitAutoQuitCountSub()
+	file, err := m.collectSupportBundle()
+	if err != nil {
+		logger.Warning("collect support bundle failed:", err)
+		return "", dbusutil.ToError(err)
+	}
+	logger.Info("support bundle saved to", file)
+	return file, nil
+}
+
+func (m *Manager) collectSupportBundle() (string, error) {
+	file := filepath.Join(supportbundle.DefaultDir, supportbundle.FileName(time.Now()))
+	b, err := supportbundle.Create(file, "lastore-daemon", updateplatform.SupportBundleRedactor(m.config))
+	if err != nil {
+		return "", err
+	}
+	supportbundle.CollectSystem(b)
+	updateplatform.CollectSupportBundleConfig(b, m.config)
+	m.updatePlatform.CollectSupportBundle(b)
+	b.AddJSON("state/jobs.json", "JobList", m.supportBundleJobs())
+	b.AddJSON("state/status.json", "Manager", m.supportBundleState())
+	if err := b.Close(); err != nil {
+		return "", err
+	}
+	if err := supportbundle.Prune(supportbundle.DefaultDir, supportbundle.DefaultKeep); err != nil {
+		logger.Warning(err)
+	}
+	return file, nil
+}
+
+// supportBundleJobs 返回当前所有任务的状态,格式与退出时保存的任务相同
+func (m *Manager) supportBundleJobs() []*JobContent {
+	m.PropsMu.RLock()
+	jobList := m.jobList
+	m.PropsMu.RUnlock()
+
+	jobs := make([]*JobContent, 0, len(jobList))
+	for _, job := range jobList {
+		job.PropsMu.RLock()
+		jobs = append(jobs, &JobContent{
+			Id:           job.Id,
+			Name:         job.Name,
+			Packages:     job.Packages,
+			CreateTime:   job.CreateTime,
+			DownloadSize: job.DownloadSize,
+			Type:         job.Type,
+			Status:       job.Status,
+			Progress:     job.Progress,
+			Description:  job.Description,
+			Environ:      job.environ,
+			QueueName:    job.queueName,
+			HaveNext:     job.next != nil,
+		})
+		job.PropsMu.RUnlock()
+	}
+	return jobs
+}
+
+func (m *Manager) supportBundleState() supportBundleState {
+	m.PropsMu.RLock()
+	state := supportBundleState{
+		UpdateMode:            m.UpdateMode,
+		CheckUpdateMode:       m.CheckUpdateMode,
+		SystemOnChanging:      m.SystemOnChanging,
+		ImmutableAutoRecovery: m.ImmutableAutoRecovery,
+		UpgradableApps:        m.UpgradableApps,
+	}
+	updateStatus := m.UpdateStatus
+	m.PropsMu.RUnlock()
+	if json.Valid([]byte(updateStatus)) {
+		state.UpdateStatus = json.RawMessage(updateStatus)
+	}
+	state.UpgradeStatus = m.config.UpgradeStatus
+	state.LastCheckTime = m.config.LastCheckTime
+	if m.policyPush != nil {
+		state.PolicyPush = m.policyPush.Status()
+	}
+	return state
+}
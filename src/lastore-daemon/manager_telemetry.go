@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
+)
+
+// PreviewTelemetry 预览按当前遥测级别上报给更新平台的设备信息,preview 为 updateplatform.TelemetryPreview 的 json 数据,
+// 包括每个字段是否上报、X-Repo-Token 的内容和携带设备信息的请求头
+func (m *Manager) PreviewTelemetry(sender dbus.Sender) (preview string, busErr *dbus.Error) {
+	m.service.DelayAutoQuit()
+	if err := m.checkInvokePermission(sender); err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(updateplatform.PreviewTelemetry(m.config))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/godbus/dbus/v5"
 	"github.com/linuxdeepin/go-lib/gettext"
 	"github.com/linuxdeepin/go-lib/utils"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/notify"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system/apt"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system/dut"
@@ -158,8 +159,8 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 	_ = os.Setenv("http_proxy", environ["http_proxy"])
 	_ = os.Setenv("https_proxy", environ["https_proxy"])
 	// 检查任务开始后,从更新平台获取仓库、更新注记等信息
-	// 从更新平台获取数据:系统更新和安全更新流程都包含
-	if err := m.updatePlatform.GenUpdatePolicyByToken(); err != nil {
+	// 从更新平台获取数据:系统更新和安全更新流程都包含,内网更新时会使用平台下发的仓库覆盖 sources.list
+	if err := m.trackSourceChange(platformSourceAuthor, "UpdateSourceList", m.updatePlatform.GenUpdatePolicyByToken); err != nil {
 		if m.config.PlatformUpdate {
 			return nil, &system.JobError{
 				ErrType:   system.ErrorPlatformUnreachable,
@@ -172,7 +173,9 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 
 	prepareUpdateSource()
 	m.reloadOemConfig(true)
-	m.updatePlatform.Token = updateplatform.UpdateTokenConfigFile(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper)
+	// 记录本次检查使用的仓库配置版本,检查成功后标记为可用,仓库配置无效时回退到之前可用的版本
+	sourceRev := m.currentSourceRevision()
+	m.updatePlatform.Token = updateplatform.UpdateTokenConfigFile(m.config.IncludeDiskInfo, m.config.GetHardwareIdByHelper, updateplatform.TelemetryPolicy(m.config))
 	m.jobManager.dispatch() // 解决 bug 59351问题（防止CreatJob获取到状态为end但是未被删除的job）
 	var job *Job
 	var isExist bool
@@ -180,7 +183,10 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 	if m.config.IntranetUpdate {
 		updateType = system.SystemUpdate //Intranet updates are limited to system updates only
 	} else {
-		updateType = system.AllCheckUpdate
+		updateType = system.AllCheckUpdateMode()
+	}
+	if err = checkSourceKeys(updateType); err != nil {
+		return nil, err
 	}
 	err = system.CustomSourceWrapper(updateType, func(path string, unref func()) error {
 		m.do.Lock()
@@ -225,11 +231,16 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 		job.setPreHooks(map[string]func() error{
 			string(system.RunningStatus): func() error {
 				// 检查更新需要重置备份状态,主要是处理备份失败后再检查更新,会直接显示失败的场景
-				m.statusManager.SetABStatus(system.AllCheckUpdate, system.NotBackup, system.NoABError)
+				m.statusManager.SetABStatus(system.AllCheckUpdateMode(), system.NotBackup, system.NoABError)
 				return nil
 			},
 			string(system.SucceedStatus): func() error {
 				job.setUpdatePolicy(m.updatePlatform.Tp)
+				if sourceRev > 0 {
+					if err := m.sourceRevisions.MarkGood(sourceRev); err != nil {
+						logger.Warning(err)
+					}
+				}
 				m.refreshUpdateInfos(true)
 				m.PropsMu.Lock()
 				m.updateSourceOnce = true
@@ -238,6 +249,8 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 					go m.reportLog(updateStatusReport, true, "")
 					// 开启自动下载时触发自动下载,发自动下载通知,不发送可更新通知;
 					// 关闭自动下载时,发可更新的通知;
+					// 桌面气泡之外的通知后端总是会收到可用更新事件
+					availableEvent := notify.Event{Type: notify.EventAvailable, Body: gettext.Tr("New version available!")}
 					if !m.updater.AutoDownloadUpdates {
 						// msg := gettext.Tr("New system edition available")
 						msg := gettext.Tr("New version available!")
@@ -247,13 +260,14 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 							hints = map[string]dbus.Variant{"x-deepin-action-view": dbus.MakeVariant(actionShowUpdateModule)}
 							if m.updatePlatform.Tp == updateplatform.NormalUpdate {
 								msg = gettext.Tr("New version available! Please go to control-center to check")
-								go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutPrivate)
+								availableEvent = newUpdateEvent(notify.EventAvailable, 0, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutPrivate)
 							}
 						} else {
 							hints = map[string]dbus.Variant{"x-deepin-action-view": dbus.MakeVariant(actionShowUpdateModule)}
-							go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+							availableEvent = newUpdateEvent(notify.EventAvailable, 0, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
 						}
 					}
+					go m.notify(availableEvent)
 				} else {
 					go m.reportLog(updateStatusReport, false, "")
 				}
@@ -303,15 +317,22 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 				var errorContent system.JobError
 				err = json.Unmarshal([]byte(job.Description), &errorContent)
 				if err == nil {
+					if strings.Contains(errorContent.ErrType.String(), system.ErrorInvalidSourcesList.String()) && sourceRev > 0 {
+						go m.autoRevertSources(sourceRev)
+					}
 					if strings.Contains(errorContent.ErrType.String(), system.ErrorFetchFailed.String()) || strings.Contains(errorContent.ErrType.String(), system.ErrorIndexDownloadFailed.String()) {
 						msg := gettext.Tr("Failed to check for updates. Please check your network.")
 						action := []string{"view", gettext.Tr("View")}
 						hints := map[string]dbus.Variant{"x-deepin-action-view": dbus.MakeVariant("dde-control-center,-m,network")}
-						go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						ev := newUpdateEvent(notify.EventFailed, 0, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+						ev.ErrType = errorContent.ErrType.String()
+						go m.notify(ev)
 					}
 					if strings.Contains(errorContent.ErrType.String(), system.ErrorInsufficientSpace.String()) {
 						msg := gettext.Tr("Failed to check for updates. Please clean up your disk first.")
-						go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+						ev := newUpdateEvent(notify.EventFailed, 0, updateNotifyShowOptional, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+						ev.ErrType = errorContent.ErrType.String()
+						go m.notify(ev)
 					}
 				}
 				// 发通知 end
@@ -379,24 +400,31 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 				useP2PUpdate := m.updater.P2PUpdateSupport && m.updater.P2PUpdateEnable
 				if m.config.PlatformUpdate {
 					// 从更新平台同步仓库源配置和InRelease，若启用P2P则替换为delivery协议
-					m.updatePlatform.SyncRepoAndInRelease(useP2PUpdate)
+					_ = m.trackSourceChange(platformSourceAuthor, "SyncRepoAndInRelease", func() error {
+						m.updatePlatform.SyncRepoAndInRelease(useP2PUpdate)
+						return nil
+					})
 				} else if useP2PUpdate {
 					// 公网并且启用P2P时，将系统更新和安全更新的APT源的http(s)协议统一替换为delivery协议
 					repos := m.updatePlatform.GetPlatformRepoSources()
-					if err := system.UpdateP2pDefaultSourceDir(system.SystemUpdate, repos); err != nil {
-						logger.Warning(err)
-					}
-					if err := system.UpdateP2pDefaultSourceDir(system.SecurityUpdate, repos); err != nil {
-						logger.Warning(err)
-					}
+					_ = m.trackSourceChange(daemonSourceAuthor, "UpdateP2pDefaultSourceDir", func() error {
+						if err := system.UpdateP2pDefaultSourceDir(system.SystemUpdate, repos); err != nil {
+							logger.Warning(err)
+						}
+						if err := system.UpdateP2pDefaultSourceDir(system.SecurityUpdate, repos); err != nil {
+							logger.Warning(err)
+						}
+						return nil
+					})
 				}
+				sourceRev = m.currentSourceRevision()
 
 				if updateplatform.IsForceUpdate(m.updatePlatform.Tp) && m.updatePlatform.Tp != updateplatform.UpdateRegularly {
 					m.stopTimerUnit(lastoreRegularlyUpdate)
 				}
 				if m.updatePlatform.TimerHasChanged {
 					msg := gettext.Tr("timer has changed. Please reboot to take effect")
-					go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate)
+					go m.notify(newUpdateEvent(notify.EventScheduled, 0, updateNotifyShowOptional, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivate))
 				}
 
 				if m.config.IntranetUpdate {
@@ -424,7 +452,14 @@ func (m *Manager) updateSource(sender dbus.Sender) (*Job, error) {
 						return nil
 					}
 				}
-				m.updatePlatform.PrepareCheckScripts()
+				if err := m.updatePlatform.PrepareCheckScripts(); err != nil {
+					logger.Warning(err)
+					job.retry = 0
+					return &system.JobError{
+						ErrType:   system.ErrorUntrustedCheckScript,
+						ErrDetail: err.Error(),
+					}
+				}
 				m.updater.setPropUpdateTarget(m.updatePlatform.GetUpdateTarget()) // 更新目标 历史版本控制中心获取UpdateTarget,获取更新日志
 
 				checkType := dut.PreUpdateCheck
@@ -579,7 +614,7 @@ func (m *Manager) generateUpdateInfo() (errList []error) {
 	}
 
 	var wg sync.WaitGroup
-	for updateType, getFn := range getUpgradablePackageList {
+	for updateType, getFn := range upgradablePackageListFuncs() {
 		wg.Add(1)
 		fn := getFn
 		t := updateType
@@ -654,6 +689,24 @@ var getUpgradablePackageList = map[system.UpdateType]func([]string) ([]string, e
 	system.UnknownUpdate:  getUnknownUpgradablePackageList,
 }
 
+// upgradablePackageListFuncs 在内置分类的基础上追加需要显示更新内容的管理员定义分类
+func upgradablePackageListFuncs() map[system.UpdateType]func([]string) ([]string, error) {
+	res := make(map[system.UpdateType]func([]string) ([]string, error))
+	for t, fn := range getUpgradablePackageList {
+		res[t] = fn
+	}
+	for _, c := range system.UpdateCategories() {
+		if !c.ShowUpdates {
+			continue
+		}
+		sourceDir := c.SourceDir()
+		res[c.Type()] = func(coreList []string) ([]string, error) {
+			return apt.ListDistUpgradePackages(sourceDir, nil)
+		}
+	}
+	return res
+}
+
 func getSystemUpgradablePackageList(coreList []string) ([]string, error) {
 	return apt.ListDistUpgradePackages(system.GetCategorySourceMap()[system.SystemUpdate], coreList)
 }
@@ -840,7 +893,7 @@ func (m *Manager) refreshUpdateInfos(sync bool) {
 			if timeStr != m.updateTime {
 				m.updateTime = timeStr
 				msg := fmt.Sprintf(gettext.Tr("The computer will be updated at %s"), m.updateTime)
-				go m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault)
+				go m.notify(newUpdateEvent(notify.EventScheduled, 0, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutDefault))
 			}
 		}
 		if m.updatePlatform.Tp == updateplatform.UpdateRegularly && len(m.updater.UpdatablePackages) > 0 {
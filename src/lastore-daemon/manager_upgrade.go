@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/notify"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system/dut"
 
 	"github.com/linuxdeepin/lastore-daemon/src/internal/system"
@@ -136,12 +137,14 @@ func (m *Manager) distUpgradePartly(sender dbus.Sender, origin system.UpdateType
 			inhibitHeld = false
 		}
 	}
+	var backupJob *Job
 	// 开始更新job
 	startUpgrade := func() error {
 		m.inhibitAutoQuitCountSub()
 		m.do.Lock()
 		defer m.do.Unlock()
 		msg := fmt.Sprintf("start install package, update mode: %v", mode)
+		m.updatePlatform.ResetBaselineDrift()
 		m.updatePlatform.PostStatusMessage(updateplatform.StatusMessage{
 			Type:       "info",
 			UpdateType: mode.JobType(),
@@ -153,6 +156,18 @@ func (m *Manager) distUpgradePartly(sender dbus.Sender, origin system.UpdateType
 			EventStatus:  true,
 			EventContent: msg,
 		})
+		// 新内核的 /boot 空间不足时,先清理旧内核再开始更新
+		removeJob, err := m.newRemoveKernelsJob(m.updater.getUpdatablePackagesByType(mode), upgradeJob)
+		if err != nil {
+			logger.Warning(err)
+		}
+		if removeJob != nil {
+			if backupJob != nil {
+				backupJob.next = removeJob
+				return nil
+			}
+			return m.jobManager.addJob(removeJob)
+		}
 		return m.jobManager.MarkStart(upgradeJob.Id)
 	}
 
@@ -188,7 +203,6 @@ func (m *Manager) distUpgradePartly(sender dbus.Sender, origin system.UpdateType
 	}
 	m.inhibitAutoQuitCountAdd() // 开始备份前add，结束备份后sub(无论是否成功)
 	var isExist bool
-	var backupJob *Job
 	if needBackup && system.NormalFileExists(system.DeepinImmutableCtlPath) {
 		isExist, backupJob, err = m.jobManager.CreateJob("", system.BackupJobType, nil, nil, nil)
 		if isExist {
@@ -214,7 +228,7 @@ func (m *Manager) distUpgradePartly(sender dbus.Sender, origin system.UpdateType
 				m.statusManager.SetUpdateStatus(mode, system.WaitRunUpgrade)
 				if m.config.IntranetUpdate {
 					msg := gettext.Tr("Start to update. Please do not shutdown")
-					go m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutNoHide)
+					go m.notify(newUpdateEvent(notify.EventInstalling, mode, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutNoHide))
 				}
 
 				m.updatePlatform.PostProcessEventMessage(updateplatform.ProcessEvent{
@@ -243,8 +257,8 @@ func (m *Manager) distUpgradePartly(sender dbus.Sender, origin system.UpdateType
 						EventContent: fmt.Sprintf("%v success", checkType),
 					})
 				}
-
-				return nil
+				// 备份前只规划和报告备份和安装需要的空间,不清理旧内核,失败时用户可以选择不备份继续更新
+				return m.checkUpgradeSpace(mode, m.updater.getUpdatablePackagesByType(mode), true, m.recordKernels(), false)
 			},
 			string(system.SucceedStatus): func() error {
 				m.statusManager.SetABStatus(mode, system.HasBackedUp, system.NoABError)
@@ -298,7 +312,7 @@ func (m *Manager) distUpgradePartly(sender dbus.Sender, origin system.UpdateType
 					// Continue dist upgrade without backup
 					"x-deepin-action-continue": dbus.MakeVariant(
 						buildDistUpgradePartlyCommand(mode, false))}
-				go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+				go m.notify(newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault))
 
 				checkType := dut.PostBackupCheck
 				if systemErr := dut.CheckSystem(checkType, nil, m.jobManager.handleJobProgressInfo); systemErr != nil {
@@ -454,6 +468,7 @@ func (m *Manager) distUpgrade(sender dbus.Sender, mode system.UpdateType,
 		// 设置hook
 		// TODO 目前最多两个job关联,先这样写,后续规划抽象每个更新类型做处理.
 		var endJob *Job
+		var kernels *kernelState
 		startJob := job
 		if job.next != nil {
 			endJob = job.next
@@ -516,12 +531,14 @@ func (m *Manager) distUpgrade(sender dbus.Sender, mode system.UpdateType,
 						EventContent: fmt.Sprintf("%v success", checkType),
 					})
 				}
-				if !system.CheckInstallAddSize(mode) {
-					return &system.JobError{
-						ErrType:      system.ErrorInsufficientSpace,
-						ErrDetail:    "There is not enough space on the disk to upgrade",
-						IsCheckError: true,
-					}
+				// 记录更新前的内核,按挂载点检查空间,不足时清理下载缓存
+				removed := m.takeRemovedKernels()
+				kernels = m.recordKernels()
+				if kernels != nil {
+					kernels.removed = removed
+				}
+				if err := m.checkUpgradeSpace(mode, packages, false, kernels, true); err != nil {
+					return err
 				}
 				m.preRunningHook(needChangeGrub, mode)
 				return nil
@@ -569,6 +586,12 @@ func (m *Manager) distUpgrade(sender dbus.Sender, mode system.UpdateType,
 				if mode&system.SecurityUpdate != 0 {
 					recordUpgradeLog(uuid, system.SecurityUpdate, m.updatePlatform.GetCVEUpdateLogs(m.updater.getUpdatablePackagesByType(system.SecurityUpdate)), upgradeRecordPath)
 				}
+				// 分析本次更新是否必须重启,结果设置在 job 上,供前端决定是否跳过重启
+				m.setJobRestartInfo(packages, job, endJob)
+				// 提示重启前确认新内核的 initramfs 和 GRUB 启动项已生成
+				if err := m.verifyKernels(kernels, job, endJob); err != nil {
+					return err
+				}
 				return m.preUpgradeCmdSuccessHook(job, mode, uuid, refreshFullMerge)
 			},
 			string(system.FailedStatus): func() error {
@@ -685,6 +708,8 @@ func (m *Manager) preRunningHook(needChangeGrub bool, mode system.UpdateType) {
 }
 
 func (m *Manager) preFailedHook(job *Job, mode system.UpdateType, uuid string) error {
+	// 失败时可能已安装了部分软件包,之后上报的状态需要重新计算基线偏离
+	m.updatePlatform.ResetBaselineDrift()
 	// 状态更新为failed
 	var errorContent system.JobError
 	err := json.Unmarshal([]byte(job.Description), &errorContent)
@@ -696,6 +721,10 @@ func (m *Manager) preFailedHook(job *Job, mode system.UpdateType, uuid string) e
 		}
 	} else {
 		errType := errorContent.ErrType.String()
+		failedEvent := func(ev notify.Event) notify.Event {
+			ev.ErrType = errType
+			return ev
+		}
 		err = m.config.SetUpgradeStatusAndReason(system.UpgradeStatusAndReason{Status: system.UpgradeFailed, ReasonCode: system.JobErrorType(errType)})
 		if err != nil {
 			logger.Warning(err)
@@ -706,10 +735,10 @@ func (m *Manager) preFailedHook(job *Job, mode system.UpdateType, uuid string) e
 			msg := gettext.Tr("Updates failed: damaged files. Please update again.")
 			action := []string{"retry", gettext.Tr("Try Again")}
 			if m.config.IntranetUpdate {
-				go m.sendNotify(updateNotifyShow, 0, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivateLong)
+				go m.notify(failedEvent(newUpdateEvent(notify.EventFailed, mode, updateNotifyShow, "preferences-system", "", msg, nil, nil, system.NotifyExpireTimeoutPrivateLong)))
 			} else {
 				hints := map[string]dbus.Variant{"x-deepin-action-retry": dbus.MakeVariant(actionShowUpdateModule)}
-				go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+				go m.notify(failedEvent(newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)))
 			}
 		} else if strings.Contains(errType, system.ErrorInsufficientSpace.String()) {
 			// 空间不足
@@ -726,9 +755,9 @@ func (m *Manager) preFailedHook(job *Job, mode system.UpdateType, uuid string) e
 				hints = nil
 			}
 			if m.config.IntranetUpdate {
-				go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutPrivateLong)
+				go m.notify(failedEvent(newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutPrivateLong)))
 			} else {
-				go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+				go m.notify(failedEvent(newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)))
 			}
 		} else {
 			// 其他原因
@@ -745,9 +774,9 @@ func (m *Manager) preFailedHook(job *Job, mode system.UpdateType, uuid string) e
 				hints = nil
 			}
 			if m.config.IntranetUpdate {
-				go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutPrivateLong)
+				go m.notify(failedEvent(newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutPrivateLong)))
 			} else {
-				go m.sendNotify(updateNotifyShowOptional, 0, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)
+				go m.notify(failedEvent(newUpdateEvent(notify.EventFailed, mode, updateNotifyShowOptional, "preferences-system", "", msg, action, hints, system.NotifyExpireTimeoutDefault)))
 			}
 		}
 	}
@@ -801,6 +830,8 @@ func (m *Manager) preFailedHook(job *Job, mode system.UpdateType, uuid string) e
 }
 
 func (m *Manager) preUpgradeCmdSuccessHook(job *Job, mode system.UpdateType, uuid string, refreshFullMerge bool) error {
+	// 开始更新时计算的基线偏离已过期,安装成功后上报的状态需要重新计算
+	m.updatePlatform.ResetBaselineDrift()
 	if !m.config.GetPlatformStatusDisable(config.DisabledRebootCheck) {
 		// 设置重启后的检查项;重启后需要检查时,需要将本次job的uuid记录到检查配置中,无需检查时只要将uuid直接记录到 upgradeJobMetaInfo 中即可
 		err := m.setRebootCheckOption(mode, uuid)
@@ -846,7 +877,10 @@ func (m *Manager) afterUpgradeCmdSuccessHook() error {
 	hints := map[string]dbus.Variant{
 		"x-deepin-action-reboot":      dbus.MakeVariant("dbus-send,--session,--print-reply,--dest=org.deepin.dde.ShutdownFront1,/org/deepin/dde/ShutdownFront1,org.deepin.dde.ShutdownFront1.Restart"),
 		"x-deepin-NoAnimationActions": dbus.MakeVariant("reboot")}
-	go m.sendNotify(updateNotifyShow, 0, "system-updated", summary, msg, action, hints, system.NotifyExpireTimeoutNoHide)
+	go func() {
+		m.notify(notify.Event{Type: notify.EventInstalled, Summary: summary})
+		m.notify(newUpdateEvent(notify.EventRebootRequired, 0, updateNotifyShow, "system-updated", summary, msg, action, hints, system.NotifyExpireTimeoutNoHide))
+	}()
 	return nil
 }
 
@@ -927,7 +961,7 @@ func (m *Manager) prepareAptCheck(mode system.UpdateType) (string, error) {
 	}
 	// 使用dut检查前的准备
 	{
-		mode &= system.AllInstallUpdate
+		mode &= system.AllInstallUpdateMode()
 		if mode == 0 {
 			return "", errors.New("invalid mode")
 		}
@@ -977,6 +1011,11 @@ func getPackagesPathList(typ system.UpdateType, listPath string) []string {
 	if typ&system.UnknownUpdate != 0 {
 		urls = append(urls, getUpgradeUrls(system.GetCategorySourceMap()[system.UnknownUpdate])...)
 	}
+	for _, c := range system.UpdateCategories() {
+		if typ&c.Type() != 0 {
+			urls = append(urls, getUpgradeUrls(c.SourceDir())...)
+		}
+	}
 	for _, repoUrl := range urls {
 		prefixMap[strings.ReplaceAll(utils.URIToPath(repoUrl), "/", "_")] = struct{}{}
 	}
@@ -75,7 +75,7 @@ func (m *UpdateModeStatusManager) InitModifyData() {
 		m.checkModeChangedCallback(m.checkMode)
 	}
 	obj := &daemonStatus{
-		TriggerBackingUpType: system.AllInstallUpdate,
+		TriggerBackingUpType: system.AllInstallUpdateMode(),
 		ABStatus:             system.NotBackup,
 		ABError:              system.NoABError,
 		UpdateStatus:         make(map[string]system.UpdateModeStatus),
@@ -88,7 +88,7 @@ func (m *UpdateModeStatusManager) InitModifyData() {
 		for _, typ := range system.AllInstallUpdateType() {
 			m.updateModeStatusObj[typ.JobType()] = system.NotDownload
 		}
-		m.currentTriggerBackingUpType = system.AllInstallUpdate
+		m.currentTriggerBackingUpType = system.AllInstallUpdateMode()
 		m.abStatus = system.NotBackup
 		m.abError = system.NoABError
 		m.syncUpdateStatusNoLock()
@@ -108,7 +108,7 @@ func (m *UpdateModeStatusManager) InitModifyData() {
 					m.updateModeStatusObj[key] = system.NoUpdate
 				}
 			}
-			m.currentTriggerBackingUpType = system.AllInstallUpdate
+			m.currentTriggerBackingUpType = system.AllInstallUpdateMode()
 			m.abStatus = system.NotBackup
 			m.abError = system.NoABError
 			err := m.lsConfig.UpdateLastoreDaemonStatus(config.CanUpgrade, false)
@@ -392,7 +392,7 @@ func (m *UpdateModeStatusManager) SetCheckMode(mode system.UpdateType) system.Up
 
 // UpdateModeAllStatusBySize 根据size计算更新所有状态,会把除了安装失败之外的所有错误去除
 func (m *UpdateModeStatusManager) UpdateModeAllStatusBySize(coreList []string) {
-	m.updateModeStatusBySize(system.AllInstallUpdate, coreList)
+	m.updateModeStatusBySize(system.AllInstallUpdateMode(), coreList)
 }
 
 // 单项计算
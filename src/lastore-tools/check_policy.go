@@ -17,6 +17,7 @@ import (
 	"time"
 
 	. "github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/policypush"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
 
 	"github.com/codegangsta/cli"
@@ -29,25 +30,35 @@ var CMDCheckPolicy = cli.Command{
 	Action: MainCheckPolicy,
 }
 
-func genVersionResponse(c *Config) (*http.Response, error) {
+// genVersionResponse 获取更新策略,etag 和 lastModified 为上次响应的 ETag 和 Last-Modified,策略没有变化时平台返回 304
+func genVersionResponse(c *Config, etag, lastModified string) (*http.Response, error) {
 	url := c.PlatformUrl
 	policyUrl := url + "/api/v1/version"
-	client := &http.Client{
-		Timeout: 4 * time.Second,
-	}
+	client := updateplatform.NewPlatformTransport(c).Client(4 * time.Second)
 	request, err := http.NewRequest("GET", policyUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", "/api/v1/version", err.Error())
 	}
-	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(updateplatform.UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper))))
+	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(updateplatform.UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper, updateplatform.TelemetryPolicy(c)))))
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		request.Header.Set("If-Modified-Since", lastModified)
+	}
 	return client.Do(request)
 }
 
 // MainCheckPolicy 检查更新策略，策略变化拉起lastore-daemon处理
 func MainCheckPolicy(c *cli.Context) error {
 	config := NewConfig(path.Join("/var/lib/lastore", "config.json"))
+	// lastore-daemon 与更新平台保持推送连接时,策略变化由平台推送,不需要轮询
+	if status, err := policypush.ReadStatus(policypush.DefaultStatusFile); err == nil && status.Active(time.Now()) {
+		logger.Debug("policy push channel is active, skip polling:", status.URL)
+		return nil
+	}
 	cacheFile := "/tmp/checkpolicy.cache"
-	var oldSum string
+	var oldSum, etag, lastModified string
 	oldTime := time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
 	nowTime := time.Now()
 	// 获取缓存的md5码
@@ -71,11 +82,22 @@ func MainCheckPolicy(c *cli.Context) error {
 			}
 			str = string(data)
 			oldTime, _ = time.Parse(time.RFC3339, str)
+			// 旧版本的缓存文件没有 ETag 和 Last-Modified
+			data, _, err = reader.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			etag = string(data)
+			data, _, err = reader.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			lastModified = string(data)
 			break
 		}
 	}
 	logger.Debug("Check old time:", oldTime)
-	response, err := genVersionResponse(config)
+	response, err := genVersionResponse(config, etag, lastModified)
 	if err != nil {
 		logger.Warning(err)
 		return err
@@ -83,6 +105,10 @@ func MainCheckPolicy(c *cli.Context) error {
 	defer func() {
 		_ = response.Body.Close()
 	}()
+	if response.StatusCode == http.StatusNotModified {
+		logger.Debug("update policy not modified")
+		return nil
+	}
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		logger.Warning(err)
@@ -101,6 +127,10 @@ func MainCheckPolicy(c *cli.Context) error {
 				_, _ = writeFile.WriteString("\n")
 				_, _ = writeFile.WriteString(nowTime.Format(time.RFC3339))
 				_, _ = writeFile.WriteString("\n")
+				_, _ = writeFile.WriteString(response.Header.Get("ETag"))
+				_, _ = writeFile.WriteString("\n")
+				_, _ = writeFile.WriteString(response.Header.Get("Last-Modified"))
+				_, _ = writeFile.WriteString("\n")
 			}
 			sysBus, err := dbus.SystemBus()
 			if err == nil {
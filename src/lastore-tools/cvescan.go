@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/vulnscan"
+)
+
+var CMDCVEScan = cli.Command{
+	Name:   "cvescan",
+	Usage:  `scan installed packages with an offline vulnerability feed (OSV or Debian security tracker json)`,
+	Action: MainCVEScan,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "feed,f",
+			Value: vulnscan.DefaultFeedPath,
+			Usage: "feed file or directory containing *.json feed files",
+		},
+		cli.StringFlag{
+			Name:  "release,r",
+			Value: "",
+			Usage: "release codename used by debian security tracker data, default is VERSION_CODENAME in /etc/os-release",
+		},
+		cli.StringFlag{
+			Name:  "ecosystem,e",
+			Value: "",
+			Usage: "only use OSV entries whose ecosystem has this prefix, e.g. Debian:12",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the report as json",
+		},
+	},
+}
+
+// MainCVEScan 处理 cvescan 子命令,不依赖 lastore-daemon 和更新平台
+func MainCVEScan(c *cli.Context) error {
+	report, err := vulnscan.ScanSystem(c.String("feed"), vulnscan.LoadOptions{
+		Release:   c.String("release"),
+		Ecosystem: c.String("ecosystem"),
+	})
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	fmt.Printf("scanned %d packages with %d advisories, %d findings\n",
+		report.Packages, report.Advisories, len(report.Findings))
+	if len(report.Findings) == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPACKAGE\tINSTALLED\tFIXED\tSEVERITY")
+	for _, f := range report.Findings {
+		fixed := f.FixedVersion
+		if fixed == "" {
+			fixed = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.ID, f.Package, f.InstalledVersion, fixed, f.Severity)
+	}
+	return w.Flush()
+}
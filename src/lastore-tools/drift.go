@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
+)
+
+var CMDDrift = cli.Command{
+	Name:   "drift",
+	Usage:  `compare installed packages with the package lists of the current baseline`,
+	Action: MainDrift,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the report as json",
+		},
+	},
+}
+
+// MainDrift 处理 drift 子命令,基线清单由 lastore-daemon 从更新平台获取,因此通过 dbus 获取偏离报告
+func MainDrift(c *cli.Context) error {
+	sysBus, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	var data string
+	err = sysBus.Object("org.deepin.dde.Lastore1", "/org/deepin/dde/Lastore1").Call(
+		"org.deepin.dde.Lastore1.Manager.GetBaselineDrift", 0).Store(&data)
+	if err != nil {
+		return err
+	}
+	var drift updateplatform.BaselineDrift
+	if err := json.Unmarshal([]byte(data), &drift); err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(drift)
+	}
+	fmt.Printf("baseline %s: %d missing, %d to purge, %d frozen drift, %d unknown origin\n", drift.Baseline,
+		len(drift.Missing), len(drift.Purge), len(drift.Frozen), len(drift.Unknown))
+	if drift.Empty() {
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tPACKAGE\tINSTALLED\tEXPECTED")
+	for _, group := range []struct {
+		kind  string
+		items []updateplatform.DriftItem
+	}{
+		{"missing", drift.Missing},
+		{"purge", drift.Purge},
+		{"frozen", drift.Frozen},
+		{"unknown", drift.Unknown},
+	} {
+		for _, item := range group.items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", group.kind, item.Package, orDash(item.InstalledVersion), orDash(item.ExpectedVersion))
+		}
+	}
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/codegangsta/cli"
 	"github.com/jouyouyun/hardware/dmi"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
 	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
 )
 
@@ -256,43 +257,60 @@ func ParseSizeToBytes(sizeStr string) (int64, error) {
 func getWhetherGatherInfo(c *config.Config) (*http.Response, error) {
 	url := c.PlatformUrl
 	policyUrl := url + "/api/v1/terminal/info/check"
-	client := &http.Client{
-		Timeout: 4 * time.Second,
-	}
+	client := updateplatform.NewPlatformTransport(c).Client(4 * time.Second)
 	logger.Infof("%v", policyUrl)
 	request, err := http.NewRequest("GET", policyUrl, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%v new request failed: %v ", "/api/v1/terminal/info/check", err.Error())
 	}
-	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(updateplatform.UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper))))
+	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(updateplatform.UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper, updateplatform.TelemetryPolicy(c)))))
 	return client.Do(request)
 }
 
-func postHardwareInfo(c *config.Config) error {
-	// get S/N
-	var sn string
-	dmi, err := dmi.GetDMI()
-	if err != nil {
-		logger.Warning("cannot get dmi")
-	} else {
-		sn = dmi.BoardSerial
+// genHardwareInfo 生成上报的硬件信息,字段按遥测策略删除或哈希,没有需要上报的字段时返回 nil
+func genHardwareInfo(policy telemetry.Policy) (*SystemInfo, error) {
+	if !policy.Allowed(telemetry.FieldBoardSerial) && !policy.Allowed(telemetry.FieldDisk) && !policy.Allowed(telemetry.FieldMemory) {
+		return nil, nil
+	}
+	var systemInfo SystemInfo
+	if policy.Allowed(telemetry.FieldBoardSerial) {
+		// get S/N
+		dmi, err := dmi.GetDMI()
+		if err != nil {
+			logger.Warning("cannot get dmi")
+		} else {
+			systemInfo.SN = policy.Value(telemetry.FieldBoardSerial, dmi.BoardSerial)
+		}
 	}
 
-	// get disk info
-	diskInfos, err := getDiskSize()
-	if err != nil {
-		return fmt.Errorf("cannot get disk infos: %w", err)
+	if policy.Allowed(telemetry.FieldDisk) {
+		diskInfos, err := getDiskSize()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get disk infos: %w", err)
+		}
+		logger.Infof("disk info :%+v", diskInfos)
+		systemInfo.Disk = diskInfos
 	}
-	logger.Infof("disk info :%+v", diskInfos)
-	memoryInfos, err := getMemorySizeByDmi()
-	if err != nil {
-		return fmt.Errorf("cannot get memory infos: %w", err)
+
+	if policy.Allowed(telemetry.FieldMemory) {
+		memoryInfos, err := getMemorySizeByDmi()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get memory infos: %w", err)
+		}
+		systemInfo.Memory = memoryInfos
 	}
+	return &systemInfo, nil
+}
 
-	systemInfo := SystemInfo{
-		SN:     sn,
-		Disk:   diskInfos,
-		Memory: memoryInfos,
+func postHardwareInfo(c *config.Config) error {
+	policy := updateplatform.TelemetryPolicy(c)
+	systemInfo, err := genHardwareInfo(policy)
+	if err != nil {
+		return err
+	}
+	if systemInfo == nil {
+		logger.Infof("telemetry level is %v, skip posting hardware info", policy.Level)
+		return nil
 	}
 
 	jsonSystemInfo, err := json.Marshal(systemInfo)
@@ -302,14 +320,13 @@ func postHardwareInfo(c *config.Config) error {
 
 	url := c.PlatformUrl
 	policyUrl := url + "/api/v1/terminal/hardware"
-	client := &http.Client{
-		Timeout: 4 * time.Second,
-	}
+	client := updateplatform.NewPlatformTransport(c).Client(4 * time.Second)
 	request, err := http.NewRequest("POST", policyUrl, bytes.NewBuffer(jsonSystemInfo))
 	if err != nil {
 		return fmt.Errorf("%v new request failed: %v ", "/api/v1/terminal/hardware", err.Error())
 	}
-	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(updateplatform.UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper))))
+	request.Header.Set("X-Repo-Token", base64.RawStdEncoding.EncodeToString([]byte(updateplatform.UpdateTokenConfigFile(c.IncludeDiskInfo, c.GetHardwareIdByHelper, policy))))
+	updateplatform.RecordUpload(telemetry.NewUpload(telemetry.KindHardware, policy.Level, request, jsonSystemInfo))
 
 	resp, err := client.Do(request)
 	if err != nil {
@@ -340,6 +357,11 @@ func MainPostHardwareInfo(c *cli.Context) error {
 
 func MainGatherInfo(c *cli.Context) error {
 	config := config.NewConfig(path.Join("/var/lib/lastore", "config.json"))
+	// 收集的用户信息只在遥测级别为 full 时上报
+	if level := updateplatform.TelemetryPolicy(config).Level; level != telemetry.LevelFull {
+		logger.Infof("telemetry level is %v, skip gathering info", level)
+		return nil
+	}
 	response, err := getWhetherGatherInfo(config)
 	if err != nil {
 		return fmt.Errorf("get whether gather info failed: %w", err)
@@ -128,6 +128,11 @@ func main() {
 		CMDPostUpgrade,
 		CMDPostHardwareInfo,
 		CMDGatherInfo,
+		CMDCVEScan,
+		CMDDrift,
+		CMDPlatformRequests,
+		CMDTelemetry,
+		CMDSupportBundle,
 	}
 
 	err := app.Run(os.Args)
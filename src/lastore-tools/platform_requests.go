@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/platformhttp"
+)
+
+var CMDPlatformRequests = cli.Command{
+	Name:   "platform-requests",
+	Usage:  `show recent requests to the update platform`,
+	Action: MainPlatformRequests,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "count,n",
+			Value: 50,
+			Usage: "number of recent requests to show, 0 for all",
+		},
+		cli.StringFlag{
+			Name:  "file,f",
+			Value: platformhttp.DefaultTraceFile,
+			Usage: "the request trace file",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the requests as json",
+		},
+	},
+}
+
+// MainPlatformRequests 处理 platform-requests 子命令,打印 lastore-daemon 和 lastore-tools 记录的更新平台请求
+func MainPlatformRequests(c *cli.Context) error {
+	records, err := platformhttp.ReadTrace(c.String("file"), c.Int("count"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("no request recorded")
+			return nil
+		}
+		return err
+	}
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tMETHOD\tSTATUS\tDURATION\tATTEMPTS\tURL\tERROR")
+	for _, r := range records {
+		status := "-"
+		if r.Status != 0 {
+			status = strconv.Itoa(r.Status)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%dms\t%d\t%s\t%s\n", r.Time.Format("2006-01-02 15:04:05"), r.Method, status,
+			r.Duration, r.Attempts, r.URL, orDash(r.Error))
+	}
+	return w.Flush()
+}
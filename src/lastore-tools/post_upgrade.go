@@ -7,7 +7,6 @@ package main
 import (
 	"bufio"
 	"io"
-	"net/http"
 	"os"
 	"path"
 	"strings"
@@ -75,6 +74,7 @@ func postUpgrade(data string) error {
 	}
 	var retErr error
 	var errDatas []string
+	client := updateplatform.NewPlatformTransport(config).Client(4 * time.Second)
 	for _, data := range datas {
 		func() {
 			jsonstring, _ := base64.StdEncoding.DecodeString(data)
@@ -94,18 +94,16 @@ func postUpgrade(data string) error {
 				return
 			}
 			logger.Debug(postContent)
-			encryptMsg, err := updateplatform.EncryptMsg(content)
+
+			cipher, err := updateplatform.NewPayloadCipher(config, "")
 			if err != nil {
+				// 密钥不可用时保留数据,下次再上报
 				logger.Warning(err)
+				retErr = err
 				errDatas = append(errDatas, data)
 				return
 			}
-			base64EncodeString := base64.StdEncoding.EncodeToString(encryptMsg)
-
-			client := &http.Client{
-				Timeout: 4 * time.Second,
-			}
-			request, err := http.NewRequest("POST", url, strings.NewReader(base64EncodeString))
+			request, err := updateplatform.NewPayloadRequest(cipher, "POST", url, content)
 			if err != nil {
 				logger.Warning(err)
 				retErr = err
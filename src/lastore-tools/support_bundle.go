@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/supportbundle"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
+)
+
+// supportBundleTimeout 等待 lastore-daemon 收集诊断信息的时间
+const supportBundleTimeout = 5 * time.Minute
+
+var CMDSupportBundle = cli.Command{
+	Name:   "support-bundle",
+	Usage:  `collect redacted logs, config and update state into a local archive for troubleshooting, run as root`,
+	Action: MainSupportBundle,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "the archive file, lastore-support-<time>.tar.gz in current directory by default",
+		},
+		cli.BoolFlag{
+			Name:  "local",
+			Usage: "collect without lastore-daemon, job and update state are not included",
+		},
+	},
+}
+
+// MainSupportBundle 处理 support-bundle 子命令,优先由 lastore-daemon 收集,以包含任务和更新状态;
+// lastore-daemon 无法收集时在本地收集日志和配置
+func MainSupportBundle(c *cli.Context) error {
+	output := c.String("output")
+	if output == "" {
+		output = supportbundle.FileName(time.Now())
+	}
+	if !c.Bool("local") {
+		file, err := collectSupportBundleByDaemon()
+		if err == nil {
+			if err := copySupportBundle(file, output); err != nil {
+				return fmt.Errorf("support bundle saved to %s, but copy failed: %v", file, err)
+			}
+			fmt.Println("support bundle saved to", output)
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "lastore-daemon failed to collect support bundle: %v, collect locally\n", err)
+	}
+	manifest, err := collectSupportBundleLocally(output)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, e := range manifest.Entries {
+		if e.Error != "" {
+			failed++
+		}
+	}
+	fmt.Printf("support bundle saved to %s, %d files, %d failed, see %s\n", output, len(manifest.Entries)-failed, failed, supportbundle.ManifestName)
+	return nil
+}
+
+func collectSupportBundleByDaemon() (string, error) {
+	sysBus, err := dbus.SystemBus()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), supportBundleTimeout)
+	defer cancel()
+	var file string
+	err = sysBus.Object("org.deepin.dde.Lastore1", "/org/deepin/dde/Lastore1").CallWithContext(ctx,
+		"org.deepin.dde.Lastore1.Manager.CollectSupportBundle", 0).Store(&file)
+	return file, err
+}
+
+// copySupportBundle 复制 lastore-daemon 生成的诊断包,只有所有者可以读取
+func copySupportBundle(src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0600)
+}
+
+func collectSupportBundleLocally(output string) (supportbundle.Manifest, error) {
+	cfg := config.NewConfig(path.Join("/var/lib/lastore", "config.json"))
+	b, err := supportbundle.Create(output, "lastore-tools", updateplatform.SupportBundleRedactor(cfg))
+	if err != nil {
+		return supportbundle.Manifest{}, err
+	}
+	supportbundle.CollectSystem(b)
+	updateplatform.CollectSupportBundleConfig(b, cfg)
+	errNoDaemon := errors.New("collected without lastore-daemon")
+	b.AddError("state/jobs.json", "JobList", errNoDaemon)
+	b.AddError("state/status.json", "Manager", errNoDaemon)
+	manifest := b.Manifest()
+	return manifest, b.Close()
+}
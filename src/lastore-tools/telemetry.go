@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2026 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/config"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/telemetry"
+	"github.com/linuxdeepin/lastore-daemon/src/internal/updateplatform"
+)
+
+var CMDTelemetry = cli.Command{
+	Name:  "telemetry",
+	Usage: `preview and audit the device information sent to the update platform`,
+	Subcommands: []cli.Command{
+		{
+			Name:   "preview",
+			Usage:  "show the exact device information sent with the current telemetry level, run as root to include hardware info",
+			Action: MainTelemetryPreview,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the preview as json",
+				},
+			},
+		},
+		{
+			Name:   "audit",
+			Usage:  "show recent uploads to the update platform",
+			Action: MainTelemetryAudit,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "count,n",
+					Value: 50,
+					Usage: "number of recent uploads to show, 0 for all",
+				},
+				cli.StringFlag{
+					Name:  "file,f",
+					Value: telemetry.DefaultAuditFile,
+					Usage: "the upload audit file",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the uploads as json",
+				},
+			},
+		},
+	},
+}
+
+// MainTelemetryPreview 处理 telemetry preview 子命令,打印按当前遥测级别上报的 token、请求头和硬件信息
+func MainTelemetryPreview(c *cli.Context) error {
+	cfg := config.NewConfig(path.Join("/var/lib/lastore", "config.json"))
+	preview := updateplatform.PreviewTelemetry(cfg)
+	hardware, err := genHardwareInfo(updateplatform.TelemetryPolicy(cfg))
+	if err != nil {
+		return err
+	}
+	if hardware != nil {
+		preview.Hardware, err = json.Marshal(hardware)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(preview)
+	}
+
+	fmt.Printf("Level: %s\n\n", preview.Level)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tLEVEL\tSENT")
+	for _, f := range preview.Fields {
+		sent := "no"
+		if f.Sent {
+			sent = "yes"
+			if f.Action == telemetry.ActionHash {
+				sent = "sha256"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Name, f.Level, sent)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("Token:")
+	for _, item := range strings.Split(preview.Token, ";") {
+		fmt.Printf("  %s\n", item)
+	}
+	fmt.Println("Headers:")
+	var names []string
+	for name := range preview.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %s: %s\n", name, preview.Headers[name])
+	}
+	fmt.Printf("Hardware: %s\n", orDash(string(preview.Hardware)))
+	fmt.Printf("Audit file: %s\n", preview.AuditFile)
+	return nil
+}
+
+// MainTelemetryAudit 处理 telemetry audit 子命令,打印 lastore-daemon 和 lastore-tools 记录的上报
+func MainTelemetryAudit(c *cli.Context) error {
+	uploads, err := telemetry.ReadAudit(c.String("file"), c.Int("count"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("no upload recorded")
+			return nil
+		}
+		return err
+	}
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(uploads)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tKIND\tLEVEL\tTASK\tSIZE\tMACHINE-ID\tURL")
+	for _, u := range uploads {
+		task := "-"
+		if u.TaskID != 0 {
+			task = fmt.Sprint(u.TaskID)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", time.Unix(u.Time, 0).Format("2006-01-02 15:04:05"), u.Kind, u.Level,
+			task, u.Size, orDash(u.Headers["X-MachineID"]), u.URL)
+	}
+	return w.Flush()
+}